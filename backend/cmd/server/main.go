@@ -16,6 +16,8 @@ import (
 	"github.com/erc8004/policy-saas/internal/blockchain"
 	"github.com/erc8004/policy-saas/internal/config"
 	"github.com/erc8004/policy-saas/internal/domain/audit"
+	"github.com/erc8004/policy-saas/internal/domain/policy"
+	"github.com/erc8004/policy-saas/internal/domain/review"
 )
 
 func main() {
@@ -45,15 +47,37 @@ func main() {
 	svcCtx, svcCancel := context.WithCancel(bgCtx)
 	defer svcCancel()
 
-	// Start on-chain event indexer
+	// On-chain event indexer, started once its hooks are registered
 	chainClient := blockchain.NewClient(cfg, logger)
 	auditLogger := audit.NewLogger(db, logger)
 	indexer := blockchain.NewIndexer(chainClient, db, auditLogger, logger)
+
+	// Start scheduled access reviews
+	reviewJob := review.NewJob(db, auditLogger, logger, cfg.Jobs.AccessReviewInterval, cfg.Jobs.AccessReviewStaleDays)
+	reviewJob.Start(svcCtx)
+
+	// Expire break-glass escalations and revert their on-chain limits
+	multiClient := blockchain.NewMultiClient(cfg.Chains, cfg.Blockchain.ChainID, logger)
+	syncer := policy.NewOnchainSyncer(db, multiClient, logger)
+	escalationExpirer := policy.NewEscalationExpirer(db, syncer, auditLogger, logger, cfg.Jobs.EscalationExpiryInterval)
+	escalationExpirer.Start(svcCtx)
+
+	// Trip circuit breakers on on-chain violations and close them after cooldown
+	circuitBreaker := policy.NewCircuitBreaker(db, multiClient, auditLogger, logger, cfg.Jobs.CircuitCooldownInterval)
+	indexer.OnConstraintViolation(circuitBreaker.Check)
+	circuitBreaker.Start(svcCtx)
 	indexer.Start(svcCtx)
 
+	// Expire permissions past valid_until, warn ahead of expiry and retire rotated permissions
+	permissionSweeper := policy.NewPermissionSweeper(db, multiClient, auditLogger, logger, cfg.Jobs.PermissionSweepInterval)
+	permissionSweeper.Start(svcCtx)
+
 	// Create server
 	server := api.NewServer(cfg, db, logger)
 
+	// Run scheduled policy and permission operations
+	server.StartScheduler(svcCtx)
+
 	// Setup HTTP server
 	httpServer := &http.Server{
 		Addr:         ":" + cfg.Server.Port,
@@ -12,6 +12,7 @@ require (
 	github.com/joho/godotenv v1.5.1
 	github.com/rs/zerolog v1.33.0
 	github.com/spruceid/siwe-go v0.2.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
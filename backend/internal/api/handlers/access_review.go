@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+
+	"github.com/erc8004/policy-saas/internal/api/middleware"
+	"github.com/erc8004/policy-saas/internal/domain/audit"
+	"github.com/erc8004/policy-saas/internal/domain/review"
+)
+
+// maxBulkRevoke bounds how many permissions one bulk revoke may touch.
+const maxBulkRevoke = 500
+
+func (h *Handlers) GetAccessReview(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == uuid.Nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	staleDays := review.DefaultStaleDays
+	if d := r.URL.Query().Get("days"); d != "" {
+		n, err := strconv.Atoi(d)
+		if err != nil || n < 1 {
+			respondError(w, http.StatusBadRequest, "days must be a positive integer")
+			return
+		}
+		staleDays = n
+	}
+
+	report, err := review.Build(r.Context(), h.db, userID, staleDays)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("failed to build access review")
+		respondError(w, http.StatusInternalServerError, "failed to build access review")
+		return
+	}
+
+	if kind := r.URL.Query().Get("kind"); kind != "" {
+		var filtered []review.Finding
+		for _, f := range report.Findings {
+			if f.Kind == kind {
+				filtered = append(filtered, f)
+			}
+		}
+		if filtered == nil {
+			filtered = []review.Finding{}
+		}
+		report.Findings = filtered
+	}
+
+	respondJSON(w, http.StatusOK, report)
+}
+
+type BulkRevokeRequest struct {
+	PermissionIDs []uuid.UUID `json:"permission_ids"`
+}
+
+type BulkRevokeResult struct {
+	PermissionID uuid.UUID `json:"permission_id"`
+	Revoked      bool      `json:"revoked"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// BulkRevokePermissions revokes the selected permissions from a review, on-chain
+// where minted. Each permission is revoked independently; failures are reported
+// per item and do not stop the rest.
+func (h *Handlers) BulkRevokePermissions(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == uuid.Nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req BulkRevokeRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if len(req.PermissionIDs) == 0 {
+		respondError(w, http.StatusBadRequest, "permission_ids is required")
+		return
+	}
+	if len(req.PermissionIDs) > maxBulkRevoke {
+		respondError(w, http.StatusBadRequest, "too many permissions; maximum is "+strconv.Itoa(maxBulkRevoke))
+		return
+	}
+
+	results := make([]BulkRevokeResult, 0, len(req.PermissionIDs))
+	revoked := 0
+	for _, permID := range req.PermissionIDs {
+		result := BulkRevokeResult{PermissionID: permID}
+		if err := h.checkNotGroupGranted(r.Context(), permID); err != nil {
+			result.Error = err.Error()
+		} else if err := h.revokePermission(r.Context(), userID, permID); err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Revoked = true
+			revoked++
+		}
+		results = append(results, result)
+	}
+
+	h.auditLogger.Log(r.Context(), audit.Event{
+		WalletID:  userID,
+		EventType: "access_review.bulk_revoked",
+		Details: map[string]interface{}{
+			"requested": len(req.PermissionIDs),
+			"revoked":   revoked,
+		},
+	})
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"revoked": revoked,
+		"results": results,
+	})
+}
@@ -0,0 +1,432 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/erc8004/policy-saas/internal/api/middleware"
+	"github.com/erc8004/policy-saas/internal/domain/audit"
+	"github.com/erc8004/policy-saas/internal/domain/policy"
+)
+
+type AgentGroup struct {
+	ID          uuid.UUID     `json:"id"`
+	WalletID    uuid.UUID     `json:"wallet_id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Budget      policy.Budget `json:"budget"`
+	MatchTags   []string      `json:"match_tags"`
+	Members     []GroupMember `json:"members"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
+
+type GroupMember struct {
+	AgentID    uuid.UUID     `json:"agent_id"`
+	AgentName  string        `json:"agent_name"`
+	Allocation policy.Budget `json:"allocation"`
+	Source     string        `json:"source"`
+	AddedAt    time.Time     `json:"added_at"`
+}
+
+type CreateAgentGroupRequest struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Budget      policy.Budget `json:"budget"`
+	// MatchTags makes every active agent carrying one of the tags a member.
+	MatchTags []string `json:"match_tags,omitempty"`
+}
+
+type UpdateAgentGroupRequest struct {
+	Name        *string        `json:"name,omitempty"`
+	Description *string        `json:"description,omitempty"`
+	Budget      *policy.Budget `json:"budget,omitempty"`
+	MatchTags   *[]string      `json:"match_tags,omitempty"`
+}
+
+type SetGroupMemberRequest struct {
+	Allocation policy.Budget `json:"allocation"`
+}
+
+const agentGroupColumns = `id, wallet_id, name, COALESCE(description, ''), budget, match_tags, created_at, updated_at`
+
+func scanAgentGroup(row interface{ Scan(...any) error }, g *AgentGroup) error {
+	var budget []byte
+	if err := row.Scan(&g.ID, &g.WalletID, &g.Name, &g.Description, &budget, &g.MatchTags, &g.CreatedAt, &g.UpdatedAt); err != nil {
+		return err
+	}
+	json.Unmarshal(budget, &g.Budget)
+	g.Members = []GroupMember{}
+	return nil
+}
+
+// loadGroupMembers fills in the members of g.
+func (h *Handlers) loadGroupMembers(ctx context.Context, g *AgentGroup) error {
+	rows, err := h.db.Query(ctx,
+		`SELECT m.agent_id, a.name, m.allocation, m.source, m.added_at
+		 FROM agent_group_members m
+		 JOIN agents a ON a.id = m.agent_id
+		 WHERE m.group_id = $1
+		 ORDER BY m.added_at`,
+		g.ID,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m GroupMember
+		var allocation []byte
+		if err := rows.Scan(&m.AgentID, &m.AgentName, &allocation, &m.Source, &m.AddedAt); err != nil {
+			continue
+		}
+		json.Unmarshal(allocation, &m.Allocation)
+		g.Members = append(g.Members, m)
+	}
+	return rows.Err()
+}
+
+func (h *Handlers) CreateAgentGroup(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == uuid.Nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req CreateAgentGroupRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if req.Name == "" {
+		respondError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	if err := policy.ValidateBudget(&req.Budget); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	budget, _ := json.Marshal(req.Budget)
+	var g AgentGroup
+	err := scanAgentGroup(h.db.QueryRow(r.Context(),
+		`INSERT INTO agent_groups (wallet_id, name, description, budget, match_tags)
+		 VALUES ($1, $2, $3, $4, $5)
+		 RETURNING `+agentGroupColumns,
+		userID, req.Name, req.Description, budget, normalizeTags(req.MatchTags),
+	), &g)
+	if err != nil {
+		respondError(w, http.StatusConflict, "a group with this name already exists")
+		return
+	}
+
+	h.auditLogger.Log(r.Context(), audit.Event{
+		WalletID:  userID,
+		EventType: "agent_group.created",
+		Details:   map[string]interface{}{"group_id": g.ID, "name": g.Name, "budget": g.Budget, "match_tags": g.MatchTags},
+	})
+
+	if len(g.MatchTags) > 0 {
+		h.reconcileGroup(r.Context(), userID, g.ID)
+		h.loadGroupMembers(r.Context(), &g)
+	}
+
+	respondJSON(w, http.StatusCreated, g)
+}
+
+func (h *Handlers) ListAgentGroups(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == uuid.Nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	rows, err := h.db.Query(r.Context(),
+		`SELECT `+agentGroupColumns+` FROM agent_groups WHERE wallet_id = $1 ORDER BY name`,
+		userID,
+	)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list agent groups")
+		return
+	}
+
+	var groups []AgentGroup
+	for rows.Next() {
+		var g AgentGroup
+		if err := scanAgentGroup(rows, &g); err != nil {
+			continue
+		}
+		groups = append(groups, g)
+	}
+	rows.Close()
+
+	for i := range groups {
+		if err := h.loadGroupMembers(r.Context(), &groups[i]); err != nil {
+			respondError(w, http.StatusInternalServerError, "failed to load group members")
+			return
+		}
+	}
+
+	if groups == nil {
+		groups = []AgentGroup{}
+	}
+
+	respondJSON(w, http.StatusOK, groups)
+}
+
+func (h *Handlers) GetAgentGroup(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid group id")
+		return
+	}
+
+	var g AgentGroup
+	err = scanAgentGroup(h.db.QueryRow(r.Context(),
+		`SELECT `+agentGroupColumns+` FROM agent_groups WHERE id = $1 AND wallet_id = $2`,
+		groupID, userID,
+	), &g)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "agent group not found")
+		return
+	}
+	if err := h.loadGroupMembers(r.Context(), &g); err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to load group members")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, g)
+}
+
+func (h *Handlers) UpdateAgentGroup(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid group id")
+		return
+	}
+
+	var req UpdateAgentGroupRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	var budget []byte
+	if req.Budget != nil {
+		if err := policy.ValidateBudget(req.Budget); err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		budget, _ = json.Marshal(req.Budget)
+	}
+	var matchTags []string
+	if req.MatchTags != nil {
+		matchTags = normalizeTags(*req.MatchTags)
+	}
+
+	var g AgentGroup
+	err = scanAgentGroup(h.db.QueryRow(r.Context(),
+		`UPDATE agent_groups SET
+			name = COALESCE($1, name),
+			description = COALESCE($2, description),
+			budget = COALESCE($3, budget),
+			match_tags = COALESCE($6, match_tags),
+			updated_at = NOW()
+		 WHERE id = $4 AND wallet_id = $5
+		 RETURNING `+agentGroupColumns,
+		req.Name, req.Description, budget, groupID, userID, matchTags,
+	), &g)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "agent group not found")
+		return
+	}
+	if req.MatchTags != nil {
+		h.reconcileGroup(r.Context(), userID, g.ID)
+	}
+	if err := h.loadGroupMembers(r.Context(), &g); err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to load group members")
+		return
+	}
+
+	h.auditLogger.Log(r.Context(), audit.Event{
+		WalletID:  userID,
+		EventType: "agent_group.updated",
+		Details:   map[string]interface{}{"group_id": g.ID, "name": g.Name, "budget": g.Budget, "match_tags": g.MatchTags},
+	})
+	if req.Budget != nil {
+		h.revokeBundles(r.Context(), userID)
+	}
+
+	respondJSON(w, http.StatusOK, g)
+}
+
+func (h *Handlers) DeleteAgentGroup(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid group id")
+		return
+	}
+
+	// Revoke the members' permissions from the group's grants first
+	h.db.Exec(r.Context(),
+		`UPDATE group_grants SET status = 'revoked', revoked_at = NOW()
+		 WHERE group_id = $1 AND wallet_id = $2 AND status = 'active'`,
+		groupID, userID,
+	)
+	h.reconcileGroup(r.Context(), userID, groupID)
+
+	result, err := h.db.Exec(r.Context(),
+		`DELETE FROM agent_groups WHERE id = $1 AND wallet_id = $2`,
+		groupID, userID,
+	)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to delete agent group")
+		return
+	}
+
+	if result.RowsAffected() == 0 {
+		respondError(w, http.StatusNotFound, "agent group not found")
+		return
+	}
+
+	h.auditLogger.Log(r.Context(), audit.Event{
+		WalletID:  userID,
+		EventType: "agent_group.deleted",
+		Details:   map[string]interface{}{"group_id": groupID},
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetGroupMember adds an agent to a group or updates its allocation.
+func (h *Handlers) SetGroupMember(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid group id")
+		return
+	}
+	agentID, err := uuid.Parse(r.PathValue("agentId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid agent id")
+		return
+	}
+
+	var req SetGroupMemberRequest
+	if r.ContentLength > 0 {
+		if err := decodeJSON(r, &req); err != nil {
+			respondError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+	if err := policy.ValidateBudget(&req.Allocation); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var exists bool
+	h.db.QueryRow(r.Context(),
+		`SELECT EXISTS(SELECT 1 FROM agent_groups WHERE id = $1 AND wallet_id = $2)
+		    AND EXISTS(SELECT 1 FROM agents WHERE id = $3 AND wallet_id = $2 AND status != 'deleted')`,
+		groupID, userID, agentID,
+	).Scan(&exists)
+	if !exists {
+		respondError(w, http.StatusNotFound, "agent group or agent not found")
+		return
+	}
+
+	allocation, _ := json.Marshal(req.Allocation)
+	var m GroupMember
+	err = h.db.QueryRow(r.Context(),
+		`INSERT INTO agent_group_members (group_id, agent_id, allocation)
+		 VALUES ($1, $2, $3)
+		 ON CONFLICT (group_id, agent_id) DO UPDATE SET allocation = EXCLUDED.allocation
+		 RETURNING agent_id, (SELECT name FROM agents WHERE id = $2), source, added_at`,
+		groupID, agentID, allocation,
+	).Scan(&m.AgentID, &m.AgentName, &m.Source, &m.AddedAt)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("failed to set group member")
+		respondError(w, http.StatusInternalServerError, "failed to set group member")
+		return
+	}
+	m.Allocation = req.Allocation
+
+	h.auditLogger.Log(r.Context(), audit.Event{
+		WalletID:  userID,
+		AgentID:   &agentID,
+		EventType: "agent_group.member_set",
+		Details:   map[string]interface{}{"group_id": groupID, "allocation": m.Allocation},
+	})
+	h.revokeBundles(r.Context(), userID)
+
+	// Grant the group's policies to the new member
+	h.reconcileGroup(r.Context(), userID, groupID)
+
+	respondJSON(w, http.StatusOK, m)
+}
+
+func (h *Handlers) RemoveGroupMember(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid group id")
+		return
+	}
+	agentID, err := uuid.Parse(r.PathValue("agentId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid agent id")
+		return
+	}
+
+	var source string
+	err = h.db.QueryRow(r.Context(),
+		`SELECT m.source FROM agent_group_members m
+		 JOIN agent_groups g ON g.id = m.group_id
+		 WHERE g.id = $1 AND g.wallet_id = $2 AND m.agent_id = $3`,
+		groupID, userID, agentID,
+	).Scan(&source)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "agent is not a member of this group")
+		return
+	}
+	if source == "tag" {
+		respondError(w, http.StatusConflict, "agent is a member through its tags; change the agent's tags or the group's match_tags instead")
+		return
+	}
+
+	result, err := h.db.Exec(r.Context(),
+		`DELETE FROM agent_group_members m USING agent_groups g
+		 WHERE m.group_id = g.id AND g.id = $1 AND g.wallet_id = $2 AND m.agent_id = $3`,
+		groupID, userID, agentID,
+	)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to remove group member")
+		return
+	}
+
+	if result.RowsAffected() == 0 {
+		respondError(w, http.StatusNotFound, "agent is not a member of this group")
+		return
+	}
+
+	h.auditLogger.Log(r.Context(), audit.Event{
+		WalletID:  userID,
+		AgentID:   &agentID,
+		EventType: "agent_group.member_removed",
+		Details:   map[string]interface{}{"group_id": groupID},
+	})
+
+	// Revoke the permissions the agent held through the group
+	h.reconcileGroup(r.Context(), userID, groupID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
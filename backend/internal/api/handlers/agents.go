@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"net/http"
@@ -27,6 +28,7 @@ type Agent struct {
 	SmartAccountAddress  *string    `json:"smart_account_address,omitempty"`
 	SignerAddress        *string    `json:"signer_address,omitempty"`
 	SignerType           *string    `json:"signer_type,omitempty"`
+	Tags                 []string   `json:"tags"`
 	CreatedAt            time.Time  `json:"created_at"`
 	UpdatedAt            time.Time  `json:"updated_at"`
 	OnchainRegisteredAt  *time.Time `json:"onchain_registered_at,omitempty"`
@@ -35,7 +37,8 @@ type Agent struct {
 type CreateAgentRequest struct {
 	Name         string `json:"name"`
 	Description  string `json:"description,omitempty"`
-	AgentAddress string `json:"agent_address,omitempty"`
+	AgentAddress string   `json:"agent_address,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
 }
 
 func (h *Handlers) CreateAgent(w http.ResponseWriter, r *http.Request) {
@@ -51,35 +54,50 @@ func (h *Handlers) CreateAgent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Name == "" {
-		respondError(w, http.StatusBadRequest, "name is required")
+	agent, err := h.createAgent(r.Context(), userID, req)
+	if err != nil {
+		respondHandlerError(w, err)
 		return
 	}
 
-	walletType := "smart_account"
-	enforcementLevel := "enforced"
+	respondJSON(w, http.StatusCreated, agent)
+}
 
+// createAgent inserts a new active smart-account agent for walletID.
+func (h *Handlers) createAgent(ctx context.Context, walletID uuid.UUID, req CreateAgentRequest) (Agent, error) {
 	var agent Agent
-	err := h.db.QueryRow(r.Context(),
-		`INSERT INTO agents (wallet_id, name, description, agent_address, status, wallet_type, enforcement_level)
-		 VALUES ($1, $2, $3, $4, 'active', $5, $6)
-		 RETURNING id, wallet_id, name, description, agent_address, status, wallet_type, enforcement_level, created_at, updated_at`,
-		userID, req.Name, req.Description, nilIfEmpty(req.AgentAddress), walletType, enforcementLevel,
-	).Scan(&agent.ID, &agent.WalletID, &agent.Name, &agent.Description, &agent.AgentAddress, &agent.Status, &agent.WalletType, &agent.EnforcementLevel, &agent.CreatedAt, &agent.UpdatedAt)
+
+	if req.Name == "" {
+		return agent, newHandlerError(http.StatusBadRequest, "name is required")
+	}
+
+	walletType := "smart_account"
+	enforcementLevel := "enforced"
+	tags := normalizeTags(req.Tags)
+
+	err := h.db.QueryRow(ctx,
+		`INSERT INTO agents (wallet_id, name, description, agent_address, status, wallet_type, enforcement_level, tags)
+		 VALUES ($1, $2, $3, $4, 'active', $5, $6, $7)
+		 RETURNING id, wallet_id, name, description, agent_address, status, wallet_type, enforcement_level, created_at, updated_at, tags`,
+		walletID, req.Name, req.Description, nilIfEmpty(req.AgentAddress), walletType, enforcementLevel, tags,
+	).Scan(&agent.ID, &agent.WalletID, &agent.Name, &agent.Description, &agent.AgentAddress, &agent.Status, &agent.WalletType, &agent.EnforcementLevel, &agent.CreatedAt, &agent.UpdatedAt, &agent.Tags)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("failed to create agent")
-		respondError(w, http.StatusInternalServerError, "failed to create agent")
-		return
+		return agent, newHandlerError(http.StatusInternalServerError, "failed to create agent")
 	}
 
-	h.auditLogger.Log(r.Context(), audit.Event{
-		WalletID:  userID,
+	h.auditLogger.Log(ctx, audit.Event{
+		WalletID:  walletID,
 		AgentID:   &agent.ID,
 		EventType: "agent.created",
 		Details:   map[string]interface{}{"name": req.Name},
 	})
 
-	respondJSON(w, http.StatusCreated, agent)
+	if len(tags) > 0 {
+		h.reconcileTaggedGroups(ctx, walletID)
+	}
+
+	return agent, nil
 }
 
 func (h *Handlers) ListAgents(w http.ResponseWriter, r *http.Request) {
@@ -92,12 +110,13 @@ func (h *Handlers) ListAgents(w http.ResponseWriter, r *http.Request) {
 	rows, err := h.db.Query(r.Context(),
 		`SELECT a.id, a.wallet_id, a.name, a.description, a.agent_address, a.onchain_registry_id,
 		        a.status, a.wallet_type, a.enforcement_level, a.created_at, a.updated_at,
-		        a.onchain_registered_at, sa.account_address, sa.signer_address, sa.signer_type
+		        a.onchain_registered_at, sa.account_address, sa.signer_address, sa.signer_type, a.tags
 		 FROM agents a
 		 LEFT JOIN smart_accounts sa ON sa.agent_id = a.id
 		 WHERE a.wallet_id = $1 AND a.status != 'deleted'
+		 AND ($2 = '' OR $2 = ANY(a.tags))
 		 ORDER BY a.created_at DESC`,
-		userID,
+		userID, strings.ToLower(r.URL.Query().Get("tag")),
 	)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "failed to list agents")
@@ -110,7 +129,7 @@ func (h *Handlers) ListAgents(w http.ResponseWriter, r *http.Request) {
 		var a Agent
 		if err := rows.Scan(&a.ID, &a.WalletID, &a.Name, &a.Description, &a.AgentAddress, &a.OnchainRegistryID,
 			&a.Status, &a.WalletType, &a.EnforcementLevel, &a.CreatedAt, &a.UpdatedAt,
-			&a.OnchainRegisteredAt, &a.SmartAccountAddress, &a.SignerAddress, &a.SignerType); err != nil {
+			&a.OnchainRegisteredAt, &a.SmartAccountAddress, &a.SignerAddress, &a.SignerType, &a.Tags); err != nil {
 			continue
 		}
 		agents = append(agents, a)
@@ -137,14 +156,14 @@ func (h *Handlers) GetAgent(w http.ResponseWriter, r *http.Request) {
 	err = h.db.QueryRow(r.Context(),
 		`SELECT a.id, a.wallet_id, a.name, a.description, a.agent_address, a.onchain_registry_id,
 		        a.status, a.wallet_type, a.enforcement_level, a.created_at, a.updated_at,
-		        a.onchain_registered_at, sa.account_address, sa.signer_address, sa.signer_type
+		        a.onchain_registered_at, sa.account_address, sa.signer_address, sa.signer_type, a.tags
 		 FROM agents a
 		 LEFT JOIN smart_accounts sa ON sa.agent_id = a.id
 		 WHERE a.id = $1 AND a.wallet_id = $2 AND a.status != 'deleted'`,
 		agentID, userID,
 	).Scan(&agent.ID, &agent.WalletID, &agent.Name, &agent.Description, &agent.AgentAddress, &agent.OnchainRegistryID,
 		&agent.Status, &agent.WalletType, &agent.EnforcementLevel, &agent.CreatedAt, &agent.UpdatedAt,
-		&agent.OnchainRegisteredAt, &agent.SmartAccountAddress, &agent.SignerAddress, &agent.SignerType)
+		&agent.OnchainRegisteredAt, &agent.SmartAccountAddress, &agent.SignerAddress, &agent.SignerType, &agent.Tags)
 	if err != nil {
 		respondError(w, http.StatusNotFound, "agent not found")
 		return
@@ -158,6 +177,8 @@ type UpdateAgentRequest struct {
 	Description  *string `json:"description,omitempty"`
 	AgentAddress *string `json:"agent_address,omitempty"`
 	Status       *string `json:"status,omitempty"`
+	// Tags replaces the agent's tags when set.
+	Tags *[]string `json:"tags,omitempty"`
 }
 
 func (h *Handlers) UpdateAgent(w http.ResponseWriter, r *http.Request) {
@@ -176,31 +197,60 @@ func (h *Handlers) UpdateAgent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	agent, err := h.updateAgent(r.Context(), userID, agentID, req)
+	if err != nil {
+		respondHandlerError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, agent)
+}
+
+// updateAgent applies a partial update to a non-deleted agent owned by walletID.
+func (h *Handlers) updateAgent(ctx context.Context, walletID, agentID uuid.UUID, req UpdateAgentRequest) (Agent, error) {
 	var agent Agent
-	err = h.db.QueryRow(r.Context(),
+	var tags []string
+	if req.Tags != nil {
+		tags = normalizeTags(*req.Tags)
+	}
+	err := h.db.QueryRow(ctx,
 		`UPDATE agents SET
 			name = COALESCE($1, name),
 			description = COALESCE($2, description),
 			agent_address = COALESCE($3, agent_address),
 			status = COALESCE($4, status),
+			tags = COALESCE($7, tags),
 			updated_at = NOW()
 		 WHERE id = $5 AND wallet_id = $6 AND status != 'deleted'
-		 RETURNING id, wallet_id, name, description, agent_address, onchain_registry_id, status, wallet_type, enforcement_level, created_at, updated_at, onchain_registered_at`,
-		req.Name, req.Description, req.AgentAddress, req.Status, agentID, userID,
-	).Scan(&agent.ID, &agent.WalletID, &agent.Name, &agent.Description, &agent.AgentAddress, &agent.OnchainRegistryID, &agent.Status, &agent.WalletType, &agent.EnforcementLevel, &agent.CreatedAt, &agent.UpdatedAt, &agent.OnchainRegisteredAt)
+		 RETURNING id, wallet_id, name, description, agent_address, onchain_registry_id, status, wallet_type, enforcement_level, created_at, updated_at, onchain_registered_at, tags`,
+		req.Name, req.Description, req.AgentAddress, req.Status, agentID, walletID, tags,
+	).Scan(&agent.ID, &agent.WalletID, &agent.Name, &agent.Description, &agent.AgentAddress, &agent.OnchainRegistryID, &agent.Status, &agent.WalletType, &agent.EnforcementLevel, &agent.CreatedAt, &agent.UpdatedAt, &agent.OnchainRegisteredAt, &agent.Tags)
 	if err != nil {
-		respondError(w, http.StatusNotFound, "agent not found")
-		return
+		return agent, newHandlerError(http.StatusNotFound, "agent not found")
 	}
 
-	h.auditLogger.Log(r.Context(), audit.Event{
-		WalletID:  userID,
+	h.auditLogger.Log(ctx, audit.Event{
+		WalletID:  walletID,
 		AgentID:   &agentID,
 		EventType: "agent.updated",
 		Details:   map[string]interface{}{"changes": req},
 	})
 
-	respondJSON(w, http.StatusOK, agent)
+	// Tags and status decide membership in tag-matched groups
+	if req.Tags != nil || req.Status != nil {
+		h.reconcileTaggedGroups(ctx, walletID)
+	}
+	if req.Status != nil {
+		// The owner now decides the status, so closing an open circuit must
+		// not reactivate the agent
+		h.db.Exec(ctx,
+			`UPDATE circuit_trips SET suspended_agent = false WHERE agent_id = $1 AND status = 'open'`,
+			agentID,
+		)
+		h.revokeBundles(ctx, walletID)
+	}
+
+	return agent, nil
 }
 
 func (h *Handlers) DeleteAgent(w http.ResponseWriter, r *http.Request) {
@@ -232,10 +282,43 @@ func (h *Handlers) DeleteAgent(w http.ResponseWriter, r *http.Request) {
 		AgentID:   &agentID,
 		EventType: "agent.deleted",
 	})
+	h.revokeBundles(r.Context(), userID)
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// GetEffectivePermissions answers "what can this agent do right now?" by
+// merging all of its active, in-window permissions.
+func (h *Handlers) GetEffectivePermissions(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	agentIDStr := r.PathValue("id")
+
+	agentID, err := uuid.Parse(agentIDStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid agent id")
+		return
+	}
+
+	var exists bool
+	err = h.db.QueryRow(r.Context(),
+		`SELECT EXISTS(SELECT 1 FROM agents WHERE id = $1 AND wallet_id = $2 AND status != 'deleted')`,
+		agentID, userID,
+	).Scan(&exists)
+	if err != nil || !exists {
+		respondError(w, http.StatusNotFound, "agent not found")
+		return
+	}
+
+	effective, err := h.policyEngine.EffectivePermissions(r.Context(), userID, agentID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("failed to compute effective permissions")
+		respondError(w, http.StatusInternalServerError, "failed to compute effective permissions")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, effective)
+}
+
 type RegisterOnchainRequest struct {
 	ChainID int64 `json:"chain_id,omitempty"`
 }
@@ -250,6 +333,12 @@ func (h *Handlers) RegisterAgentOnchain(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// A frozen wallet keeps its agents deactivated until it is unfrozen
+	if err := h.checkNotFrozen(r.Context(), userID); err != nil {
+		respondHandlerError(w, err)
+		return
+	}
+
 	// Parse optional chain_id from body
 	var req RegisterOnchainRequest
 	decodeJSON(r, &req) // best-effort; body may be empty
@@ -279,9 +368,9 @@ func (h *Handlers) RegisterAgentOnchain(w http.ResponseWriter, r *http.Request)
 			onchain_registered_at = NOW(),
 			updated_at = NOW()
 		 WHERE id = $2 AND wallet_id = $3 AND status != 'deleted'
-		 RETURNING id, wallet_id, name, description, agent_address, onchain_registry_id, status, wallet_type, enforcement_level, created_at, updated_at, onchain_registered_at`,
+		 RETURNING id, wallet_id, name, description, agent_address, onchain_registry_id, status, wallet_type, enforcement_level, created_at, updated_at, onchain_registered_at, tags`,
 		registryID, agentID, userID,
-	).Scan(&agent.ID, &agent.WalletID, &agent.Name, &agent.Description, &agent.AgentAddress, &agent.OnchainRegistryID, &agent.Status, &agent.WalletType, &agent.EnforcementLevel, &agent.CreatedAt, &agent.UpdatedAt, &agent.OnchainRegisteredAt)
+	).Scan(&agent.ID, &agent.WalletID, &agent.Name, &agent.Description, &agent.AgentAddress, &agent.OnchainRegistryID, &agent.Status, &agent.WalletType, &agent.EnforcementLevel, &agent.CreatedAt, &agent.UpdatedAt, &agent.OnchainRegisteredAt, &agent.Tags)
 	if err != nil {
 		respondError(w, http.StatusNotFound, "agent not found")
 		return
@@ -387,10 +476,10 @@ func (h *Handlers) SyncOnchainAgents(w http.ResponseWriter, r *http.Request) {
 		err = h.db.QueryRow(ctx,
 			`INSERT INTO agents (wallet_id, name, description, status, wallet_type, enforcement_level, onchain_registry_id, onchain_registered_at)
 			 VALUES ($1, $2, $3, $4, 'smart_account', 'enforced', $5, $6)
-			 RETURNING id, wallet_id, name, description, agent_address, onchain_registry_id, status, wallet_type, enforcement_level, created_at, updated_at, onchain_registered_at`,
+			 RETURNING id, wallet_id, name, description, agent_address, onchain_registry_id, status, wallet_type, enforcement_level, created_at, updated_at, onchain_registered_at, tags`,
 			walletID, name, description, status, registryHex, regAt,
 		).Scan(&agent.ID, &agent.WalletID, &agent.Name, &agent.Description, &agent.AgentAddress, &agent.OnchainRegistryID,
-			&agent.Status, &agent.WalletType, &agent.EnforcementLevel, &agent.CreatedAt, &agent.UpdatedAt, &agent.OnchainRegisteredAt)
+			&agent.Status, &agent.WalletType, &agent.EnforcementLevel, &agent.CreatedAt, &agent.UpdatedAt, &agent.OnchainRegisteredAt, &agent.Tags)
 		if err != nil {
 			h.logger.Error().Err(err).Str("registry_id", registryHex).Msg("failed to insert synced agent")
 			continue
@@ -126,12 +126,15 @@ func (h *Handlers) VerifySignature(w http.ResponseWriter, r *http.Request) {
 
 type CreateAPIKeyRequest struct {
 	Name string `json:"name"`
+	// AgentID binds the key to one agent, which can then only act as itself.
+	AgentID *uuid.UUID `json:"agent_id,omitempty"`
 }
 
 type CreateAPIKeyResponse struct {
-	ID   uuid.UUID `json:"id"`
-	Key  string    `json:"key"`
-	Name string    `json:"name"`
+	ID      uuid.UUID  `json:"id"`
+	Key     string     `json:"key"`
+	Name    string     `json:"name"`
+	AgentID *uuid.UUID `json:"agent_id,omitempty"`
 }
 
 func (h *Handlers) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
@@ -147,6 +150,18 @@ func (h *Handlers) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.AgentID != nil {
+		var agentExists bool
+		h.db.QueryRow(r.Context(),
+			`SELECT EXISTS(SELECT 1 FROM agents WHERE id = $1 AND wallet_id = $2 AND status != 'deleted')`,
+			*req.AgentID, userID,
+		).Scan(&agentExists)
+		if !agentExists {
+			respondError(w, http.StatusBadRequest, "agent not found")
+			return
+		}
+	}
+
 	// Generate API key
 	keyBytes := make([]byte, 32)
 	if _, err := rand.Read(keyBytes); err != nil {
@@ -157,10 +172,10 @@ func (h *Handlers) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
 
 	var keyID uuid.UUID
 	err := h.db.QueryRow(r.Context(),
-		`INSERT INTO api_keys (wallet_id, name, key_hash, key_prefix)
-		 VALUES ($1, $2, encode(sha256($3::bytea), 'hex'), $4)
+		`INSERT INTO api_keys (wallet_id, name, key_hash, key_prefix, agent_id)
+		 VALUES ($1, $2, encode(sha256($3::bytea), 'hex'), $4, $5)
 		 RETURNING id`,
-		userID, req.Name, key, key[:16],
+		userID, req.Name, key, key[:16], req.AgentID,
 	).Scan(&keyID)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("failed to create API key")
@@ -171,13 +186,15 @@ func (h *Handlers) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
 	h.auditLogger.Log(r.Context(), audit.Event{
 		WalletID:  userID,
 		EventType: "api_key.created",
+		AgentID:   req.AgentID,
 		Details:   map[string]interface{}{"key_id": keyID, "name": req.Name},
 	})
 
 	respondJSON(w, http.StatusCreated, CreateAPIKeyResponse{
-		ID:   keyID,
-		Key:  key,
-		Name: req.Name,
+		ID:      keyID,
+		Key:     key,
+		Name:    req.Name,
+		AgentID: req.AgentID,
 	})
 }
 
@@ -185,6 +202,7 @@ type APIKeyListItem struct {
 	ID        uuid.UUID  `json:"id"`
 	Name      string     `json:"name"`
 	KeyPrefix string     `json:"key_prefix"`
+	AgentID   *uuid.UUID `json:"agent_id,omitempty"`
 	CreatedAt time.Time  `json:"created_at"`
 	LastUsed  *time.Time `json:"last_used_at,omitempty"`
 }
@@ -197,7 +215,7 @@ func (h *Handlers) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
 	}
 
 	rows, err := h.db.Query(r.Context(),
-		`SELECT id, name, key_prefix, created_at, last_used_at
+		`SELECT id, name, key_prefix, agent_id, created_at, last_used_at
 		 FROM api_keys WHERE wallet_id = $1 AND revoked_at IS NULL
 		 ORDER BY created_at DESC`,
 		userID,
@@ -211,7 +229,7 @@ func (h *Handlers) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
 	var keys []APIKeyListItem
 	for rows.Next() {
 		var k APIKeyListItem
-		if err := rows.Scan(&k.ID, &k.Name, &k.KeyPrefix, &k.CreatedAt, &k.LastUsed); err != nil {
+		if err := rows.Scan(&k.ID, &k.Name, &k.KeyPrefix, &k.AgentID, &k.CreatedAt, &k.LastUsed); err != nil {
 			continue
 		}
 		keys = append(keys, k)
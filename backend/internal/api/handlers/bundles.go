@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/erc8004/policy-saas/internal/api/middleware"
+	"github.com/erc8004/policy-saas/internal/domain/audit"
+	"github.com/erc8004/policy-saas/internal/domain/policy"
+)
+
+type ExportBundleRequest struct {
+	// TTLSeconds defaults to 15 minutes and may be at most an hour.
+	TTLSeconds int `json:"ttl_seconds,omitempty"`
+}
+
+type RevocationEpochResponse struct {
+	RevocationEpoch int64 `json:"revocation_epoch"`
+}
+
+// ExportAgentBundle exports an agent's effective policies as a signed,
+// expiring bundle it can evaluate in-process.
+func (h *Handlers) ExportAgentBundle(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	agentID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid agent id")
+		return
+	}
+
+	var req ExportBundleRequest
+	if err := decodeJSON(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	ttl := policy.DefaultBundleTTL
+	if req.TTLSeconds != 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+	if ttl <= 0 || ttl > policy.MaxBundleTTL {
+		respondError(w, http.StatusBadRequest, "ttl_seconds must be between 1 and 3600")
+		return
+	}
+
+	if h.serviceSigner == nil {
+		respondError(w, http.StatusServiceUnavailable, "policy bundles need a service signing key (SERVICE_SIGNING_KEY)")
+		return
+	}
+
+	var exists bool
+	err = h.db.QueryRow(r.Context(),
+		`SELECT EXISTS(SELECT 1 FROM agents WHERE id = $1 AND wallet_id = $2 AND status = 'active')`,
+		agentID, userID,
+	).Scan(&exists)
+	if err != nil || !exists {
+		respondError(w, http.StatusNotFound, "agent not found or not active")
+		return
+	}
+
+	bundle, reason, err := h.policyEngine.BuildBundle(r.Context(), userID, agentID, ttl)
+	if err != nil {
+		h.logger.Error().Err(err).Str("agent_id", agentID.String()).Msg("failed to build policy bundle")
+		respondError(w, http.StatusInternalServerError, "failed to build policy bundle")
+		return
+	}
+	if reason != "" {
+		respondError(w, http.StatusForbidden, reason)
+		return
+	}
+
+	signed, err := policy.SignBundle(bundle, h.serviceSigner)
+	if err != nil {
+		h.logger.Error().Err(err).Str("agent_id", agentID.String()).Msg("failed to sign policy bundle")
+		respondError(w, http.StatusInternalServerError, "failed to sign policy bundle")
+		return
+	}
+
+	h.auditLogger.Log(r.Context(), audit.Event{
+		WalletID:  userID,
+		AgentID:   &agentID,
+		EventType: "bundle.issued",
+		Details: map[string]interface{}{
+			"bundle_hash":      signed.BundleHash,
+			"revocation_epoch": bundle.RevocationEpoch,
+			"expires_at":       bundle.ExpiresAt,
+			"grants":           len(bundle.Grants),
+			"online_only":      len(bundle.OnlineOnly),
+		},
+	})
+
+	respondJSON(w, http.StatusOK, signed)
+}
+
+// GetRevocationEpoch returns the wallet's revocation epoch. Evaluators poll
+// it and stop using bundles issued under an older one.
+func (h *Handlers) GetRevocationEpoch(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	epoch, err := policy.RevocationEpoch(r.Context(), h.db, userID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "wallet not found")
+		return
+	}
+	respondJSON(w, http.StatusOK, RevocationEpochResponse{RevocationEpoch: epoch})
+}
+
+// RevokeBundles advances the revocation epoch on the owner's request, for
+// changes the service does not treat as revocations by itself.
+func (h *Handlers) RevokeBundles(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	epoch, err := policy.BumpRevocationEpoch(r.Context(), h.db, userID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "wallet not found")
+		return
+	}
+
+	h.auditLogger.Log(r.Context(), audit.Event{
+		WalletID:  userID,
+		EventType: "bundles.revoked",
+		Details:   map[string]interface{}{"revocation_epoch": epoch},
+	})
+
+	respondJSON(w, http.StatusOK, RevocationEpochResponse{RevocationEpoch: epoch})
+}
+
+// revokeBundles advances the wallet's revocation epoch after a change that
+// takes authority away, so bundles issued before it stop validating.
+func (h *Handlers) revokeBundles(ctx context.Context, walletID uuid.UUID) {
+	if _, err := policy.BumpRevocationEpoch(ctx, h.db, walletID); err != nil {
+		h.logger.Error().Err(err).Str("wallet_id", walletID.String()).Msg("failed to advance revocation epoch")
+	}
+}
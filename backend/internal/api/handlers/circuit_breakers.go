@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/erc8004/policy-saas/internal/api/middleware"
+	"github.com/erc8004/policy-saas/internal/domain/audit"
+	"github.com/erc8004/policy-saas/internal/domain/policy"
+)
+
+type CircuitBreaker struct {
+	ID        uuid.UUID          `json:"id"`
+	WalletID  uuid.UUID          `json:"wallet_id"`
+	AgentID   *uuid.UUID         `json:"agent_id,omitempty"`
+	Name      string             `json:"name"`
+	Rule      policy.CircuitRule `json:"rule"`
+	Status    string             `json:"status"`
+	CreatedAt time.Time          `json:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
+
+type CreateCircuitBreakerRequest struct {
+	// AgentID scopes the rule to one agent; omitted, it applies to each
+	// agent of the wallet.
+	AgentID *uuid.UUID         `json:"agent_id,omitempty"`
+	Name    string             `json:"name"`
+	Rule    policy.CircuitRule `json:"rule"`
+}
+
+type UpdateCircuitBreakerRequest struct {
+	Name   *string             `json:"name,omitempty"`
+	Rule   *policy.CircuitRule `json:"rule,omitempty"`
+	Status *string             `json:"status,omitempty"`
+}
+
+type CircuitTrip struct {
+	ID        uuid.UUID  `json:"id"`
+	WalletID  uuid.UUID  `json:"wallet_id"`
+	AgentID   uuid.UUID  `json:"agent_id"`
+	BreakerID *uuid.UUID `json:"breaker_id,omitempty"`
+	Reason    string     `json:"reason"`
+	Status    string     `json:"status"`
+	OpenedAt  time.Time  `json:"opened_at"`
+	ResetAt   *time.Time `json:"reset_at,omitempty"`
+	ClosedAt  *time.Time `json:"closed_at,omitempty"`
+	ClosedBy  *string    `json:"closed_by,omitempty"`
+}
+
+const circuitBreakerColumns = `id, wallet_id, agent_id, name, rule, status, created_at, updated_at`
+
+func scanCircuitBreaker(row interface{ Scan(...any) error }, b *CircuitBreaker) error {
+	var ruleBytes []byte
+	if err := row.Scan(&b.ID, &b.WalletID, &b.AgentID, &b.Name, &ruleBytes, &b.Status, &b.CreatedAt, &b.UpdatedAt); err != nil {
+		return err
+	}
+	json.Unmarshal(ruleBytes, &b.Rule)
+	return nil
+}
+
+const circuitTripColumns = `id, wallet_id, agent_id, breaker_id, reason, status, opened_at, reset_at, closed_at, closed_by`
+
+func scanCircuitTrip(row interface{ Scan(...any) error }, t *CircuitTrip) error {
+	return row.Scan(&t.ID, &t.WalletID, &t.AgentID, &t.BreakerID, &t.Reason, &t.Status, &t.OpenedAt, &t.ResetAt, &t.ClosedAt, &t.ClosedBy)
+}
+
+func (h *Handlers) CreateCircuitBreaker(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == uuid.Nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req CreateCircuitBreakerRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if req.Name == "" {
+		respondError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	if err := policy.ValidateCircuitRule(&req.Rule); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.AgentID != nil {
+		var exists bool
+		h.db.QueryRow(r.Context(),
+			`SELECT EXISTS(SELECT 1 FROM agents WHERE id = $1 AND wallet_id = $2 AND status != 'deleted')`,
+			*req.AgentID, userID,
+		).Scan(&exists)
+		if !exists {
+			respondError(w, http.StatusBadRequest, "agent not found")
+			return
+		}
+	}
+
+	ruleBytes, _ := json.Marshal(req.Rule)
+	var b CircuitBreaker
+	err := scanCircuitBreaker(h.db.QueryRow(r.Context(),
+		`INSERT INTO circuit_breakers (wallet_id, agent_id, name, rule)
+		 VALUES ($1, $2, $3, $4)
+		 RETURNING `+circuitBreakerColumns,
+		userID, req.AgentID, req.Name, ruleBytes,
+	), &b)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("failed to create circuit breaker")
+		respondError(w, http.StatusInternalServerError, "failed to create circuit breaker")
+		return
+	}
+
+	h.auditLogger.Log(r.Context(), audit.Event{
+		WalletID:  userID,
+		AgentID:   b.AgentID,
+		EventType: "circuit_breaker.created",
+		Details:   map[string]interface{}{"breaker_id": b.ID, "name": b.Name, "rule": b.Rule},
+	})
+
+	respondJSON(w, http.StatusCreated, b)
+}
+
+func (h *Handlers) ListCircuitBreakers(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == uuid.Nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	rows, err := h.db.Query(r.Context(),
+		`SELECT `+circuitBreakerColumns+` FROM circuit_breakers WHERE wallet_id = $1 ORDER BY created_at DESC`,
+		userID,
+	)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list circuit breakers")
+		return
+	}
+	defer rows.Close()
+
+	var breakers []CircuitBreaker
+	for rows.Next() {
+		var b CircuitBreaker
+		if err := scanCircuitBreaker(rows, &b); err != nil {
+			continue
+		}
+		breakers = append(breakers, b)
+	}
+
+	if breakers == nil {
+		breakers = []CircuitBreaker{}
+	}
+
+	respondJSON(w, http.StatusOK, breakers)
+}
+
+func (h *Handlers) GetCircuitBreaker(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	breakerID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid circuit breaker id")
+		return
+	}
+
+	var b CircuitBreaker
+	err = scanCircuitBreaker(h.db.QueryRow(r.Context(),
+		`SELECT `+circuitBreakerColumns+` FROM circuit_breakers WHERE id = $1 AND wallet_id = $2`,
+		breakerID, userID,
+	), &b)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "circuit breaker not found")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, b)
+}
+
+func (h *Handlers) UpdateCircuitBreaker(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	breakerID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid circuit breaker id")
+		return
+	}
+
+	var req UpdateCircuitBreakerRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	var ruleBytes []byte
+	if req.Rule != nil {
+		if err := policy.ValidateCircuitRule(req.Rule); err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		ruleBytes, _ = json.Marshal(req.Rule)
+	}
+	if req.Status != nil && *req.Status != "active" && *req.Status != "disabled" {
+		respondError(w, http.StatusBadRequest, "status must be active or disabled")
+		return
+	}
+
+	var b CircuitBreaker
+	err = scanCircuitBreaker(h.db.QueryRow(r.Context(),
+		`UPDATE circuit_breakers SET
+			name = COALESCE($1, name),
+			rule = COALESCE($2, rule),
+			status = COALESCE($3, status),
+			updated_at = NOW()
+		 WHERE id = $4 AND wallet_id = $5
+		 RETURNING `+circuitBreakerColumns,
+		req.Name, ruleBytes, req.Status, breakerID, userID,
+	), &b)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "circuit breaker not found")
+		return
+	}
+
+	h.auditLogger.Log(r.Context(), audit.Event{
+		WalletID:  userID,
+		AgentID:   b.AgentID,
+		EventType: "circuit_breaker.updated",
+		Details:   map[string]interface{}{"breaker_id": b.ID, "status": b.Status, "rule": b.Rule},
+	})
+
+	respondJSON(w, http.StatusOK, b)
+}
+
+// DeleteCircuitBreaker removes a rule. Circuits it already opened stay open
+// until their cooldown passes or they are reset.
+func (h *Handlers) DeleteCircuitBreaker(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	breakerID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid circuit breaker id")
+		return
+	}
+
+	result, err := h.db.Exec(r.Context(),
+		`DELETE FROM circuit_breakers WHERE id = $1 AND wallet_id = $2`,
+		breakerID, userID,
+	)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to delete circuit breaker")
+		return
+	}
+
+	if result.RowsAffected() == 0 {
+		respondError(w, http.StatusNotFound, "circuit breaker not found")
+		return
+	}
+
+	h.auditLogger.Log(r.Context(), audit.Event{
+		WalletID:  userID,
+		EventType: "circuit_breaker.deleted",
+		Details:   map[string]interface{}{"breaker_id": breakerID},
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handlers) ListCircuitTrips(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == uuid.Nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	agentID := r.URL.Query().Get("agent_id")
+	rows, err := h.db.Query(r.Context(),
+		`SELECT `+circuitTripColumns+` FROM circuit_trips
+		 WHERE wallet_id = $1 AND ($2 = '' OR status = $2) AND ($3 = '' OR agent_id::text = $3)
+		 ORDER BY opened_at DESC LIMIT 100`,
+		userID, status, agentID,
+	)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list circuit trips")
+		return
+	}
+	defer rows.Close()
+
+	trips := []CircuitTrip{}
+	for rows.Next() {
+		var t CircuitTrip
+		if err := scanCircuitTrip(rows, &t); err != nil {
+			continue
+		}
+		trips = append(trips, t)
+	}
+
+	respondJSON(w, http.StatusOK, trips)
+}
+
+// ResetAgentCircuit closes an agent's open circuit by hand and lifts its
+// suspension. Permissions revoked when it tripped are not restored. Only a
+// signed-in session may reset, not API keys.
+func (h *Handlers) ResetAgentCircuit(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	agentID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid agent id")
+		return
+	}
+	session := middleware.GetWallet(r.Context())
+	if session == "" {
+		respondError(w, http.StatusForbidden, "resetting a circuit requires a signed-in wallet session")
+		return
+	}
+
+	reset, err := h.circuitBreaker.Reset(r.Context(), userID, agentID, strings.ToLower(session))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to reset circuit")
+		return
+	}
+	if !reset {
+		respondError(w, http.StatusNotFound, "agent has no open circuit")
+		return
+	}
+
+	h.reconcileTaggedGroups(r.Context(), userID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/erc8004/policy-saas/internal/api/middleware"
+	"github.com/erc8004/policy-saas/internal/domain/audit"
+	"github.com/erc8004/policy-saas/internal/domain/policy"
+)
+
+type DelegatePermissionRequest struct {
+	// AgentID is the sub-agent receiving the delegated permission.
+	AgentID    uuid.UUID         `json:"agent_id"`
+	Name       string            `json:"name,omitempty"`
+	Definition policy.Definition `json:"definition"`
+	ValidFrom  *time.Time        `json:"valid_from,omitempty"`
+	// ValidUntil defaults to the delegating permission's.
+	ValidUntil *time.Time `json:"valid_until,omitempty"`
+	Mint       bool       `json:"mint,omitempty"`
+}
+
+type DelegatePermissionResponse struct {
+	Permission Permission `json:"permission"`
+	Policy     Policy     `json:"policy"`
+	// MintError is set when the permission was delegated but minting failed.
+	MintError string `json:"mint_error,omitempty"`
+}
+
+// DelegatePermission hands a strict subset of a permission to another agent.
+// The sub-permission's definition must be an attenuation of the delegating
+// permission's; its spending is charged to every permission above it, and it
+// is revoked with them.
+func (h *Handlers) DelegatePermission(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	parentID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid permission id")
+		return
+	}
+
+	var req DelegatePermissionRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	resp, err := h.delegatePermission(r.Context(), userID, parentID, req)
+	if err != nil {
+		respondHandlerError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, resp)
+}
+
+func (h *Handlers) delegatePermission(ctx context.Context, walletID, parentID uuid.UUID, req DelegatePermissionRequest) (DelegatePermissionResponse, error) {
+	var resp DelegatePermissionResponse
+
+	var parent Permission
+	var parentDefBytes []byte
+	var parentPolicyName string
+	err := h.db.QueryRow(ctx,
+		`SELECT pol.definition, pol.name FROM permissions p
+		 JOIN policies pol ON pol.id = p.policy_id AND pol.status = 'active'
+		 WHERE p.id = $1 AND p.wallet_id = $2 AND p.status = 'active'`,
+		parentID, walletID,
+	).Scan(&parentDefBytes, &parentPolicyName)
+	if err == nil {
+		err = scanPermission(h.db.QueryRow(ctx,
+			`SELECT `+permissionColumns+` FROM permissions WHERE id = $1`, parentID,
+		), &parent)
+	}
+	if err != nil {
+		return resp, newHandlerError(http.StatusNotFound, "permission not found or not active")
+	}
+	if parent.TaskID != nil {
+		return resp, newHandlerError(http.StatusBadRequest, "task sessions cannot be delegated")
+	}
+	var parentDef policy.Definition
+	json.Unmarshal(parentDefBytes, &parentDef)
+
+	// Agents already in the chain cannot receive it again, which also keeps
+	// delegates' spending from being counted twice
+	var depth int
+	var inChain bool
+	h.db.QueryRow(ctx,
+		`WITH RECURSIVE chain AS (
+		   SELECT id, agent_id, delegated_from FROM permissions WHERE id = $1
+		   UNION
+		   SELECT p.id, p.agent_id, p.delegated_from FROM permissions p JOIN chain c ON p.id = c.delegated_from
+		 )
+		 SELECT COUNT(*) - 1, COALESCE(BOOL_OR(agent_id = $2), false) FROM chain`,
+		parentID, req.AgentID,
+	).Scan(&depth, &inChain)
+	if depth+1 > policy.MaxDelegationDepth {
+		return resp, newHandlerError(http.StatusBadRequest, "permissions may be delegated at most "+strconv.Itoa(policy.MaxDelegationDepth)+" levels deep")
+	}
+	if inChain {
+		return resp, newHandlerError(http.StatusBadRequest, "agent already holds this permission or one it was delegated from")
+	}
+
+	if err := h.policyEngine.ValidateDefinition(&req.Definition); err != nil {
+		return resp, newHandlerError(http.StatusBadRequest, err.Error())
+	}
+	if err := policy.CheckAttenuation(&req.Definition, &parentDef); err != nil {
+		return resp, newHandlerError(http.StatusBadRequest, "definition is not a subset of the delegating permission: "+err.Error())
+	}
+
+	// The permission's own window must lie within the parent's effective one
+	parentFrom, parentUntil := policy.EffectiveWindow(parentDef.Duration, parent.ValidFrom, parent.ValidUntil)
+	validFrom := time.Now()
+	if req.ValidFrom != nil {
+		validFrom = *req.ValidFrom
+	}
+	if validFrom.Before(parentFrom) {
+		validFrom = parentFrom
+	}
+	validUntil := req.ValidUntil
+	if validUntil == nil {
+		validUntil = parentUntil
+	}
+	if err := policy.WithinWindow(policy.Duration{ValidFrom: &validFrom, ValidUntil: validUntil}, &parentFrom, parentUntil); err != nil {
+		return resp, newHandlerError(http.StatusBadRequest, err.Error())
+	}
+	if validUntil != nil && !validUntil.After(validFrom) {
+		return resp, newHandlerError(http.StatusBadRequest, "valid_until must be after valid_from")
+	}
+
+	if err := h.checkNotFrozen(ctx, walletID); err != nil {
+		return resp, err
+	}
+	var agentName string
+	err = h.db.QueryRow(ctx,
+		`SELECT name FROM agents WHERE id = $1 AND wallet_id = $2 AND status = 'active'`,
+		req.AgentID, walletID,
+	).Scan(&agentName)
+	if err != nil {
+		return resp, newHandlerError(http.StatusBadRequest, "agent not found or inactive")
+	}
+
+	name := req.Name
+	if name == "" {
+		name = parentPolicyName + " (delegated to " + agentName + ")"
+	}
+	p, err := h.createPolicy(ctx, walletID, CreatePolicyRequest{
+		Name:        name,
+		Description: "Delegated from permission " + parentID.String(),
+		Definition:  req.Definition,
+	})
+	if err != nil {
+		return resp, err
+	}
+	activated, err := h.activatePolicy(ctx, walletID, p.ID, "")
+	if err != nil {
+		h.db.Exec(ctx, `UPDATE policies SET status = 'deleted', updated_at = NOW() WHERE id = $1`, p.ID)
+		return resp, err
+	}
+
+	// The parent link is written with the permission itself: without it the
+	// permission would validate as a root, free of the chain's limits
+	perm, err := h.createPermission(ctx, walletID, CreatePermissionRequest{
+		AgentID:       req.AgentID,
+		PolicyID:      activated.ID,
+		ValidFrom:     &validFrom,
+		ValidUntil:    validUntil,
+		DelegatedFrom: &parentID,
+	})
+	if err != nil {
+		h.db.Exec(ctx, `UPDATE policies SET status = 'deleted', updated_at = NOW() WHERE id = $1`, activated.ID)
+		return resp, err
+	}
+
+	details := map[string]interface{}{
+		"delegated_from":   parentID,
+		"delegating_agent": parent.AgentID,
+		"depth":            depth + 1,
+	}
+	if req.Mint {
+		minted, err := h.mintPermission(ctx, walletID, perm.ID)
+		if err != nil {
+			resp.MintError = err.Error()
+			details["mint_error"] = err.Error()
+		} else {
+			perm = minted
+		}
+	}
+	details["minted"] = perm.OnchainTokenID != nil
+
+	h.auditLogger.Log(ctx, audit.Event{
+		WalletID:     walletID,
+		AgentID:      &perm.AgentID,
+		PolicyID:     &perm.PolicyID,
+		PermissionID: &perm.ID,
+		EventType:    "permission.delegated",
+		Details:      details,
+	})
+
+	resp.Permission = perm
+	resp.Policy = activated
+	return resp, nil
+}
+
+// ListDelegations returns the permissions delegated from a permission,
+// directly or further down.
+func (h *Handlers) ListDelegations(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	permID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid permission id")
+		return
+	}
+
+	rows, err := h.db.Query(r.Context(),
+		`WITH RECURSIVE tree AS (
+		   SELECT id FROM permissions WHERE delegated_from = $1 AND wallet_id = $2
+		   UNION
+		   SELECT p.id FROM permissions p JOIN tree t ON p.delegated_from = t.id
+		 )
+		 SELECT `+permissionColumns+` FROM permissions
+		 WHERE id IN (SELECT id FROM tree) AND task_id IS NULL
+		 ORDER BY created_at`,
+		permID, userID,
+	)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list delegations")
+		return
+	}
+	defer rows.Close()
+
+	delegations := []Permission{}
+	for rows.Next() {
+		var p Permission
+		if err := scanPermission(rows, &p); err != nil {
+			continue
+		}
+		delegations = append(delegations, p)
+	}
+
+	respondJSON(w, http.StatusOK, delegations)
+}
+
+// revokeDelegates revokes the active permissions delegated from permID; each
+// revocation cascades further down.
+func (h *Handlers) revokeDelegates(ctx context.Context, walletID, permID uuid.UUID) {
+	rows, err := h.db.Query(ctx,
+		`SELECT id FROM permissions WHERE delegated_from = $1 AND wallet_id = $2 AND status = 'active'`,
+		permID, walletID,
+	)
+	if err != nil {
+		h.logger.Error().Err(err).Str("permission_id", permID.String()).Msg("failed to load delegated permissions")
+		return
+	}
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if err := h.revokePermission(ctx, walletID, id); err != nil {
+			h.logger.Error().Err(err).Str("permission_id", id.String()).Msg("failed to revoke delegated permission")
+		}
+	}
+}
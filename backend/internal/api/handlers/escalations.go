@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/google/uuid"
+
+	"github.com/erc8004/policy-saas/internal/api/middleware"
+	"github.com/erc8004/policy-saas/internal/domain/audit"
+	"github.com/erc8004/policy-saas/internal/domain/policy"
+)
+
+type Escalation struct {
+	ID           uuid.UUID         `json:"id"`
+	WalletID     uuid.UUID         `json:"wallet_id"`
+	PermissionID uuid.UUID         `json:"permission_id"`
+	Constraints  policy.Escalation `json:"constraints"`
+	Reason       string            `json:"reason"`
+	Approver     string            `json:"approver"`
+	Status       string            `json:"status"`
+	ExpiresAt    time.Time         `json:"expires_at"`
+	CreatedAt    time.Time         `json:"created_at"`
+	ApprovedAt   *time.Time        `json:"approved_at,omitempty"`
+	EndedAt      *time.Time        `json:"ended_at,omitempty"`
+}
+
+type CreateEscalationRequest struct {
+	PermissionID uuid.UUID         `json:"permission_id"`
+	Constraints  policy.Escalation `json:"constraints"`
+	Reason       string            `json:"reason"`
+	ExpiresAt    time.Time         `json:"expires_at"`
+	Approver     string            `json:"approver,omitempty"`
+}
+
+const escalationColumns = `id, wallet_id, permission_id, constraints, reason, approver, status, expires_at, created_at, approved_at, ended_at`
+
+func scanEscalation(row interface{ Scan(...any) error }, e *Escalation) error {
+	var constraints []byte
+	if err := row.Scan(&e.ID, &e.WalletID, &e.PermissionID, &constraints, &e.Reason, &e.Approver, &e.Status,
+		&e.ExpiresAt, &e.CreatedAt, &e.ApprovedAt, &e.EndedAt); err != nil {
+		return err
+	}
+	json.Unmarshal(constraints, &e.Constraints)
+	return nil
+}
+
+// CreateEscalation requests a temporary raise of one permission's limits. It
+// takes effect only once the approver approves it.
+func (h *Handlers) CreateEscalation(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == uuid.Nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req CreateEscalationRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if strings.TrimSpace(req.Reason) == "" {
+		respondError(w, http.StatusBadRequest, "reason is required")
+		return
+	}
+	now := time.Now()
+	if !req.ExpiresAt.After(now) {
+		respondError(w, http.StatusBadRequest, "expires_at must be in the future")
+		return
+	}
+	if req.ExpiresAt.Sub(now) > policy.MaxEscalationDuration {
+		respondError(w, http.StatusBadRequest, "escalations may last at most "+policy.MaxEscalationDuration.String())
+		return
+	}
+
+	var agentID, policyID uuid.UUID
+	var defBytes []byte
+	var walletAddress string
+	err := h.db.QueryRow(r.Context(),
+		`SELECT p.agent_id, p.policy_id, pol.definition, w.address
+		 FROM permissions p
+		 JOIN policies pol ON pol.id = p.policy_id
+		 JOIN wallets w ON w.id = p.wallet_id
+		 WHERE p.id = $1 AND p.wallet_id = $2 AND p.status = 'active'`,
+		req.PermissionID, userID,
+	).Scan(&agentID, &policyID, &defBytes, &walletAddress)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "permission not found or not active")
+		return
+	}
+
+	var def policy.Definition
+	json.Unmarshal(defBytes, &def)
+	if err := policy.ValidateEscalation(&req.Constraints, def.Constraints); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	approver := walletAddress
+	if req.Approver != "" {
+		if !common.IsHexAddress(req.Approver) {
+			respondError(w, http.StatusBadRequest, "approver must be an address")
+			return
+		}
+		approver = req.Approver
+	}
+
+	constraints, _ := json.Marshal(req.Constraints)
+	var e Escalation
+	err = scanEscalation(h.db.QueryRow(r.Context(),
+		`INSERT INTO escalations (wallet_id, permission_id, constraints, reason, approver, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 RETURNING `+escalationColumns,
+		userID, req.PermissionID, constraints, req.Reason, strings.ToLower(approver), req.ExpiresAt,
+	), &e)
+	if err != nil {
+		respondError(w, http.StatusConflict, "permission already has an open escalation")
+		return
+	}
+
+	h.auditLogger.Log(r.Context(), audit.Event{
+		WalletID:     userID,
+		AgentID:      &agentID,
+		PolicyID:     &policyID,
+		PermissionID: &req.PermissionID,
+		EventType:    "escalation.requested",
+		Details: map[string]interface{}{
+			"escalation_id": e.ID,
+			"constraints":   e.Constraints,
+			"reason":        e.Reason,
+			"approver":      e.Approver,
+			"expires_at":    e.ExpiresAt,
+		},
+	})
+
+	respondJSON(w, http.StatusCreated, e)
+}
+
+func (h *Handlers) ListEscalations(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == uuid.Nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	rows, err := h.db.Query(r.Context(),
+		`SELECT `+escalationColumns+` FROM escalations
+		 WHERE (wallet_id = $1 OR approver = $3) AND ($2 = '' OR status = $2)
+		 ORDER BY created_at DESC`,
+		userID, status, approverAddress(r.Context()),
+	)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list escalations")
+		return
+	}
+	defer rows.Close()
+
+	var escalations []Escalation
+	for rows.Next() {
+		var e Escalation
+		if err := scanEscalation(rows, &e); err != nil {
+			continue
+		}
+		escalations = append(escalations, e)
+	}
+
+	if escalations == nil {
+		escalations = []Escalation{}
+	}
+
+	respondJSON(w, http.StatusOK, escalations)
+}
+
+func (h *Handlers) GetEscalation(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	escalationID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid escalation id")
+		return
+	}
+
+	var e Escalation
+	err = scanEscalation(h.db.QueryRow(r.Context(),
+		`SELECT `+escalationColumns+` FROM escalations WHERE id = $1 AND (wallet_id = $2 OR approver = $3)`,
+		escalationID, userID, approverAddress(r.Context()),
+	), &e)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "escalation not found")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, e)
+}
+
+// approverAddress returns the signed-in address as escalations store their
+// approver, or a value no approver has for API keys.
+func approverAddress(ctx context.Context) string {
+	wallet := middleware.GetWallet(ctx)
+	if wallet == "" {
+		return "-"
+	}
+	return strings.ToLower(wallet)
+}
+
+// ApproveEscalation activates a pending escalation and pushes the raised
+// limits on-chain. Only a signed-in session of the approver address may
+// approve; API keys cannot.
+func (h *Handlers) ApproveEscalation(w http.ResponseWriter, r *http.Request) {
+	h.decideEscalation(w, r, true)
+}
+
+func (h *Handlers) RejectEscalation(w http.ResponseWriter, r *http.Request) {
+	h.decideEscalation(w, r, false)
+}
+
+func (h *Handlers) decideEscalation(w http.ResponseWriter, r *http.Request, approve bool) {
+	userID := middleware.GetUserID(r.Context())
+	escalationID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid escalation id")
+		return
+	}
+
+	// The approver may be any address, signed in to its own wallet, so the
+	// escalation is looked up across wallets and authorized by address
+	session := middleware.GetWallet(r.Context())
+	var walletID uuid.UUID
+	var approver string
+	err = h.db.QueryRow(r.Context(),
+		`SELECT wallet_id, approver FROM escalations WHERE id = $1 AND status = 'pending'`,
+		escalationID,
+	).Scan(&walletID, &approver)
+	if err != nil || (walletID != userID && !policy.MayDecideEscalation(approver, session)) {
+		respondError(w, http.StatusNotFound, "escalation not found or not pending")
+		return
+	}
+	if !policy.MayDecideEscalation(approver, session) {
+		respondError(w, http.StatusForbidden, "only the approver "+approver+" can decide this escalation")
+		return
+	}
+
+	query := `UPDATE escalations SET status = 'active', approved_at = NOW()
+		 WHERE id = $1 AND wallet_id = $2 AND status = 'pending' AND expires_at > NOW()
+		 RETURNING ` + escalationColumns
+	eventType := "escalation.approved"
+	if !approve {
+		query = `UPDATE escalations SET status = 'rejected', ended_at = NOW()
+		 WHERE id = $1 AND wallet_id = $2 AND status = 'pending'
+		 RETURNING ` + escalationColumns
+		eventType = "escalation.rejected"
+	}
+
+	var e Escalation
+	if err := scanEscalation(h.db.QueryRow(r.Context(), query, escalationID, walletID), &e); err != nil {
+		respondError(w, http.StatusConflict, "escalation has expired")
+		return
+	}
+
+	details := map[string]interface{}{"escalation_id": e.ID, "approver": session}
+	if approve {
+		h.syncEscalation(r.Context(), &e, details)
+	}
+	h.logEscalation(r.Context(), &e, eventType, details)
+
+	respondJSON(w, http.StatusOK, e)
+}
+
+// RevokeEscalation ends an escalation early and reverts the on-chain limits.
+func (h *Handlers) RevokeEscalation(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	escalationID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid escalation id")
+		return
+	}
+
+	var previous string
+	err = h.db.QueryRow(r.Context(),
+		`SELECT status FROM escalations WHERE id = $1 AND wallet_id = $2 AND status IN ('pending', 'active')`,
+		escalationID, userID,
+	).Scan(&previous)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "escalation not found or already ended")
+		return
+	}
+
+	var e Escalation
+	err = scanEscalation(h.db.QueryRow(r.Context(),
+		`UPDATE escalations SET status = 'revoked', ended_at = NOW()
+		 WHERE id = $1 AND wallet_id = $2 AND status IN ('pending', 'active')
+		 RETURNING `+escalationColumns,
+		escalationID, userID,
+	), &e)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "escalation not found or already ended")
+		return
+	}
+
+	details := map[string]interface{}{"escalation_id": e.ID}
+	if previous == "active" {
+		h.syncEscalation(r.Context(), &e, details)
+		h.revokeBundles(r.Context(), userID)
+	}
+	h.logEscalation(r.Context(), &e, "escalation.revoked", details)
+
+	respondJSON(w, http.StatusOK, e)
+}
+
+// syncEscalation re-syncs the permission's constraints, which include the
+// escalation while it is active. Failures are recorded in details; off-chain
+// validation already reflects the change.
+func (h *Handlers) syncEscalation(ctx context.Context, e *Escalation, details map[string]interface{}) {
+	if h.onchainSyncer == nil {
+		return
+	}
+	var agentID uuid.UUID
+	var minted bool
+	if err := h.db.QueryRow(ctx,
+		`SELECT agent_id, onchain_token_id IS NOT NULL FROM permissions WHERE id = $1`, e.PermissionID,
+	).Scan(&agentID, &minted); err != nil || !minted {
+		return
+	}
+	if err := h.onchainSyncer.SyncConstraints(ctx, e.PermissionID, agentID); err != nil {
+		h.logger.Error().Err(err).Str("escalation_id", e.ID.String()).Msg("escalation constraint sync failed")
+		details["onchain_sync_error"] = err.Error()
+	}
+}
+
+func (h *Handlers) logEscalation(ctx context.Context, e *Escalation, eventType string, details map[string]interface{}) {
+	var agentID, policyID uuid.UUID
+	h.db.QueryRow(ctx,
+		`SELECT agent_id, policy_id FROM permissions WHERE id = $1`, e.PermissionID,
+	).Scan(&agentID, &policyID)
+
+	h.auditLogger.Log(ctx, audit.Event{
+		WalletID:     e.WalletID,
+		AgentID:      &agentID,
+		PolicyID:     &policyID,
+		PermissionID: &e.PermissionID,
+		EventType:    eventType,
+		Details:      details,
+	})
+}
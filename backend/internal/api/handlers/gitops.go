@@ -0,0 +1,442 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/erc8004/policy-saas/internal/api/middleware"
+	"github.com/erc8004/policy-saas/internal/domain/audit"
+	"github.com/erc8004/policy-saas/internal/domain/gitops"
+)
+
+const maxBundleSize = 1 << 20
+
+type GitopsChangeResult struct {
+	Resource string        `json:"resource"`
+	Action   gitops.Action `json:"action"`
+	Name     string        `json:"name"`
+	ID       *uuid.UUID    `json:"id,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+type GitopsApplyResponse struct {
+	Plan    *gitops.Plan         `json:"plan"`
+	Applied bool                 `json:"applied"`
+	Results []GitopsChangeResult `json:"results"`
+}
+
+// PlanBundle returns the changes needed to reconcile the wallet with a bundle.
+// POST /api/v1/gitops/plan
+func (h *Handlers) PlanBundle(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == uuid.Nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	plan, err := h.planBundle(w, r, userID)
+	if err != nil {
+		respondHandlerError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, plan)
+}
+
+// ApplyBundle plans a bundle and executes the plan. If plan_hash is given and
+// no longer matches (state or bundle changed since review), nothing is applied.
+// Apply stops at the first failing change; earlier changes stay applied.
+// POST /api/v1/gitops/apply?plan_hash=...
+func (h *Handlers) ApplyBundle(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == uuid.Nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	plan, err := h.planBundle(w, r, userID)
+	if err != nil {
+		respondHandlerError(w, err)
+		return
+	}
+
+	resp := GitopsApplyResponse{Plan: plan, Results: []GitopsChangeResult{}}
+	if expected := r.URL.Query().Get("plan_hash"); expected != "" && expected != plan.Hash {
+		respondJSON(w, http.StatusConflict, map[string]interface{}{
+			"error": "plan_hash does not match the current plan",
+			"plan":  plan,
+		})
+		return
+	}
+
+	ctx := r.Context()
+	agentIDs := make(map[string]uuid.UUID)
+	policyIDs := make(map[string]uuid.UUID)
+	for _, c := range plan.Changes {
+		if c.Action == gitops.ActionNoop {
+			continue
+		}
+		id, err := h.applyChange(ctx, userID, c, agentIDs, policyIDs)
+		result := GitopsChangeResult{Resource: c.Resource, Action: c.Action, Name: c.Name, ID: id}
+		if err != nil {
+			result.Error = err.Error()
+			resp.Results = append(resp.Results, result)
+			h.logger.Error().Err(err).Str("resource", c.Resource).Str("name", c.Name).Msg("gitops apply failed")
+
+			status := http.StatusInternalServerError
+			var he *handlerError
+			if errors.As(err, &he) {
+				status = he.status
+			}
+			respondJSON(w, status, resp)
+			return
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	resp.Applied = true
+
+	h.auditLogger.Log(ctx, audit.Event{
+		WalletID:  userID,
+		EventType: "gitops.applied",
+		Details:   map[string]interface{}{"plan_hash": plan.Hash, "summary": plan.Summary},
+	})
+
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// planBundle reads the bundle from the request body and plans it against the
+// wallet's current state. The format comes from ?format= or the Content-Type.
+func (h *Handlers) planBundle(w http.ResponseWriter, r *http.Request, walletID uuid.UUID) (*gitops.Plan, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBundleSize))
+	if err != nil {
+		return nil, newHandlerError(http.StatusBadRequest, "failed to read bundle")
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		contentType := r.Header.Get("Content-Type")
+		switch {
+		case strings.Contains(contentType, "yaml"):
+			format = "yaml"
+		case strings.Contains(contentType, "json"):
+			format = "json"
+		}
+	}
+
+	bundle, err := gitops.ParseBundle(body, format)
+	if err != nil {
+		return nil, newHandlerError(http.StatusBadRequest, err.Error())
+	}
+
+	state, err := h.loadGitopsState(r.Context(), walletID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("failed to load gitops state")
+		return nil, newHandlerError(http.StatusInternalServerError, "failed to load current state")
+	}
+
+	plan, err := gitops.BuildPlan(bundle, state)
+	if err != nil {
+		return nil, newHandlerError(http.StatusBadRequest, err.Error())
+	}
+
+	for _, c := range plan.Changes {
+		if c.Policy == nil {
+			continue
+		}
+		if err := h.policyEngine.ValidateDefinition(&c.Policy.Definition); err != nil {
+			return nil, newHandlerError(http.StatusBadRequest, "policy "+c.Name+": "+err.Error())
+		}
+	}
+
+	return plan, nil
+}
+
+func (h *Handlers) loadGitopsState(ctx context.Context, walletID uuid.UUID) (*gitops.State, error) {
+	state := &gitops.State{}
+
+	rows, err := h.db.Query(ctx,
+		`SELECT id, name, COALESCE(description, ''), entries, managed_by IS NOT NULL
+		 FROM address_books WHERE wallet_id = $1 ORDER BY name`,
+		walletID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var ab gitops.AddressBookState
+		var entries []byte
+		if err := rows.Scan(&ab.ID, &ab.Name, &ab.Description, &entries, &ab.Managed); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		json.Unmarshal(entries, &ab.Entries)
+		state.AddressBooks = append(state.AddressBooks, ab)
+	}
+	rows.Close()
+
+	rows, err = h.db.Query(ctx,
+		`SELECT id, name, COALESCE(description, ''), COALESCE(agent_address, ''), status, managed_by IS NOT NULL
+		 FROM agents WHERE wallet_id = $1 AND status != 'deleted' ORDER BY created_at`,
+		walletID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var a gitops.AgentState
+		if err := rows.Scan(&a.ID, &a.Name, &a.Description, &a.AgentAddress, &a.Status, &a.Managed); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		state.Agents = append(state.Agents, a)
+	}
+	rows.Close()
+
+	rows, err = h.db.Query(ctx,
+		`SELECT id, name, COALESCE(description, ''), definition, status, version, managed_by IS NOT NULL
+		 FROM policies WHERE wallet_id = $1 AND status != 'deleted' ORDER BY created_at`,
+		walletID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var p gitops.PolicyState
+		var defBytes []byte
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &defBytes, &p.Status, &p.Version, &p.Managed); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		json.Unmarshal(defBytes, &p.Definition)
+		state.Policies = append(state.Policies, p)
+	}
+	rows.Close()
+
+	rows, err = h.db.Query(ctx,
+		`SELECT id, agent_id, policy_id, valid_from, valid_until, onchain_token_id IS NOT NULL, managed_by IS NOT NULL
+		 FROM permissions WHERE wallet_id = $1 AND status = 'active' ORDER BY created_at`,
+		walletID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p gitops.PermissionState
+		if err := rows.Scan(&p.ID, &p.AgentID, &p.PolicyID, &p.ValidFrom, &p.ValidUntil, &p.Minted, &p.Managed); err != nil {
+			return nil, err
+		}
+		state.Permissions = append(state.Permissions, p)
+	}
+
+	return state, rows.Err()
+}
+
+// applyChange executes one planned change through the same helpers the
+// resource endpoints use, so validation, on-chain calls and audit events match.
+func (h *Handlers) applyChange(ctx context.Context, walletID uuid.UUID, c gitops.Change, agentIDs, policyIDs map[string]uuid.UUID) (*uuid.UUID, error) {
+	switch c.Resource {
+	case gitops.ResourceAddressBook:
+		return h.applyAddressBook(ctx, walletID, c)
+
+	case gitops.ResourceAgent:
+		var agent Agent
+		var err error
+		if c.Action == gitops.ActionCreate {
+			agent, err = h.createAgent(ctx, walletID, CreateAgentRequest{
+				Name:         c.Agent.Name,
+				Description:  c.Agent.Description,
+				AgentAddress: c.Agent.AgentAddress,
+			})
+		} else {
+			req := UpdateAgentRequest{Description: &c.Agent.Description}
+			if c.Agent.AgentAddress != "" {
+				req.AgentAddress = &c.Agent.AgentAddress
+			}
+			agent, err = h.updateAgent(ctx, walletID, *c.ID, req)
+		}
+		if err != nil {
+			return c.ID, err
+		}
+		agentIDs[c.Name] = agent.ID
+		return &agent.ID, h.markManaged(ctx, "agents", agent.ID)
+
+	case gitops.ResourcePolicy:
+		return h.applyPolicy(ctx, walletID, c, policyIDs)
+
+	case gitops.ResourcePermission:
+		if c.Action == gitops.ActionRevoke {
+			return c.ID, h.revokePermission(ctx, walletID, *c.ID)
+		}
+		if c.Transition == "remint" {
+			// The minted grant keeps its window on-chain, so it is rotated
+			// to a minted successor with the new one.
+			resp, err := h.rotatePermission(ctx, walletID, *c.ID, RotatePermissionRequest{
+				ValidFrom:  c.Permission.ValidFrom,
+				ValidUntil: c.Permission.ValidUntil,
+			})
+			if err != nil {
+				return c.ID, err
+			}
+			return &resp.Successor.ID, h.markManaged(ctx, "permissions", resp.Successor.ID)
+		}
+		if c.Action == gitops.ActionUpdate {
+			_, err := h.db.Exec(ctx,
+				`UPDATE permissions SET valid_from = COALESCE($1, valid_from), valid_until = $2, managed_by = $3
+				 WHERE id = $4 AND wallet_id = $5 AND status = 'active'`,
+				c.Permission.ValidFrom, c.Permission.ValidUntil, gitops.ManagedBy, *c.ID, walletID,
+			)
+			if err != nil {
+				return c.ID, newHandlerError(http.StatusInternalServerError, "failed to update permission")
+			}
+			h.auditLogger.Log(ctx, audit.Event{
+				WalletID:     walletID,
+				PermissionID: c.ID,
+				EventType:    "permission.updated",
+				Details:      map[string]interface{}{"valid_from": c.Permission.ValidFrom, "valid_until": c.Permission.ValidUntil},
+			})
+			return c.ID, nil
+		}
+
+		req := CreatePermissionRequest{ValidFrom: c.Permission.ValidFrom, ValidUntil: c.Permission.ValidUntil}
+		if id, ok := agentIDs[c.Permission.Agent]; ok {
+			req.AgentID = id
+		} else if c.AgentID != nil {
+			req.AgentID = *c.AgentID
+		}
+		if id, ok := policyIDs[c.Permission.Policy]; ok {
+			req.PolicyID = id
+		} else if c.PolicyID != nil {
+			req.PolicyID = *c.PolicyID
+		}
+		perm, err := h.createPermission(ctx, walletID, req)
+		if err != nil {
+			return nil, err
+		}
+		return &perm.ID, h.markManaged(ctx, "permissions", perm.ID)
+	}
+
+	return nil, newHandlerError(http.StatusBadRequest, "unknown resource: "+c.Resource)
+}
+
+func (h *Handlers) applyPolicy(ctx context.Context, walletID uuid.UUID, c gitops.Change, policyIDs map[string]uuid.UUID) (*uuid.UUID, error) {
+	switch c.Action {
+	case gitops.ActionRevoke:
+		_, err := h.revokePolicy(ctx, walletID, *c.ID)
+		return c.ID, err
+
+	case gitops.ActionDelete:
+		result, err := h.db.Exec(ctx,
+			`UPDATE policies SET status = 'deleted', updated_at = NOW() WHERE id = $1 AND wallet_id = $2 AND status = 'draft'`,
+			*c.ID, walletID,
+		)
+		if err != nil || result.RowsAffected() == 0 {
+			return c.ID, newHandlerError(http.StatusConflict, "policy is no longer a draft")
+		}
+		h.auditLogger.Log(ctx, audit.Event{
+			WalletID:  walletID,
+			PolicyID:  c.ID,
+			EventType: "policy.deleted",
+		})
+		return c.ID, nil
+
+	case gitops.ActionCreate:
+		p, err := h.createPolicy(ctx, walletID, CreatePolicyRequest{
+			Name:        c.Policy.Name,
+			Description: c.Policy.Description,
+			Definition:  c.Policy.Definition,
+		})
+		if err != nil {
+			return nil, err
+		}
+		policyIDs[c.Name] = p.ID
+		if err := h.markManaged(ctx, "policies", p.ID); err != nil {
+			return &p.ID, err
+		}
+		if c.Policy.DesiredStatus() == "active" {
+			if _, err := h.activatePolicy(ctx, walletID, p.ID, ""); err != nil {
+				return &p.ID, err
+			}
+		}
+		return &p.ID, nil
+	}
+
+	// Update: write fields first so activation registers the new definition.
+	policyIDs[c.Name] = *c.ID
+	for _, d := range c.Diff {
+		if strings.HasPrefix(d.Path, "definition") || d.Path == "description" {
+			if _, err := h.updatePolicy(ctx, walletID, *c.ID, UpdatePolicyRequest{
+				Description: &c.Policy.Description,
+				Definition:  &c.Policy.Definition,
+			}); err != nil {
+				return c.ID, err
+			}
+			break
+		}
+	}
+	switch c.Transition {
+	case "activate":
+		if _, err := h.activatePolicy(ctx, walletID, *c.ID, ""); err != nil {
+			return c.ID, err
+		}
+	case "reactivate":
+		if _, err := h.reactivatePolicy(ctx, walletID, *c.ID); err != nil {
+			return c.ID, err
+		}
+	}
+	return c.ID, h.markManaged(ctx, "policies", *c.ID)
+}
+
+func (h *Handlers) applyAddressBook(ctx context.Context, walletID uuid.UUID, c gitops.Change) (*uuid.UUID, error) {
+	if c.Action == gitops.ActionDelete {
+		if _, err := h.db.Exec(ctx, `DELETE FROM address_books WHERE id = $1 AND wallet_id = $2 AND managed_by IS NOT NULL`, *c.ID, walletID); err != nil {
+			return c.ID, newHandlerError(http.StatusInternalServerError, "failed to delete address book")
+		}
+		h.auditLogger.Log(ctx, audit.Event{
+			WalletID:  walletID,
+			EventType: "address_book.deleted",
+			Details:   map[string]interface{}{"name": c.Name},
+		})
+		return c.ID, nil
+	}
+
+	entries, _ := json.Marshal(c.AddressBook.Entries)
+	var id uuid.UUID
+	err := h.db.QueryRow(ctx,
+		`INSERT INTO address_books (wallet_id, name, description, entries, managed_by)
+		 VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (wallet_id, name) DO UPDATE SET description = EXCLUDED.description, entries = EXCLUDED.entries, managed_by = EXCLUDED.managed_by, updated_at = NOW()
+		 RETURNING id`,
+		walletID, c.AddressBook.Name, c.AddressBook.Description, entries, gitops.ManagedBy,
+	).Scan(&id)
+	if err != nil {
+		return c.ID, newHandlerError(http.StatusInternalServerError, "failed to save address book")
+	}
+
+	eventType := "address_book.updated"
+	if c.Action == gitops.ActionCreate {
+		eventType = "address_book.created"
+	}
+	h.auditLogger.Log(ctx, audit.Event{
+		WalletID:  walletID,
+		EventType: eventType,
+		Details:   map[string]interface{}{"name": c.Name, "entries": len(c.AddressBook.Entries)},
+	})
+	return &id, nil
+}
+
+// markManaged flags a resource as owned by bundle apply. table is always a
+// constant from this file, never user input.
+func (h *Handlers) markManaged(ctx context.Context, table string, id uuid.UUID) error {
+	_, err := h.db.Exec(ctx, `UPDATE `+table+` SET managed_by = $1 WHERE id = $2`, gitops.ManagedBy, id)
+	if err != nil {
+		return newHandlerError(http.StatusInternalServerError, "failed to mark "+table+" as managed")
+	}
+	return nil
+}
@@ -0,0 +1,415 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/erc8004/policy-saas/internal/api/middleware"
+	"github.com/erc8004/policy-saas/internal/domain/audit"
+)
+
+// GroupGrant is a policy granted to every member of an agent group. Members
+// hold it through ordinary permissions linked by group_grant_id, so
+// validation, minting and constraint sync treat them like any other grant.
+type GroupGrant struct {
+	ID          uuid.UUID    `json:"id"`
+	WalletID    uuid.UUID    `json:"wallet_id"`
+	GroupID     uuid.UUID    `json:"group_id"`
+	PolicyID    uuid.UUID    `json:"policy_id"`
+	Status      string       `json:"status"`
+	ValidFrom   time.Time    `json:"valid_from"`
+	ValidUntil  *time.Time   `json:"valid_until,omitempty"`
+	AutoMint    bool         `json:"auto_mint"`
+	CreatedAt   time.Time    `json:"created_at"`
+	RevokedAt   *time.Time   `json:"revoked_at,omitempty"`
+	Permissions []Permission `json:"permissions"`
+}
+
+type CreateGroupGrantRequest struct {
+	PolicyID   uuid.UUID  `json:"policy_id"`
+	ValidFrom  *time.Time `json:"valid_from,omitempty"`
+	ValidUntil *time.Time `json:"valid_until,omitempty"`
+}
+
+type GroupMintResult struct {
+	PermissionID uuid.UUID `json:"permission_id"`
+	AgentID      uuid.UUID `json:"agent_id"`
+	Minted       bool      `json:"minted"`
+	Error        string    `json:"error,omitempty"`
+}
+
+type GroupMintResponse struct {
+	Grant   GroupGrant        `json:"grant"`
+	Results []GroupMintResult `json:"results"`
+}
+
+const groupGrantColumns = `id, wallet_id, group_id, policy_id, status, valid_from, valid_until, auto_mint, created_at, revoked_at`
+
+func scanGroupGrant(row interface{ Scan(...any) error }, g *GroupGrant) error {
+	if err := row.Scan(&g.ID, &g.WalletID, &g.GroupID, &g.PolicyID, &g.Status, &g.ValidFrom, &g.ValidUntil, &g.AutoMint, &g.CreatedAt, &g.RevokedAt); err != nil {
+		return err
+	}
+	g.Permissions = []Permission{}
+	return nil
+}
+
+// normalizeTags lowercases, trims and de-duplicates tags.
+func normalizeTags(tags []string) []string {
+	out := []string{}
+	seen := map[string]bool{}
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (h *Handlers) loadGrantPermissions(ctx context.Context, g *GroupGrant) error {
+	rows, err := h.db.Query(ctx,
+		`SELECT `+permissionColumns+` FROM permissions WHERE group_grant_id = $1 ORDER BY created_at`,
+		g.ID,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p Permission
+		if err := scanPermission(rows, &p); err != nil {
+			continue
+		}
+		g.Permissions = append(g.Permissions, p)
+	}
+	return rows.Err()
+}
+
+// reconcileTaggedGroups refreshes every group of the wallet that selects
+// members by tag, after an agent's tags or status changed.
+func (h *Handlers) reconcileTaggedGroups(ctx context.Context, walletID uuid.UUID) {
+	rows, err := h.db.Query(ctx,
+		`SELECT id FROM agent_groups WHERE wallet_id = $1 AND cardinality(match_tags) > 0`,
+		walletID,
+	)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("failed to load tagged groups")
+		return
+	}
+	var groupIDs []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err == nil {
+			groupIDs = append(groupIDs, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range groupIDs {
+		h.reconcileGroup(ctx, walletID, id)
+	}
+}
+
+// reconcileGroup brings a group's tag-matched members and its members'
+// permissions in line with its grants: members without a permission for an
+// active grant get one (minted too when the grant auto-mints), and
+// permissions of agents that left the group or of revoked grants are revoked.
+// Failures are logged; the next change to the group retries them.
+func (h *Handlers) reconcileGroup(ctx context.Context, walletID, groupID uuid.UUID) {
+	if _, err := h.db.Exec(ctx,
+		`INSERT INTO agent_group_members (group_id, agent_id, source)
+		 SELECT g.id, a.id, 'tag' FROM agent_groups g
+		 JOIN agents a ON a.wallet_id = g.wallet_id AND a.status = 'active' AND a.tags && g.match_tags
+		 WHERE g.id = $1 AND g.wallet_id = $2
+		 ON CONFLICT (group_id, agent_id) DO NOTHING`,
+		groupID, walletID,
+	); err != nil {
+		h.logger.Error().Err(err).Str("group_id", groupID.String()).Msg("failed to add tagged members")
+	}
+	if _, err := h.db.Exec(ctx,
+		`DELETE FROM agent_group_members m USING agent_groups g
+		 WHERE m.group_id = g.id AND g.id = $1 AND g.wallet_id = $2 AND m.source = 'tag'
+		 AND NOT EXISTS (SELECT 1 FROM agents a WHERE a.id = m.agent_id AND a.status = 'active' AND a.tags && g.match_tags)`,
+		groupID, walletID,
+	); err != nil {
+		h.logger.Error().Err(err).Str("group_id", groupID.String()).Msg("failed to remove untagged members")
+	}
+
+	// Members missing a permission for an active grant
+	type missing struct {
+		grant GroupGrant
+		agent uuid.UUID
+	}
+	rows, err := h.db.Query(ctx,
+		`SELECT gg.id, gg.policy_id, gg.valid_from, gg.valid_until, gg.auto_mint, m.agent_id
+		 FROM group_grants gg
+		 JOIN agent_group_members m ON m.group_id = gg.group_id
+		 JOIN agents a ON a.id = m.agent_id AND a.status = 'active'
+		 WHERE gg.group_id = $1 AND gg.wallet_id = $2 AND gg.status = 'active'
+		 AND NOT EXISTS (SELECT 1 FROM permissions p WHERE p.group_grant_id = gg.id AND p.agent_id = m.agent_id AND p.status = 'active')`,
+		groupID, walletID,
+	)
+	if err != nil {
+		h.logger.Error().Err(err).Str("group_id", groupID.String()).Msg("failed to load group grants")
+		return
+	}
+	var toGrant []missing
+	for rows.Next() {
+		var m missing
+		if err := rows.Scan(&m.grant.ID, &m.grant.PolicyID, &m.grant.ValidFrom, &m.grant.ValidUntil, &m.grant.AutoMint, &m.agent); err == nil {
+			toGrant = append(toGrant, m)
+		}
+	}
+	rows.Close()
+
+	for _, m := range toGrant {
+		grantID := m.grant.ID
+		perm, err := h.createPermission(ctx, walletID, CreatePermissionRequest{
+			AgentID:      m.agent,
+			PolicyID:     m.grant.PolicyID,
+			ValidFrom:    &m.grant.ValidFrom,
+			ValidUntil:   m.grant.ValidUntil,
+			GroupGrantID: &grantID,
+		})
+		if err != nil {
+			h.logger.Error().Err(err).Str("group_grant_id", grantID.String()).Str("agent_id", m.agent.String()).Msg("failed to grant group permission")
+			continue
+		}
+		if m.grant.AutoMint {
+			if _, err := h.mintPermission(ctx, walletID, perm.ID); err != nil {
+				h.logger.Error().Err(err).Str("permission_id", perm.ID.String()).Msg("failed to mint group permission")
+			}
+		}
+	}
+
+	// Permissions that no longer belong: the agent left or the grant was revoked
+	rows, err = h.db.Query(ctx,
+		`SELECT p.id FROM permissions p
+		 JOIN group_grants gg ON gg.id = p.group_grant_id
+		 WHERE gg.group_id = $1 AND gg.wallet_id = $2 AND p.status = 'active'
+		 AND (gg.status != 'active' OR NOT EXISTS (
+		     SELECT 1 FROM agent_group_members m WHERE m.group_id = gg.group_id AND m.agent_id = p.agent_id))`,
+		groupID, walletID,
+	)
+	if err != nil {
+		h.logger.Error().Err(err).Str("group_id", groupID.String()).Msg("failed to load stale group permissions")
+		return
+	}
+	var toRevoke []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err == nil {
+			toRevoke = append(toRevoke, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range toRevoke {
+		if err := h.revokePermission(ctx, walletID, id); err != nil {
+			h.logger.Error().Err(err).Str("permission_id", id.String()).Msg("failed to revoke group permission")
+		}
+	}
+}
+
+// CreateGroupGrant grants a policy to all current and future members of a group.
+func (h *Handlers) CreateGroupGrant(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid group id")
+		return
+	}
+
+	var req CreateGroupGrantRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	var ok bool
+	h.db.QueryRow(r.Context(),
+		`SELECT EXISTS(SELECT 1 FROM agent_groups WHERE id = $1 AND wallet_id = $3)
+		    AND EXISTS(SELECT 1 FROM policies WHERE id = $2 AND wallet_id = $3 AND status = 'active')`,
+		groupID, req.PolicyID, userID,
+	).Scan(&ok)
+	if !ok {
+		respondError(w, http.StatusBadRequest, "group not found or policy not found or not active")
+		return
+	}
+
+	validFrom := time.Now()
+	if req.ValidFrom != nil {
+		validFrom = *req.ValidFrom
+	}
+
+	var g GroupGrant
+	err = scanGroupGrant(h.db.QueryRow(r.Context(),
+		`INSERT INTO group_grants (wallet_id, group_id, policy_id, valid_from, valid_until)
+		 VALUES ($1, $2, $3, $4, $5)
+		 RETURNING `+groupGrantColumns,
+		userID, groupID, req.PolicyID, validFrom, req.ValidUntil,
+	), &g)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("failed to create group grant")
+		respondError(w, http.StatusInternalServerError, "failed to create group grant")
+		return
+	}
+
+	h.auditLogger.Log(r.Context(), audit.Event{
+		WalletID:  userID,
+		PolicyID:  &g.PolicyID,
+		EventType: "group_grant.created",
+		Details:   map[string]interface{}{"group_grant_id": g.ID, "group_id": groupID},
+	})
+
+	h.reconcileGroup(r.Context(), userID, groupID)
+	h.loadGrantPermissions(r.Context(), &g)
+
+	respondJSON(w, http.StatusCreated, g)
+}
+
+func (h *Handlers) ListGroupGrants(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid group id")
+		return
+	}
+
+	rows, err := h.db.Query(r.Context(),
+		`SELECT `+groupGrantColumns+` FROM group_grants WHERE group_id = $1 AND wallet_id = $2 ORDER BY created_at DESC`,
+		groupID, userID,
+	)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list group grants")
+		return
+	}
+
+	var grants []GroupGrant
+	for rows.Next() {
+		var g GroupGrant
+		if err := scanGroupGrant(rows, &g); err != nil {
+			continue
+		}
+		grants = append(grants, g)
+	}
+	rows.Close()
+
+	for i := range grants {
+		if err := h.loadGrantPermissions(r.Context(), &grants[i]); err != nil {
+			respondError(w, http.StatusInternalServerError, "failed to load group grant permissions")
+			return
+		}
+	}
+
+	if grants == nil {
+		grants = []GroupGrant{}
+	}
+
+	respondJSON(w, http.StatusOK, grants)
+}
+
+// RevokeGroupGrant revokes a group grant and every member permission from it.
+func (h *Handlers) RevokeGroupGrant(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid group id")
+		return
+	}
+	grantID, err := uuid.Parse(r.PathValue("grantId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid group grant id")
+		return
+	}
+
+	var g GroupGrant
+	err = scanGroupGrant(h.db.QueryRow(r.Context(),
+		`UPDATE group_grants SET status = 'revoked', revoked_at = NOW()
+		 WHERE id = $1 AND group_id = $2 AND wallet_id = $3 AND status = 'active'
+		 RETURNING `+groupGrantColumns,
+		grantID, groupID, userID,
+	), &g)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "group grant not found or not active")
+		return
+	}
+
+	h.auditLogger.Log(r.Context(), audit.Event{
+		WalletID:  userID,
+		PolicyID:  &g.PolicyID,
+		EventType: "group_grant.revoked",
+		Details:   map[string]interface{}{"group_grant_id": g.ID, "group_id": groupID},
+	})
+
+	h.reconcileGroup(r.Context(), userID, groupID)
+	h.loadGrantPermissions(r.Context(), &g)
+
+	respondJSON(w, http.StatusOK, g)
+}
+
+// MintGroupGrant mints every unminted member permission of a group grant and
+// turns on auto-mint, so agents that join later are minted as they join.
+func (h *Handlers) MintGroupGrant(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid group id")
+		return
+	}
+	grantID, err := uuid.Parse(r.PathValue("grantId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid group grant id")
+		return
+	}
+
+	var g GroupGrant
+	err = scanGroupGrant(h.db.QueryRow(r.Context(),
+		`UPDATE group_grants SET auto_mint = true
+		 WHERE id = $1 AND group_id = $2 AND wallet_id = $3 AND status = 'active'
+		 RETURNING `+groupGrantColumns,
+		grantID, groupID, userID,
+	), &g)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "group grant not found or not active")
+		return
+	}
+	if err := h.loadGrantPermissions(r.Context(), &g); err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to load group grant permissions")
+		return
+	}
+
+	results := []GroupMintResult{}
+	for i, p := range g.Permissions {
+		if p.Status != "active" || p.MintedAt != nil {
+			continue
+		}
+		result := GroupMintResult{PermissionID: p.ID, AgentID: p.AgentID}
+		minted, err := h.mintPermission(r.Context(), userID, p.ID)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Minted = true
+			g.Permissions[i] = minted
+		}
+		results = append(results, result)
+	}
+
+	h.auditLogger.Log(r.Context(), audit.Event{
+		WalletID:  userID,
+		PolicyID:  &g.PolicyID,
+		EventType: "group_grant.minted",
+		Details:   map[string]interface{}{"group_grant_id": g.ID, "group_id": groupID, "results": results},
+	})
+
+	respondJSON(w, http.StatusOK, GroupMintResponse{Grant: g, Results: results})
+}
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/erc8004/policy-saas/internal/api/middleware"
+	"github.com/erc8004/policy-saas/internal/domain/audit"
+	"github.com/erc8004/policy-saas/internal/domain/policy"
+)
+
+type Guardrail struct {
+	ID          uuid.UUID        `json:"id"`
+	WalletID    uuid.UUID        `json:"wallet_id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Definition  policy.Guardrail `json:"definition"`
+	Status      string           `json:"status"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+type CreateGuardrailRequest struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Definition  policy.Guardrail `json:"definition"`
+}
+
+type UpdateGuardrailRequest struct {
+	Name        *string           `json:"name,omitempty"`
+	Description *string           `json:"description,omitempty"`
+	Definition  *policy.Guardrail `json:"definition,omitempty"`
+	Status      *string           `json:"status,omitempty"`
+}
+
+const guardrailColumns = `id, wallet_id, name, COALESCE(description, ''), definition, status, created_at, updated_at`
+
+func scanGuardrail(row interface{ Scan(...any) error }, g *Guardrail) error {
+	var defBytes []byte
+	if err := row.Scan(&g.ID, &g.WalletID, &g.Name, &g.Description, &defBytes, &g.Status, &g.CreatedAt, &g.UpdatedAt); err != nil {
+		return err
+	}
+	json.Unmarshal(defBytes, &g.Definition)
+	return nil
+}
+
+func (h *Handlers) CreateGuardrail(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == uuid.Nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req CreateGuardrailRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if req.Name == "" {
+		respondError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	if err := policy.ValidateGuardrail(&req.Definition); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	defBytes, _ := json.Marshal(req.Definition)
+	var g Guardrail
+	err := scanGuardrail(h.db.QueryRow(r.Context(),
+		`INSERT INTO guardrails (wallet_id, name, description, definition, status)
+		 VALUES ($1, $2, $3, $4, 'active')
+		 RETURNING `+guardrailColumns,
+		userID, req.Name, req.Description, defBytes,
+	), &g)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("failed to create guardrail")
+		respondError(w, http.StatusInternalServerError, "failed to create guardrail")
+		return
+	}
+
+	h.auditLogger.Log(r.Context(), audit.Event{
+		WalletID:  userID,
+		EventType: "guardrail.created",
+		Details:   map[string]interface{}{"guardrail_id": g.ID, "name": g.Name, "definition": g.Definition},
+	})
+
+	h.resyncOnchainConstraints(r.Context(), userID)
+	h.revokeBundles(r.Context(), userID)
+
+	respondJSON(w, http.StatusCreated, g)
+}
+
+func (h *Handlers) ListGuardrails(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == uuid.Nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	rows, err := h.db.Query(r.Context(),
+		`SELECT `+guardrailColumns+` FROM guardrails WHERE wallet_id = $1 ORDER BY created_at DESC`,
+		userID,
+	)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list guardrails")
+		return
+	}
+	defer rows.Close()
+
+	var guardrails []Guardrail
+	for rows.Next() {
+		var g Guardrail
+		if err := scanGuardrail(rows, &g); err != nil {
+			continue
+		}
+		guardrails = append(guardrails, g)
+	}
+
+	if guardrails == nil {
+		guardrails = []Guardrail{}
+	}
+
+	respondJSON(w, http.StatusOK, guardrails)
+}
+
+func (h *Handlers) GetGuardrail(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	guardrailIDStr := r.PathValue("id")
+
+	guardrailID, err := uuid.Parse(guardrailIDStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid guardrail id")
+		return
+	}
+
+	var g Guardrail
+	err = scanGuardrail(h.db.QueryRow(r.Context(),
+		`SELECT `+guardrailColumns+` FROM guardrails WHERE id = $1 AND wallet_id = $2`,
+		guardrailID, userID,
+	), &g)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "guardrail not found")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, g)
+}
+
+func (h *Handlers) UpdateGuardrail(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	guardrailIDStr := r.PathValue("id")
+
+	guardrailID, err := uuid.Parse(guardrailIDStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid guardrail id")
+		return
+	}
+
+	var req UpdateGuardrailRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	var defBytes []byte
+	if req.Definition != nil {
+		if err := policy.ValidateGuardrail(req.Definition); err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		defBytes, _ = json.Marshal(req.Definition)
+	}
+	if req.Status != nil && *req.Status != "active" && *req.Status != "disabled" {
+		respondError(w, http.StatusBadRequest, "status must be active or disabled")
+		return
+	}
+
+	var g Guardrail
+	err = scanGuardrail(h.db.QueryRow(r.Context(),
+		`UPDATE guardrails SET
+			name = COALESCE($1, name),
+			description = COALESCE($2, description),
+			definition = COALESCE($3, definition),
+			status = COALESCE($4, status),
+			updated_at = NOW()
+		 WHERE id = $5 AND wallet_id = $6
+		 RETURNING `+guardrailColumns,
+		req.Name, req.Description, defBytes, req.Status, guardrailID, userID,
+	), &g)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "guardrail not found")
+		return
+	}
+
+	h.auditLogger.Log(r.Context(), audit.Event{
+		WalletID:  userID,
+		EventType: "guardrail.updated",
+		Details:   map[string]interface{}{"guardrail_id": g.ID, "status": g.Status, "definition": g.Definition},
+	})
+
+	if req.Definition != nil || req.Status != nil {
+		h.resyncOnchainConstraints(r.Context(), userID)
+		h.revokeBundles(r.Context(), userID)
+	}
+
+	respondJSON(w, http.StatusOK, g)
+}
+
+func (h *Handlers) DeleteGuardrail(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	guardrailIDStr := r.PathValue("id")
+
+	guardrailID, err := uuid.Parse(guardrailIDStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid guardrail id")
+		return
+	}
+
+	result, err := h.db.Exec(r.Context(),
+		`DELETE FROM guardrails WHERE id = $1 AND wallet_id = $2`,
+		guardrailID, userID,
+	)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to delete guardrail")
+		return
+	}
+
+	if result.RowsAffected() == 0 {
+		respondError(w, http.StatusNotFound, "guardrail not found")
+		return
+	}
+
+	h.auditLogger.Log(r.Context(), audit.Event{
+		WalletID:  userID,
+		EventType: "guardrail.deleted",
+		Details:   map[string]interface{}{"guardrail_id": guardrailID},
+	})
+
+	h.resyncOnchainConstraints(r.Context(), userID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resyncOnchainConstraints pushes constraints again for every minted, active
+// permission of the wallet so on-chain enforcement reflects guardrail changes.
+// Failures are logged; off-chain validation already applies the new guardrails.
+func (h *Handlers) resyncOnchainConstraints(ctx context.Context, walletID uuid.UUID) {
+	if h.onchainSyncer == nil {
+		return
+	}
+
+	rows, err := h.db.Query(ctx,
+		`SELECT id, agent_id FROM permissions
+		 WHERE wallet_id = $1 AND status = 'active' AND onchain_token_id IS NOT NULL`,
+		walletID,
+	)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("failed to list permissions for constraint resync")
+		return
+	}
+	type pending struct{ permID, agentID uuid.UUID }
+	var perms []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.permID, &p.agentID); err == nil {
+			perms = append(perms, p)
+		}
+	}
+	rows.Close()
+
+	for _, p := range perms {
+		if err := h.onchainSyncer.SyncConstraints(ctx, p.permID, p.agentID); err != nil {
+			h.logger.Warn().Err(err).Str("permission_id", p.permID.String()).Msg("constraint resync failed")
+		}
+	}
+}
@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"sync"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog"
@@ -21,18 +23,34 @@ type Handlers struct {
 	auditLogger   *audit.Logger
 	chainClients  *blockchain.MultiClient
 	onchainSyncer *policy.OnchainSyncer
+	// circuitBreaker trips rules after denials; its cooldown job runs from main.
+	circuitBreaker *policy.CircuitBreaker
+	// killSwitchRuns holds the IDs of kill switch jobs running in this process.
+	killSwitchRuns sync.Map
+	// serviceSigner signs validation receipts; nil when no key is configured.
+	serviceSigner *blockchain.ServiceSigner
 }
 
 func New(db *pgxpool.Pool, logger zerolog.Logger, cfg *config.Config) *Handlers {
 	mc := blockchain.NewMultiClient(cfg.Chains, cfg.Blockchain.ChainID, logger)
+	auditLogger := audit.NewLogger(db, logger)
+	var signer *blockchain.ServiceSigner
+	if cfg.Signing.Key != "" {
+		var err error
+		if signer, err = blockchain.NewServiceSigner(cfg.Signing.Key, cfg.Blockchain.ChainID); err != nil {
+			logger.Error().Err(err).Msg("SERVICE_SIGNING_KEY is invalid, signed receipts are disabled")
+		}
+	}
 	return &Handlers{
-		db:            db,
-		logger:        logger,
-		cfg:           cfg,
-		policyEngine:  policy.NewEngine(db, logger),
-		auditLogger:   audit.NewLogger(db, logger),
-		chainClients:  mc,
-		onchainSyncer: policy.NewOnchainSyncer(db, mc, logger),
+		db:             db,
+		logger:         logger,
+		cfg:            cfg,
+		policyEngine:   policy.NewEngine(db, logger),
+		auditLogger:    auditLogger,
+		chainClients:   mc,
+		onchainSyncer:  policy.NewOnchainSyncer(db, mc, logger),
+		circuitBreaker: policy.NewCircuitBreaker(db, mc, auditLogger, logger, 0),
+		serviceSigner:  signer,
 	}
 }
 
@@ -67,6 +85,32 @@ func respondError(w http.ResponseWriter, status int, message string) {
 	respondJSON(w, status, map[string]string{"error": message})
 }
 
+// handlerError carries an HTTP status alongside a client-facing message so that
+// shared helpers can be reused by several handlers and background flows.
+type handlerError struct {
+	status  int
+	message string
+}
+
+func newHandlerError(status int, message string) *handlerError {
+	return &handlerError{status: status, message: message}
+}
+
+func (e *handlerError) Error() string {
+	return e.message
+}
+
+// respondHandlerError writes err using its status when it is a handlerError,
+// falling back to 500 otherwise.
+func respondHandlerError(w http.ResponseWriter, err error) {
+	var he *handlerError
+	if errors.As(err, &he) {
+		respondError(w, he.status, he.message)
+		return
+	}
+	respondError(w, http.StatusInternalServerError, err.Error())
+}
+
 func decodeJSON(r *http.Request, v interface{}) error {
 	return json.NewDecoder(r.Body).Decode(v)
 }
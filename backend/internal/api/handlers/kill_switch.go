@@ -0,0 +1,719 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/erc8004/policy-saas/internal/api/middleware"
+	"github.com/erc8004/policy-saas/internal/blockchain"
+	"github.com/erc8004/policy-saas/internal/domain/audit"
+)
+
+// KillSwitchJob tracks one freeze or unfreeze of a wallet. The DB part runs
+// in the request; the on-chain steps run in the background.
+type KillSwitchJob struct {
+	ID             uuid.UUID        `json:"id"`
+	WalletID       uuid.UUID        `json:"wallet_id"`
+	Kind           string           `json:"kind"`
+	Status         string           `json:"status"`
+	Reason         *string          `json:"reason,omitempty"`
+	RequestedBy    *string          `json:"requested_by,omitempty"`
+	Remint         bool             `json:"remint"`
+	TotalSteps     int              `json:"total_steps"`
+	CompletedSteps int              `json:"completed_steps"`
+	FailedSteps    int              `json:"failed_steps"`
+	CreatedAt      time.Time        `json:"created_at"`
+	FinishedAt     *time.Time       `json:"finished_at,omitempty"`
+	Steps          []KillSwitchStep `json:"steps,omitempty"`
+}
+
+type KillSwitchStep struct {
+	ID        uuid.UUID  `json:"id"`
+	Kind      string     `json:"kind"`
+	TargetID  uuid.UUID  `json:"target_id"`
+	Status    string     `json:"status"`
+	TxHash    *string    `json:"tx_hash,omitempty"`
+	ResultID  *uuid.UUID `json:"result_id,omitempty"`
+	Error     *string    `json:"error,omitempty"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+type KillSwitchStatus struct {
+	Frozen       bool       `json:"frozen"`
+	FrozenAt     *time.Time `json:"frozen_at,omitempty"`
+	FrozenReason *string    `json:"frozen_reason,omitempty"`
+	// Restorable lists the grants revoked by a freeze that can still be
+	// restored.
+	Restorable []Permission `json:"restorable"`
+}
+
+type EngageKillSwitchRequest struct {
+	Reason string `json:"reason"`
+}
+
+type UnfreezeRequest struct {
+	// PermissionIDs are the frozen grants to restore; empty lifts the
+	// freeze without restoring any.
+	PermissionIDs []uuid.UUID `json:"permission_ids"`
+	// Remint mints restored grants that were minted before the freeze.
+	Remint bool `json:"remint"`
+}
+
+const killSwitchJobColumns = `j.id, j.wallet_id, j.kind, j.status, j.reason, j.requested_by, j.remint, j.created_at, j.finished_at,
+	(SELECT COUNT(*) FROM kill_switch_steps s WHERE s.job_id = j.id),
+	(SELECT COUNT(*) FROM kill_switch_steps s WHERE s.job_id = j.id AND s.status = 'done'),
+	(SELECT COUNT(*) FROM kill_switch_steps s WHERE s.job_id = j.id AND s.status = 'failed')`
+
+func scanKillSwitchJob(row interface{ Scan(...any) error }, j *KillSwitchJob) error {
+	return row.Scan(&j.ID, &j.WalletID, &j.Kind, &j.Status, &j.Reason, &j.RequestedBy, &j.Remint, &j.CreatedAt, &j.FinishedAt,
+		&j.TotalSteps, &j.CompletedSteps, &j.FailedSteps)
+}
+
+// killSwitchStepEvents names the audit event of each completed step kind.
+var killSwitchStepEvents = map[string]string{
+	"revoke_permission":  "kill_switch.permission_revoked",
+	"deactivate_agent":   "kill_switch.agent_deactivated",
+	"reactivate_agent":   "kill_switch.agent_reactivated",
+	"restore_permission": "kill_switch.permission_restored",
+}
+
+// checkNotFrozen rejects changes that would grant or re-enable access while
+// the wallet's kill switch is engaged.
+func (h *Handlers) checkNotFrozen(ctx context.Context, walletID uuid.UUID) error {
+	var frozen bool
+	h.db.QueryRow(ctx,
+		`SELECT frozen_at IS NOT NULL FROM wallets WHERE id = $1`, walletID,
+	).Scan(&frozen)
+	if frozen {
+		return newHandlerError(http.StatusConflict, "wallet is frozen by the emergency kill switch")
+	}
+	return nil
+}
+
+func (h *Handlers) GetKillSwitch(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == uuid.Nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var status KillSwitchStatus
+	err := h.db.QueryRow(r.Context(),
+		`SELECT frozen_at, frozen_reason FROM wallets WHERE id = $1`, userID,
+	).Scan(&status.FrozenAt, &status.FrozenReason)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "wallet not found")
+		return
+	}
+	status.Frozen = status.FrozenAt != nil
+
+	rows, err := h.db.Query(r.Context(),
+		`SELECT `+permissionColumns+` FROM permissions p
+		 WHERE wallet_id = $1 AND status = 'revoked' AND frozen_by IS NOT NULL
+		 AND (valid_until IS NULL OR valid_until > NOW())
+		 AND NOT EXISTS (SELECT 1 FROM kill_switch_steps s
+		                 WHERE s.kind = 'restore_permission' AND s.target_id = p.id AND s.status != 'failed')
+		 ORDER BY revoked_at DESC`,
+		userID,
+	)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list frozen permissions")
+		return
+	}
+	defer rows.Close()
+
+	status.Restorable = []Permission{}
+	for rows.Next() {
+		var p Permission
+		if err := scanPermission(rows, &p); err != nil {
+			continue
+		}
+		status.Restorable = append(status.Restorable, p)
+	}
+
+	respondJSON(w, http.StatusOK, status)
+}
+
+// EngageKillSwitch freezes the wallet: validation denies every agent at once,
+// every active grant is revoked and open escalations end. Minted grants are
+// then revoked on-chain and registered agents deactivated by a background
+// job. API keys may engage the switch; only a signed-in session may lift it.
+func (h *Handlers) EngageKillSwitch(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == uuid.Nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req EngageKillSwitchRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		respondError(w, http.StatusBadRequest, "reason is required")
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to engage kill switch")
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx,
+		`UPDATE wallets SET frozen_at = NOW(), frozen_reason = $2 WHERE id = $1 AND frozen_at IS NULL`,
+		userID, req.Reason,
+	)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to engage kill switch")
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		respondError(w, http.StatusConflict, "wallet is already frozen")
+		return
+	}
+
+	var jobID uuid.UUID
+	if err := tx.QueryRow(ctx,
+		`INSERT INTO kill_switch_jobs (wallet_id, kind, reason, requested_by)
+		 VALUES ($1, 'freeze', $2, $3) RETURNING id`,
+		userID, req.Reason, nilIfEmpty(strings.ToLower(middleware.GetWallet(ctx))),
+	).Scan(&jobID); err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to engage kill switch")
+		return
+	}
+
+	escalations, err := tx.Exec(ctx,
+		`UPDATE escalations SET status = 'revoked', ended_at = NOW()
+		 WHERE wallet_id = $1 AND status IN ('pending', 'active')`,
+		userID,
+	)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to engage kill switch")
+		return
+	}
+
+	rows, err := tx.Query(ctx,
+		`UPDATE permissions SET status = 'revoked', revoked_at = NOW(), frozen_by = $2
+		 WHERE wallet_id = $1 AND status = 'active'
+		 RETURNING id, onchain_token_id IS NOT NULL`,
+		userID, jobID,
+	)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to engage kill switch")
+		return
+	}
+	revoked := []uuid.UUID{}
+	var minted []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		var isMinted bool
+		if err := rows.Scan(&id, &isMinted); err != nil {
+			continue
+		}
+		revoked = append(revoked, id)
+		if isMinted {
+			minted = append(minted, id)
+		}
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		respondError(w, http.StatusInternalServerError, "failed to engage kill switch")
+		return
+	}
+
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO kill_switch_steps (job_id, kind, target_id)
+		 SELECT $1, 'revoke_permission', id FROM unnest($2::uuid[]) AS id`,
+		jobID, minted,
+	); err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to engage kill switch")
+		return
+	}
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO kill_switch_steps (job_id, kind, target_id)
+		 SELECT $1, 'deactivate_agent', id FROM agents
+		 WHERE wallet_id = $2 AND status != 'deleted' AND onchain_registry_id IS NOT NULL`,
+		jobID, userID,
+	); err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to engage kill switch")
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to engage kill switch")
+		return
+	}
+	h.revokeBundles(ctx, userID)
+
+	h.auditLogger.Log(ctx, audit.Event{
+		WalletID:  userID,
+		EventType: "kill_switch.engaged",
+		Priority:  audit.PriorityHigh,
+		Details: map[string]interface{}{
+			"job_id":              jobID,
+			"reason":              req.Reason,
+			"permissions_revoked": revoked,
+			"escalations_revoked": escalations.RowsAffected(),
+		},
+	})
+
+	h.startKillSwitchJob(userID, jobID)
+
+	job, err := h.loadKillSwitchJob(ctx, userID, jobID)
+	if err != nil {
+		respondHandlerError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusAccepted, job)
+}
+
+// Unfreeze lifts the kill switch and restores the selected frozen grants as
+// new permissions, reactivating their agents on-chain first. Only a
+// signed-in session may unfreeze, so a leaked API key cannot undo a freeze.
+// Further grants can be restored by calling it again after the freeze is
+// lifted.
+func (h *Handlers) Unfreeze(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == uuid.Nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	session := middleware.GetWallet(r.Context())
+	if session == "" {
+		respondError(w, http.StatusForbidden, "unfreezing requires a signed-in wallet session")
+		return
+	}
+
+	var req UnfreezeRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to unfreeze")
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var frozenAt *time.Time
+	if err := tx.QueryRow(ctx,
+		`SELECT frozen_at FROM wallets WHERE id = $1 FOR UPDATE`, userID,
+	).Scan(&frozenAt); err != nil {
+		respondError(w, http.StatusNotFound, "wallet not found")
+		return
+	}
+	if frozenAt == nil && len(req.PermissionIDs) == 0 {
+		respondError(w, http.StatusConflict, "wallet is not frozen")
+		return
+	}
+
+	var running bool
+	tx.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM kill_switch_jobs WHERE wallet_id = $1 AND kind = 'freeze' AND status = 'running')`,
+		userID,
+	).Scan(&running)
+	if running {
+		respondError(w, http.StatusConflict, "a freeze is still in progress; wait for its job to finish")
+		return
+	}
+
+	ids := uniqueUUIDs(req.PermissionIDs)
+	var restorable int
+	if err := tx.QueryRow(ctx,
+		`SELECT COUNT(*) FROM permissions
+		 WHERE wallet_id = $1 AND id = ANY($2) AND status = 'revoked' AND frozen_by IS NOT NULL
+		 AND (valid_until IS NULL OR valid_until > NOW())`,
+		userID, ids,
+	).Scan(&restorable); err != nil || restorable != len(ids) {
+		respondError(w, http.StatusBadRequest, "permission_ids must be unexpired grants revoked by the kill switch")
+		return
+	}
+
+	if _, err := tx.Exec(ctx,
+		`UPDATE wallets SET frozen_at = NULL, frozen_reason = NULL WHERE id = $1`, userID,
+	); err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to unfreeze")
+		return
+	}
+
+	var jobID uuid.UUID
+	if err := tx.QueryRow(ctx,
+		`INSERT INTO kill_switch_jobs (wallet_id, kind, requested_by, remint)
+		 VALUES ($1, 'unfreeze', $2, $3) RETURNING id`,
+		userID, strings.ToLower(session), req.Remint,
+	).Scan(&jobID); err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to unfreeze")
+		return
+	}
+
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO kill_switch_steps (job_id, kind, target_id)
+		 SELECT DISTINCT $1::uuid, 'reactivate_agent', a.id
+		 FROM permissions p JOIN agents a ON a.id = p.agent_id
+		 WHERE p.id = ANY($2) AND a.status != 'deleted' AND a.onchain_registry_id IS NOT NULL`,
+		jobID, ids,
+	); err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to unfreeze")
+		return
+	}
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO kill_switch_steps (job_id, kind, target_id)
+		 SELECT $1, 'restore_permission', id FROM unnest($2::uuid[]) AS id`,
+		jobID, ids,
+	); err != nil {
+		respondError(w, http.StatusConflict, "some permissions are already restored")
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to unfreeze")
+		return
+	}
+
+	h.auditLogger.Log(ctx, audit.Event{
+		WalletID:  userID,
+		EventType: "kill_switch.unfrozen",
+		Priority:  audit.PriorityHigh,
+		Details: map[string]interface{}{
+			"job_id":         jobID,
+			"approver":       session,
+			"was_frozen":     frozenAt != nil,
+			"permission_ids": ids,
+			"remint":         req.Remint,
+		},
+	})
+
+	h.startKillSwitchJob(userID, jobID)
+
+	job, err := h.loadKillSwitchJob(ctx, userID, jobID)
+	if err != nil {
+		respondHandlerError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusAccepted, job)
+}
+
+func (h *Handlers) ListKillSwitchJobs(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == uuid.Nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	rows, err := h.db.Query(r.Context(),
+		`SELECT `+killSwitchJobColumns+` FROM kill_switch_jobs j
+		 WHERE j.wallet_id = $1
+		 ORDER BY j.created_at DESC LIMIT 50`,
+		userID,
+	)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list kill switch jobs")
+		return
+	}
+	defer rows.Close()
+
+	jobs := []KillSwitchJob{}
+	for rows.Next() {
+		var j KillSwitchJob
+		if err := scanKillSwitchJob(rows, &j); err != nil {
+			continue
+		}
+		jobs = append(jobs, j)
+	}
+
+	respondJSON(w, http.StatusOK, jobs)
+}
+
+func (h *Handlers) GetKillSwitchJob(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	jobID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid job id")
+		return
+	}
+
+	job, err := h.loadKillSwitchJob(r.Context(), userID, jobID)
+	if err != nil {
+		respondHandlerError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, job)
+}
+
+// RetryKillSwitchJob re-runs the failed and unfinished steps of a job, for
+// example after an RPC outage or a restart interrupted it. Freeze steps are
+// only retried while the wallet is still frozen.
+func (h *Handlers) RetryKillSwitchJob(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	jobID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid job id")
+		return
+	}
+
+	job, err := h.loadKillSwitchJob(r.Context(), userID, jobID)
+	if err != nil {
+		respondHandlerError(w, err)
+		return
+	}
+	if _, running := h.killSwitchRuns.Load(jobID); running {
+		respondError(w, http.StatusConflict, "job is running")
+		return
+	}
+	if job.Kind == "freeze" {
+		if h.checkNotFrozen(r.Context(), userID) == nil {
+			respondError(w, http.StatusConflict, "wallet is no longer frozen")
+			return
+		}
+	}
+	if job.CompletedSteps == job.TotalSteps && job.Status != "running" {
+		respondError(w, http.StatusConflict, "job has no steps left to run")
+		return
+	}
+
+	h.db.Exec(r.Context(),
+		`UPDATE kill_switch_steps SET status = 'pending', error = NULL, updated_at = NOW()
+		 WHERE job_id = $1 AND status = 'failed'`,
+		jobID,
+	)
+	h.db.Exec(r.Context(),
+		`UPDATE kill_switch_jobs SET status = 'running', finished_at = NULL WHERE id = $1`, jobID,
+	)
+
+	h.auditLogger.Log(r.Context(), audit.Event{
+		WalletID:  userID,
+		EventType: "kill_switch.retried",
+		Priority:  audit.PriorityHigh,
+		Details:   map[string]interface{}{"job_id": jobID, "kind": job.Kind},
+	})
+
+	h.startKillSwitchJob(userID, jobID)
+
+	job, err = h.loadKillSwitchJob(r.Context(), userID, jobID)
+	if err != nil {
+		respondHandlerError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusAccepted, job)
+}
+
+func (h *Handlers) loadKillSwitchJob(ctx context.Context, walletID, jobID uuid.UUID) (KillSwitchJob, error) {
+	var job KillSwitchJob
+	err := scanKillSwitchJob(h.db.QueryRow(ctx,
+		`SELECT `+killSwitchJobColumns+` FROM kill_switch_jobs j WHERE j.id = $1 AND j.wallet_id = $2`,
+		jobID, walletID,
+	), &job)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return job, newHandlerError(http.StatusNotFound, "kill switch job not found")
+	}
+	if err != nil {
+		return job, newHandlerError(http.StatusInternalServerError, "failed to load kill switch job")
+	}
+
+	rows, err := h.db.Query(ctx,
+		`SELECT id, kind, target_id, status, tx_hash, result_id, error, updated_at
+		 FROM kill_switch_steps WHERE job_id = $1 ORDER BY kind, target_id`,
+		jobID,
+	)
+	if err != nil {
+		return job, newHandlerError(http.StatusInternalServerError, "failed to load kill switch job")
+	}
+	defer rows.Close()
+
+	job.Steps = []KillSwitchStep{}
+	for rows.Next() {
+		var s KillSwitchStep
+		if err := rows.Scan(&s.ID, &s.Kind, &s.TargetID, &s.Status, &s.TxHash, &s.ResultID, &s.Error, &s.UpdatedAt); err != nil {
+			continue
+		}
+		job.Steps = append(job.Steps, s)
+	}
+	return job, nil
+}
+
+// startKillSwitchJob runs the job's pending steps in the background. The run
+// outlives the request, so it uses its own context.
+func (h *Handlers) startKillSwitchJob(walletID, jobID uuid.UUID) {
+	if _, running := h.killSwitchRuns.LoadOrStore(jobID, struct{}{}); running {
+		return
+	}
+	go func() {
+		defer h.killSwitchRuns.Delete(jobID)
+		h.runKillSwitchJob(context.Background(), walletID, jobID)
+	}()
+}
+
+// runKillSwitchJob runs each pending step, records its outcome and audits it
+// with a high-priority webhook, then finishes the job. Agents are reactivated
+// before grants are restored so that restored grants can be minted.
+func (h *Handlers) runKillSwitchJob(ctx context.Context, walletID, jobID uuid.UUID) {
+	var remint bool
+	h.db.QueryRow(ctx, `SELECT remint FROM kill_switch_jobs WHERE id = $1`, jobID).Scan(&remint)
+
+	rows, err := h.db.Query(ctx,
+		`SELECT id, kind, target_id, result_id FROM kill_switch_steps
+		 WHERE job_id = $1 AND status = 'pending'
+		 ORDER BY CASE kind WHEN 'revoke_permission' THEN 0 WHEN 'deactivate_agent' THEN 1
+		                    WHEN 'reactivate_agent' THEN 2 ELSE 3 END, target_id`,
+		jobID,
+	)
+	if err != nil {
+		h.logger.Error().Err(err).Str("job_id", jobID.String()).Msg("failed to load kill switch steps")
+		return
+	}
+	var steps []KillSwitchStep
+	for rows.Next() {
+		var s KillSwitchStep
+		if err := rows.Scan(&s.ID, &s.Kind, &s.TargetID, &s.ResultID); err != nil {
+			continue
+		}
+		steps = append(steps, s)
+	}
+	rows.Close()
+
+	failed := 0
+	for _, s := range steps {
+		txHash, resultID, err := h.runKillSwitchStep(ctx, walletID, s, remint)
+
+		status, errMsg := "done", ""
+		eventType := killSwitchStepEvents[s.Kind]
+		details := map[string]interface{}{"job_id": jobID, "step_id": s.ID, "kind": s.Kind, "target_id": s.TargetID}
+		if err != nil {
+			failed++
+			status, errMsg = "failed", err.Error()
+			eventType = "kill_switch.step_failed"
+			details["error"] = errMsg
+		}
+		if txHash != "" {
+			details["tx_hash"] = txHash
+		}
+		if resultID != nil {
+			details["result_id"] = *resultID
+		}
+
+		h.db.Exec(ctx,
+			`UPDATE kill_switch_steps SET status = $2, tx_hash = $3, result_id = $4, error = $5, updated_at = NOW()
+			 WHERE id = $1`,
+			s.ID, status, nilIfEmpty(txHash), resultID, nilIfEmpty(errMsg),
+		)
+
+		event := audit.Event{
+			WalletID:  walletID,
+			EventType: eventType,
+			Priority:  audit.PriorityHigh,
+			Details:   details,
+			TxHash:    txHash,
+		}
+		target := s.TargetID
+		if strings.HasSuffix(s.Kind, "_agent") {
+			event.AgentID = &target
+		} else {
+			event.PermissionID = &target
+		}
+		h.auditLogger.Log(ctx, event)
+	}
+
+	status := "completed"
+	if failed > 0 {
+		status = "failed"
+	}
+	h.db.Exec(ctx,
+		`UPDATE kill_switch_jobs SET status = $2, finished_at = NOW() WHERE id = $1`,
+		jobID, status,
+	)
+	h.auditLogger.Log(ctx, audit.Event{
+		WalletID:  walletID,
+		EventType: "kill_switch." + status,
+		Priority:  audit.PriorityHigh,
+		Details:   map[string]interface{}{"job_id": jobID, "steps": len(steps), "failed_steps": failed},
+	})
+}
+
+// runKillSwitchStep performs one step and returns its transaction hash and,
+// for a restored grant, the new permission's ID.
+func (h *Handlers) runKillSwitchStep(ctx context.Context, walletID uuid.UUID, s KillSwitchStep, remint bool) (string, *uuid.UUID, error) {
+	bc := h.chainClients.Primary()
+
+	switch s.Kind {
+	case "revoke_permission":
+		var tokenID string
+		if err := h.db.QueryRow(ctx,
+			`SELECT onchain_token_id FROM permissions WHERE id = $1 AND wallet_id = $2`, s.TargetID, walletID,
+		).Scan(&tokenID); err != nil {
+			return "", nil, errors.New("permission not found")
+		}
+		permIDBytes, err := blockchain.HexToBytes32(tokenID)
+		if err != nil {
+			return "", nil, err
+		}
+		txHash, err := bc.RevokePermission(ctx, permIDBytes)
+		return txHash, nil, err
+
+	case "deactivate_agent":
+		txHash, err := bc.DeactivateAgent(ctx, blockchain.UUIDToBytes32(s.TargetID.String()))
+		return txHash, nil, err
+
+	case "reactivate_agent":
+		txHash, err := bc.RegisterAgent(ctx, blockchain.UUIDToBytes32(s.TargetID.String()), s.TargetID.String())
+		return txHash, nil, err
+
+	case "restore_permission":
+		var req CreatePermissionRequest
+		var minted bool
+		if err := h.db.QueryRow(ctx,
+			`SELECT agent_id, policy_id, valid_from, valid_until, group_grant_id, onchain_token_id IS NOT NULL
+			 FROM permissions WHERE id = $1 AND wallet_id = $2`,
+			s.TargetID, walletID,
+		).Scan(&req.AgentID, &req.PolicyID, &req.ValidFrom, &req.ValidUntil, &req.GroupGrantID, &minted); err != nil {
+			return "", nil, errors.New("permission not found")
+		}
+		// A retry after a failed mint reuses the grant already restored
+		restoredID := s.ResultID
+		if restoredID == nil {
+			perm, err := h.createPermission(ctx, walletID, req)
+			if err != nil {
+				return "", nil, err
+			}
+			restoredID = &perm.ID
+		}
+		if remint && minted {
+			var alreadyMinted bool
+			h.db.QueryRow(ctx,
+				`SELECT onchain_token_id IS NOT NULL FROM permissions WHERE id = $1`, *restoredID,
+			).Scan(&alreadyMinted)
+			if !alreadyMinted {
+				if _, err := h.mintPermission(ctx, walletID, *restoredID); err != nil {
+					return "", restoredID, err
+				}
+			}
+		}
+		return "", restoredID, nil
+	}
+
+	return "", nil, errors.New("unknown step kind " + s.Kind)
+}
+
+func uniqueUUIDs(ids []uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool, len(ids))
+	out := []uuid.UUID{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
@@ -0,0 +1,446 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/erc8004/policy-saas/internal/api/middleware"
+	"github.com/erc8004/policy-saas/internal/domain/audit"
+	"github.com/erc8004/policy-saas/internal/domain/policy"
+)
+
+// PermissionRequest is an agent asking for a permission it does not have,
+// either as an instance of a template or as a proposed definition.
+type PermissionRequest struct {
+	ID                  uuid.UUID              `json:"id"`
+	WalletID            uuid.UUID              `json:"wallet_id"`
+	AgentID             uuid.UUID              `json:"agent_id"`
+	TemplateID          *uuid.UUID             `json:"template_id,omitempty"`
+	TemplateParams      map[string]interface{} `json:"template_params,omitempty"`
+	Definition          *policy.Definition     `json:"definition,omitempty"`
+	Reason              string                 `json:"reason"`
+	ValidationRequestID *uuid.UUID             `json:"validation_request_id,omitempty"`
+	ValidUntil          *time.Time             `json:"valid_until,omitempty"`
+	Status              string                 `json:"status"`
+	DecidedBy           *string                `json:"decided_by,omitempty"`
+	DecisionNote        *string                `json:"decision_note,omitempty"`
+	PermissionID        *uuid.UUID             `json:"permission_id,omitempty"`
+	CreatedAt           time.Time              `json:"created_at"`
+	DecidedAt           *time.Time             `json:"decided_at,omitempty"`
+}
+
+type CreatePermissionRequestRequest struct {
+	// AgentID defaults to the agent of an agent-bound API key, which may
+	// only request permissions for itself.
+	AgentID        uuid.UUID              `json:"agent_id"`
+	TemplateID     *uuid.UUID             `json:"template_id,omitempty"`
+	TemplateParams map[string]interface{} `json:"template_params,omitempty"`
+	Definition     *policy.Definition     `json:"definition,omitempty"`
+	Reason         string                 `json:"reason"`
+	// ValidationRequestID is the request_id of the denied validation that
+	// prompted the request.
+	ValidationRequestID *uuid.UUID `json:"validation_request_id,omitempty"`
+	ValidUntil          *time.Time `json:"valid_until,omitempty"`
+}
+
+type DecidePermissionRequestRequest struct {
+	// ValidUntil overrides the requested expiry on approval.
+	ValidUntil *time.Time `json:"valid_until,omitempty"`
+	// Mint mints the permission on approval.
+	Mint bool   `json:"mint,omitempty"`
+	Note string `json:"note,omitempty"`
+}
+
+type ApprovePermissionRequestResponse struct {
+	Request    PermissionRequest `json:"request"`
+	Permission Permission        `json:"permission"`
+	// MintError is set when the permission was granted but minting failed.
+	MintError string `json:"mint_error,omitempty"`
+}
+
+const permissionRequestColumns = `id, wallet_id, agent_id, template_id, template_params, definition, reason, validation_request_id,
+	valid_until, status, decided_by, decision_note, permission_id, created_at, decided_at`
+
+func scanPermissionRequest(row interface{ Scan(...any) error }, pr *PermissionRequest) error {
+	var params, def []byte
+	if err := row.Scan(&pr.ID, &pr.WalletID, &pr.AgentID, &pr.TemplateID, &params, &def, &pr.Reason, &pr.ValidationRequestID,
+		&pr.ValidUntil, &pr.Status, &pr.DecidedBy, &pr.DecisionNote, &pr.PermissionID, &pr.CreatedAt, &pr.DecidedAt); err != nil {
+		return err
+	}
+	if params != nil {
+		json.Unmarshal(params, &pr.TemplateParams)
+	}
+	if def != nil {
+		pr.Definition = &policy.Definition{}
+		json.Unmarshal(def, pr.Definition)
+	}
+	return nil
+}
+
+// CreatePermissionRequest queues a request for the owner to review. Agents
+// call it with the wallet's API key, typically after a denied validation.
+func (h *Handlers) CreatePermissionRequest(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == uuid.Nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req CreatePermissionRequestRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	agentID, denied := keyAgent(r.Context(), req.AgentID)
+	if denied != "" {
+		respondError(w, http.StatusForbidden, denied)
+		return
+	}
+	req.AgentID = agentID
+
+	if strings.TrimSpace(req.Reason) == "" {
+		respondError(w, http.StatusBadRequest, "reason is required")
+		return
+	}
+	if (req.TemplateID == nil) == (req.Definition == nil) {
+		respondError(w, http.StatusBadRequest, "specify either template_id or definition")
+		return
+	}
+	if req.ValidUntil != nil && !req.ValidUntil.After(time.Now()) {
+		respondError(w, http.StatusBadRequest, "valid_until must be in the future")
+		return
+	}
+
+	var agentExists bool
+	h.db.QueryRow(r.Context(),
+		`SELECT EXISTS(SELECT 1 FROM agents WHERE id = $1 AND wallet_id = $2 AND status != 'deleted')`,
+		req.AgentID, userID,
+	).Scan(&agentExists)
+	if !agentExists {
+		respondError(w, http.StatusBadRequest, "agent not found")
+		return
+	}
+
+	// Check the request can be granted as asked, so the owner only reviews
+	// requests that would work
+	def := req.Definition
+	if req.TemplateID != nil {
+		t, err := h.loadTemplate(r.Context(), userID, *req.TemplateID)
+		if err != nil {
+			respondHandlerError(w, err)
+			return
+		}
+		if def, err = t.template().Render(req.TemplateParams); err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if err := h.policyEngine.ValidateDefinition(def); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.ValidationRequestID != nil {
+		var denied bool
+		err := h.db.QueryRow(r.Context(),
+			`SELECT NOT allowed FROM validation_requests WHERE id = $1 AND wallet_id = $2 AND agent_id = $3`,
+			*req.ValidationRequestID, userID, req.AgentID,
+		).Scan(&denied)
+		if err != nil || !denied {
+			respondError(w, http.StatusBadRequest, "validation_request_id must be a denied validation of this agent")
+			return
+		}
+	}
+
+	var params, defBytes []byte
+	if req.TemplateID != nil {
+		if req.TemplateParams == nil {
+			req.TemplateParams = map[string]interface{}{}
+		}
+		params, _ = json.Marshal(req.TemplateParams)
+	} else {
+		defBytes, _ = json.Marshal(req.Definition)
+	}
+
+	var pr PermissionRequest
+	err := scanPermissionRequest(h.db.QueryRow(r.Context(),
+		`INSERT INTO permission_requests (wallet_id, agent_id, template_id, template_params, definition, reason, validation_request_id, valid_until)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		 RETURNING `+permissionRequestColumns,
+		userID, req.AgentID, req.TemplateID, params, defBytes, req.Reason, req.ValidationRequestID, req.ValidUntil,
+	), &pr)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("failed to create permission request")
+		respondError(w, http.StatusInternalServerError, "failed to create permission request")
+		return
+	}
+
+	details := map[string]interface{}{"request_id": pr.ID, "reason": pr.Reason}
+	if pr.TemplateID != nil {
+		details["template_id"] = *pr.TemplateID
+	}
+	if pr.ValidationRequestID != nil {
+		details["validation_request_id"] = *pr.ValidationRequestID
+	}
+	h.auditLogger.Log(r.Context(), audit.Event{
+		WalletID:  userID,
+		AgentID:   &pr.AgentID,
+		EventType: "permission_request.created",
+		Details:   details,
+	})
+
+	respondJSON(w, http.StatusCreated, pr)
+}
+
+func (h *Handlers) ListPermissionRequests(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == uuid.Nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	agentID := r.URL.Query().Get("agent_id")
+	// An agent-bound API key sees only its own agent's requests
+	if authAgent := middleware.GetAgentID(r.Context()); authAgent != uuid.Nil {
+		agentID = authAgent.String()
+	}
+	rows, err := h.db.Query(r.Context(),
+		`SELECT `+permissionRequestColumns+` FROM permission_requests
+		 WHERE wallet_id = $1 AND ($2 = '' OR status = $2) AND ($3 = '' OR agent_id::text = $3)
+		 ORDER BY created_at DESC LIMIT 100`,
+		userID, status, agentID,
+	)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list permission requests")
+		return
+	}
+	defer rows.Close()
+
+	requests := []PermissionRequest{}
+	for rows.Next() {
+		var pr PermissionRequest
+		if err := scanPermissionRequest(rows, &pr); err != nil {
+			continue
+		}
+		requests = append(requests, pr)
+	}
+
+	respondJSON(w, http.StatusOK, requests)
+}
+
+func (h *Handlers) GetPermissionRequest(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	requestID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid permission request id")
+		return
+	}
+
+	var pr PermissionRequest
+	err = scanPermissionRequest(h.db.QueryRow(r.Context(),
+		`SELECT `+permissionRequestColumns+` FROM permission_requests WHERE id = $1 AND wallet_id = $2`,
+		requestID, userID,
+	), &pr)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "permission request not found")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, pr)
+}
+
+// ApprovePermissionRequest grants the requested permission and optionally
+// mints it. Only a signed-in session may decide, not API keys, so an agent
+// cannot approve its own request.
+func (h *Handlers) ApprovePermissionRequest(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	requestID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid permission request id")
+		return
+	}
+	session := middleware.GetWallet(r.Context())
+	if session == "" {
+		respondError(w, http.StatusForbidden, "deciding a permission request requires a signed-in wallet session")
+		return
+	}
+
+	// The body is optional
+	var req DecidePermissionRequestRequest
+	if err := decodeJSON(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	resp, err := h.approvePermissionRequest(r.Context(), userID, requestID, strings.ToLower(session), req)
+	if err != nil {
+		respondHandlerError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// approvePermissionRequest claims the pending request, then grants it. If the
+// grant fails the request goes back to pending.
+func (h *Handlers) approvePermissionRequest(ctx context.Context, walletID, requestID uuid.UUID, decidedBy string, req DecidePermissionRequestRequest) (ApprovePermissionRequestResponse, error) {
+	var resp ApprovePermissionRequestResponse
+
+	var pr PermissionRequest
+	err := scanPermissionRequest(h.db.QueryRow(ctx,
+		`UPDATE permission_requests SET status = 'approved', decided_by = $3, decision_note = $4, decided_at = NOW()
+		 WHERE id = $1 AND wallet_id = $2 AND status = 'pending'
+		 RETURNING `+permissionRequestColumns,
+		requestID, walletID, decidedBy, nilIfEmpty(req.Note),
+	), &pr)
+	if err != nil {
+		return resp, newHandlerError(http.StatusNotFound, "permission request not found or not pending")
+	}
+
+	validUntil := pr.ValidUntil
+	if req.ValidUntil != nil {
+		validUntil = req.ValidUntil
+	}
+
+	perm, err := h.grantPermissionRequest(ctx, walletID, &pr, validUntil)
+	if err != nil {
+		h.db.Exec(ctx,
+			`UPDATE permission_requests SET status = 'pending', decided_by = NULL, decision_note = NULL, decided_at = NULL
+			 WHERE id = $1`,
+			pr.ID,
+		)
+		return resp, err
+	}
+
+	h.db.Exec(ctx, `UPDATE permission_requests SET permission_id = $1 WHERE id = $2`, perm.ID, pr.ID)
+	pr.PermissionID = &perm.ID
+
+	details := map[string]interface{}{"request_id": pr.ID, "decided_by": decidedBy}
+	if pr.DecisionNote != nil {
+		details["note"] = *pr.DecisionNote
+	}
+	if pr.ValidationRequestID != nil {
+		details["validation_request_id"] = *pr.ValidationRequestID
+	}
+	if req.Mint {
+		minted, err := h.mintPermission(ctx, walletID, perm.ID)
+		if err != nil {
+			resp.MintError = err.Error()
+			details["mint_error"] = err.Error()
+		} else {
+			perm = minted
+		}
+	}
+	details["minted"] = perm.OnchainTokenID != nil
+
+	h.auditLogger.Log(ctx, audit.Event{
+		WalletID:     walletID,
+		AgentID:      &pr.AgentID,
+		PolicyID:     &perm.PolicyID,
+		PermissionID: &perm.ID,
+		EventType:    "permission_request.approved",
+		Details:      details,
+	})
+
+	resp.Request = pr
+	resp.Permission = perm
+	return resp, nil
+}
+
+// grantPermissionRequest creates the permission a request asks for. A
+// proposed definition becomes a new policy, activated before the grant.
+func (h *Handlers) grantPermissionRequest(ctx context.Context, walletID uuid.UUID, pr *PermissionRequest, validUntil *time.Time) (Permission, error) {
+	if pr.TemplateID != nil {
+		return h.createPermission(ctx, walletID, CreatePermissionRequest{
+			AgentID:        pr.AgentID,
+			TemplateID:     pr.TemplateID,
+			TemplateParams: pr.TemplateParams,
+			ValidUntil:     validUntil,
+		})
+	}
+	if pr.Definition == nil {
+		return Permission{}, newHandlerError(http.StatusConflict, "the requested template no longer exists")
+	}
+
+	if err := h.checkNotFrozen(ctx, walletID); err != nil {
+		return Permission{}, err
+	}
+	var agentName string
+	h.db.QueryRow(ctx, `SELECT name FROM agents WHERE id = $1`, pr.AgentID).Scan(&agentName)
+
+	p, err := h.createPolicy(ctx, walletID, CreatePolicyRequest{
+		Name:        "Requested by " + agentName,
+		Description: "Granted from permission request " + pr.ID.String(),
+		Definition:  *pr.Definition,
+	})
+	if err != nil {
+		return Permission{}, err
+	}
+	if _, err := h.activatePolicy(ctx, walletID, p.ID, ""); err != nil {
+		h.db.Exec(ctx, `UPDATE policies SET status = 'deleted', updated_at = NOW() WHERE id = $1`, p.ID)
+		return Permission{}, err
+	}
+
+	return h.createPermission(ctx, walletID, CreatePermissionRequest{
+		AgentID:    pr.AgentID,
+		PolicyID:   p.ID,
+		ValidUntil: validUntil,
+	})
+}
+
+// RejectPermissionRequest declines a pending request. Only a signed-in
+// session may decide.
+func (h *Handlers) RejectPermissionRequest(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	requestID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid permission request id")
+		return
+	}
+	session := middleware.GetWallet(r.Context())
+	if session == "" {
+		respondError(w, http.StatusForbidden, "deciding a permission request requires a signed-in wallet session")
+		return
+	}
+
+	// The body is optional
+	var req DecidePermissionRequestRequest
+	if err := decodeJSON(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	var pr PermissionRequest
+	err = scanPermissionRequest(h.db.QueryRow(r.Context(),
+		`UPDATE permission_requests SET status = 'rejected', decided_by = $3, decision_note = $4, decided_at = NOW()
+		 WHERE id = $1 AND wallet_id = $2 AND status = 'pending'
+		 RETURNING `+permissionRequestColumns,
+		requestID, userID, strings.ToLower(session), nilIfEmpty(req.Note),
+	), &pr)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "permission request not found or not pending")
+		return
+	}
+
+	details := map[string]interface{}{"request_id": pr.ID, "decided_by": strings.ToLower(session)}
+	if pr.DecisionNote != nil {
+		details["note"] = *pr.DecisionNote
+	}
+	h.auditLogger.Log(r.Context(), audit.Event{
+		WalletID:  userID,
+		AgentID:   &pr.AgentID,
+		EventType: "permission_request.rejected",
+		Details:   details,
+	})
+
+	respondJSON(w, http.StatusOK, pr)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"math/big"
@@ -12,6 +13,7 @@ import (
 	"github.com/erc8004/policy-saas/internal/api/middleware"
 	"github.com/erc8004/policy-saas/internal/blockchain"
 	"github.com/erc8004/policy-saas/internal/domain/audit"
+	"github.com/erc8004/policy-saas/internal/domain/policy"
 )
 
 type Permission struct {
@@ -26,6 +28,23 @@ type Permission struct {
 	CreatedAt      time.Time  `json:"created_at"`
 	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
 	MintedAt       *time.Time `json:"minted_at,omitempty"`
+	// GroupGrantID is set when the permission comes from a grant to a group.
+	GroupGrantID *uuid.UUID `json:"group_grant_id,omitempty"`
+	// RotatedFrom is the permission this one replaced by rotation.
+	RotatedFrom *uuid.UUID `json:"rotated_from,omitempty"`
+	// RetireAt is when a rotated permission is revoked in favor of its successor.
+	RetireAt *time.Time `json:"retire_at,omitempty"`
+	// DelegatedFrom is the permission this one was delegated from.
+	DelegatedFrom *uuid.UUID `json:"delegated_from,omitempty"`
+	// TaskID and SessionScope are set on task sessions.
+	TaskID       *string              `json:"task_id,omitempty"`
+	SessionScope *policy.SessionScope `json:"session_scope,omitempty"`
+}
+
+const permissionColumns = `id, wallet_id, agent_id, policy_id, status, onchain_token_id, valid_from, valid_until, created_at, revoked_at, minted_at, group_grant_id, rotated_from, retire_at, delegated_from, task_id, session_scope`
+
+func scanPermission(row interface{ Scan(...any) error }, p *Permission) error {
+	return row.Scan(&p.ID, &p.WalletID, &p.AgentID, &p.PolicyID, &p.Status, &p.OnchainTokenID, &p.ValidFrom, &p.ValidUntil, &p.CreatedAt, &p.RevokedAt, &p.MintedAt, &p.GroupGrantID, &p.RotatedFrom, &p.RetireAt, &p.DelegatedFrom, &p.TaskID, &p.SessionScope)
 }
 
 type CreatePermissionRequest struct {
@@ -33,6 +52,14 @@ type CreatePermissionRequest struct {
 	PolicyID   uuid.UUID  `json:"policy_id"`
 	ValidFrom  *time.Time `json:"valid_from,omitempty"`
 	ValidUntil *time.Time `json:"valid_until,omitempty"`
+	// TemplateID grants a new instance of a policy template instead of an
+	// existing policy. Mutually exclusive with PolicyID.
+	TemplateID     *uuid.UUID             `json:"template_id,omitempty"`
+	TemplateParams map[string]interface{} `json:"template_params,omitempty"`
+	// GroupGrantID links a member's permission to its group grant.
+	GroupGrantID *uuid.UUID `json:"-"`
+	// DelegatedFrom links a delegated permission to the one it attenuates.
+	DelegatedFrom *uuid.UUID `json:"-"`
 }
 
 func (h *Handlers) CreatePermission(w http.ResponseWriter, r *http.Request) {
@@ -48,26 +75,53 @@ func (h *Handlers) CreatePermission(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Verify agent belongs to user
-	var agentExists bool
-	h.db.QueryRow(r.Context(),
-		`SELECT EXISTS(SELECT 1 FROM agents WHERE id = $1 AND wallet_id = $2 AND status = 'active')`,
-		req.AgentID, userID,
-	).Scan(&agentExists)
-	if !agentExists {
-		respondError(w, http.StatusBadRequest, "agent not found or inactive")
+	perm, err := h.createPermission(r.Context(), userID, req)
+	if err != nil {
+		respondHandlerError(w, err)
 		return
 	}
 
+	respondJSON(w, http.StatusCreated, perm)
+}
+
+// createPermission links an active agent to an active policy owned by walletID.
+// When a template is given, a policy instance is rendered and activated first.
+func (h *Handlers) createPermission(ctx context.Context, walletID uuid.UUID, req CreatePermissionRequest) (Permission, error) {
+	var perm Permission
+
+	if err := h.checkNotFrozen(ctx, walletID); err != nil {
+		return perm, err
+	}
+
+	// Verify agent belongs to user
+	var agentName string
+	err := h.db.QueryRow(ctx,
+		`SELECT name FROM agents WHERE id = $1 AND wallet_id = $2 AND status = 'active'`,
+		req.AgentID, walletID,
+	).Scan(&agentName)
+	if err != nil {
+		return perm, newHandlerError(http.StatusBadRequest, "agent not found or inactive")
+	}
+
+	if req.TemplateID != nil {
+		if req.PolicyID != uuid.Nil {
+			return perm, newHandlerError(http.StatusBadRequest, "specify either policy_id or template_id")
+		}
+		p, err := h.instantiateTemplate(ctx, walletID, *req.TemplateID, req.TemplateParams, agentName)
+		if err != nil {
+			return perm, err
+		}
+		req.PolicyID = p.ID
+	}
+
 	// Verify policy belongs to user and is active
 	var policyActive bool
-	h.db.QueryRow(r.Context(),
+	h.db.QueryRow(ctx,
 		`SELECT EXISTS(SELECT 1 FROM policies WHERE id = $1 AND wallet_id = $2 AND status = 'active')`,
-		req.PolicyID, userID,
+		req.PolicyID, walletID,
 	).Scan(&policyActive)
 	if !policyActive {
-		respondError(w, http.StatusBadRequest, "policy not found or not active")
-		return
+		return perm, newHandlerError(http.StatusBadRequest, "policy not found or not active")
 	}
 
 	validFrom := time.Now()
@@ -75,28 +129,30 @@ func (h *Handlers) CreatePermission(w http.ResponseWriter, r *http.Request) {
 		validFrom = *req.ValidFrom
 	}
 
-	var perm Permission
-	err := h.db.QueryRow(r.Context(),
-		`INSERT INTO permissions (wallet_id, agent_id, policy_id, status, valid_from, valid_until)
-		 VALUES ($1, $2, $3, 'active', $4, $5)
-		 RETURNING id, wallet_id, agent_id, policy_id, status, onchain_token_id, valid_from, valid_until, created_at, revoked_at, minted_at`,
-		userID, req.AgentID, req.PolicyID, validFrom, req.ValidUntil,
-	).Scan(&perm.ID, &perm.WalletID, &perm.AgentID, &perm.PolicyID, &perm.Status, &perm.OnchainTokenID, &perm.ValidFrom, &perm.ValidUntil, &perm.CreatedAt, &perm.RevokedAt, &perm.MintedAt)
+	err = scanPermission(h.db.QueryRow(ctx,
+		`INSERT INTO permissions (wallet_id, agent_id, policy_id, status, valid_from, valid_until, group_grant_id, delegated_from)
+		 VALUES ($1, $2, $3, 'active', $4, $5, $6, $7)
+		 RETURNING `+permissionColumns,
+		walletID, req.AgentID, req.PolicyID, validFrom, req.ValidUntil, req.GroupGrantID, req.DelegatedFrom,
+	), &perm)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("failed to create permission")
-		respondError(w, http.StatusInternalServerError, "failed to create permission")
-		return
+		return perm, newHandlerError(http.StatusInternalServerError, "failed to create permission")
 	}
 
-	h.auditLogger.Log(r.Context(), audit.Event{
-		WalletID:     userID,
+	event := audit.Event{
+		WalletID:     walletID,
 		AgentID:      &req.AgentID,
 		PolicyID:     &req.PolicyID,
 		PermissionID: &perm.ID,
 		EventType:    "permission.created",
-	})
+	}
+	if req.GroupGrantID != nil {
+		event.Details = map[string]interface{}{"group_grant_id": *req.GroupGrantID}
+	}
+	h.auditLogger.Log(ctx, event)
 
-	respondJSON(w, http.StatusCreated, perm)
+	return perm, nil
 }
 
 func (h *Handlers) ListPermissions(w http.ResponseWriter, r *http.Request) {
@@ -109,8 +165,7 @@ func (h *Handlers) ListPermissions(w http.ResponseWriter, r *http.Request) {
 	agentID := r.URL.Query().Get("agent_id")
 	policyID := r.URL.Query().Get("policy_id")
 
-	query := `SELECT id, wallet_id, agent_id, policy_id, status, onchain_token_id, valid_from, valid_until, created_at, revoked_at, minted_at
-		 FROM permissions WHERE wallet_id = $1`
+	query := `SELECT ` + permissionColumns + ` FROM permissions WHERE wallet_id = $1`
 	args := []interface{}{userID}
 
 	if agentID != "" {
@@ -137,7 +192,7 @@ func (h *Handlers) ListPermissions(w http.ResponseWriter, r *http.Request) {
 	var permissions []Permission
 	for rows.Next() {
 		var p Permission
-		if err := rows.Scan(&p.ID, &p.WalletID, &p.AgentID, &p.PolicyID, &p.Status, &p.OnchainTokenID, &p.ValidFrom, &p.ValidUntil, &p.CreatedAt, &p.RevokedAt, &p.MintedAt); err != nil {
+		if err := scanPermission(rows, &p); err != nil {
 			continue
 		}
 		permissions = append(permissions, p)
@@ -161,11 +216,10 @@ func (h *Handlers) GetPermission(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var perm Permission
-	err = h.db.QueryRow(r.Context(),
-		`SELECT id, wallet_id, agent_id, policy_id, status, onchain_token_id, valid_from, valid_until, created_at, revoked_at, minted_at
-		 FROM permissions WHERE id = $1 AND wallet_id = $2`,
+	err = scanPermission(h.db.QueryRow(r.Context(),
+		`SELECT `+permissionColumns+` FROM permissions WHERE id = $1 AND wallet_id = $2`,
 		permID, userID,
-	).Scan(&perm.ID, &perm.WalletID, &perm.AgentID, &perm.PolicyID, &perm.Status, &perm.OnchainTokenID, &perm.ValidFrom, &perm.ValidUntil, &perm.CreatedAt, &perm.RevokedAt, &perm.MintedAt)
+	), &perm)
 	if err != nil {
 		respondError(w, http.StatusNotFound, "permission not found")
 		return
@@ -184,81 +238,118 @@ func (h *Handlers) DeletePermission(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if the permission was minted on-chain (need to revoke there too)
-	var onchainTokenID *string
-	h.db.QueryRow(r.Context(),
-		`SELECT onchain_token_id FROM permissions WHERE id = $1 AND wallet_id = $2 AND status = 'active'`,
-		permID, userID,
-	).Scan(&onchainTokenID)
-
-	// Revoke on-chain if permission was minted
-	if onchainTokenID != nil && *onchainTokenID != "" {
-		permIDBytes, decErr := blockchain.HexToBytes32(*onchainTokenID)
-		if decErr == nil {
-			txHash, bcErr := h.chainClients.Primary().RevokePermission(r.Context(), permIDBytes)
-			if bcErr != nil {
-				h.logger.Error().Err(bcErr).Str("permission_id", permID.String()).Msg("on-chain permission revocation failed (continuing with DB revoke)")
-			} else {
-				h.logger.Info().Str("permission_id", permID.String()).Str("tx_hash", txHash).Msg("permission revoked on-chain")
-			}
-		}
+	if err := h.deletePermission(r.Context(), userID, permID); err != nil {
+		respondHandlerError(w, err)
+		return
 	}
 
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deletePermission revokes an active permission, or hard-deletes one that is
+// already revoked.
+func (h *Handlers) deletePermission(ctx context.Context, walletID, permID uuid.UUID) error {
 	// Check current status to decide action
 	var currentStatus string
 	var agentID, policyID uuid.UUID
-	err = h.db.QueryRow(r.Context(),
+	err := h.db.QueryRow(ctx,
 		`SELECT status, agent_id, policy_id FROM permissions WHERE id = $1 AND wallet_id = $2`,
-		permID, userID,
+		permID, walletID,
 	).Scan(&currentStatus, &agentID, &policyID)
 	if err != nil {
-		respondError(w, http.StatusNotFound, "permission not found")
-		return
+		return newHandlerError(http.StatusNotFound, "permission not found")
 	}
 
-	if currentStatus == "active" {
+	switch currentStatus {
+	case "active":
 		// Revoke active permission (soft delete)
-		_, err = h.db.Exec(r.Context(),
-			`UPDATE permissions SET status = 'revoked', revoked_at = NOW()
-			 WHERE id = $1 AND wallet_id = $2 AND status = 'active'`,
-			permID, userID,
-		)
-		if err != nil {
-			respondError(w, http.StatusInternalServerError, "failed to revoke permission")
-			return
+		if err := h.checkNotGroupGranted(ctx, permID); err != nil {
+			return err
 		}
-
-		h.auditLogger.Log(r.Context(), audit.Event{
-			WalletID:     userID,
-			AgentID:      &agentID,
-			PolicyID:     &policyID,
-			PermissionID: &permID,
-			EventType:    "permission.revoked",
-		})
-	} else if currentStatus == "revoked" {
+		return h.revokePermission(ctx, walletID, permID)
+	case "revoked":
 		// Hard delete revoked permission
-		_, err = h.db.Exec(r.Context(),
+		_, err = h.db.Exec(ctx,
 			`DELETE FROM permissions WHERE id = $1 AND wallet_id = $2 AND status = 'revoked'`,
-			permID, userID,
+			permID, walletID,
 		)
 		if err != nil {
-			respondError(w, http.StatusInternalServerError, "failed to delete permission")
-			return
+			return newHandlerError(http.StatusInternalServerError, "failed to delete permission")
 		}
 
-		h.auditLogger.Log(r.Context(), audit.Event{
-			WalletID:     userID,
+		h.auditLogger.Log(ctx, audit.Event{
+			WalletID:     walletID,
 			AgentID:      &agentID,
 			PolicyID:     &policyID,
 			PermissionID: &permID,
 			EventType:    "permission.deleted",
 		})
-	} else {
-		respondError(w, http.StatusBadRequest, "permission cannot be deleted in current state")
-		return
+		return nil
+	default:
+		return newHandlerError(http.StatusBadRequest, "permission cannot be deleted in current state")
 	}
+}
 
-	w.WriteHeader(http.StatusNoContent)
+// checkNotGroupGranted refuses to revoke a member's permission from a group
+// grant on its own, since the group would grant it straight back.
+func (h *Handlers) checkNotGroupGranted(ctx context.Context, permID uuid.UUID) error {
+	var fromGroup bool
+	h.db.QueryRow(ctx,
+		`SELECT group_grant_id IS NOT NULL FROM permissions WHERE id = $1`, permID,
+	).Scan(&fromGroup)
+	if fromGroup {
+		return newHandlerError(http.StatusConflict, "permission is granted through an agent group; revoke the group grant or remove the agent from the group")
+	}
+	return nil
+}
+
+// revokePermission revokes an active permission on-chain (best-effort, if minted)
+// and marks it revoked in the DB.
+func (h *Handlers) revokePermission(ctx context.Context, walletID, permID uuid.UUID) error {
+	var agentID, policyID uuid.UUID
+	var onchainTokenID *string
+	err := h.db.QueryRow(ctx,
+		`SELECT agent_id, policy_id, onchain_token_id FROM permissions WHERE id = $1 AND wallet_id = $2 AND status = 'active'`,
+		permID, walletID,
+	).Scan(&agentID, &policyID, &onchainTokenID)
+	if err != nil {
+		return newHandlerError(http.StatusNotFound, "permission not found or not active")
+	}
+
+	// Revoke on-chain if permission was minted
+	if onchainTokenID != nil && *onchainTokenID != "" {
+		permIDBytes, decErr := blockchain.HexToBytes32(*onchainTokenID)
+		if decErr == nil {
+			txHash, bcErr := h.chainClients.Primary().RevokePermission(ctx, permIDBytes)
+			if bcErr != nil {
+				h.logger.Error().Err(bcErr).Str("permission_id", permID.String()).Msg("on-chain permission revocation failed (continuing with DB revoke)")
+			} else {
+				h.logger.Info().Str("permission_id", permID.String()).Str("tx_hash", txHash).Msg("permission revoked on-chain")
+			}
+		}
+	}
+
+	_, err = h.db.Exec(ctx,
+		`UPDATE permissions SET status = 'revoked', revoked_at = NOW()
+		 WHERE id = $1 AND wallet_id = $2 AND status = 'active'`,
+		permID, walletID,
+	)
+	if err != nil {
+		return newHandlerError(http.StatusInternalServerError, "failed to revoke permission")
+	}
+
+	h.auditLogger.Log(ctx, audit.Event{
+		WalletID:     walletID,
+		AgentID:      &agentID,
+		PolicyID:     &policyID,
+		PermissionID: &permID,
+		EventType:    "permission.revoked",
+	})
+	h.revokeBundles(ctx, walletID)
+
+	h.revokeDelegates(ctx, walletID, permID)
+
+	return nil
 }
 
 func (h *Handlers) MintPermission(w http.ResponseWriter, r *http.Request) {
@@ -271,6 +362,24 @@ func (h *Handlers) MintPermission(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	perm, err := h.mintPermission(r.Context(), userID, permID)
+	if err != nil {
+		respondHandlerError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, perm)
+}
+
+// mintPermission mints an active, unminted permission on-chain and pushes its
+// constraints to the agent's smart account.
+func (h *Handlers) mintPermission(ctx context.Context, walletID, permID uuid.UUID) (Permission, error) {
+	var perm Permission
+
+	if err := h.checkNotFrozen(ctx, walletID); err != nil {
+		return perm, err
+	}
+
 	// Query permission details for on-chain minting, including agent's on-chain registration status
 	var agentID, policyID uuid.UUID
 	var validFrom time.Time
@@ -278,29 +387,26 @@ func (h *Handlers) MintPermission(w http.ResponseWriter, r *http.Request) {
 	var defJSON []byte
 	var onchainHash *string
 	var agentOnchainRegistryID *string
-	err = h.db.QueryRow(r.Context(),
+	err := h.db.QueryRow(ctx,
 		`SELECT p.agent_id, p.policy_id, p.valid_from, p.valid_until, pol.definition, pol.onchain_hash, a.onchain_registry_id
 		 FROM permissions p
 		 JOIN policies pol ON pol.id = p.policy_id
 		 JOIN agents a ON a.id = p.agent_id
 		 WHERE p.id = $1 AND p.wallet_id = $2 AND p.status = 'active' AND p.minted_at IS NULL`,
-		permID, userID,
+		permID, walletID,
 	).Scan(&agentID, &policyID, &validFrom, &validUntil, &defJSON, &onchainHash, &agentOnchainRegistryID)
 	if err != nil {
-		respondError(w, http.StatusNotFound, "permission not found or already minted")
-		return
+		return perm, newHandlerError(http.StatusNotFound, "permission not found or already minted")
 	}
 
 	// Pre-check: agent must be registered on-chain before minting
 	if agentOnchainRegistryID == nil || *agentOnchainRegistryID == "" {
-		respondError(w, http.StatusBadRequest, "agent must be registered on-chain before minting a permission (use Register On-chain first)")
-		return
+		return perm, newHandlerError(http.StatusBadRequest, "agent must be registered on-chain before minting a permission (use Register On-chain first)")
 	}
 
 	// Pre-check: policy must be activated on-chain before minting
 	if onchainHash == nil || *onchainHash == "" {
-		respondError(w, http.StatusBadRequest, "policy must be activated on-chain before minting a permission (use Activate first)")
-		return
+		return perm, newHandlerError(http.StatusBadRequest, "policy must be activated on-chain before minting a permission (use Activate first)")
 	}
 
 	// Decode the on-chain policy ID into [32]byte
@@ -310,6 +416,14 @@ func (h *Handlers) MintPermission(w http.ResponseWriter, r *http.Request) {
 		policyHashBytes = blockchain.PolicyContentHash(defJSON)
 	}
 
+	// The on-chain window must not outlive the policy's own validity
+	var def policy.Definition
+	json.Unmarshal(defJSON, &def)
+	validFrom, validUntil = policy.EffectiveWindow(def.Duration, validFrom, validUntil)
+	if validUntil != nil && !validUntil.After(validFrom) {
+		return perm, newHandlerError(http.StatusBadRequest, "permission validity does not overlap the policy's validity window")
+	}
+
 	agentIDBytes := blockchain.UUIDToBytes32(agentID.String())
 	validFromBig := big.NewInt(validFrom.Unix())
 	var validUntilBig *big.Int
@@ -334,18 +448,16 @@ func (h *Handlers) MintPermission(w http.ResponseWriter, r *http.Request) {
 		Msg("attempting on-chain permission mint")
 
 	// Pre-flight diagnosis: identify exactly which contract check would fail
-	if reason := h.chainClients.Primary().DiagnoseGrantPermission(r.Context(), policyHashBytes, agentIDBytes); reason != "" {
+	if reason := h.chainClients.Primary().DiagnoseGrantPermission(ctx, policyHashBytes, agentIDBytes); reason != "" {
 		h.logger.Error().Str("permission_id", permID.String()).Str("reason", reason).Msg("mint pre-flight check failed")
-		respondError(w, http.StatusBadRequest, "cannot mint: "+reason)
-		return
+		return perm, newHandlerError(http.StatusBadRequest, "cannot mint: "+reason)
 	}
 
 	// Mint on-chain (or simulate)
-	onchainTokenID, txHash, err := h.chainClients.Primary().GrantPermission(r.Context(), policyHashBytes, agentIDBytes, validFromBig, validUntilBig)
+	onchainTokenID, txHash, err := h.chainClients.Primary().GrantPermission(ctx, policyHashBytes, agentIDBytes, validFromBig, validUntilBig)
 	if err != nil {
 		h.logger.Error().Err(err).Str("permission_id", permID.String()).Msg("on-chain minting failed")
-		respondError(w, http.StatusInternalServerError, "on-chain minting failed: "+err.Error())
-		return
+		return perm, newHandlerError(http.StatusInternalServerError, "on-chain minting failed: "+err.Error())
 	}
 
 	h.logger.Info().
@@ -356,37 +468,41 @@ func (h *Handlers) MintPermission(w http.ResponseWriter, r *http.Request) {
 		Msg("permission minted on-chain")
 
 	// Update DB with on-chain token ID
-	var perm Permission
-	err = h.db.QueryRow(r.Context(),
+	err = scanPermission(h.db.QueryRow(ctx,
 		`UPDATE permissions SET
 			onchain_token_id = $1,
 			minted_at = NOW()
 		 WHERE id = $2 AND wallet_id = $3 AND status = 'active' AND minted_at IS NULL
-		 RETURNING id, wallet_id, agent_id, policy_id, status, onchain_token_id, valid_from, valid_until, created_at, revoked_at, minted_at`,
-		onchainTokenID, permID, userID,
-	).Scan(&perm.ID, &perm.WalletID, &perm.AgentID, &perm.PolicyID, &perm.Status, &perm.OnchainTokenID, &perm.ValidFrom, &perm.ValidUntil, &perm.CreatedAt, &perm.RevokedAt, &perm.MintedAt)
+		 RETURNING `+permissionColumns,
+		onchainTokenID, permID, walletID,
+	), &perm)
 	if err != nil {
-		respondError(w, http.StatusNotFound, "permission not found or already minted")
-		return
+		return perm, newHandlerError(http.StatusNotFound, "permission not found or already minted")
 	}
 
-	h.auditLogger.Log(r.Context(), audit.Event{
-		WalletID:     userID,
+	h.auditLogger.Log(ctx, audit.Event{
+		WalletID:     walletID,
 		AgentID:      &perm.AgentID,
 		PolicyID:     &perm.PolicyID,
 		PermissionID: &permID,
 		EventType:    "permission.minted",
-		Details:      map[string]interface{}{"token_id": onchainTokenID, "tx_hash": txHash, "simulated": h.chainClients.Primary().IsSimulated()},
+		Details: map[string]interface{}{
+			"token_id":         onchainTokenID,
+			"tx_hash":          txHash,
+			"simulated":        h.chainClients.Primary().IsSimulated(),
+			"valid_from_unix":  validFromBig.Int64(),
+			"valid_until_unix": validUntilBig.Int64(),
+		},
 	})
 
 	// Best-effort constraint sync for smart account agents (Phase 4)
 	if h.onchainSyncer != nil {
-		if err := h.onchainSyncer.SyncConstraints(r.Context(), permID, perm.AgentID); err != nil {
+		if err := h.onchainSyncer.SyncConstraints(ctx, permID, perm.AgentID); err != nil {
 			h.logger.Error().Err(err).Msg("constraint sync failed (permission still minted)")
 		}
 	}
 
-	respondJSON(w, http.StatusOK, perm)
+	return perm, nil
 }
 
 // unmarshalDefinition is a helper to parse policy definition JSON.
@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"time"
 
@@ -22,6 +25,7 @@ type Policy struct {
 	Status          string               `json:"status"`
 	Version         int                  `json:"version"`
 	OnchainHash     *string              `json:"onchain_hash,omitempty"`
+	ContentHash     *string              `json:"content_hash,omitempty"`
 	CreatedAt       time.Time            `json:"created_at"`
 	UpdatedAt       time.Time            `json:"updated_at"`
 	ActivatedAt     *time.Time           `json:"activated_at,omitempty"`
@@ -47,48 +51,62 @@ func (h *Handlers) CreatePolicy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Name == "" {
-		respondError(w, http.StatusBadRequest, "name is required")
+	p, err := h.createPolicy(r.Context(), userID, req)
+	if err != nil {
+		respondHandlerError(w, err)
 		return
 	}
 
+	respondJSON(w, http.StatusCreated, p)
+}
+
+// createPolicy validates the definition and inserts a new draft policy with its first version.
+func (h *Handlers) createPolicy(ctx context.Context, walletID uuid.UUID, req CreatePolicyRequest) (Policy, error) {
+	var p Policy
+
+	if req.Name == "" {
+		return p, newHandlerError(http.StatusBadRequest, "name is required")
+	}
+
 	// Validate the policy definition
 	if err := h.policyEngine.ValidateDefinition(&req.Definition); err != nil {
-		respondError(w, http.StatusBadRequest, err.Error())
-		return
+		return p, newHandlerError(http.StatusBadRequest, err.Error())
 	}
 
 	defBytes, _ := json.Marshal(req.Definition)
+	contentHash, canonical, err := policy.ContentHash(&req.Definition)
+	if err != nil {
+		return p, newHandlerError(http.StatusBadRequest, "failed to canonicalize definition")
+	}
+	contentHashHex := policy.ContentHashHex(contentHash)
 
-	var p Policy
-	err := h.db.QueryRow(r.Context(),
-		`INSERT INTO policies (wallet_id, name, description, definition, status, version)
-		 VALUES ($1, $2, $3, $4, 'draft', 1)
-		 RETURNING id, wallet_id, name, description, definition, status, version, onchain_hash, created_at, updated_at, activated_at, revoked_at`,
-		userID, req.Name, req.Description, defBytes,
-	).Scan(&p.ID, &p.WalletID, &p.Name, &p.Description, &defBytes, &p.Status, &p.Version, &p.OnchainHash, &p.CreatedAt, &p.UpdatedAt, &p.ActivatedAt, &p.RevokedAt)
+	err = h.db.QueryRow(ctx,
+		`INSERT INTO policies (wallet_id, name, description, definition, status, version, canonical_definition, content_hash)
+		 VALUES ($1, $2, $3, $4, 'draft', 1, $5, $6)
+		 RETURNING id, wallet_id, name, description, definition, status, version, onchain_hash, content_hash, created_at, updated_at, activated_at, revoked_at`,
+		walletID, req.Name, req.Description, defBytes, string(canonical), contentHashHex,
+	).Scan(&p.ID, &p.WalletID, &p.Name, &p.Description, &defBytes, &p.Status, &p.Version, &p.OnchainHash, &p.ContentHash, &p.CreatedAt, &p.UpdatedAt, &p.ActivatedAt, &p.RevokedAt)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("failed to create policy")
-		respondError(w, http.StatusInternalServerError, "failed to create policy")
-		return
+		return p, newHandlerError(http.StatusInternalServerError, "failed to create policy")
 	}
 	json.Unmarshal(defBytes, &p.Definition)
 
 	// Record version
-	h.db.Exec(r.Context(),
-		`INSERT INTO policy_versions (policy_id, version, definition, created_by)
-		 VALUES ($1, $2, $3, $4)`,
-		p.ID, 1, defBytes, userID,
+	h.db.Exec(ctx,
+		`INSERT INTO policy_versions (policy_id, version, definition, created_by, canonical_definition, content_hash)
+		 VALUES ($1, $2, $3, $4, $5, $6)`,
+		p.ID, 1, defBytes, walletID, string(canonical), contentHashHex,
 	)
 
-	h.auditLogger.Log(r.Context(), audit.Event{
-		WalletID:  userID,
+	h.auditLogger.Log(ctx, audit.Event{
+		WalletID:  walletID,
 		PolicyID:  &p.ID,
 		EventType: "policy.created",
 		Details:   map[string]interface{}{"name": req.Name},
 	})
 
-	respondJSON(w, http.StatusCreated, p)
+	return p, nil
 }
 
 func (h *Handlers) ListPolicies(w http.ResponseWriter, r *http.Request) {
@@ -99,7 +117,7 @@ func (h *Handlers) ListPolicies(w http.ResponseWriter, r *http.Request) {
 	}
 
 	rows, err := h.db.Query(r.Context(),
-		`SELECT id, wallet_id, name, description, definition, status, version, onchain_hash, created_at, updated_at, activated_at, revoked_at
+		`SELECT id, wallet_id, name, description, definition, status, version, onchain_hash, content_hash, created_at, updated_at, activated_at, revoked_at
 		 FROM policies WHERE wallet_id = $1 AND status != 'deleted'
 		 ORDER BY created_at DESC`,
 		userID,
@@ -114,7 +132,7 @@ func (h *Handlers) ListPolicies(w http.ResponseWriter, r *http.Request) {
 	for rows.Next() {
 		var p Policy
 		var defBytes []byte
-		if err := rows.Scan(&p.ID, &p.WalletID, &p.Name, &p.Description, &defBytes, &p.Status, &p.Version, &p.OnchainHash, &p.CreatedAt, &p.UpdatedAt, &p.ActivatedAt, &p.RevokedAt); err != nil {
+		if err := rows.Scan(&p.ID, &p.WalletID, &p.Name, &p.Description, &defBytes, &p.Status, &p.Version, &p.OnchainHash, &p.ContentHash, &p.CreatedAt, &p.UpdatedAt, &p.ActivatedAt, &p.RevokedAt); err != nil {
 			continue
 		}
 		json.Unmarshal(defBytes, &p.Definition)
@@ -141,10 +159,10 @@ func (h *Handlers) GetPolicy(w http.ResponseWriter, r *http.Request) {
 	var p Policy
 	var defBytes []byte
 	err = h.db.QueryRow(r.Context(),
-		`SELECT id, wallet_id, name, description, definition, status, version, onchain_hash, created_at, updated_at, activated_at, revoked_at
+		`SELECT id, wallet_id, name, description, definition, status, version, onchain_hash, content_hash, created_at, updated_at, activated_at, revoked_at
 		 FROM policies WHERE id = $1 AND wallet_id = $2 AND status != 'deleted'`,
 		policyID, userID,
-	).Scan(&p.ID, &p.WalletID, &p.Name, &p.Description, &defBytes, &p.Status, &p.Version, &p.OnchainHash, &p.CreatedAt, &p.UpdatedAt, &p.ActivatedAt, &p.RevokedAt)
+	).Scan(&p.ID, &p.WalletID, &p.Name, &p.Description, &defBytes, &p.Status, &p.Version, &p.OnchainHash, &p.ContentHash, &p.CreatedAt, &p.UpdatedAt, &p.ActivatedAt, &p.RevokedAt)
 	if err != nil {
 		respondError(w, http.StatusNotFound, "policy not found")
 		return
@@ -158,6 +176,9 @@ type UpdatePolicyRequest struct {
 	Name        *string            `json:"name,omitempty"`
 	Description *string            `json:"description,omitempty"`
 	Definition  *policy.Definition `json:"definition,omitempty"`
+	// OwnerSignature is the owner's EIP-712 approval of the new version of an
+	// active policy.
+	OwnerSignature string `json:"owner_signature,omitempty"`
 }
 
 func (h *Handlers) UpdatePolicy(w http.ResponseWriter, r *http.Request) {
@@ -176,16 +197,30 @@ func (h *Handlers) UpdatePolicy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	p, err := h.updatePolicy(r.Context(), userID, policyID, req)
+	if err != nil {
+		respondHandlerError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, p)
+}
+
+// updatePolicy applies a partial update. Changing the definition of an active
+// policy bumps its version; every definition change is recorded in policy_versions.
+func (h *Handlers) updatePolicy(ctx context.Context, walletID, policyID uuid.UUID, req UpdatePolicyRequest) (Policy, error) {
+	var p Policy
+
 	// Check if policy exists and is in draft status
 	var currentStatus string
 	var currentVersion int
-	err = h.db.QueryRow(r.Context(),
-		`SELECT status, version FROM policies WHERE id = $1 AND wallet_id = $2 AND status != 'deleted'`,
-		policyID, userID,
-	).Scan(&currentStatus, &currentVersion)
+	var onchainHash *string
+	err := h.db.QueryRow(ctx,
+		`SELECT status, version, onchain_hash FROM policies WHERE id = $1 AND wallet_id = $2 AND status != 'deleted'`,
+		policyID, walletID,
+	).Scan(&currentStatus, &currentVersion, &onchainHash)
 	if err != nil {
-		respondError(w, http.StatusNotFound, "policy not found")
-		return
+		return p, newHandlerError(http.StatusNotFound, "policy not found")
 	}
 
 	// Can only update draft policies directly; active policies create new version
@@ -195,50 +230,97 @@ func (h *Handlers) UpdatePolicy(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var defBytes []byte
+	var canonical, contentHashHex *string
+	var approval *ownerApproval
+	var registeredVersion *int
+	var updateTx string
 	if req.Definition != nil {
 		if err := h.policyEngine.ValidateDefinition(req.Definition); err != nil {
-			respondError(w, http.StatusBadRequest, err.Error())
-			return
+			return p, newHandlerError(http.StatusBadRequest, err.Error())
 		}
 		defBytes, _ = json.Marshal(req.Definition)
+		hash, canonicalBytes, err := policy.ContentHash(req.Definition)
+		if err != nil {
+			return p, newHandlerError(http.StatusBadRequest, "failed to canonicalize definition")
+		}
+		canonicalStr := string(canonicalBytes)
+		hashHex := policy.ContentHashHex(hash)
+		canonical, contentHashHex = &canonicalStr, &hashHex
+
+		// A new version of an active policy may need the owner's approval
+		if currentStatus == "active" {
+			if approval, err = h.checkOwnerApproval(ctx, walletID, policyID, newVersion, hash, req.OwnerSignature); err != nil {
+				return p, err
+			}
+		}
+
+		// The registered hash must follow the new version, or verification
+		// reports the legitimate change as a mismatch
+		if currentStatus == "active" && onchainHash != nil && *onchainHash != "" {
+			onchainPolicyID, err := blockchain.HexToBytes32(*onchainHash)
+			if err != nil {
+				return p, newHandlerError(http.StatusInternalServerError, "stored on-chain policy id is invalid")
+			}
+			updateTx, err = h.chainClients.Primary().UpdatePolicy(ctx, onchainPolicyID, hash)
+			if err != nil {
+				h.logger.Error().Err(err).Str("policy_id", policyID.String()).Msg("on-chain policy update failed")
+				return p, newHandlerError(http.StatusBadGateway, "on-chain policy update failed: "+err.Error())
+			}
+			registeredVersion = &newVersion
+		}
 	}
 
-	var p Policy
 	var resultDefBytes []byte
-	err = h.db.QueryRow(r.Context(),
+	err = h.db.QueryRow(ctx,
 		`UPDATE policies SET
 			name = COALESCE($1, name),
 			description = COALESCE($2, description),
 			definition = COALESCE($3, definition),
 			version = $4,
+			canonical_definition = COALESCE($7, canonical_definition),
+			content_hash = COALESCE($8, content_hash),
+			registered_content_hash = CASE WHEN $9::int IS NULL THEN registered_content_hash ELSE $8 END,
+			registered_version = COALESCE($9, registered_version),
 			updated_at = NOW()
 		 WHERE id = $5 AND wallet_id = $6 AND status != 'deleted'
-		 RETURNING id, wallet_id, name, description, definition, status, version, onchain_hash, created_at, updated_at, activated_at, revoked_at`,
-		req.Name, req.Description, defBytes, newVersion, policyID, userID,
-	).Scan(&p.ID, &p.WalletID, &p.Name, &p.Description, &resultDefBytes, &p.Status, &p.Version, &p.OnchainHash, &p.CreatedAt, &p.UpdatedAt, &p.ActivatedAt, &p.RevokedAt)
+		 RETURNING id, wallet_id, name, description, definition, status, version, onchain_hash, content_hash, created_at, updated_at, activated_at, revoked_at`,
+		req.Name, req.Description, defBytes, newVersion, policyID, walletID, canonical, contentHashHex, registeredVersion,
+	).Scan(&p.ID, &p.WalletID, &p.Name, &p.Description, &resultDefBytes, &p.Status, &p.Version, &p.OnchainHash, &p.ContentHash, &p.CreatedAt, &p.UpdatedAt, &p.ActivatedAt, &p.RevokedAt)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, "failed to update policy")
-		return
+		return p, newHandlerError(http.StatusInternalServerError, "failed to update policy")
 	}
 	json.Unmarshal(resultDefBytes, &p.Definition)
 
 	// Record version if definition changed
-	if req.Definition != nil {
-		h.db.Exec(r.Context(),
-			`INSERT INTO policy_versions (policy_id, version, definition, created_by)
-			 VALUES ($1, $2, $3, $4)`,
-			p.ID, newVersion, defBytes, userID,
+	if approval != nil {
+		h.recordOwnerApproval(ctx, walletID, p.ID, newVersion, defBytes, *canonical, *contentHashHex, approval)
+	} else if req.Definition != nil {
+		h.db.Exec(ctx,
+			`INSERT INTO policy_versions (policy_id, version, definition, created_by, canonical_definition, content_hash)
+			 VALUES ($1, $2, $3, $4, $5, $6)`,
+			p.ID, newVersion, defBytes, walletID, canonical, contentHashHex,
 		)
 	}
 
-	h.auditLogger.Log(r.Context(), audit.Event{
-		WalletID:  userID,
+	details := map[string]interface{}{"version": newVersion}
+	if updateTx != "" {
+		details["tx_hash"] = updateTx
+	}
+	if approval != nil {
+		details["owner_signed"] = true
+		details["signature_type"] = approval.Type
+	}
+	h.auditLogger.Log(ctx, audit.Event{
+		WalletID:  walletID,
 		PolicyID:  &policyID,
 		EventType: "policy.updated",
-		Details:   map[string]interface{}{"version": newVersion},
+		Details:   details,
 	})
+	if req.Definition != nil && p.Status == "active" {
+		h.revokeBundles(ctx, walletID)
+	}
 
-	respondJSON(w, http.StatusOK, p)
+	return p, nil
 }
 
 func (h *Handlers) DeletePolicy(w http.ResponseWriter, r *http.Request) {
@@ -270,10 +352,17 @@ func (h *Handlers) DeletePolicy(w http.ResponseWriter, r *http.Request) {
 		PolicyID:  &policyID,
 		EventType: "policy.deleted",
 	})
+	h.revokeBundles(r.Context(), userID)
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+type ActivatePolicyRequest struct {
+	// OwnerSignature is the owner's EIP-712 approval of the policy's
+	// current version.
+	OwnerSignature string `json:"owner_signature,omitempty"`
+}
+
 func (h *Handlers) ActivatePolicy(w http.ResponseWriter, r *http.Request) {
 	userID := middleware.GetUserID(r.Context())
 	policyIDStr := r.PathValue("id")
@@ -284,24 +373,62 @@ func (h *Handlers) ActivatePolicy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The body is optional
+	var req ActivatePolicyRequest
+	if err := decodeJSON(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	p, err := h.activatePolicy(r.Context(), userID, policyID, req.OwnerSignature)
+	if err != nil {
+		respondHandlerError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, p)
+}
+
+// activatePolicy registers a draft policy on-chain and marks it active.
+// Shared by the HTTP handler and flows that activate policies on the owner's
+// behalf; those pass no ownerSignature, so they fail for wallets that
+// require one.
+func (h *Handlers) activatePolicy(ctx context.Context, walletID, policyID uuid.UUID, ownerSignature string) (Policy, error) {
+	var p Policy
+
 	// Get the policy definition before activating (needed for on-chain hash)
 	var defBytes []byte
-	err = h.db.QueryRow(r.Context(),
-		`SELECT definition FROM policies WHERE id = $1 AND wallet_id = $2 AND status = 'draft'`,
-		policyID, userID,
-	).Scan(&defBytes)
+	var version int
+	err := h.db.QueryRow(ctx,
+		`SELECT definition, version FROM policies WHERE id = $1 AND wallet_id = $2 AND status = 'draft'`,
+		policyID, walletID,
+	).Scan(&defBytes, &version)
 	if err != nil {
-		respondError(w, http.StatusNotFound, "policy not found or already active")
-		return
+		return p, newHandlerError(http.StatusNotFound, "policy not found or already active")
+	}
+
+	// Hash the canonical serialization so the on-chain content hash can be
+	// reproduced from the definition alone (JSONB key order is not stable).
+	var def policy.Definition
+	if err := json.Unmarshal(defBytes, &def); err != nil {
+		return p, newHandlerError(http.StatusInternalServerError, "failed to parse policy definition")
+	}
+	contentHash, canonical, err := policy.ContentHash(&def)
+	if err != nil {
+		return p, newHandlerError(http.StatusInternalServerError, "failed to canonicalize policy definition")
+	}
+	contentHashHex := policy.ContentHashHex(contentHash)
+
+	approval, err := h.checkOwnerApproval(ctx, walletID, policyID, version, contentHash, ownerSignature)
+	if err != nil {
+		return p, err
 	}
 
 	// Register the policy on-chain via PolicyRegistry.createPolicy(contentHash)
-	contentHash := blockchain.PolicyContentHash(defBytes)
-	onchainPolicyID, txHash, err := h.chainClients.Primary().CreatePolicy(r.Context(), contentHash)
+	onchainPolicyID, txHash, err := h.chainClients.Primary().CreatePolicy(ctx, contentHash)
 	if err != nil {
 		h.logger.Error().Err(err).Str("policy_id", policyID.String()).Msg("on-chain policy creation failed")
-		respondError(w, http.StatusInternalServerError, "on-chain policy creation failed: "+err.Error())
-		return
+		return p, newHandlerError(http.StatusInternalServerError, "on-chain policy creation failed: "+err.Error())
 	}
 
 	h.logger.Info().
@@ -311,27 +438,32 @@ func (h *Handlers) ActivatePolicy(w http.ResponseWriter, r *http.Request) {
 		Msg("policy registered on-chain")
 
 	// Activate in DB and store the on-chain hash
-	var p Policy
-	err = h.db.QueryRow(r.Context(),
-		`UPDATE policies SET status = 'active', activated_at = NOW(), updated_at = NOW(), onchain_hash = $1
+	err = h.db.QueryRow(ctx,
+		`UPDATE policies SET status = 'active', activated_at = NOW(), updated_at = NOW(), onchain_hash = $1,
+			canonical_definition = $4, content_hash = $5, registered_content_hash = $5, registered_version = version
 		 WHERE id = $2 AND wallet_id = $3 AND status = 'draft'
-		 RETURNING id, wallet_id, name, description, definition, status, version, onchain_hash, created_at, updated_at, activated_at, revoked_at`,
-		onchainPolicyID, policyID, userID,
-	).Scan(&p.ID, &p.WalletID, &p.Name, &p.Description, &defBytes, &p.Status, &p.Version, &p.OnchainHash, &p.CreatedAt, &p.UpdatedAt, &p.ActivatedAt, &p.RevokedAt)
+		 RETURNING id, wallet_id, name, description, definition, status, version, onchain_hash, content_hash, created_at, updated_at, activated_at, revoked_at`,
+		onchainPolicyID, policyID, walletID, string(canonical), contentHashHex,
+	).Scan(&p.ID, &p.WalletID, &p.Name, &p.Description, &defBytes, &p.Status, &p.Version, &p.OnchainHash, &p.ContentHash, &p.CreatedAt, &p.UpdatedAt, &p.ActivatedAt, &p.RevokedAt)
 	if err != nil {
-		respondError(w, http.StatusNotFound, "policy not found or already active")
-		return
+		return p, newHandlerError(http.StatusNotFound, "policy not found or already active")
 	}
 	json.Unmarshal(defBytes, &p.Definition)
 
-	h.auditLogger.Log(r.Context(), audit.Event{
-		WalletID:  userID,
+	details := map[string]interface{}{"onchain_hash": onchainPolicyID, "content_hash": contentHashHex, "tx_hash": txHash, "simulated": h.chainClients.Primary().IsSimulated()}
+	if approval != nil {
+		h.recordOwnerApproval(ctx, walletID, policyID, p.Version, defBytes, string(canonical), contentHashHex, approval)
+		details["owner_signed"] = true
+		details["signature_type"] = approval.Type
+	}
+	h.auditLogger.Log(ctx, audit.Event{
+		WalletID:  walletID,
 		PolicyID:  &policyID,
 		EventType: "policy.activated",
-		Details:   map[string]interface{}{"onchain_hash": onchainPolicyID, "tx_hash": txHash, "simulated": h.chainClients.Primary().IsSimulated()},
+		Details:   details,
 	})
 
-	respondJSON(w, http.StatusOK, p)
+	return p, nil
 }
 
 func (h *Handlers) RevokePolicy(w http.ResponseWriter, r *http.Request) {
@@ -344,18 +476,31 @@ func (h *Handlers) RevokePolicy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	p, err := h.revokePolicy(r.Context(), userID, policyID)
+	if err != nil {
+		respondHandlerError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, p)
+}
+
+// revokePolicy deactivates an active policy on-chain (best-effort) and marks it revoked.
+func (h *Handlers) revokePolicy(ctx context.Context, walletID, policyID uuid.UUID) (Policy, error) {
+	var p Policy
+
 	// Get the on-chain hash before revoking (needed for deactivatePolicy call)
 	var onchainHash *string
-	h.db.QueryRow(r.Context(),
+	h.db.QueryRow(ctx,
 		`SELECT onchain_hash FROM policies WHERE id = $1 AND wallet_id = $2 AND status = 'active'`,
-		policyID, userID,
+		policyID, walletID,
 	).Scan(&onchainHash)
 
 	// Deactivate on-chain if the policy was registered
 	if onchainHash != nil && *onchainHash != "" {
 		policyIDBytes, decErr := blockchain.HexToBytes32(*onchainHash)
 		if decErr == nil {
-			txHash, bcErr := h.chainClients.Primary().DeactivatePolicy(r.Context(), policyIDBytes)
+			txHash, bcErr := h.chainClients.Primary().DeactivatePolicy(ctx, policyIDBytes)
 			if bcErr != nil {
 				h.logger.Error().Err(bcErr).Str("policy_id", policyID.String()).Msg("on-chain policy deactivation failed (continuing with DB revoke)")
 			} else {
@@ -364,27 +509,26 @@ func (h *Handlers) RevokePolicy(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	var p Policy
 	var defBytes []byte
-	err = h.db.QueryRow(r.Context(),
+	err := h.db.QueryRow(ctx,
 		`UPDATE policies SET status = 'revoked', revoked_at = NOW(), updated_at = NOW()
 		 WHERE id = $1 AND wallet_id = $2 AND status = 'active'
-		 RETURNING id, wallet_id, name, description, definition, status, version, onchain_hash, created_at, updated_at, activated_at, revoked_at`,
-		policyID, userID,
-	).Scan(&p.ID, &p.WalletID, &p.Name, &p.Description, &defBytes, &p.Status, &p.Version, &p.OnchainHash, &p.CreatedAt, &p.UpdatedAt, &p.ActivatedAt, &p.RevokedAt)
+		 RETURNING id, wallet_id, name, description, definition, status, version, onchain_hash, content_hash, created_at, updated_at, activated_at, revoked_at`,
+		policyID, walletID,
+	).Scan(&p.ID, &p.WalletID, &p.Name, &p.Description, &defBytes, &p.Status, &p.Version, &p.OnchainHash, &p.ContentHash, &p.CreatedAt, &p.UpdatedAt, &p.ActivatedAt, &p.RevokedAt)
 	if err != nil {
-		respondError(w, http.StatusNotFound, "policy not found or not active")
-		return
+		return p, newHandlerError(http.StatusNotFound, "policy not found or not active")
 	}
 	json.Unmarshal(defBytes, &p.Definition)
 
-	h.auditLogger.Log(r.Context(), audit.Event{
-		WalletID:  userID,
+	h.auditLogger.Log(ctx, audit.Event{
+		WalletID:  walletID,
 		PolicyID:  &policyID,
 		EventType: "policy.revoked",
 	})
+	h.revokeBundles(ctx, walletID)
 
-	respondJSON(w, http.StatusOK, p)
+	return p, nil
 }
 
 func (h *Handlers) ReactivatePolicy(w http.ResponseWriter, r *http.Request) {
@@ -397,52 +541,190 @@ func (h *Handlers) ReactivatePolicy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	p, err := h.reactivatePolicy(r.Context(), userID, policyID)
+	if err != nil {
+		respondHandlerError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, p)
+}
+
+// reactivatePolicy re-enables a revoked policy on-chain (if it was registered) and in the DB.
+func (h *Handlers) reactivatePolicy(ctx context.Context, walletID, policyID uuid.UUID) (Policy, error) {
+	var p Policy
+
 	// Verify the policy is currently revoked and get its on-chain hash
 	var onchainHash *string
-	err = h.db.QueryRow(r.Context(),
+	err := h.db.QueryRow(ctx,
 		`SELECT onchain_hash FROM policies WHERE id = $1 AND wallet_id = $2 AND status = 'revoked'`,
-		policyID, userID,
+		policyID, walletID,
 	).Scan(&onchainHash)
 	if err != nil {
-		respondError(w, http.StatusNotFound, "policy not found or not revoked")
-		return
+		return p, newHandlerError(http.StatusNotFound, "policy not found or not revoked")
 	}
 
 	// Reactivate on-chain if the policy was previously registered
 	if onchainHash != nil && *onchainHash != "" {
 		policyIDBytes, decErr := blockchain.HexToBytes32(*onchainHash)
 		if decErr == nil {
-			txHash, bcErr := h.chainClients.Primary().ReactivatePolicy(r.Context(), policyIDBytes)
+			txHash, bcErr := h.chainClients.Primary().ReactivatePolicy(ctx, policyIDBytes)
 			if bcErr != nil {
 				h.logger.Error().Err(bcErr).Str("policy_id", policyID.String()).Msg("on-chain policy reactivation failed")
-				respondError(w, http.StatusInternalServerError, "on-chain policy reactivation failed: "+bcErr.Error())
-				return
+				return p, newHandlerError(http.StatusInternalServerError, "on-chain policy reactivation failed: "+bcErr.Error())
 			}
 			h.logger.Info().Str("policy_id", policyID.String()).Str("tx_hash", txHash).Msg("policy reactivated on-chain")
 		}
 	}
 
 	// Reactivate in DB
-	var p Policy
 	var defBytes []byte
-	err = h.db.QueryRow(r.Context(),
+	err = h.db.QueryRow(ctx,
 		`UPDATE policies SET status = 'active', revoked_at = NULL, updated_at = NOW()
 		 WHERE id = $1 AND wallet_id = $2 AND status = 'revoked'
-		 RETURNING id, wallet_id, name, description, definition, status, version, onchain_hash, created_at, updated_at, activated_at, revoked_at`,
-		policyID, userID,
-	).Scan(&p.ID, &p.WalletID, &p.Name, &p.Description, &defBytes, &p.Status, &p.Version, &p.OnchainHash, &p.CreatedAt, &p.UpdatedAt, &p.ActivatedAt, &p.RevokedAt)
+		 RETURNING id, wallet_id, name, description, definition, status, version, onchain_hash, content_hash, created_at, updated_at, activated_at, revoked_at`,
+		policyID, walletID,
+	).Scan(&p.ID, &p.WalletID, &p.Name, &p.Description, &defBytes, &p.Status, &p.Version, &p.OnchainHash, &p.ContentHash, &p.CreatedAt, &p.UpdatedAt, &p.ActivatedAt, &p.RevokedAt)
 	if err != nil {
-		respondError(w, http.StatusNotFound, "policy not found or not revoked")
-		return
+		return p, newHandlerError(http.StatusNotFound, "policy not found or not revoked")
 	}
 	json.Unmarshal(defBytes, &p.Definition)
 
-	h.auditLogger.Log(r.Context(), audit.Event{
-		WalletID:  userID,
+	h.auditLogger.Log(ctx, audit.Event{
+		WalletID:  walletID,
 		PolicyID:  &policyID,
 		EventType: "policy.reactivated",
 		Details:   map[string]interface{}{"simulated": h.chainClients.Primary().IsSimulated()},
 	})
 
-	respondJSON(w, http.StatusOK, p)
+	return p, nil
+}
+
+type PolicyVerification struct {
+	PolicyID            uuid.UUID `json:"policy_id"`
+	Version             int       `json:"version"`
+	OnchainPolicyID     string    `json:"onchain_policy_id"`
+	CanonicalDefinition string    `json:"canonical_definition"`
+	ComputedContentHash string    `json:"computed_content_hash"`
+	StoredContentHash   *string   `json:"stored_content_hash,omitempty"`
+	OnchainContentHash  *string   `json:"onchain_content_hash,omitempty"`
+	OnchainVersion      *string   `json:"onchain_version,omitempty"`
+	OnchainActive       *bool     `json:"onchain_active,omitempty"`
+	OnchainOwner        *string   `json:"onchain_owner,omitempty"`
+	HashScheme          string    `json:"hash_scheme"`
+	Verified            bool      `json:"verified"`
+	Simulated           bool      `json:"simulated"`
+	Reason              string    `json:"reason,omitempty"`
+	// RegisteredContentHash and RegisteredVersion are what the service last
+	// registered on-chain for this policy.
+	RegisteredContentHash *string `json:"registered_content_hash,omitempty"`
+	RegisteredVersion     *int    `json:"registered_version,omitempty"`
+}
+
+// VerifyPolicy proves that the content hash registered in the PolicyRegistry
+// matches the stored policy definition.
+// GET /api/v1/policies/{id}/verify
+func (h *Handlers) VerifyPolicy(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	policyIDStr := r.PathValue("id")
+
+	policyID, err := uuid.Parse(policyIDStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid policy id")
+		return
+	}
+
+	var defBytes []byte
+	var version int
+	var onchainHash, storedHash, registeredHash *string
+	var registeredVersion *int
+	err = h.db.QueryRow(r.Context(),
+		`SELECT definition, version, onchain_hash, content_hash, registered_content_hash, registered_version
+		 FROM policies WHERE id = $1 AND wallet_id = $2 AND status != 'deleted'`,
+		policyID, userID,
+	).Scan(&defBytes, &version, &onchainHash, &storedHash, &registeredHash, &registeredVersion)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "policy not found")
+		return
+	}
+
+	if onchainHash == nil || *onchainHash == "" {
+		respondError(w, http.StatusBadRequest, "policy has not been registered on-chain (use Activate first)")
+		return
+	}
+
+	onchainPolicyID, err := blockchain.HexToBytes32(*onchainHash)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "stored on-chain policy id is invalid")
+		return
+	}
+
+	var def policy.Definition
+	if err := json.Unmarshal(defBytes, &def); err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to parse policy definition")
+		return
+	}
+	computed, canonical, err := policy.ContentHash(&def)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to canonicalize policy definition")
+		return
+	}
+
+	bc := h.chainClients.Primary()
+	v := PolicyVerification{
+		PolicyID:            policyID,
+		Version:             version,
+		OnchainPolicyID:     *onchainHash,
+		CanonicalDefinition: string(canonical),
+		ComputedContentHash: policy.ContentHashHex(computed),
+		StoredContentHash:   storedHash,
+		HashScheme:          "canonical",
+
+		RegisteredContentHash: registeredHash,
+		RegisteredVersion:     registeredVersion,
+		Simulated:           bc.IsSimulated(),
+	}
+
+	onchain, err := bc.GetPolicy(r.Context(), onchainPolicyID)
+	if err != nil {
+		h.logger.Error().Err(err).Str("policy_id", policyID.String()).Msg("failed to read policy from PolicyRegistry")
+		respondError(w, http.StatusBadGateway, "failed to read on-chain policy: "+err.Error())
+		return
+	}
+
+	if onchain == nil {
+		// Simulated mode derives the policy id from the content hash, so the
+		// stored id can still be checked deterministically. After an update
+		// the id keeps the first hash; only the recorded one can be compared.
+		v.Verified = onchainPolicyID == blockchain.SimulatedPolicyID(computed) ||
+			(registeredHash != nil && *registeredHash == v.ComputedContentHash)
+		if !v.Verified && onchainPolicyID == blockchain.SimulatedPolicyID(blockchain.PolicyContentHash(defBytes)) {
+			v.Verified = true
+			v.HashScheme = "legacy_jsonb"
+		}
+	} else {
+		onchainContentHash := policy.ContentHashHex(onchain.ContentHash)
+		onchainVersion := onchain.Version.String()
+		owner := onchain.Owner.Hex()
+		v.OnchainContentHash = &onchainContentHash
+		v.OnchainVersion = &onchainVersion
+		v.OnchainActive = &onchain.Active
+		v.OnchainOwner = &owner
+		v.Verified = onchain.ContentHash == computed
+		if !v.Verified && onchain.ContentHash == blockchain.PolicyContentHash(defBytes) {
+			// Activated before canonical hashing was introduced.
+			v.Verified = true
+			v.HashScheme = "legacy_jsonb"
+		}
+	}
+
+	if !v.Verified && registeredHash != nil && *registeredHash != v.ComputedContentHash {
+		v.Reason = "definition drifted since it was registered on-chain"
+	} else if !v.Verified {
+		v.Reason = "on-chain content hash does not match the stored definition"
+	} else if storedHash != nil && *storedHash != v.ComputedContentHash {
+		v.Reason = "stored content hash is stale; the definition changed after it was recorded"
+	}
+
+	respondJSON(w, http.StatusOK, v)
 }
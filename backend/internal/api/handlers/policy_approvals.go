@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/google/uuid"
+
+	"github.com/erc8004/policy-saas/internal/api/middleware"
+	"github.com/erc8004/policy-saas/internal/blockchain"
+	"github.com/erc8004/policy-saas/pkg/policyeval"
+)
+
+// ownerApproval is a verified owner signature over one policy version.
+type ownerApproval struct {
+	Signature string
+	// Type is "eoa" for a plain wallet signature or "eip1271" for one a
+	// contract wallet accepted.
+	Type string
+}
+
+// checkOwnerApproval verifies sigHex as the wallet owner's EIP-712 approval
+// of version of policyID with contentHash. Without a signature it returns
+// nil, unless the wallet requires one.
+func (h *Handlers) checkOwnerApproval(ctx context.Context, walletID, policyID uuid.UUID, version int, contentHash [32]byte, sigHex string) (*ownerApproval, error) {
+	var address string
+	var required bool
+	err := h.db.QueryRow(ctx,
+		`SELECT address, require_policy_signatures FROM wallets WHERE id = $1`, walletID,
+	).Scan(&address, &required)
+	if err != nil {
+		return nil, newHandlerError(http.StatusNotFound, "wallet not found")
+	}
+	if sigHex == "" {
+		if required {
+			return nil, newHandlerError(http.StatusForbidden, "this wallet requires the owner's signature over the policy version (owner_signature)")
+		}
+		return nil, nil
+	}
+
+	sig, err := hexutil.Decode(sigHex)
+	if err != nil {
+		return nil, newHandlerError(http.StatusBadRequest, "owner_signature: invalid hex")
+	}
+	approval := blockchain.PolicyApproval{
+		Wallet:      common.HexToAddress(address),
+		PolicyID:    blockchain.UUIDToBytes32(policyID.String()),
+		Version:     int64(version),
+		ContentHash: contentHash,
+	}
+	sigType, err := h.verifyOwnerSignature(ctx, approval.Wallet, approval.Digest(h.cfg.Blockchain.ChainID), sig)
+	if err != nil {
+		return nil, newHandlerError(http.StatusForbidden, "owner_signature: "+err.Error())
+	}
+	return &ownerApproval{Signature: hexutil.Encode(sig), Type: sigType}, nil
+}
+
+// verifyOwnerSignature checks that owner signed digest, by recovery for a
+// plain wallet or through EIP-1271 for a contract wallet.
+func (h *Handlers) verifyOwnerSignature(ctx context.Context, owner common.Address, digest [32]byte, sig []byte) (string, error) {
+	if len(sig) == 65 {
+		if signer, err := policyeval.RecoverSigner(digest, sig); err == nil && signer == owner {
+			return "eoa", nil
+		}
+	}
+	ok, err := h.chainClients.Primary().IsValidSignature(ctx, owner, digest, sig)
+	if err != nil {
+		if len(sig) == 65 {
+			return "", errors.New("not signed by the wallet owner")
+		}
+		return "", err
+	}
+	if !ok {
+		return "", errors.New("not signed by the wallet owner")
+	}
+	return "eip1271", nil
+}
+
+// recordOwnerApproval stores an approval on its policy version, together
+// with the content it approved.
+func (h *Handlers) recordOwnerApproval(ctx context.Context, walletID, policyID uuid.UUID, version int, defBytes []byte, canonical, contentHashHex string, approval *ownerApproval) {
+	_, err := h.db.Exec(ctx,
+		`INSERT INTO policy_versions (policy_id, version, definition, created_by, canonical_definition, content_hash, owner_signature, owner_signature_type, signed_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+		 ON CONFLICT (policy_id, version) DO UPDATE SET
+			definition = EXCLUDED.definition,
+			canonical_definition = EXCLUDED.canonical_definition,
+			content_hash = EXCLUDED.content_hash,
+			owner_signature = EXCLUDED.owner_signature,
+			owner_signature_type = EXCLUDED.owner_signature_type,
+			signed_at = EXCLUDED.signed_at`,
+		policyID, version, defBytes, walletID, canonical, contentHashHex, approval.Signature, approval.Type,
+	)
+	if err != nil {
+		h.logger.Error().Err(err).Str("policy_id", policyID.String()).Int("version", version).Msg("failed to record owner approval")
+	}
+}
+
+type PolicyApprovalResponse struct {
+	PolicyID      uuid.UUID  `json:"policy_id"`
+	Version       int        `json:"version"`
+	Wallet        string     `json:"wallet"`
+	ContentHash   string     `json:"content_hash"`
+	Signature     *string    `json:"signature,omitempty"`
+	SignatureType *string    `json:"signature_type,omitempty"`
+	SignedAt      *time.Time `json:"signed_at,omitempty"`
+	ChainID       int64      `json:"chain_id"`
+	// Valid is the result of verifying the stored signature again now,
+	// against the stored canonical definition.
+	Valid  bool   `json:"valid"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// GetPolicyApproval re-verifies the owner's signature on a policy version.
+func (h *Handlers) GetPolicyApproval(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	policyID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid policy id")
+		return
+	}
+	version, err := strconv.Atoi(r.PathValue("version"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid version")
+		return
+	}
+
+	resp := PolicyApprovalResponse{PolicyID: policyID, Version: version, ChainID: h.cfg.Blockchain.ChainID}
+	var canonical, contentHash *string
+	err = h.db.QueryRow(r.Context(),
+		`SELECT w.address, pv.canonical_definition, pv.content_hash, pv.owner_signature, pv.owner_signature_type, pv.signed_at
+		 FROM policy_versions pv
+		 JOIN policies p ON p.id = pv.policy_id
+		 JOIN wallets w ON w.id = p.wallet_id
+		 WHERE pv.policy_id = $1 AND pv.version = $2 AND p.wallet_id = $3`,
+		policyID, version, userID,
+	).Scan(&resp.Wallet, &canonical, &contentHash, &resp.Signature, &resp.SignatureType, &resp.SignedAt)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "policy version not found")
+		return
+	}
+	if contentHash != nil {
+		resp.ContentHash = *contentHash
+	}
+
+	switch {
+	case resp.Signature == nil:
+		resp.Reason = "version was not signed by the owner"
+	case canonical == nil || contentHash == nil:
+		resp.Reason = "version has no canonical definition"
+	default:
+		hash, err := blockchain.HexToBytes32(*contentHash)
+		if err != nil || blockchain.PolicyContentHash([]byte(*canonical)) != hash {
+			resp.Reason = "stored definition does not match its content hash"
+			break
+		}
+		sig, err := hexutil.Decode(*resp.Signature)
+		if err != nil {
+			resp.Reason = "stored signature is malformed"
+			break
+		}
+		approval := blockchain.PolicyApproval{
+			Wallet:      common.HexToAddress(resp.Wallet),
+			PolicyID:    blockchain.UUIDToBytes32(policyID.String()),
+			Version:     int64(version),
+			ContentHash: hash,
+		}
+		if _, err := h.verifyOwnerSignature(r.Context(), approval.Wallet, approval.Digest(resp.ChainID), sig); err != nil {
+			resp.Reason = err.Error()
+			break
+		}
+		resp.Valid = true
+	}
+
+	respondJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/google/uuid"
+
+	"github.com/erc8004/policy-saas/internal/blockchain"
+	"github.com/erc8004/policy-saas/internal/domain/policy"
+)
+
+// PublicOnchainCheck is the live PolicyRegistry.isPermissionValid answer for
+// a minted permission.
+type PublicOnchainCheck struct {
+	Checked bool   `json:"checked"`
+	Valid   *bool  `json:"valid,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// PublicPolicyDetails is shown only when the owner opted in.
+type PublicPolicyDetails struct {
+	Name       string            `json:"name"`
+	Definition policy.Definition `json:"definition"`
+}
+
+type PublicPermission struct {
+	ID                uuid.UUID            `json:"id"`
+	OnchainID         *string              `json:"onchain_id"`
+	PolicyOnchainID   *string              `json:"policy_onchain_id"`
+	PolicyContentHash *string              `json:"policy_content_hash"`
+	PolicyVersion     int                  `json:"policy_version"`
+	ValidFrom         time.Time            `json:"valid_from"`
+	ValidUntil        *time.Time           `json:"valid_until"`
+	Onchain           PublicOnchainCheck   `json:"onchain"`
+	Policy            *PublicPolicyDetails `json:"policy,omitempty"`
+}
+
+type PublicAgentPermissions struct {
+	AgentID      string             `json:"agent_id"`
+	SmartAccount *string            `json:"smart_account"`
+	Status       string             `json:"status"`
+	ChainID      int64              `json:"chain_id"`
+	Permissions  []PublicPermission `json:"permissions"`
+	CheckedAt    time.Time          `json:"checked_at"`
+}
+
+// GetPublicAgentPermissions lets third-party protocols check what an agent
+// is allowed to do, without an account. The agent is addressed by its
+// on-chain agent ID (bytes32) or its smart-account address. Policy contents
+// stay private unless the owner enabled public_policy_details.
+func (h *Handlers) GetPublicAgentPermissions(w http.ResponseWriter, r *http.Request) {
+	ref := strings.ToLower(r.PathValue("agentId"))
+
+	var lookup string
+	var arg interface{}
+	switch {
+	case len(ref) == 66 && strings.HasPrefix(ref, "0x"):
+		b, err := blockchain.HexToBytes32(ref)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid agent id")
+			return
+		}
+		id, err := blockchain.Bytes32ToUUID(b)
+		if err != nil {
+			respondError(w, http.StatusNotFound, "agent not found")
+			return
+		}
+		lookup, arg = `a.id = $1`, id
+	case common.IsHexAddress(ref):
+		lookup, arg = `LOWER(sa.account_address) = $1`, ref
+	default:
+		respondError(w, http.StatusBadRequest, "expected a bytes32 agent id or a smart-account address")
+		return
+	}
+
+	var agentID, walletID uuid.UUID
+	var showDetails bool
+	resp := PublicAgentPermissions{
+		ChainID:     h.chainClients.Primary().ChainID(),
+		Permissions: []PublicPermission{},
+	}
+	err := h.db.QueryRow(r.Context(),
+		`SELECT a.id, a.wallet_id, a.status, sa.account_address, w.public_policy_details
+		 FROM agents a
+		 JOIN wallets w ON w.id = a.wallet_id
+		 LEFT JOIN smart_accounts sa ON sa.agent_id = a.id
+		 WHERE `+lookup+` AND a.status != 'deleted'`,
+		arg,
+	).Scan(&agentID, &walletID, &resp.Status, &resp.SmartAccount, &showDetails)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "agent not found")
+		return
+	}
+	resp.AgentID = policy.ContentHashHex(blockchain.UUIDToBytes32(agentID.String()))
+
+	// Task sessions are excluded: they are short-lived and their scope is
+	// private to the owner's workflow.
+	rows, err := h.db.Query(r.Context(),
+		`SELECT p.id, p.onchain_token_id, p.valid_from, p.valid_until,
+			pol.onchain_hash, pol.content_hash, pol.version, pol.name, pol.definition
+		 FROM permissions p
+		 JOIN policies pol ON pol.id = p.policy_id
+		 WHERE p.agent_id = $1 AND p.wallet_id = $2 AND p.status = 'active' AND p.task_id IS NULL
+		   AND (p.valid_until IS NULL OR p.valid_until > NOW())
+		 ORDER BY p.valid_from`,
+		agentID, walletID,
+	)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list permissions")
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p PublicPermission
+		var name string
+		var defJSON []byte
+		if err := rows.Scan(&p.ID, &p.OnchainID, &p.ValidFrom, &p.ValidUntil,
+			&p.PolicyOnchainID, &p.PolicyContentHash, &p.PolicyVersion, &name, &defJSON); err != nil {
+			continue
+		}
+		if showDetails {
+			details := &PublicPolicyDetails{Name: name}
+			if json.Unmarshal(defJSON, &details.Definition) == nil {
+				p.Policy = details
+			}
+		}
+		resp.Permissions = append(resp.Permissions, p)
+	}
+	rows.Close()
+
+	bc := h.chainClients.Primary()
+	for i := range resp.Permissions {
+		p := &resp.Permissions[i]
+		if p.OnchainID == nil {
+			p.Onchain.Error = "permission is not minted"
+			continue
+		}
+		permID, err := blockchain.HexToBytes32(*p.OnchainID)
+		if err != nil {
+			p.Onchain.Error = "stored on-chain id is malformed"
+			continue
+		}
+		if bc.IsSimulated() {
+			p.Onchain.Error = "on-chain checks are unavailable in simulated mode"
+			continue
+		}
+		valid, err := bc.IsPermissionValid(r.Context(), permID)
+		if err != nil {
+			h.logger.Warn().Err(err).Str("permission_id", p.ID.String()).Msg("public on-chain permission check failed")
+			p.Onchain.Error = "on-chain check failed"
+			continue
+		}
+		p.Onchain.Checked = true
+		p.Onchain.Valid = &valid
+	}
+
+	resp.CheckedAt = time.Now().UTC()
+	respondJSON(w, http.StatusOK, resp)
+}
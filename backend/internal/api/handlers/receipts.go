@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/google/uuid"
+
+	"github.com/erc8004/policy-saas/internal/blockchain"
+	"github.com/erc8004/policy-saas/internal/domain/policy"
+	"github.com/erc8004/policy-saas/pkg/policyeval"
+)
+
+// ValidationReceipt is an EIP-712 signed validation decision. The hashes
+// are 0x-prefixed bytes32; ExpiresAt is a unix timestamp.
+type ValidationReceipt struct {
+	RequestID  uuid.UUID `json:"request_id"`
+	AgentID    uuid.UUID `json:"agent_id"`
+	ActionHash string    `json:"action_hash"`
+	Allowed    bool      `json:"allowed"`
+	PolicyHash string    `json:"policy_hash"`
+	ExpiresAt  int64     `json:"expires_at"`
+	ChainID    int64     `json:"chain_id"`
+	Signer     string    `json:"signer"`
+	Signature  string    `json:"signature"`
+}
+
+// typed returns the receipt's signed content.
+func (r *ValidationReceipt) typed() (blockchain.ValidationReceipt, error) {
+	actionHash, err := blockchain.HexToBytes32(r.ActionHash)
+	if err != nil {
+		return blockchain.ValidationReceipt{}, errors.New("action_hash: " + err.Error())
+	}
+	policyHash, err := blockchain.HexToBytes32(r.PolicyHash)
+	if err != nil {
+		return blockchain.ValidationReceipt{}, errors.New("policy_hash: " + err.Error())
+	}
+	return blockchain.ValidationReceipt{
+		RequestID:  blockchain.UUIDToBytes32(r.RequestID.String()),
+		AgentID:    blockchain.UUIDToBytes32(r.AgentID.String()),
+		ActionHash: actionHash,
+		Allowed:    r.Allowed,
+		PolicyHash: policyHash,
+		ExpiresAt:  r.ExpiresAt,
+	}, nil
+}
+
+// signReceipt signs the decision on an action with the service key.
+func (h *Handlers) signReceipt(ctx context.Context, requestID, agentID uuid.UUID, action *policy.Action, result policy.ValidationResult) (*ValidationReceipt, error) {
+	if h.serviceSigner == nil {
+		return nil, errors.New("signed receipts are not configured")
+	}
+
+	actionHash, err := policy.ActionDigest(action)
+	if err != nil {
+		return nil, err
+	}
+	var policyHash [32]byte
+	if result.Allowed && result.PolicyID != nil {
+		var contentHash *string
+		h.db.QueryRow(ctx, `SELECT content_hash FROM policies WHERE id = $1`, *result.PolicyID).Scan(&contentHash)
+		if contentHash == nil {
+			return nil, errors.New("policy has no content hash")
+		}
+		if policyHash, err = blockchain.HexToBytes32(*contentHash); err != nil {
+			return nil, err
+		}
+	}
+
+	receipt := blockchain.ValidationReceipt{
+		RequestID:  blockchain.UUIDToBytes32(requestID.String()),
+		AgentID:    blockchain.UUIDToBytes32(agentID.String()),
+		ActionHash: actionHash,
+		Allowed:    result.Allowed,
+		PolicyHash: policyHash,
+		ExpiresAt:  time.Now().Add(h.cfg.Signing.ReceiptTTL).Unix(),
+	}
+	sig, err := h.serviceSigner.SignReceipt(receipt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ValidationReceipt{
+		RequestID:  requestID,
+		AgentID:    agentID,
+		ActionHash: policy.ContentHashHex(actionHash),
+		Allowed:    result.Allowed,
+		PolicyHash: policy.ContentHashHex(policyHash),
+		ExpiresAt:  receipt.ExpiresAt,
+		ChainID:    h.serviceSigner.ChainID(),
+		Signer:     h.serviceSigner.Address().Hex(),
+		Signature:  hexutil.Encode(sig),
+	}, nil
+}
+
+type ReceiptKey struct {
+	Address string `json:"address"`
+	// Status is "active" for the key signing now and "retired" for keys
+	// whose earlier receipts still verify.
+	Status string `json:"status"`
+}
+
+type ReceiptKeySet struct {
+	Domain map[string]interface{}         `json:"domain"`
+	Types  map[string][]map[string]string `json:"types"`
+	Keys   []ReceiptKey                   `json:"keys"`
+}
+
+// receiptKeys returns the published signing keys, the active one first.
+func (h *Handlers) receiptKeys() []ReceiptKey {
+	keys := []ReceiptKey{}
+	if h.serviceSigner != nil {
+		keys = append(keys, ReceiptKey{Address: h.serviceSigner.Address().Hex(), Status: "active"})
+	}
+	for _, a := range h.cfg.Signing.RetiredSigners {
+		if common.IsHexAddress(a) {
+			keys = append(keys, ReceiptKey{Address: common.HexToAddress(a).Hex(), Status: "retired"})
+		}
+	}
+	return keys
+}
+
+// GetReceiptKeys publishes the EIP-712 domain, the receipt type and the
+// keys receipts are signed with, so anyone can verify a receipt offline.
+func (h *Handlers) GetReceiptKeys(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, ReceiptKeySet{
+		Domain: map[string]interface{}{
+			"name":    policyeval.ServiceDomainName,
+			"version": policyeval.ServiceDomainVersion,
+			"chainId": h.cfg.Blockchain.ChainID,
+		},
+		Types: map[string][]map[string]string{
+			"ValidationReceipt": {
+				{"name": "requestId", "type": "bytes32"},
+				{"name": "agentId", "type": "bytes32"},
+				{"name": "actionHash", "type": "bytes32"},
+				{"name": "allowed", "type": "bool"},
+				{"name": "policyHash", "type": "bytes32"},
+				{"name": "expiresAt", "type": "uint256"},
+			},
+		},
+		Keys: h.receiptKeys(),
+	})
+}
+
+type VerifyReceiptRequest struct {
+	Receipt ValidationReceipt `json:"receipt"`
+	// Action, if given, is checked against the receipt's action_hash.
+	Action *policy.Action `json:"action,omitempty"`
+}
+
+type VerifyReceiptResponse struct {
+	// Valid is true when the signature recovers to a published key and the
+	// action, if given, matches. An expired receipt can still be valid.
+	Valid         bool   `json:"valid"`
+	Signer        string `json:"signer,omitempty"`
+	KeyStatus     string `json:"key_status,omitempty"`
+	Expired       bool   `json:"expired"`
+	ActionMatches *bool  `json:"action_matches,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+// VerifyReceipt checks a validation receipt's signature against the
+// published keys.
+func (h *Handlers) VerifyReceipt(w http.ResponseWriter, r *http.Request) {
+	var req VerifyReceiptRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	receipt, err := req.Receipt.typed()
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	sig, err := hexutil.Decode(req.Receipt.Signature)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "signature: invalid hex")
+		return
+	}
+
+	resp := VerifyReceiptResponse{Expired: time.Now().Unix() >= req.Receipt.ExpiresAt}
+	if req.Receipt.ChainID != h.cfg.Blockchain.ChainID {
+		resp.Reason = "receipt was not issued for this service's chain"
+		respondJSON(w, http.StatusOK, resp)
+		return
+	}
+	signer, err := policyeval.RecoverSigner(receipt.Digest(req.Receipt.ChainID), sig)
+	if err != nil {
+		resp.Reason = err.Error()
+		respondJSON(w, http.StatusOK, resp)
+		return
+	}
+	resp.Signer = signer.Hex()
+	for _, k := range h.receiptKeys() {
+		if strings.EqualFold(k.Address, signer.Hex()) {
+			resp.KeyStatus = k.Status
+		}
+	}
+	if resp.KeyStatus == "" {
+		resp.Reason = "signature is not from a published key"
+		respondJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	if req.Action != nil {
+		hash, err := policy.ActionDigest(req.Action)
+		matches := err == nil && hash == receipt.ActionHash
+		resp.ActionMatches = &matches
+		if !matches {
+			resp.Reason = "action does not match the receipt"
+			respondJSON(w, http.StatusOK, resp)
+			return
+		}
+	}
+
+	resp.Valid = true
+	respondJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/erc8004/policy-saas/internal/api/middleware"
+	"github.com/erc8004/policy-saas/internal/domain/audit"
+	"github.com/erc8004/policy-saas/internal/domain/gitops"
+	"github.com/erc8004/policy-saas/internal/domain/policy"
+)
+
+const (
+	defaultRecommendDays = 30
+	maxRecommendDays     = 365
+)
+
+type RecommendPolicyRequest struct {
+	PolicyID        *uuid.UUID `json:"policy_id,omitempty"`
+	Days            int        `json:"days,omitempty"`
+	Percentile      int        `json:"percentile,omitempty"`
+	HeadroomPercent *int       `json:"headroom_percent,omitempty"`
+	Save            bool       `json:"save,omitempty"`
+}
+
+type RecommendPolicyResponse struct {
+	AgentID     uuid.UUID              `json:"agent_id"`
+	PolicyID    uuid.UUID              `json:"policy_id"`
+	PeriodStart time.Time              `json:"period_start"`
+	PeriodEnd   time.Time              `json:"period_end"`
+	Draft       CreatePolicyRequest    `json:"draft"`
+	Diff        []gitops.FieldChange   `json:"diff"`
+	Observed    policy.ObservedSummary `json:"observed"`
+	Notes       []string               `json:"notes,omitempty"`
+	SavedPolicy *Policy                `json:"saved_policy,omitempty"`
+}
+
+// RecommendPolicy proposes a least-privilege version of one of the agent's
+// policies from the actions it actually performed over a period.
+func (h *Handlers) RecommendPolicy(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	agentIDStr := r.PathValue("id")
+
+	agentID, err := uuid.Parse(agentIDStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid agent id")
+		return
+	}
+
+	var req RecommendPolicyRequest
+	if r.ContentLength > 0 {
+		if err := decodeJSON(r, &req); err != nil {
+			respondError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+
+	if req.Days == 0 {
+		req.Days = defaultRecommendDays
+	}
+	if req.Days < 1 || req.Days > maxRecommendDays {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("days must be between 1 and %d", maxRecommendDays))
+		return
+	}
+	opts := policy.DefaultRecommendOptions
+	if req.Percentile != 0 {
+		if req.Percentile < 1 || req.Percentile > 100 {
+			respondError(w, http.StatusBadRequest, "percentile must be between 1 and 100")
+			return
+		}
+		opts.Percentile = req.Percentile
+	}
+	if req.HeadroomPercent != nil {
+		if *req.HeadroomPercent < 0 {
+			respondError(w, http.StatusBadRequest, "headroom_percent must not be negative")
+			return
+		}
+		opts.HeadroomPercent = *req.HeadroomPercent
+	}
+
+	// Policies the agent currently holds
+	rows, err := h.db.Query(r.Context(),
+		`SELECT DISTINCT p.policy_id FROM permissions p
+		 JOIN agents a ON a.id = p.agent_id
+		 WHERE p.agent_id = $1 AND p.wallet_id = $2 AND p.status = 'active' AND a.status != 'deleted'`,
+		agentID, userID,
+	)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to load permissions")
+		return
+	}
+	var held []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err == nil {
+			held = append(held, id)
+		}
+	}
+	rows.Close()
+
+	var policyID uuid.UUID
+	switch {
+	case req.PolicyID != nil:
+		found := false
+		for _, id := range held {
+			if id == *req.PolicyID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			respondError(w, http.StatusNotFound, "agent has no active permission for this policy")
+			return
+		}
+		policyID = *req.PolicyID
+	case len(held) == 1:
+		policyID = held[0]
+	case len(held) == 0:
+		respondError(w, http.StatusNotFound, "agent has no active permissions")
+		return
+	default:
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("agent holds %d policies; specify policy_id", len(held)))
+		return
+	}
+
+	var policyName string
+	var current policy.Definition
+	var defBytes []byte
+	err = h.db.QueryRow(r.Context(),
+		`SELECT name, definition FROM policies WHERE id = $1 AND wallet_id = $2`,
+		policyID, userID,
+	).Scan(&policyName, &defBytes)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "policy not found")
+		return
+	}
+	json.Unmarshal(defBytes, &current)
+
+	end := time.Now()
+	start := end.AddDate(0, 0, -req.Days)
+	observations, err := h.policyEngine.ObservedActions(r.Context(), userID, agentID, &policyID, start)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("failed to load observed actions")
+		respondError(w, http.StatusInternalServerError, "failed to load observed actions")
+		return
+	}
+
+	rec := policy.Recommend(current, observations, opts)
+	if len(held) > 1 && rec.Observed.Executions > 0 {
+		rec.Notes = append(rec.Notes, "on-chain executions cannot be attributed to a single policy; all of the agent's executions were included")
+	}
+	if err := h.policyEngine.ValidateDefinition(&rec.Definition); err != nil {
+		respondError(w, http.StatusUnprocessableEntity, "recommended definition is invalid: "+err.Error())
+		return
+	}
+
+	diff := gitops.DiffDefinitions(&current, &rec.Definition)
+	if diff == nil {
+		diff = []gitops.FieldChange{}
+	}
+
+	resp := RecommendPolicyResponse{
+		AgentID:     agentID,
+		PolicyID:    policyID,
+		PeriodStart: start,
+		PeriodEnd:   end,
+		Draft: CreatePolicyRequest{
+			Name:        policyName + " (least privilege)",
+			Description: fmt.Sprintf("Recommended from %d observed actions over %d days", len(observations), req.Days),
+			Definition:  rec.Definition,
+		},
+		Diff:     diff,
+		Observed: rec.Observed,
+		Notes:    rec.Notes,
+	}
+
+	if req.Save {
+		p, err := h.createPolicy(r.Context(), userID, resp.Draft)
+		if err != nil {
+			respondHandlerError(w, err)
+			return
+		}
+		resp.SavedPolicy = &p
+	}
+
+	h.auditLogger.Log(r.Context(), audit.Event{
+		WalletID:  userID,
+		AgentID:   &agentID,
+		PolicyID:  &policyID,
+		EventType: "policy.recommended",
+		Details: map[string]interface{}{
+			"days":         req.Days,
+			"observations": len(observations),
+			"changes":      len(diff),
+			"saved":        req.Save,
+		},
+	})
+
+	respondJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/erc8004/policy-saas/internal/api/middleware"
+	"github.com/erc8004/policy-saas/internal/domain/audit"
+)
+
+// maxRotationOverlap bounds how long a rotated permission stays valid next
+// to its successor.
+const maxRotationOverlap = 7 * 24 * time.Hour
+
+type RotatePermissionRequest struct {
+	ValidFrom  *time.Time `json:"valid_from,omitempty"`
+	ValidUntil *time.Time `json:"valid_until,omitempty"`
+	// PolicyID moves the successor to another policy; by default it keeps
+	// the predecessor's policy at its current version.
+	PolicyID *uuid.UUID `json:"policy_id,omitempty"`
+	// OverlapSeconds keeps the predecessor valid for that long after the
+	// successor starts; 0 revokes it as soon as the successor is issued.
+	OverlapSeconds int `json:"overlap_seconds,omitempty"`
+	// Mint mints the successor. Defaults to whether the predecessor was minted.
+	Mint *bool `json:"mint,omitempty"`
+}
+
+type RotatePermissionResponse struct {
+	Predecessor Permission `json:"predecessor"`
+	Successor   Permission `json:"successor"`
+}
+
+// RotatePermission issues a successor to an active permission, mints it and
+// syncs its constraints, then revokes the predecessor once the overlap ends.
+func (h *Handlers) RotatePermission(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	permID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid permission id")
+		return
+	}
+
+	var req RotatePermissionRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	resp, err := h.rotatePermission(r.Context(), userID, permID, req)
+	if err != nil {
+		respondHandlerError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, resp)
+}
+
+func (h *Handlers) rotatePermission(ctx context.Context, walletID, permID uuid.UUID, req RotatePermissionRequest) (RotatePermissionResponse, error) {
+	var resp RotatePermissionResponse
+
+	var pred Permission
+	err := scanPermission(h.db.QueryRow(ctx,
+		`SELECT `+permissionColumns+` FROM permissions WHERE id = $1 AND wallet_id = $2 AND status = 'active'`,
+		permID, walletID,
+	), &pred)
+	if err != nil {
+		return resp, newHandlerError(http.StatusNotFound, "permission not found or not active")
+	}
+	if err := h.checkNotGroupGranted(ctx, permID); err != nil {
+		return resp, err
+	}
+	if pred.TaskID != nil {
+		return resp, newHandlerError(http.StatusBadRequest, "task sessions cannot be rotated")
+	}
+	if pred.RetireAt != nil {
+		return resp, newHandlerError(http.StatusConflict, "permission has already been rotated")
+	}
+
+	overlap := time.Duration(req.OverlapSeconds) * time.Second
+	if overlap < 0 || overlap > maxRotationOverlap {
+		return resp, newHandlerError(http.StatusBadRequest, "overlap_seconds must be between 0 and "+maxRotationOverlap.String())
+	}
+
+	now := time.Now()
+	validFrom := now
+	if req.ValidFrom != nil {
+		validFrom = *req.ValidFrom
+	}
+	validUntil := req.ValidUntil
+	if validUntil == nil && pred.ValidUntil != nil && pred.ValidUntil.After(validFrom) {
+		validUntil = pred.ValidUntil
+	}
+	if validUntil != nil && (!validUntil.After(validFrom) || !validUntil.After(now)) {
+		return resp, newHandlerError(http.StatusBadRequest, "valid_until must be in the future and after valid_from")
+	}
+
+	policyID := pred.PolicyID
+	if req.PolicyID != nil {
+		// A delegated permission's policy was checked against its parent
+		if pred.DelegatedFrom != nil && *req.PolicyID != pred.PolicyID {
+			return resp, newHandlerError(http.StatusBadRequest, "a delegated permission keeps its policy on rotation")
+		}
+		policyID = *req.PolicyID
+	}
+	mint := pred.OnchainTokenID != nil
+	if req.Mint != nil {
+		mint = *req.Mint
+	}
+
+	succ, err := h.createPermission(ctx, walletID, CreatePermissionRequest{
+		AgentID:    pred.AgentID,
+		PolicyID:   policyID,
+		ValidFrom:  &validFrom,
+		ValidUntil: validUntil,
+	})
+	if err != nil {
+		return resp, err
+	}
+	if _, err := h.db.Exec(ctx,
+		`UPDATE permissions SET rotated_from = $1, delegated_from = $3 WHERE id = $2`, permID, succ.ID, pred.DelegatedFrom,
+	); err != nil {
+		h.abandonSuccessor(ctx, walletID, succ)
+		return resp, newHandlerError(http.StatusConflict, "permission already has an active successor")
+	}
+	succ.RotatedFrom = &permID
+	succ.DelegatedFrom = pred.DelegatedFrom
+
+	if mint {
+		minted, err := h.mintPermission(ctx, walletID, succ.ID)
+		if err != nil {
+			h.abandonSuccessor(ctx, walletID, succ)
+			return resp, err
+		}
+		succ = minted
+	}
+
+	// The predecessor covers the agent until the successor starts, plus the overlap
+	retireAt := validFrom
+	if retireAt.Before(now) {
+		retireAt = now
+	}
+	retireAt = retireAt.Add(overlap)
+
+	// Revoke right away when there is no overlap; the sweeper retires it
+	// otherwise, and also picks it up if the immediate revocation fails
+	revoked := false
+	if !retireAt.After(now) {
+		if err := h.revokePermission(ctx, walletID, permID); err != nil {
+			h.logger.Error().Err(err).Str("permission_id", permID.String()).Msg("failed to revoke rotated permission")
+		} else {
+			revoked = true
+		}
+	}
+	if !revoked {
+		h.db.Exec(ctx,
+			`UPDATE permissions SET retire_at = $1 WHERE id = $2 AND wallet_id = $3`,
+			retireAt, permID, walletID,
+		)
+	}
+
+	if err := scanPermission(h.db.QueryRow(ctx,
+		`SELECT `+permissionColumns+` FROM permissions WHERE id = $1`, permID,
+	), &resp.Predecessor); err != nil {
+		resp.Predecessor = pred
+	}
+	resp.Successor = succ
+
+	var version int
+	h.db.QueryRow(ctx, `SELECT version FROM policies WHERE id = $1`, policyID).Scan(&version)
+	h.auditLogger.Log(ctx, audit.Event{
+		WalletID:     walletID,
+		AgentID:      &pred.AgentID,
+		PolicyID:     &policyID,
+		PermissionID: &succ.ID,
+		EventType:    "permission.rotated",
+		Details: map[string]interface{}{
+			"predecessor_id":     permID,
+			"successor_id":       succ.ID,
+			"previous_policy_id": pred.PolicyID,
+			"policy_version":     version,
+			"retire_at":          retireAt,
+			"minted":             succ.OnchainTokenID != nil,
+		},
+	})
+
+	return resp, nil
+}
+
+// abandonSuccessor revokes a successor whose rotation could not complete,
+// leaving the predecessor in place.
+func (h *Handlers) abandonSuccessor(ctx context.Context, walletID uuid.UUID, succ Permission) {
+	h.db.Exec(ctx,
+		`UPDATE permissions SET status = 'revoked', revoked_at = NOW(), rotated_from = NULL
+		 WHERE id = $1 AND wallet_id = $2`,
+		succ.ID, walletID,
+	)
+	h.auditLogger.Log(ctx, audit.Event{
+		WalletID:     walletID,
+		AgentID:      &succ.AgentID,
+		PolicyID:     &succ.PolicyID,
+		PermissionID: &succ.ID,
+		EventType:    "permission.revoked",
+		Details:      map[string]interface{}{"reason": "rotation failed"},
+	})
+}
+
+// GetPermissionLineage returns the rotation chain a permission belongs to,
+// oldest first.
+func (h *Handlers) GetPermissionLineage(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	permID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid permission id")
+		return
+	}
+
+	rows, err := h.db.Query(r.Context(),
+		`WITH RECURSIVE ancestors AS (
+		   SELECT id, rotated_from FROM permissions WHERE id = $1 AND wallet_id = $2
+		   UNION
+		   SELECT p.id, p.rotated_from FROM permissions p JOIN ancestors a ON p.id = a.rotated_from
+		 ), root AS (
+		   SELECT id FROM ancestors WHERE rotated_from IS NULL
+		 ), chain AS (
+		   SELECT id FROM root
+		   UNION
+		   SELECT p.id FROM permissions p JOIN chain c ON p.rotated_from = c.id
+		 )
+		 SELECT `+permissionColumns+` FROM permissions
+		 WHERE id IN (SELECT id FROM chain) AND wallet_id = $2
+		 ORDER BY created_at`,
+		permID, userID,
+	)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to load lineage")
+		return
+	}
+	defer rows.Close()
+
+	lineage := []Permission{}
+	for rows.Next() {
+		var p Permission
+		if err := scanPermission(rows, &p); err != nil {
+			continue
+		}
+		lineage = append(lineage, p)
+	}
+
+	if len(lineage) == 0 {
+		respondError(w, http.StatusNotFound, "permission not found")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, lineage)
+}
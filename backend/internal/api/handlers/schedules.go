@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/erc8004/policy-saas/internal/api/middleware"
+	"github.com/erc8004/policy-saas/internal/domain/audit"
+)
+
+const (
+	// maxScheduleAhead bounds how far in the future an operation may run.
+	maxScheduleAhead = 365 * 24 * time.Hour
+	// maxScheduleAttempts bounds the retries of a failing operation.
+	maxScheduleAttempts = 10
+	// staleScheduleRun is how long a claimed operation may stay running before
+	// another scheduler tick assumes its process died and runs it again.
+	staleScheduleRun = 15 * time.Minute
+)
+
+// scheduleTargets maps each operation to the table its target lives in.
+var scheduleTargets = map[string]string{
+	"activate_policy":   "policies",
+	"revoke_policy":     "policies",
+	"mint_permission":   "permissions",
+	"delete_permission": "permissions",
+}
+
+type ScheduledOperation struct {
+	ID            uuid.UUID  `json:"id"`
+	WalletID      uuid.UUID  `json:"wallet_id"`
+	Operation     string     `json:"operation"`
+	TargetID      uuid.UUID  `json:"target_id"`
+	RunAt         time.Time  `json:"run_at"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	MaxAttempts   int        `json:"max_attempts"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	LastError     *string    `json:"last_error,omitempty"`
+	CreatedBy     *string    `json:"created_by,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	CompletedAt   *time.Time `json:"completed_at,omitempty"`
+	CancelledAt   *time.Time `json:"cancelled_at,omitempty"`
+}
+
+type CreateScheduleRequest struct {
+	Operation string    `json:"operation"`
+	TargetID  uuid.UUID `json:"target_id"`
+	RunAt     time.Time `json:"run_at"`
+	// MaxAttempts is how often a failing operation is tried. Defaults to 3.
+	MaxAttempts int `json:"max_attempts,omitempty"`
+}
+
+const scheduledOperationColumns = `id, wallet_id, operation, target_id, run_at, status, attempts, max_attempts, next_attempt_at,
+	last_error, created_by, created_at, updated_at, completed_at, cancelled_at`
+
+func scanScheduledOperation(row interface{ Scan(...any) error }, s *ScheduledOperation) error {
+	return row.Scan(&s.ID, &s.WalletID, &s.Operation, &s.TargetID, &s.RunAt, &s.Status, &s.Attempts, &s.MaxAttempts, &s.NextAttemptAt,
+		&s.LastError, &s.CreatedBy, &s.CreatedAt, &s.UpdatedAt, &s.CompletedAt, &s.CancelledAt)
+}
+
+func (h *Handlers) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == uuid.Nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req CreateScheduleRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	table, ok := scheduleTargets[req.Operation]
+	if !ok {
+		respondError(w, http.StatusBadRequest, "operation must be activate_policy, revoke_policy, mint_permission or delete_permission")
+		return
+	}
+	now := time.Now()
+	if !req.RunAt.After(now) || req.RunAt.After(now.Add(maxScheduleAhead)) {
+		respondError(w, http.StatusBadRequest, "run_at must be in the future and within a year")
+		return
+	}
+	if req.MaxAttempts == 0 {
+		req.MaxAttempts = 3
+	}
+	if req.MaxAttempts < 1 || req.MaxAttempts > maxScheduleAttempts {
+		respondError(w, http.StatusBadRequest, "max_attempts must be between 1 and 10")
+		return
+	}
+
+	var exists bool
+	h.db.QueryRow(r.Context(),
+		`SELECT EXISTS(SELECT 1 FROM `+table+` WHERE id = $1 AND wallet_id = $2)`,
+		req.TargetID, userID,
+	).Scan(&exists)
+	if !exists {
+		respondError(w, http.StatusBadRequest, strings.TrimSuffix(table, "s")+" not found")
+		return
+	}
+
+	var s ScheduledOperation
+	err := scanScheduledOperation(h.db.QueryRow(r.Context(),
+		`INSERT INTO scheduled_operations (wallet_id, operation, target_id, run_at, next_attempt_at, max_attempts, created_by)
+		 VALUES ($1, $2, $3, $4, $4, $5, $6)
+		 RETURNING `+scheduledOperationColumns,
+		userID, req.Operation, req.TargetID, req.RunAt, req.MaxAttempts,
+		nilIfEmpty(strings.ToLower(middleware.GetWallet(r.Context()))),
+	), &s)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("failed to create scheduled operation")
+		respondError(w, http.StatusInternalServerError, "failed to create schedule")
+		return
+	}
+
+	h.auditLogger.Log(r.Context(), h.scheduleEvent(s, "schedule.created", map[string]interface{}{"run_at": s.RunAt}))
+
+	respondJSON(w, http.StatusCreated, s)
+}
+
+func (h *Handlers) ListSchedules(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == uuid.Nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	targetID := r.URL.Query().Get("target_id")
+	rows, err := h.db.Query(r.Context(),
+		`SELECT `+scheduledOperationColumns+` FROM scheduled_operations
+		 WHERE wallet_id = $1 AND ($2 = '' OR status = $2) AND ($3 = '' OR target_id::text = $3)
+		 ORDER BY run_at DESC LIMIT 100`,
+		userID, status, targetID,
+	)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list schedules")
+		return
+	}
+	defer rows.Close()
+
+	schedules := []ScheduledOperation{}
+	for rows.Next() {
+		var s ScheduledOperation
+		if err := scanScheduledOperation(rows, &s); err != nil {
+			continue
+		}
+		schedules = append(schedules, s)
+	}
+
+	respondJSON(w, http.StatusOK, schedules)
+}
+
+func (h *Handlers) GetSchedule(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	scheduleID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid schedule id")
+		return
+	}
+
+	var s ScheduledOperation
+	err = scanScheduledOperation(h.db.QueryRow(r.Context(),
+		`SELECT `+scheduledOperationColumns+` FROM scheduled_operations WHERE id = $1 AND wallet_id = $2`,
+		scheduleID, userID,
+	), &s)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "schedule not found")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, s)
+}
+
+// CancelSchedule cancels an operation that has not run yet, including one
+// waiting to be retried.
+func (h *Handlers) CancelSchedule(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	scheduleID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid schedule id")
+		return
+	}
+
+	var s ScheduledOperation
+	err = scanScheduledOperation(h.db.QueryRow(r.Context(),
+		`UPDATE scheduled_operations SET status = 'cancelled', cancelled_at = NOW(), updated_at = NOW()
+		 WHERE id = $1 AND wallet_id = $2 AND status = 'pending'
+		 RETURNING `+scheduledOperationColumns,
+		scheduleID, userID,
+	), &s)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "schedule not found or no longer pending")
+		return
+	}
+
+	h.auditLogger.Log(r.Context(), h.scheduleEvent(s, "schedule.cancelled", map[string]interface{}{
+		"cancelled_by": nilIfEmpty(strings.ToLower(middleware.GetWallet(r.Context()))),
+	}))
+
+	respondJSON(w, http.StatusOK, s)
+}
+
+// StartScheduler runs due scheduled operations every interval in the
+// background. A non-positive interval disables it.
+func (h *Handlers) StartScheduler(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := h.runDueSchedules(ctx); err != nil {
+					h.logger.Error().Err(err).Msg("scheduler: run error")
+				}
+			}
+		}
+	}()
+}
+
+// runDueSchedules claims due operations and runs them one by one. Claiming
+// skips rows locked by another instance, so each run happens once.
+func (h *Handlers) runDueSchedules(ctx context.Context) error {
+	rows, err := h.db.Query(ctx,
+		`UPDATE scheduled_operations SET status = 'running', attempts = attempts + 1, updated_at = NOW()
+		 WHERE id IN (
+		   SELECT id FROM scheduled_operations
+		   WHERE (status = 'pending' AND next_attempt_at <= NOW())
+		      OR (status = 'running' AND updated_at < NOW() - make_interval(secs => $1))
+		   ORDER BY next_attempt_at
+		   LIMIT 50
+		   FOR UPDATE SKIP LOCKED
+		 )
+		 RETURNING `+scheduledOperationColumns,
+		staleScheduleRun.Seconds(),
+	)
+	if err != nil {
+		return err
+	}
+	var due []ScheduledOperation
+	for rows.Next() {
+		var s ScheduledOperation
+		if err := scanScheduledOperation(rows, &s); err == nil {
+			due = append(due, s)
+		}
+	}
+	rows.Close()
+
+	for _, s := range due {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		h.runSchedule(ctx, s)
+	}
+	return nil
+}
+
+func (h *Handlers) runSchedule(ctx context.Context, s ScheduledOperation) {
+	err := h.executeSchedule(ctx, s)
+	if err == nil {
+		h.db.Exec(ctx,
+			`UPDATE scheduled_operations SET status = 'completed', completed_at = NOW(), updated_at = NOW(), last_error = NULL
+			 WHERE id = $1`,
+			s.ID,
+		)
+		h.auditLogger.Log(ctx, h.scheduleEvent(s, "schedule.executed", map[string]interface{}{"attempt": s.Attempts}))
+		return
+	}
+
+	retry := s.Attempts < s.MaxAttempts
+	if retry {
+		h.db.Exec(ctx,
+			`UPDATE scheduled_operations SET status = 'pending', next_attempt_at = $2, last_error = $3, updated_at = NOW()
+			 WHERE id = $1`,
+			s.ID, time.Now().Add(scheduleBackoff(s.Attempts)), err.Error(),
+		)
+	} else {
+		h.db.Exec(ctx,
+			`UPDATE scheduled_operations SET status = 'failed', last_error = $2, updated_at = NOW() WHERE id = $1`,
+			s.ID, err.Error(),
+		)
+	}
+	h.logger.Warn().Err(err).Str("schedule_id", s.ID.String()).Int("attempt", s.Attempts).Bool("retry", retry).Msg("scheduled operation failed")
+	h.auditLogger.Log(ctx, h.scheduleEvent(s, "schedule.failed", map[string]interface{}{
+		"attempt": s.Attempts,
+		"error":   err.Error(),
+		"retry":   retry,
+	}))
+}
+
+// executeSchedule runs the operation through the same path as its endpoint.
+func (h *Handlers) executeSchedule(ctx context.Context, s ScheduledOperation) error {
+	var err error
+	switch s.Operation {
+	case "activate_policy":
+		_, err = h.activatePolicy(ctx, s.WalletID, s.TargetID, "")
+	case "revoke_policy":
+		_, err = h.revokePolicy(ctx, s.WalletID, s.TargetID)
+	case "mint_permission":
+		_, err = h.mintPermission(ctx, s.WalletID, s.TargetID)
+	case "delete_permission":
+		err = h.deletePermission(ctx, s.WalletID, s.TargetID)
+	default:
+		err = errors.New("unknown operation " + s.Operation)
+	}
+	return err
+}
+
+// scheduleBackoff is the delay before retrying after the given attempt:
+// one minute, doubling up to an hour.
+func scheduleBackoff(attempt int) time.Duration {
+	d := time.Minute
+	for i := 1; i < attempt && d < time.Hour; i++ {
+		d *= 2
+	}
+	if d > time.Hour {
+		d = time.Hour
+	}
+	return d
+}
+
+func (h *Handlers) scheduleEvent(s ScheduledOperation, eventType string, details map[string]interface{}) audit.Event {
+	e := audit.Event{WalletID: s.WalletID, EventType: eventType, Details: details}
+	details["schedule_id"] = s.ID
+	details["operation"] = s.Operation
+	details["target_id"] = s.TargetID
+	// A deleted permission can no longer be referenced; target_id in the
+	// details still identifies it
+	target := s.TargetID
+	switch s.Operation {
+	case "activate_policy", "revoke_policy":
+		e.PolicyID = &target
+	case "mint_permission":
+		e.PermissionID = &target
+	}
+	return e
+}
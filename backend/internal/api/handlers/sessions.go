@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/erc8004/policy-saas/internal/api/middleware"
+	"github.com/erc8004/policy-saas/internal/domain/audit"
+	"github.com/erc8004/policy-saas/internal/domain/policy"
+)
+
+type CreateSessionRequest struct {
+	TaskID string `json:"task_id"`
+	// Budget caps the session's total spending, in the token's base units.
+	Budget string `json:"budget"`
+	// TTLSeconds defaults to 15 minutes and may be at most an hour.
+	TTLSeconds int `json:"ttl_seconds,omitempty"`
+	// Actions, Tokens and Protocols narrow the permission's lists.
+	Actions   []string `json:"actions,omitempty"`
+	Tokens    []string `json:"tokens,omitempty"`
+	Protocols []string `json:"protocols,omitempty"`
+	// MaxTxCount caps how many actions the session may have allowed.
+	MaxTxCount int  `json:"max_tx_count,omitempty"`
+	Mint       bool `json:"mint,omitempty"`
+}
+
+type CreateSessionResponse struct {
+	Session Permission `json:"session"`
+	// Token authenticates the session at /sessions/validate. It is only
+	// returned once.
+	Token string `json:"token"`
+	// MintError is set when the session was created but minting failed.
+	MintError string `json:"mint_error,omitempty"`
+}
+
+// CreateSession cuts a short-lived task session from an active permission:
+// a permission for the same agent, narrowed to a budget and optionally to
+// fewer actions and assets, that expires within minutes and is used with its
+// own credential.
+func (h *Handlers) CreateSession(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	parentID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid permission id")
+		return
+	}
+
+	var req CreateSessionRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	resp, err := h.createSession(r.Context(), userID, parentID, req)
+	if err != nil {
+		respondHandlerError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, resp)
+}
+
+func (h *Handlers) createSession(ctx context.Context, walletID, parentID uuid.UUID, req CreateSessionRequest) (CreateSessionResponse, error) {
+	var resp CreateSessionResponse
+
+	req.TaskID = strings.TrimSpace(req.TaskID)
+	if req.TaskID == "" {
+		return resp, newHandlerError(http.StatusBadRequest, "task_id is required")
+	}
+	ttl := policy.DefaultSessionTTL
+	if req.TTLSeconds != 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+	if ttl <= 0 || ttl > policy.MaxSessionTTL {
+		return resp, newHandlerError(http.StatusBadRequest, "ttl_seconds must be between 1 and 3600")
+	}
+
+	var parent Permission
+	err := scanPermission(h.db.QueryRow(ctx,
+		`SELECT `+permissionColumns+` FROM permissions WHERE id = $1 AND wallet_id = $2 AND status = 'active'`,
+		parentID, walletID,
+	), &parent)
+	var parentDefBytes []byte
+	if err == nil {
+		err = h.db.QueryRow(ctx,
+			`SELECT definition FROM policies WHERE id = $1 AND status = 'active'`, parent.PolicyID,
+		).Scan(&parentDefBytes)
+	}
+	if err != nil {
+		return resp, newHandlerError(http.StatusNotFound, "permission not found or not active")
+	}
+	if parent.TaskID != nil {
+		return resp, newHandlerError(http.StatusBadRequest, "sessions cannot be started from a task session")
+	}
+	var parentDef policy.Definition
+	json.Unmarshal(parentDefBytes, &parentDef)
+
+	scope := policy.SessionScope{
+		Budget:     req.Budget,
+		Actions:    req.Actions,
+		Tokens:     req.Tokens,
+		Protocols:  req.Protocols,
+		MaxTxCount: req.MaxTxCount,
+	}
+	if err := policy.ValidateSessionScope(&scope, &parentDef); err != nil {
+		return resp, newHandlerError(http.StatusBadRequest, err.Error())
+	}
+
+	// The session never outlives the permission it was cut from
+	now := time.Now()
+	parentFrom, parentUntil := policy.EffectiveWindow(parentDef.Duration, parent.ValidFrom, parent.ValidUntil)
+	if now.Before(parentFrom) {
+		return resp, newHandlerError(http.StatusBadRequest, "permission is not valid yet")
+	}
+	validUntil := now.Add(ttl)
+	if parentUntil != nil && parentUntil.Before(validUntil) {
+		validUntil = *parentUntil
+	}
+	if !validUntil.After(now) {
+		return resp, newHandlerError(http.StatusBadRequest, "permission has no validity left")
+	}
+
+	if err := h.checkNotFrozen(ctx, walletID); err != nil {
+		return resp, err
+	}
+	var agentActive bool
+	h.db.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM agents WHERE id = $1 AND wallet_id = $2 AND status = 'active')`,
+		parent.AgentID, walletID,
+	).Scan(&agentActive)
+	if !agentActive {
+		return resp, newHandlerError(http.StatusBadRequest, "agent not found or inactive")
+	}
+
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return resp, newHandlerError(http.StatusInternalServerError, "failed to generate session token")
+	}
+	token := "erc8004_session_" + hex.EncodeToString(tokenBytes)
+
+	var session Permission
+	err = scanPermission(h.db.QueryRow(ctx,
+		`INSERT INTO permissions (wallet_id, agent_id, policy_id, status, valid_from, valid_until, delegated_from, task_id, session_scope, session_token_hash)
+		 VALUES ($1, $2, $3, 'active', $4, $5, $6, $7, $8, encode(sha256($9::bytea), 'hex'))
+		 RETURNING `+permissionColumns,
+		walletID, parent.AgentID, parent.PolicyID, now, validUntil, parentID, req.TaskID, scope, token,
+	), &session)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("failed to create task session")
+		return resp, newHandlerError(http.StatusInternalServerError, "failed to create session")
+	}
+
+	details := map[string]interface{}{
+		"task_id":        req.TaskID,
+		"delegated_from": parentID,
+		"budget":         scope.Budget,
+		"valid_until":    validUntil,
+	}
+	if req.Mint {
+		minted, err := h.mintPermission(ctx, walletID, session.ID)
+		if err != nil {
+			resp.MintError = err.Error()
+			details["mint_error"] = err.Error()
+		} else {
+			session = minted
+		}
+	}
+	details["minted"] = session.OnchainTokenID != nil
+
+	h.auditLogger.Log(ctx, audit.Event{
+		WalletID:     walletID,
+		AgentID:      &session.AgentID,
+		PolicyID:     &session.PolicyID,
+		PermissionID: &session.ID,
+		EventType:    "permission.session_created",
+		Details:      details,
+	})
+
+	resp.Session = session
+	resp.Token = token
+	return resp, nil
+}
+
+// ListSessions returns the task sessions started from a permission, newest
+// first.
+func (h *Handlers) ListSessions(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	permID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid permission id")
+		return
+	}
+
+	rows, err := h.db.Query(r.Context(),
+		`SELECT `+permissionColumns+` FROM permissions
+		 WHERE delegated_from = $1 AND wallet_id = $2 AND task_id IS NOT NULL
+		 ORDER BY created_at DESC`,
+		permID, userID,
+	)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list sessions")
+		return
+	}
+	defer rows.Close()
+
+	sessions := []Permission{}
+	for rows.Next() {
+		var p Permission
+		if err := scanPermission(rows, &p); err != nil {
+			continue
+		}
+		sessions = append(sessions, p)
+	}
+
+	respondJSON(w, http.StatusOK, sessions)
+}
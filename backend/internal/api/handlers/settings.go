@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/erc8004/policy-saas/internal/api/middleware"
+	"github.com/erc8004/policy-saas/internal/domain/audit"
+	"github.com/erc8004/policy-saas/internal/domain/policy"
+)
+
+// WalletSettings are wallet-wide defaults that policies can override.
+type WalletSettings struct {
+	StrictMatching bool `json:"strict_matching"`
+	// ExpiryWarningDays are the days before valid_until at which a
+	// permission.expiring event is sent.
+	ExpiryWarningDays []int32 `json:"expiry_warning_days"`
+	// RequirePolicySignatures makes activating a policy, and changing an
+	// active one, require the owner's EIP-712 signature.
+	RequirePolicySignatures bool `json:"require_policy_signatures"`
+	// PublicPolicyDetails adds policy names and definitions to the public
+	// verification API, which otherwise shows only their hashes.
+	PublicPolicyDetails bool `json:"public_policy_details"`
+}
+
+type UpdateSettingsRequest struct {
+	StrictMatching          *bool    `json:"strict_matching,omitempty"`
+	ExpiryWarningDays       *[]int32 `json:"expiry_warning_days,omitempty"`
+	RequirePolicySignatures *bool    `json:"require_policy_signatures,omitempty"`
+	PublicPolicyDetails     *bool    `json:"public_policy_details,omitempty"`
+}
+
+func (h *Handlers) GetSettings(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == uuid.Nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var s WalletSettings
+	err := h.db.QueryRow(r.Context(),
+		`SELECT strict_matching, expiry_warning_days, require_policy_signatures, public_policy_details FROM wallets WHERE id = $1`,
+		userID,
+	).Scan(&s.StrictMatching, &s.ExpiryWarningDays, &s.RequirePolicySignatures, &s.PublicPolicyDetails)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "wallet not found")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, s)
+}
+
+func (h *Handlers) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == uuid.Nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req UpdateSettingsRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	var warningDays []int32
+	if req.ExpiryWarningDays != nil {
+		warningDays = []int32{}
+		for _, d := range *req.ExpiryWarningDays {
+			if d < 1 || d > policy.MaxExpiryWarningDays {
+				respondError(w, http.StatusBadRequest, fmt.Sprintf("expiry_warning_days must be between 1 and %d", policy.MaxExpiryWarningDays))
+				return
+			}
+			warningDays = append(warningDays, d)
+		}
+	}
+
+	// An API key must not be able to lift the signature requirement
+	if req.RequirePolicySignatures != nil && !*req.RequirePolicySignatures && middleware.GetWallet(r.Context()) == "" {
+		respondError(w, http.StatusForbidden, "require_policy_signatures can only be turned off from a signed-in session")
+		return
+	}
+
+	var s WalletSettings
+	err := h.db.QueryRow(r.Context(),
+		`UPDATE wallets SET
+			strict_matching = COALESCE($1, strict_matching),
+			expiry_warning_days = COALESCE($2, expiry_warning_days),
+			require_policy_signatures = COALESCE($3, require_policy_signatures),
+			public_policy_details = COALESCE($4, public_policy_details)
+		 WHERE id = $5
+		 RETURNING strict_matching, expiry_warning_days, require_policy_signatures, public_policy_details`,
+		req.StrictMatching, warningDays, req.RequirePolicySignatures, req.PublicPolicyDetails, userID,
+	).Scan(&s.StrictMatching, &s.ExpiryWarningDays, &s.RequirePolicySignatures, &s.PublicPolicyDetails)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "wallet not found")
+		return
+	}
+
+	h.auditLogger.Log(r.Context(), audit.Event{
+		WalletID:  userID,
+		EventType: "settings.updated",
+		Details:   map[string]interface{}{"strict_matching": s.StrictMatching, "expiry_warning_days": s.ExpiryWarningDays, "require_policy_signatures": s.RequirePolicySignatures, "public_policy_details": s.PublicPolicyDetails},
+	})
+
+	// Strict matching can deny actions that bundles issued before allow
+	if req.StrictMatching != nil && *req.StrictMatching {
+		h.revokeBundles(r.Context(), userID)
+	}
+
+	respondJSON(w, http.StatusOK, s)
+}
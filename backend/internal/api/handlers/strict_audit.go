@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/erc8004/policy-saas/internal/api/middleware"
+	"github.com/erc8004/policy-saas/internal/domain/policy"
+)
+
+type StrictAuditEntry struct {
+	PolicyID         uuid.UUID      `json:"policy_id"`
+	Name             string         `json:"name"`
+	Status           string         `json:"status"`
+	Strict           bool           `json:"strict"`
+	StrictSource     string         `json:"strict_source"` // "policy" or "wallet"
+	RestrictedFields []string       `json:"restricted_fields"`
+	AllowedRequests  int            `json:"allowed_requests"`
+	WouldDeny        int            `json:"would_deny"`
+	MissingFields    map[string]int `json:"missing_fields,omitempty"`
+	WouldChange      bool           `json:"would_change"`
+}
+
+type StrictAuditResponse struct {
+	WalletStrict bool               `json:"wallet_strict"`
+	Since        time.Time          `json:"since"`
+	Policies     []StrictAuditEntry `json:"policies"`
+}
+
+// StrictAudit replays each policy's recently allowed validations to show
+// which policies would behave differently under strict matching.
+func (h *Handlers) StrictAudit(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == uuid.Nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	days := 30
+	if d := r.URL.Query().Get("days"); d != "" {
+		n, err := strconv.Atoi(d)
+		if err != nil || n < 1 {
+			respondError(w, http.StatusBadRequest, "days must be a positive integer")
+			return
+		}
+		days = n
+	}
+	since := time.Now().AddDate(0, 0, -days)
+
+	resp := StrictAuditResponse{Since: since, Policies: []StrictAuditEntry{}}
+	h.db.QueryRow(r.Context(),
+		`SELECT strict_matching FROM wallets WHERE id = $1`, userID,
+	).Scan(&resp.WalletStrict)
+
+	rows, err := h.db.Query(r.Context(),
+		`SELECT id, name, status, definition FROM policies
+		 WHERE wallet_id = $1 AND status != 'deleted'
+		 ORDER BY created_at DESC`,
+		userID,
+	)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list policies")
+		return
+	}
+	type policyRow struct {
+		entry StrictAuditEntry
+		def   policy.Definition
+	}
+	var policies []policyRow
+	for rows.Next() {
+		var p policyRow
+		var defBytes []byte
+		if err := rows.Scan(&p.entry.PolicyID, &p.entry.Name, &p.entry.Status, &defBytes); err != nil {
+			continue
+		}
+		json.Unmarshal(defBytes, &p.def)
+		policies = append(policies, p)
+	}
+	rows.Close()
+
+	for _, p := range policies {
+		entry := p.entry
+		entry.Strict = p.def.StrictMatching(resp.WalletStrict)
+		entry.StrictSource = "wallet"
+		if p.def.Strict != nil {
+			entry.StrictSource = "policy"
+		}
+		entry.RestrictedFields = policy.RestrictedFields(&p.def)
+		if entry.RestrictedFields == nil {
+			entry.RestrictedFields = []string{}
+		}
+
+		if len(entry.RestrictedFields) > 0 {
+			actions, err := h.allowedActionsForPolicy(r, userID, entry.PolicyID, since)
+			if err != nil {
+				respondError(w, http.StatusInternalServerError, "failed to load validation history")
+				return
+			}
+			entry.AllowedRequests = len(actions)
+			entry.WouldDeny, entry.MissingFields = policy.StrictImpact(&p.def, actions)
+			entry.WouldChange = !entry.Strict && entry.WouldDeny > 0
+		}
+
+		resp.Policies = append(resp.Policies, entry)
+	}
+
+	respondJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handlers) allowedActionsForPolicy(r *http.Request, walletID, policyID uuid.UUID, since time.Time) ([]policy.Action, error) {
+	rows, err := h.db.Query(r.Context(),
+		`SELECT action_data FROM validation_requests
+		 WHERE wallet_id = $1 AND policy_id = $2 AND allowed = true AND created_at >= $3`,
+		walletID, policyID, since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var actions []policy.Action
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var a policy.Action
+		if err := json.Unmarshal(data, &a); err == nil {
+			actions = append(actions, a)
+		}
+	}
+	return actions, rows.Err()
+}
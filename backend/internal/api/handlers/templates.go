@@ -0,0 +1,550 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/erc8004/policy-saas/internal/api/middleware"
+	"github.com/erc8004/policy-saas/internal/domain/audit"
+	"github.com/erc8004/policy-saas/internal/domain/policy"
+)
+
+type PolicyTemplate struct {
+	ID          uuid.UUID              `json:"id"`
+	WalletID    uuid.UUID              `json:"wallet_id"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	LibraryKey  *string                `json:"library_key,omitempty"`
+	Parameters  []policy.TemplateParam `json:"parameters"`
+	Definition  json.RawMessage        `json:"definition"`
+	Version     int                    `json:"version"`
+	Status      string                 `json:"status"`
+	CreatedAt   time.Time              `json:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at"`
+}
+
+func (t *PolicyTemplate) template() *policy.Template {
+	return &policy.Template{
+		Name:        t.Name,
+		Description: t.Description,
+		Parameters:  t.Parameters,
+		Definition:  t.Definition,
+	}
+}
+
+type TemplateInstance struct {
+	PolicyID        uuid.UUID              `json:"policy_id"`
+	Name            string                 `json:"name"`
+	Status          string                 `json:"status"`
+	Version         int                    `json:"version"`
+	TemplateVersion int                    `json:"template_version"`
+	Params          map[string]interface{} `json:"params"`
+	Error           string                 `json:"error,omitempty"`
+}
+
+type CreateTemplateRequest struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  []policy.TemplateParam `json:"parameters"`
+	Definition  json.RawMessage        `json:"definition"`
+	// FromLibrary copies a built-in starter template; other fields override it.
+	FromLibrary string `json:"from_library,omitempty"`
+}
+
+type UpdateTemplateRequest struct {
+	Name        *string                 `json:"name,omitempty"`
+	Description *string                 `json:"description,omitempty"`
+	Parameters  *[]policy.TemplateParam `json:"parameters,omitempty"`
+	Definition  json.RawMessage         `json:"definition,omitempty"`
+	// Propagate re-renders existing instances with the new version (default true).
+	Propagate *bool `json:"propagate,omitempty"`
+}
+
+type RenderTemplateRequest struct {
+	Params map[string]interface{} `json:"params"`
+}
+
+const templateColumns = `id, wallet_id, name, COALESCE(description, ''), library_key, parameters, definition, version, status, created_at, updated_at`
+
+func scanTemplate(row interface{ Scan(...any) error }, t *PolicyTemplate) error {
+	var params, def []byte
+	if err := row.Scan(&t.ID, &t.WalletID, &t.Name, &t.Description, &t.LibraryKey, &params, &def, &t.Version, &t.Status, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		return err
+	}
+	json.Unmarshal(params, &t.Parameters)
+	if t.Parameters == nil {
+		t.Parameters = []policy.TemplateParam{}
+	}
+	t.Definition = def
+	return nil
+}
+
+// ListTemplateLibrary returns the built-in starter templates.
+// GET /api/v1/templates/library
+func (h *Handlers) ListTemplateLibrary(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, policy.StarterTemplates())
+}
+
+func (h *Handlers) CreateTemplate(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == uuid.Nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req CreateTemplateRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	var libraryKey *string
+	if req.FromLibrary != "" {
+		lt, ok := policy.StarterTemplate(req.FromLibrary)
+		if !ok {
+			respondError(w, http.StatusBadRequest, "unknown library template: "+req.FromLibrary)
+			return
+		}
+		libraryKey = &lt.Key
+		if req.Name == "" {
+			req.Name = lt.Name
+		}
+		if req.Description == "" {
+			req.Description = lt.Description
+		}
+		if req.Parameters == nil {
+			req.Parameters = lt.Parameters
+		}
+		if len(req.Definition) == 0 {
+			req.Definition = lt.Definition
+		}
+	}
+
+	tmpl := policy.Template{Name: req.Name, Description: req.Description, Parameters: req.Parameters, Definition: req.Definition}
+	if err := tmpl.Validate(); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if tmpl.Parameters == nil {
+		tmpl.Parameters = []policy.TemplateParam{}
+	}
+	paramBytes, _ := json.Marshal(tmpl.Parameters)
+
+	var t PolicyTemplate
+	err := scanTemplate(h.db.QueryRow(r.Context(),
+		`INSERT INTO policy_templates (wallet_id, name, description, library_key, parameters, definition)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 RETURNING `+templateColumns,
+		userID, tmpl.Name, tmpl.Description, libraryKey, paramBytes, []byte(tmpl.Definition),
+	), &t)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("failed to create template")
+		respondError(w, http.StatusInternalServerError, "failed to create template")
+		return
+	}
+
+	h.db.Exec(r.Context(),
+		`INSERT INTO policy_template_versions (template_id, version, parameters, definition, created_by)
+		 VALUES ($1, 1, $2, $3, $4)`,
+		t.ID, paramBytes, []byte(tmpl.Definition), userID,
+	)
+
+	h.auditLogger.Log(r.Context(), audit.Event{
+		WalletID:  userID,
+		EventType: "template.created",
+		Details:   map[string]interface{}{"template_id": t.ID, "name": t.Name, "library_key": req.FromLibrary},
+	})
+
+	respondJSON(w, http.StatusCreated, t)
+}
+
+func (h *Handlers) ListTemplates(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == uuid.Nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	rows, err := h.db.Query(r.Context(),
+		`SELECT `+templateColumns+` FROM policy_templates WHERE wallet_id = $1 AND status != 'deleted' ORDER BY created_at DESC`,
+		userID,
+	)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list templates")
+		return
+	}
+	defer rows.Close()
+
+	var templates []PolicyTemplate
+	for rows.Next() {
+		var t PolicyTemplate
+		if err := scanTemplate(rows, &t); err != nil {
+			continue
+		}
+		templates = append(templates, t)
+	}
+
+	if templates == nil {
+		templates = []PolicyTemplate{}
+	}
+
+	respondJSON(w, http.StatusOK, templates)
+}
+
+func (h *Handlers) GetTemplate(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	templateID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid template id")
+		return
+	}
+
+	t, err := h.loadTemplate(r.Context(), userID, templateID)
+	if err != nil {
+		respondHandlerError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, t)
+}
+
+// UpdateTemplate changes a template. Changing parameters or the definition
+// bumps the template version and, unless propagate is false, re-renders every
+// instance through the normal policy update path (active instances get a new
+// policy version).
+func (h *Handlers) UpdateTemplate(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	templateID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid template id")
+		return
+	}
+
+	var req UpdateTemplateRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	current, err := h.loadTemplate(r.Context(), userID, templateID)
+	if err != nil {
+		respondHandlerError(w, err)
+		return
+	}
+
+	tmpl := current.template()
+	if req.Name != nil {
+		tmpl.Name = *req.Name
+	}
+	if req.Description != nil {
+		tmpl.Description = *req.Description
+	}
+	contentChanged := false
+	if req.Parameters != nil {
+		tmpl.Parameters = *req.Parameters
+		contentChanged = true
+	}
+	if len(req.Definition) > 0 {
+		tmpl.Definition = req.Definition
+		contentChanged = true
+	}
+	if err := tmpl.Validate(); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if tmpl.Parameters == nil {
+		tmpl.Parameters = []policy.TemplateParam{}
+	}
+
+	newVersion := current.Version
+	if contentChanged {
+		newVersion++
+	}
+	paramBytes, _ := json.Marshal(tmpl.Parameters)
+
+	var t PolicyTemplate
+	err = scanTemplate(h.db.QueryRow(r.Context(),
+		`UPDATE policy_templates SET name = $1, description = $2, parameters = $3, definition = $4, version = $5, updated_at = NOW()
+		 WHERE id = $6 AND wallet_id = $7 AND status = 'active'
+		 RETURNING `+templateColumns,
+		tmpl.Name, tmpl.Description, paramBytes, []byte(tmpl.Definition), newVersion, templateID, userID,
+	), &t)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to update template")
+		return
+	}
+
+	if contentChanged {
+		h.db.Exec(r.Context(),
+			`INSERT INTO policy_template_versions (template_id, version, parameters, definition, created_by)
+			 VALUES ($1, $2, $3, $4, $5)`,
+			t.ID, newVersion, paramBytes, []byte(tmpl.Definition), userID,
+		)
+	}
+
+	h.auditLogger.Log(r.Context(), audit.Event{
+		WalletID:  userID,
+		EventType: "template.updated",
+		Details:   map[string]interface{}{"template_id": t.ID, "version": newVersion},
+	})
+
+	instances := []TemplateInstance{}
+	if contentChanged && (req.Propagate == nil || *req.Propagate) {
+		instances, err = h.propagateTemplate(r.Context(), userID, &t)
+		if err != nil {
+			respondHandlerError(w, err)
+			return
+		}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"template":  t,
+		"instances": instances,
+	})
+}
+
+// PropagateTemplate upgrades instances that are behind the template's current
+// version, e.g. after an update made with propagate=false.
+// POST /api/v1/templates/{id}/propagate
+func (h *Handlers) PropagateTemplate(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	templateID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid template id")
+		return
+	}
+
+	t, err := h.loadTemplate(r.Context(), userID, templateID)
+	if err != nil {
+		respondHandlerError(w, err)
+		return
+	}
+
+	instances, err := h.propagateTemplate(r.Context(), userID, t)
+	if err != nil {
+		respondHandlerError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, instances)
+}
+
+func (h *Handlers) DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	templateID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid template id")
+		return
+	}
+
+	// Existing instances keep their rendered definitions; they just stop
+	// receiving template updates.
+	result, err := h.db.Exec(r.Context(),
+		`UPDATE policy_templates SET status = 'deleted', updated_at = NOW() WHERE id = $1 AND wallet_id = $2 AND status != 'deleted'`,
+		templateID, userID,
+	)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to delete template")
+		return
+	}
+
+	if result.RowsAffected() == 0 {
+		respondError(w, http.StatusNotFound, "template not found")
+		return
+	}
+
+	h.auditLogger.Log(r.Context(), audit.Event{
+		WalletID:  userID,
+		EventType: "template.deleted",
+		Details:   map[string]interface{}{"template_id": templateID},
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RenderTemplate previews the definition a grant with the given params would get.
+// POST /api/v1/templates/{id}/render
+func (h *Handlers) RenderTemplate(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	templateID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid template id")
+		return
+	}
+
+	var req RenderTemplateRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	t, err := h.loadTemplate(r.Context(), userID, templateID)
+	if err != nil {
+		respondHandlerError(w, err)
+		return
+	}
+
+	def, err := t.template().Render(req.Params)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := h.policyEngine.ValidateDefinition(def); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, def)
+}
+
+// ListTemplateInstances lists the policies instantiated from a template.
+// GET /api/v1/templates/{id}/instances
+func (h *Handlers) ListTemplateInstances(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	templateID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid template id")
+		return
+	}
+
+	instances, err := h.templateInstances(r.Context(), userID, templateID, false)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list template instances")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, instances)
+}
+
+func (h *Handlers) loadTemplate(ctx context.Context, walletID, templateID uuid.UUID) (*PolicyTemplate, error) {
+	var t PolicyTemplate
+	err := scanTemplate(h.db.QueryRow(ctx,
+		`SELECT `+templateColumns+` FROM policy_templates WHERE id = $1 AND wallet_id = $2 AND status = 'active'`,
+		templateID, walletID,
+	), &t)
+	if err != nil {
+		return nil, newHandlerError(http.StatusNotFound, "template not found")
+	}
+	return &t, nil
+}
+
+func (h *Handlers) templateInstances(ctx context.Context, walletID, templateID uuid.UUID, outdatedOnly bool) ([]TemplateInstance, error) {
+	rows, err := h.db.Query(ctx,
+		`SELECT p.id, p.name, p.status, p.version, COALESCE(p.template_version, 0), p.template_params
+		 FROM policies p
+		 JOIN policy_templates t ON t.id = p.template_id
+		 WHERE p.template_id = $1 AND p.wallet_id = $2 AND p.status != 'deleted'
+		 AND (NOT $3 OR COALESCE(p.template_version, 0) < t.version)
+		 ORDER BY p.created_at`,
+		templateID, walletID, outdatedOnly,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	instances := []TemplateInstance{}
+	for rows.Next() {
+		var inst TemplateInstance
+		var params []byte
+		if err := rows.Scan(&inst.PolicyID, &inst.Name, &inst.Status, &inst.Version, &inst.TemplateVersion, &params); err != nil {
+			continue
+		}
+		json.Unmarshal(params, &inst.Params)
+		instances = append(instances, inst)
+	}
+	return instances, rows.Err()
+}
+
+// instantiateTemplate renders a template with params and creates an active
+// policy for agentName linked to it. Only the caller's params are stored, so
+// later changes to parameter defaults reach the instance on propagation.
+func (h *Handlers) instantiateTemplate(ctx context.Context, walletID, templateID uuid.UUID, params map[string]interface{}, agentName string) (Policy, error) {
+	var p Policy
+
+	t, err := h.loadTemplate(ctx, walletID, templateID)
+	if err != nil {
+		return p, err
+	}
+	def, err := t.template().Render(params)
+	if err != nil {
+		return p, newHandlerError(http.StatusBadRequest, err.Error())
+	}
+
+	p, err = h.createPolicy(ctx, walletID, CreatePolicyRequest{
+		Name:        t.Name + " (" + agentName + ")",
+		Description: "Instance of template " + t.Name,
+		Definition:  *def,
+	})
+	if err != nil {
+		return p, err
+	}
+
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+	paramBytes, _ := json.Marshal(params)
+	_, err = h.db.Exec(ctx,
+		`UPDATE policies SET template_id = $1, template_version = $2, template_params = $3 WHERE id = $4`,
+		t.ID, t.Version, paramBytes, p.ID,
+	)
+	if err != nil {
+		return p, newHandlerError(http.StatusInternalServerError, "failed to link policy to template")
+	}
+
+	activated, err := h.activatePolicy(ctx, walletID, p.ID, "")
+	if err != nil {
+		// Do not leave an orphaned draft behind a failed grant.
+		h.db.Exec(ctx, `UPDATE policies SET status = 'deleted', updated_at = NOW() WHERE id = $1`, p.ID)
+		return p, err
+	}
+
+	h.auditLogger.Log(ctx, audit.Event{
+		WalletID:  walletID,
+		PolicyID:  &activated.ID,
+		EventType: "template.instantiated",
+		Details:   map[string]interface{}{"template_id": t.ID, "template_version": t.Version, "params": params},
+	})
+
+	return activated, nil
+}
+
+// propagateTemplate re-renders instances behind the template's version. An
+// instance whose params no longer satisfy the template is left unchanged and
+// reported with an error.
+func (h *Handlers) propagateTemplate(ctx context.Context, walletID uuid.UUID, t *PolicyTemplate) ([]TemplateInstance, error) {
+	instances, err := h.templateInstances(ctx, walletID, t.ID, true)
+	if err != nil {
+		return nil, newHandlerError(http.StatusInternalServerError, "failed to load template instances")
+	}
+
+	tmpl := t.template()
+	for i := range instances {
+		inst := &instances[i]
+		def, err := tmpl.Render(inst.Params)
+		if err != nil {
+			inst.Error = err.Error()
+			continue
+		}
+		p, err := h.updatePolicy(ctx, walletID, inst.PolicyID, UpdatePolicyRequest{Definition: def})
+		if err != nil {
+			inst.Error = err.Error()
+			continue
+		}
+		h.db.Exec(ctx, `UPDATE policies SET template_version = $1 WHERE id = $2`, t.Version, inst.PolicyID)
+		inst.Version = p.Version
+		inst.TemplateVersion = t.Version
+	}
+
+	h.auditLogger.Log(ctx, audit.Event{
+		WalletID:  walletID,
+		EventType: "template.propagated",
+		Details:   map[string]interface{}{"template_id": t.ID, "version": t.Version, "instances": len(instances)},
+	})
+
+	return instances, nil
+}
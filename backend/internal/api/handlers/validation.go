@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
 	"time"
 
@@ -14,6 +15,8 @@ import (
 type ValidateRequest struct {
 	AgentID uuid.UUID     `json:"agent_id"`
 	Action  policy.Action `json:"action"`
+	// Receipt asks for an EIP-712 signed receipt of the decision.
+	Receipt bool `json:"receipt,omitempty"`
 }
 
 type ValidateResponse struct {
@@ -26,6 +29,9 @@ type ValidateResponse struct {
 	EnforcementLevel string                 `json:"enforcement_level"`
 	WalletType       string                 `json:"wallet_type"`
 	OnchainEnforced  bool                   `json:"onchain_enforced"`
+	Receipt          *ValidationReceipt     `json:"receipt,omitempty"`
+	// ReceiptError is set when a receipt was asked for but could not be signed.
+	ReceiptError string `json:"receipt_error,omitempty"`
 }
 
 func (h *Handlers) ValidateAction(w http.ResponseWriter, r *http.Request) {
@@ -49,7 +55,13 @@ func (h *Handlers) ValidateAction(w http.ResponseWriter, r *http.Request) {
 	const enforcementLevel = "enforced"
 
 	// Off-chain validation is a pre-flight simulation; on-chain enforcement handles real blocking
-	result := h.policyEngine.Validate(r.Context(), userID, req.AgentID, req.Action)
+	agentID, denied := h.callerAgent(r.Context(), req.AgentID)
+	if denied != "" {
+		respondError(w, http.StatusForbidden, denied)
+		return
+	}
+	req.AgentID = agentID
+	result := h.validateAs(r.Context(), userID, req.AgentID, req.Action)
 
 	// Log the validation request
 	h.db.Exec(r.Context(),
@@ -58,6 +70,10 @@ func (h *Handlers) ValidateAction(w http.ResponseWriter, r *http.Request) {
 		requestID, userID, req.AgentID, req.Action.Type, req.Action, result.Allowed, result.Reason, result.PermissionID, result.PolicyID, time.Since(startTime).Milliseconds(),
 	)
 
+	if !result.Allowed {
+		h.circuitBreaker.Check(r.Context(), userID, req.AgentID)
+	}
+
 	h.auditLogger.Log(r.Context(), audit.Event{
 		WalletID:     userID,
 		AgentID:      &req.AgentID,
@@ -74,7 +90,7 @@ func (h *Handlers) ValidateAction(w http.ResponseWriter, r *http.Request) {
 		},
 	})
 
-	respondJSON(w, http.StatusOK, ValidateResponse{
+	resp := ValidateResponse{
 		Allowed:          result.Allowed,
 		Reason:           result.Reason,
 		PermissionID:     result.PermissionID,
@@ -84,7 +100,52 @@ func (h *Handlers) ValidateAction(w http.ResponseWriter, r *http.Request) {
 		EnforcementLevel: enforcementLevel,
 		WalletType:       walletType,
 		OnchainEnforced:  true,
-	})
+	}
+	if req.Receipt {
+		receipt, err := h.signReceipt(r.Context(), requestID, req.AgentID, &req.Action, result)
+		if err != nil {
+			resp.ReceiptError = err.Error()
+		}
+		resp.Receipt = receipt
+	}
+
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// callerAgent returns the agent a request acts as. A task session acts only
+// as its own agent, and an agent-bound API key only as its agent; a
+// non-empty message means the request named another.
+func (h *Handlers) callerAgent(ctx context.Context, requested uuid.UUID) (uuid.UUID, string) {
+	if sessionID := middleware.GetSessionID(ctx); sessionID != uuid.Nil {
+		var sessionAgent uuid.UUID
+		h.db.QueryRow(ctx, `SELECT agent_id FROM permissions WHERE id = $1`, sessionID).Scan(&sessionAgent)
+		if requested != uuid.Nil && requested != sessionAgent {
+			return uuid.Nil, "session belongs to a different agent"
+		}
+		return sessionAgent, ""
+	}
+	return keyAgent(ctx, requested)
+}
+
+// keyAgent is callerAgent for API keys and signed-in owners.
+func keyAgent(ctx context.Context, requested uuid.UUID) (uuid.UUID, string) {
+	authAgent := middleware.GetAgentID(ctx)
+	if authAgent == uuid.Nil {
+		return requested, ""
+	}
+	if requested != uuid.Nil && requested != authAgent {
+		return uuid.Nil, "API key belongs to a different agent"
+	}
+	return authAgent, ""
+}
+
+// validateAs decides an action for agentID. A task session is checked only
+// within its own scope.
+func (h *Handlers) validateAs(ctx context.Context, userID, agentID uuid.UUID, action policy.Action) policy.ValidationResult {
+	if sessionID := middleware.GetSessionID(ctx); sessionID != uuid.Nil {
+		return h.policyEngine.ValidateSession(ctx, userID, sessionID, action)
+	}
+	return h.policyEngine.Validate(ctx, userID, agentID, action)
 }
 
 type BatchValidateRequest struct {
@@ -113,27 +174,47 @@ func (h *Handlers) ValidateBatch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	for i := range req.Requests {
+		agentID, denied := h.callerAgent(r.Context(), req.Requests[i].AgentID)
+		if denied != "" {
+			respondError(w, http.StatusForbidden, denied)
+			return
+		}
+		req.Requests[i].AgentID = agentID
+	}
+
 	var results []ValidateResponse
 	for _, vReq := range req.Requests {
 		requestID := uuid.New()
 		startTime := time.Now()
 
-		result := h.policyEngine.Validate(r.Context(), userID, vReq.AgentID, vReq.Action)
+		result := h.validateAs(r.Context(), userID, vReq.AgentID, vReq.Action)
 
 		h.db.Exec(r.Context(),
 			`INSERT INTO validation_requests (id, wallet_id, agent_id, action_type, action_data, allowed, reason, permission_id, policy_id, latency_ms)
 			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
 			requestID, userID, vReq.AgentID, vReq.Action.Type, vReq.Action, result.Allowed, result.Reason, result.PermissionID, result.PolicyID, time.Since(startTime).Milliseconds(),
 		)
+		if !result.Allowed {
+			h.circuitBreaker.Check(r.Context(), userID, vReq.AgentID)
+		}
 
-		results = append(results, ValidateResponse{
+		resp := ValidateResponse{
 			Allowed:      result.Allowed,
 			Reason:       result.Reason,
 			PermissionID: result.PermissionID,
 			PolicyID:     result.PolicyID,
 			Constraints:  result.Constraints,
 			RequestID:    requestID,
-		})
+		}
+		if vReq.Receipt {
+			receipt, err := h.signReceipt(r.Context(), requestID, vReq.AgentID, &vReq.Action, result)
+			if err != nil {
+				resp.ReceiptError = err.Error()
+			}
+			resp.Receipt = receipt
+		}
+		results = append(results, resp)
 	}
 
 	respondJSON(w, http.StatusOK, BatchValidateResponse{Results: results})
@@ -151,6 +232,7 @@ type SimulateResponse struct {
 	CurrentUsage    map[string]interface{} `json:"current_usage,omitempty"`
 	RemainingQuota  map[string]interface{} `json:"remaining_quota,omitempty"`
 	Recommendations []string               `json:"recommendations,omitempty"`
+	GroupHeadroom   []policy.GroupHeadroom `json:"group_headroom,omitempty"`
 }
 
 func (h *Handlers) SimulateAction(w http.ResponseWriter, r *http.Request) {
@@ -166,6 +248,13 @@ func (h *Handlers) SimulateAction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	agentID, denied := keyAgent(r.Context(), req.AgentID)
+	if denied != "" {
+		respondError(w, http.StatusForbidden, denied)
+		return
+	}
+	req.AgentID = agentID
+
 	result := h.policyEngine.Simulate(r.Context(), userID, req.AgentID, req.Action)
 
 	respondJSON(w, http.StatusOK, SimulateResponse{
@@ -175,5 +264,6 @@ func (h *Handlers) SimulateAction(w http.ResponseWriter, r *http.Request) {
 		CurrentUsage:    result.CurrentUsage,
 		RemainingQuota:  result.RemainingQuota,
 		Recommendations: result.Recommendations,
+		GroupHeadroom:   result.GroupHeadroom,
 	})
 }
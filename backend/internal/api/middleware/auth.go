@@ -13,8 +13,10 @@ import (
 type contextKey string
 
 const (
-	WalletContextKey contextKey = "wallet"
-	UserIDContextKey contextKey = "user_id"
+	WalletContextKey  contextKey = "wallet"
+	UserIDContextKey  contextKey = "user_id"
+	SessionContextKey contextKey = "session_id"
+	AgentContextKey   contextKey = "agent_id"
 )
 
 func Auth(jwtSecret string, db *pgxpool.Pool) func(next http.Handler) http.Handler {
@@ -23,9 +25,14 @@ func Auth(jwtSecret string, db *pgxpool.Pool) func(next http.Handler) http.Handl
 			// Check for API key first
 			apiKey := r.Header.Get("X-API-Key")
 			if apiKey != "" {
-				walletID, err := validateAPIKey(r.Context(), db, apiKey)
+				walletID, agentID, err := validateAPIKey(r.Context(), db, apiKey)
 				if err == nil {
 					ctx := context.WithValue(r.Context(), UserIDContextKey, walletID)
+					if agentID != nil {
+						ctx = context.WithValue(ctx, AgentContextKey, *agentID)
+						agentRoutesOnly(next).ServeHTTP(w, r.WithContext(ctx))
+						return
+					}
 					next.ServeHTTP(w, r.WithContext(ctx))
 					return
 				}
@@ -87,13 +94,65 @@ func Auth(jwtSecret string, db *pgxpool.Pool) func(next http.Handler) http.Handl
 	}
 }
 
-func validateAPIKey(ctx context.Context, db *pgxpool.Pool, key string) (uuid.UUID, error) {
+// agentRoutes are the only endpoints an agent-bound API key opens. Anything
+// else acts with the owner's authority, so the agent could grant itself what
+// it is meant to request.
+var agentRoutes = map[string]bool{
+	"POST /api/v1/validate":            true,
+	"POST /api/v1/validate/batch":      true,
+	"POST /api/v1/validate/simulate":   true,
+	"POST /api/v1/permission-requests": true,
+	"GET /api/v1/permission-requests":  true,
+}
+
+func agentRoutesOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !agentRoutes[r.Method+" "+strings.TrimSuffix(r.URL.Path, "/")] {
+			http.Error(w, `{"error":"API key is bound to an agent and cannot use this endpoint"}`, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func validateAPIKey(ctx context.Context, db *pgxpool.Pool, key string) (uuid.UUID, *uuid.UUID, error) {
 	var walletID uuid.UUID
+	var agentID *uuid.UUID
 	err := db.QueryRow(ctx,
-		`SELECT wallet_id FROM api_keys WHERE key_hash = encode(sha256($1::bytea), 'hex') AND revoked_at IS NULL`,
+		`SELECT wallet_id, agent_id FROM api_keys WHERE key_hash = encode(sha256($1::bytea), 'hex') AND revoked_at IS NULL`,
 		key,
-	).Scan(&walletID)
-	return walletID, err
+	).Scan(&walletID, &agentID)
+	return walletID, agentID, err
+}
+
+// SessionAuth authenticates a task session by its X-Session-Token. The
+// session's wallet becomes the user; the session itself is in the context.
+func SessionAuth(db *pgxpool.Pool) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := r.Header.Get("X-Session-Token")
+			if token == "" {
+				http.Error(w, `{"error":"missing session token"}`, http.StatusUnauthorized)
+				return
+			}
+
+			var sessionID, walletID uuid.UUID
+			err := db.QueryRow(r.Context(),
+				`SELECT id, wallet_id FROM permissions
+				 WHERE session_token_hash = encode(sha256($1::bytea), 'hex')
+				 AND status = 'active' AND valid_until > NOW()`,
+				token,
+			).Scan(&sessionID, &walletID)
+			if err != nil {
+				http.Error(w, `{"error":"invalid or expired session token"}`, http.StatusUnauthorized)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), UserIDContextKey, walletID)
+			ctx = context.WithValue(ctx, SessionContextKey, sessionID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
 }
 
 func GetWallet(ctx context.Context) string {
@@ -105,3 +164,17 @@ func GetUserID(ctx context.Context) uuid.UUID {
 	userID, _ := ctx.Value(UserIDContextKey).(uuid.UUID)
 	return userID
 }
+
+// GetSessionID returns the task session authenticated by SessionAuth, or
+// uuid.Nil.
+func GetSessionID(ctx context.Context) uuid.UUID {
+	sessionID, _ := ctx.Value(SessionContextKey).(uuid.UUID)
+	return sessionID
+}
+
+// GetAgentID returns the agent an agent-bound API key authenticates, or
+// uuid.Nil for the owner and wallet-wide keys.
+func GetAgentID(ctx context.Context) uuid.UUID {
+	agentID, _ := ctx.Value(AgentContextKey).(uuid.UUID)
+	return agentID
+}
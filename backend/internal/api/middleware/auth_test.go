@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAgentRoutesOnly(t *testing.T) {
+	h := agentRoutesOnly(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{"POST", "/api/v1/api-keys", http.StatusForbidden},
+		{"POST", "/api/v1/api-keys/", http.StatusForbidden},
+		{"POST", "/api/v1/permissions", http.StatusForbidden},
+		{"POST", "/api/v1/policies/00000000-0000-0000-0000-000000000001/activate", http.StatusForbidden},
+		{"POST", "/api/v1/permission-requests/00000000-0000-0000-0000-000000000001/approve", http.StatusForbidden},
+		{"POST", "/api/v1/validate", http.StatusOK},
+		{"POST", "/api/v1/validate/", http.StatusOK},
+		{"POST", "/api/v1/validate/batch", http.StatusOK},
+		{"POST", "/api/v1/validate/simulate", http.StatusOK},
+		{"POST", "/api/v1/permission-requests", http.StatusOK},
+		{"GET", "/api/v1/permission-requests", http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.want {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.path, rec.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// RateLimit allows each client IP limit requests per window and answers
+// 429 beyond that. Counts are kept in memory, per server instance. It
+// relies on RealIP having set r.RemoteAddr to the client's address, so
+// clients cannot pick their own bucket with forwarded headers.
+func RateLimit(limit int, window time.Duration) func(next http.Handler) http.Handler {
+	var mu sync.Mutex
+	windows := make(map[string]*rateWindow)
+	lastSweep := time.Now()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if limit <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := r.RemoteAddr
+			if host, _, err := net.SplitHostPort(key); err == nil {
+				key = host
+			}
+
+			now := time.Now()
+			mu.Lock()
+			// Drop finished windows so idle clients don't accumulate
+			if now.Sub(lastSweep) > window {
+				for k, rw := range windows {
+					if now.Sub(rw.start) >= window {
+						delete(windows, k)
+					}
+				}
+				lastSweep = now
+			}
+			rw, ok := windows[key]
+			if !ok || now.Sub(rw.start) >= window {
+				rw = &rateWindow{start: now}
+				windows[key] = rw
+			}
+			rw.count++
+			count, reset := rw.count, rw.start.Add(window)
+			mu.Unlock()
+
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(max(limit-count, 0)))
+			if count > limit {
+				w.Header().Set("Retry-After", strconv.Itoa(int(reset.Sub(now).Seconds())+1))
+				http.Error(w, `{"error":"rate limit exceeded"}`, http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
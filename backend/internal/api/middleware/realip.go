@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// RealIP sets r.RemoteAddr to the client's address. X-Forwarded-For and
+// X-Real-IP are only believed when the connection comes from one of trusted,
+// addresses or CIDR ranges of the reverse proxies in front of the server;
+// otherwise anyone could claim any address. Invalid entries are ignored.
+func RealIP(trusted []string) func(next http.Handler) http.Handler {
+	var proxies []netip.Prefix
+	for _, t := range trusted {
+		if prefix, err := netip.ParsePrefix(t); err == nil {
+			proxies = append(proxies, prefix.Masked())
+		} else if addr, err := netip.ParseAddr(t); err == nil {
+			proxies = append(proxies, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+		}
+	}
+	isTrusted := func(addr netip.Addr) bool {
+		addr = addr.Unmap()
+		for _, p := range proxies {
+			if p.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if client, ok := forwardedClient(r, isTrusted); ok {
+				r.RemoteAddr = client
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedClient returns the client address named by a trusted proxy. The
+// X-Forwarded-For chain is walked from the nearest hop, skipping trusted
+// proxies, so entries the client prepended are never used.
+func forwardedClient(r *http.Request, isTrusted func(netip.Addr) bool) (string, bool) {
+	host := r.RemoteAddr
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	peer, err := netip.ParseAddr(host)
+	if err != nil || !isTrusted(peer) {
+		return "", false
+	}
+
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		hops := strings.Split(xff, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				return "", false
+			}
+			if !isTrusted(addr) {
+				return addr.String(), true
+			}
+		}
+		return "", false
+	}
+	if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return addr.String(), true
+	}
+	return "", false
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	var got string
+	h := RealIP([]string{"10.0.0.0/8", "192.168.1.1"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.RemoteAddr
+	}))
+
+	tests := []struct {
+		name    string
+		peer    string
+		headers map[string]string
+		want    string
+	}{
+		{"untrusted peer keeps its address", "203.0.113.7:4000", map[string]string{"X-Forwarded-For": "1.2.3.4", "X-Real-IP": "1.2.3.4"}, "203.0.113.7:4000"},
+		{"trusted proxy names the client", "10.1.2.3:4000", map[string]string{"X-Forwarded-For": "198.51.100.9"}, "198.51.100.9"},
+		{"prepended entries are ignored", "10.1.2.3:4000", map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.9, 192.168.1.1"}, "198.51.100.9"},
+		{"X-Real-IP from a trusted proxy", "192.168.1.1:4000", map[string]string{"X-Real-IP": "198.51.100.9"}, "198.51.100.9"},
+		{"malformed chain is not believed", "10.1.2.3:4000", map[string]string{"X-Forwarded-For": "nonsense"}, "10.1.2.3:4000"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tt.peer
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		if got != tt.want {
+			t.Errorf("%s: RemoteAddr = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"net/http"
 	"time"
 
@@ -38,7 +39,7 @@ func NewServer(cfg *config.Config, db *pgxpool.Pool, logger zerolog.Logger) *Ser
 
 func (s *Server) setupMiddleware() {
 	s.router.Use(middleware.RequestID)
-	s.router.Use(middleware.RealIP)
+	s.router.Use(customMiddleware.RealIP(s.config.Server.TrustedProxies))
 	s.router.Use(customMiddleware.Logger(s.logger))
 	s.router.Use(middleware.Recoverer)
 	s.router.Use(middleware.Timeout(60 * time.Second))
@@ -46,7 +47,7 @@ func (s *Server) setupMiddleware() {
 	s.router.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   s.config.Server.AllowOrigins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-API-Key"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-API-Key", "X-Session-Token"},
 		ExposedHeaders:   []string{"Link"},
 		AllowCredentials: true,
 		MaxAge:           300,
@@ -63,6 +64,25 @@ func (s *Server) setupRoutes() {
 			r.Post("/verify", s.handlers.VerifySignature)
 		})
 
+		// Signed receipt keys and verification (public)
+		r.Route("/receipts", func(r chi.Router) {
+			r.Get("/keys", s.handlers.GetReceiptKeys)
+			r.Post("/verify", s.handlers.VerifyReceipt)
+		})
+
+		// Permission verification for third-party protocols (public, rate-limited)
+		r.Route("/public", func(r chi.Router) {
+			r.Use(customMiddleware.RateLimit(s.config.Server.PublicRateLimit, time.Minute))
+			r.Get("/agents/{agentId}/permissions", s.handlers.GetPublicAgentPermissions)
+		})
+
+		// Task sessions authenticate with their own token and may only validate
+		r.Group(func(r chi.Router) {
+			r.Use(customMiddleware.SessionAuth(s.db))
+			r.Post("/sessions/validate", s.handlers.ValidateAction)
+			r.Post("/sessions/validate/batch", s.handlers.ValidateBatch)
+		})
+
 		// Protected routes
 		r.Group(func(r chi.Router) {
 			r.Use(customMiddleware.Auth(s.config.JWT.Secret, s.db))
@@ -85,18 +105,117 @@ func (s *Server) setupRoutes() {
 				r.Post("/{id}/register-onchain", s.handlers.RegisterAgentOnchain)
 				r.Post("/{id}/deploy-smart-account", s.handlers.DeploySmartAccount)
 				r.Get("/{id}/smart-account", s.handlers.GetSmartAccount)
+				r.Get("/{id}/effective-permissions", s.handlers.GetEffectivePermissions)
+				r.Post("/{id}/recommend-policy", s.handlers.RecommendPolicy)
+				r.Post("/{id}/circuit/reset", s.handlers.ResetAgentCircuit)
+				r.Post("/{id}/bundle", s.handlers.ExportAgentBundle)
 			})
 
 			// Policies
 			r.Route("/policies", func(r chi.Router) {
 				r.Post("/", s.handlers.CreatePolicy)
 				r.Get("/", s.handlers.ListPolicies)
+				r.Get("/strict-audit", s.handlers.StrictAudit)
 				r.Get("/{id}", s.handlers.GetPolicy)
 				r.Put("/{id}", s.handlers.UpdatePolicy)
 				r.Delete("/{id}", s.handlers.DeletePolicy)
 				r.Post("/{id}/activate", s.handlers.ActivatePolicy)
 				r.Post("/{id}/revoke", s.handlers.RevokePolicy)
 				r.Post("/{id}/reactivate", s.handlers.ReactivatePolicy)
+				r.Get("/{id}/verify", s.handlers.VerifyPolicy)
+				r.Get("/{id}/versions/{version}/approval", s.handlers.GetPolicyApproval)
+			})
+
+			// Policy templates
+			r.Route("/templates", func(r chi.Router) {
+				r.Get("/library", s.handlers.ListTemplateLibrary)
+				r.Post("/", s.handlers.CreateTemplate)
+				r.Get("/", s.handlers.ListTemplates)
+				r.Get("/{id}", s.handlers.GetTemplate)
+				r.Put("/{id}", s.handlers.UpdateTemplate)
+				r.Delete("/{id}", s.handlers.DeleteTemplate)
+				r.Post("/{id}/render", s.handlers.RenderTemplate)
+				r.Get("/{id}/instances", s.handlers.ListTemplateInstances)
+				r.Post("/{id}/propagate", s.handlers.PropagateTemplate)
+			})
+
+			// Guardrails (wallet-wide boundaries)
+			r.Route("/guardrails", func(r chi.Router) {
+				r.Post("/", s.handlers.CreateGuardrail)
+				r.Get("/", s.handlers.ListGuardrails)
+				r.Get("/{id}", s.handlers.GetGuardrail)
+				r.Patch("/{id}", s.handlers.UpdateGuardrail)
+				r.Delete("/{id}", s.handlers.DeleteGuardrail)
+			})
+
+			// Agent groups with shared budgets
+			r.Route("/agent-groups", func(r chi.Router) {
+				r.Post("/", s.handlers.CreateAgentGroup)
+				r.Get("/", s.handlers.ListAgentGroups)
+				r.Get("/{id}", s.handlers.GetAgentGroup)
+				r.Patch("/{id}", s.handlers.UpdateAgentGroup)
+				r.Delete("/{id}", s.handlers.DeleteAgentGroup)
+				r.Put("/{id}/members/{agentId}", s.handlers.SetGroupMember)
+				r.Delete("/{id}/members/{agentId}", s.handlers.RemoveGroupMember)
+				r.Post("/{id}/grants", s.handlers.CreateGroupGrant)
+				r.Get("/{id}/grants", s.handlers.ListGroupGrants)
+				r.Delete("/{id}/grants/{grantId}", s.handlers.RevokeGroupGrant)
+				r.Post("/{id}/grants/{grantId}/mint", s.handlers.MintGroupGrant)
+			})
+
+			// Wallet settings
+			r.Get("/settings", s.handlers.GetSettings)
+			r.Patch("/settings", s.handlers.UpdateSettings)
+
+			// Access review
+			r.Get("/access-review", s.handlers.GetAccessReview)
+			r.Post("/access-review/revoke", s.handlers.BulkRevokePermissions)
+
+			// Break-glass escalations
+			r.Route("/escalations", func(r chi.Router) {
+				r.Get("/", s.handlers.ListEscalations)
+				r.Post("/", s.handlers.CreateEscalation)
+				r.Get("/{id}", s.handlers.GetEscalation)
+				r.Post("/{id}/approve", s.handlers.ApproveEscalation)
+				r.Post("/{id}/reject", s.handlers.RejectEscalation)
+				r.Post("/{id}/revoke", s.handlers.RevokeEscalation)
+			})
+
+			// Circuit breakers on repeated denials
+			r.Route("/circuit-breakers", func(r chi.Router) {
+				r.Post("/", s.handlers.CreateCircuitBreaker)
+				r.Get("/", s.handlers.ListCircuitBreakers)
+				r.Get("/trips", s.handlers.ListCircuitTrips)
+				r.Get("/{id}", s.handlers.GetCircuitBreaker)
+				r.Patch("/{id}", s.handlers.UpdateCircuitBreaker)
+				r.Delete("/{id}", s.handlers.DeleteCircuitBreaker)
+			})
+
+			// Emergency kill switch
+			r.Route("/kill-switch", func(r chi.Router) {
+				r.Get("/", s.handlers.GetKillSwitch)
+				r.Post("/", s.handlers.EngageKillSwitch)
+				r.Post("/unfreeze", s.handlers.Unfreeze)
+				r.Get("/jobs", s.handlers.ListKillSwitchJobs)
+				r.Get("/jobs/{id}", s.handlers.GetKillSwitchJob)
+				r.Post("/jobs/{id}/retry", s.handlers.RetryKillSwitchJob)
+			})
+
+			// Permission requests from agents, decided by the owner
+			r.Route("/permission-requests", func(r chi.Router) {
+				r.Post("/", s.handlers.CreatePermissionRequest)
+				r.Get("/", s.handlers.ListPermissionRequests)
+				r.Get("/{id}", s.handlers.GetPermissionRequest)
+				r.Post("/{id}/approve", s.handlers.ApprovePermissionRequest)
+				r.Post("/{id}/reject", s.handlers.RejectPermissionRequest)
+			})
+
+			// Scheduled policy and permission operations
+			r.Route("/schedules", func(r chi.Router) {
+				r.Post("/", s.handlers.CreateSchedule)
+				r.Get("/", s.handlers.ListSchedules)
+				r.Get("/{id}", s.handlers.GetSchedule)
+				r.Post("/{id}/cancel", s.handlers.CancelSchedule)
 			})
 
 			// Permissions
@@ -106,6 +225,24 @@ func (s *Server) setupRoutes() {
 				r.Get("/{id}", s.handlers.GetPermission)
 				r.Delete("/{id}", s.handlers.DeletePermission)
 				r.Post("/{id}/mint", s.handlers.MintPermission)
+				r.Post("/{id}/rotate", s.handlers.RotatePermission)
+				r.Get("/{id}/lineage", s.handlers.GetPermissionLineage)
+				r.Post("/{id}/delegate", s.handlers.DelegatePermission)
+				r.Get("/{id}/delegations", s.handlers.ListDelegations)
+				r.Post("/{id}/sessions", s.handlers.CreateSession)
+				r.Get("/{id}/sessions", s.handlers.ListSessions)
+			})
+
+			// Signed policy bundles for offline evaluation
+			r.Route("/bundles", func(r chi.Router) {
+				r.Get("/epoch", s.handlers.GetRevocationEpoch)
+				r.Post("/revoke", s.handlers.RevokeBundles)
+			})
+
+			// Policy-as-code bundles
+			r.Route("/gitops", func(r chi.Router) {
+				r.Post("/plan", s.handlers.PlanBundle)
+				r.Post("/apply", s.handlers.ApplyBundle)
 			})
 
 			// Validation (Core Product)
@@ -133,6 +270,11 @@ func (s *Server) setupRoutes() {
 	})
 }
 
+// StartScheduler runs scheduled operations in the background until ctx ends.
+func (s *Server) StartScheduler(ctx context.Context) {
+	s.handlers.StartScheduler(ctx, s.config.Jobs.ScheduleInterval)
+}
+
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	s.router.ServeHTTP(w, r)
 }
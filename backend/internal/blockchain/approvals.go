@@ -0,0 +1,82 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/erc8004/policy-saas/pkg/policyeval"
+)
+
+// PolicyApprovalType is the EIP-712 type a wallet owner signs to approve one
+// version of a policy.
+const PolicyApprovalType = "PolicyApproval(address wallet,bytes32 policyId,uint256 version,bytes32 contentHash)"
+
+var policyApprovalTypeHash = crypto.Keccak256([]byte(PolicyApprovalType))
+
+// eip1271MagicValue is bytes4(keccak256("isValidSignature(bytes32,bytes)")),
+// returned by a contract wallet that accepts a signature.
+var eip1271MagicValue = []byte{0x16, 0x26, 0xba, 0x7e}
+
+// PolicyApproval is an owner's approval of a policy version. ContentHash is
+// the keccak256 of the version's canonical definition.
+type PolicyApproval struct {
+	Wallet      common.Address
+	PolicyID    [32]byte
+	Version     int64
+	ContentHash [32]byte
+}
+
+// Digest returns the EIP-712 digest of the approval on chainID.
+func (a PolicyApproval) Digest(chainID int64) [32]byte {
+	structHash := crypto.Keccak256(
+		policyApprovalTypeHash,
+		common.LeftPadBytes(a.Wallet.Bytes(), 32),
+		a.PolicyID[:],
+		math.U256Bytes(big.NewInt(a.Version)),
+		a.ContentHash[:],
+	)
+	return policyeval.TypedDataDigest(policyeval.ServiceDomainSeparator(chainID), structHash)
+}
+
+// encodeIsValidSignature returns the calldata of EIP-1271
+// isValidSignature(bytes32 hash, bytes signature).
+func encodeIsValidSignature(digest [32]byte, sig []byte) []byte {
+	data := append([]byte{}, eip1271MagicValue...)
+	data = append(data, digest[:]...)
+	data = append(data, math.U256Bytes(big.NewInt(64))...)
+	data = append(data, math.U256Bytes(big.NewInt(int64(len(sig))))...)
+	data = append(data, sig...)
+	if pad := len(sig) % 32; pad != 0 {
+		data = append(data, make([]byte, 32-pad)...)
+	}
+	return data
+}
+
+// IsValidSignature asks a contract wallet, through EIP-1271, whether sig is
+// a valid signature of digest on its behalf. It needs an RPC connection and
+// reports false for accounts without code.
+func (c *Client) IsValidSignature(ctx context.Context, account common.Address, digest [32]byte, sig []byte) (bool, error) {
+	if c.simulated || c.ethClient == nil {
+		return false, errors.New("contract wallet signatures cannot be checked in simulated mode")
+	}
+	code, err := c.ethClient.CodeAt(ctx, account, nil)
+	if err != nil {
+		return false, err
+	}
+	if len(code) == 0 {
+		return false, nil
+	}
+	out, err := c.ethClient.CallContract(ctx, ethereum.CallMsg{To: &account, Data: encodeIsValidSignature(digest, sig)}, nil)
+	if err != nil {
+		// A reverting isValidSignature rejects the signature
+		return false, nil
+	}
+	return len(out) >= 4 && bytes.Equal(out[:4], eip1271MagicValue), nil
+}
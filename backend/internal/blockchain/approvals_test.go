@@ -0,0 +1,80 @@
+package blockchain
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+
+	"github.com/erc8004/policy-saas/pkg/policyeval"
+)
+
+func TestPolicyApprovalDigest_MatchesTypedData(t *testing.T) {
+	a := PolicyApproval{
+		Wallet:      common.HexToAddress("0x2c7536E3605D9C16a7a3D7b1898e529396a65c23"),
+		PolicyID:    UUIDToBytes32("550e8400-e29b-41d4-a716-446655440000"),
+		Version:     3,
+		ContentHash: Keccak256String("definition"),
+	}
+
+	td := apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+			},
+			"PolicyApproval": {
+				{Name: "wallet", Type: "address"},
+				{Name: "policyId", Type: "bytes32"},
+				{Name: "version", Type: "uint256"},
+				{Name: "contentHash", Type: "bytes32"},
+			},
+		},
+		PrimaryType: "PolicyApproval",
+		Domain: apitypes.TypedDataDomain{
+			Name:    policyeval.ServiceDomainName,
+			Version: policyeval.ServiceDomainVersion,
+			ChainId: (*math.HexOrDecimal256)(big.NewInt(1)),
+		},
+		Message: apitypes.TypedDataMessage{
+			"wallet":      a.Wallet.Hex(),
+			"policyId":    hexutil.Encode(a.PolicyID[:]),
+			"version":     "3",
+			"contentHash": hexutil.Encode(a.ContentHash[:]),
+		},
+	}
+	want, _, err := apitypes.TypedDataAndHash(td)
+	if err != nil {
+		t.Fatalf("typed data hash: %v", err)
+	}
+
+	got := a.Digest(1)
+	if !bytes.Equal(got[:], want) {
+		t.Fatalf("digest mismatch:\n got  %x\n want %x", got, want)
+	}
+}
+
+func TestEncodeIsValidSignature_MatchesABI(t *testing.T) {
+	bytes32, _ := abi.NewType("bytes32", "", nil)
+	bytesT, _ := abi.NewType("bytes", "", nil)
+	args := abi.Arguments{{Type: bytes32}, {Type: bytesT}}
+
+	digest := Keccak256String("digest")
+	for _, n := range []int{65, 64, 0} {
+		sig := bytes.Repeat([]byte{0xab}, n)
+		packed, err := args.Pack(digest, sig)
+		if err != nil {
+			t.Fatalf("pack: %v", err)
+		}
+		want := append(append([]byte{}, eip1271MagicValue...), packed...)
+		if got := encodeIsValidSignature(digest, sig); !bytes.Equal(got, want) {
+			t.Fatalf("calldata mismatch for %d-byte signature:\n got  %x\n want %x", n, got, want)
+		}
+	}
+}
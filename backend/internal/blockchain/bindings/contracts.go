@@ -50,6 +50,7 @@ const policyRegistryABI = `[
 	{"type":"function","name":"getPolicy","inputs":[{"name":"policyId","type":"bytes32"}],"outputs":[{"name":"id","type":"bytes32"},{"name":"owner","type":"address"},{"name":"contentHash","type":"bytes32"},{"name":"version","type":"uint256"},{"name":"createdAt","type":"uint256"},{"name":"updatedAt","type":"uint256"},{"name":"active","type":"bool"}],"stateMutability":"view"},
 	{"type":"function","name":"getPermission","inputs":[{"name":"permissionId","type":"bytes32"}],"outputs":[{"name":"policyId","type":"bytes32"},{"name":"agentId","type":"bytes32"},{"name":"owner","type":"address"},{"name":"validFrom","type":"uint256"},{"name":"validUntil","type":"uint256"},{"name":"active","type":"bool"}],"stateMutability":"view"},
 	{"type":"function","name":"isPermissionValid","inputs":[{"name":"permissionId","type":"bytes32"}],"outputs":[{"name":"","type":"bool"}],"stateMutability":"view"},
+	{"type":"function","name":"updatePolicy","inputs":[{"name":"policyId","type":"bytes32"},{"name":"contentHash","type":"bytes32"}],"outputs":[],"stateMutability":"nonpayable"},
 	{"type":"function","name":"deactivatePolicy","inputs":[{"name":"policyId","type":"bytes32"}],"outputs":[],"stateMutability":"nonpayable"},
 	{"type":"function","name":"reactivatePolicy","inputs":[{"name":"policyId","type":"bytes32"}],"outputs":[],"stateMutability":"nonpayable"},
 	{"type":"function","name":"revokePermission","inputs":[{"name":"permissionId","type":"bytes32"}],"outputs":[],"stateMutability":"nonpayable"}
@@ -5,6 +5,7 @@ import (
 	"crypto/ecdsa"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"math/big"
 	"strings"
@@ -272,6 +273,26 @@ func (c *Client) reactivateAgent(ctx context.Context, agentID [32]byte) (string,
 	return receipt.TxHash.Hex(), nil
 }
 
+// DeactivateAgent calls deactivateAgent on the IdentityRegistry, so the
+// agent can no longer act on-chain until it is reactivated.
+// Returns the transaction hash.
+func (c *Client) DeactivateAgent(ctx context.Context, agentID [32]byte) (string, error) {
+	if c.simulated || c.identityRegistry == nil {
+		hash := sha256.Sum256(append([]byte("deactivate:"), agentID[:]...))
+		return "0x" + hex.EncodeToString(hash[:]), nil
+	}
+
+	tx, err := c.transact(ctx, c.identityRegistry.BoundContract, "deactivateAgent", agentID)
+	if err != nil {
+		return "", fmt.Errorf("deactivateAgent tx failed: %w", err)
+	}
+	receipt, err := c.WaitForTx(ctx, tx)
+	if err != nil {
+		return "", err
+	}
+	return receipt.TxHash.Hex(), nil
+}
+
 // GetCreationFee returns the current smart account creation fee in wei.
 // In simulated mode, returns 0.
 func (c *Client) GetCreationFee(ctx context.Context) (*big.Int, error) {
@@ -350,9 +371,9 @@ func (c *Client) CreateSmartAccount(ctx context.Context, owner common.Address, s
 // Returns the on-chain policy ID (bytes32) as hex string and the tx hash.
 func (c *Client) CreatePolicy(ctx context.Context, contentHash [32]byte) (string, string, error) {
 	if c.simulated || c.policyRegistry == nil {
-		policyID := crypto.Keccak256(append([]byte("policy:"), contentHash[:]...))
-		txHash := sha256.Sum256(append([]byte("createPolicy:"), policyID...))
-		return "0x" + hex.EncodeToString(policyID), "0x" + hex.EncodeToString(txHash[:]), nil
+		policyID := SimulatedPolicyID(contentHash)
+		txHash := sha256.Sum256(append([]byte("createPolicy:"), policyID[:]...))
+		return "0x" + hex.EncodeToString(policyID[:]), "0x" + hex.EncodeToString(txHash[:]), nil
 	}
 
 	tx, err := c.transact(ctx, c.policyRegistry.BoundContract, "createPolicy", contentHash)
@@ -372,8 +393,37 @@ func (c *Client) CreatePolicy(ctx context.Context, contentHash [32]byte) (string
 	}
 
 	// Fallback: compute deterministically
-	policyID := crypto.Keccak256(append([]byte("policy:"), contentHash[:]...))
-	return "0x" + hex.EncodeToString(policyID), receipt.TxHash.Hex(), nil
+	policyID := SimulatedPolicyID(contentHash)
+	return "0x" + hex.EncodeToString(policyID[:]), receipt.TxHash.Hex(), nil
+}
+
+// SimulatedPolicyID returns the policy ID that CreatePolicy derives from a
+// content hash when no PolicyCreated log is available (simulated mode).
+func SimulatedPolicyID(contentHash [32]byte) [32]byte {
+	var result [32]byte
+	copy(result[:], crypto.Keccak256(append([]byte("policy:"), contentHash[:]...)))
+	return result
+}
+
+// UpdatePolicy registers a new content hash for an existing policy in the
+// PolicyRegistry, which bumps its on-chain version. Returns the tx hash.
+func (c *Client) UpdatePolicy(ctx context.Context, policyID [32]byte, contentHash [32]byte) (string, error) {
+	if c.simulated || c.policyRegistry == nil {
+		txHash := sha256.Sum256(append(append([]byte("updatePolicy:"), policyID[:]...), contentHash[:]...))
+		return "0x" + hex.EncodeToString(txHash[:]), nil
+	}
+
+	tx, err := c.transact(ctx, c.policyRegistry.BoundContract, "updatePolicy", policyID, contentHash)
+	if err != nil {
+		return "", fmt.Errorf("updatePolicy tx failed: %w", err)
+	}
+
+	receipt, err := c.WaitForTx(ctx, tx)
+	if err != nil {
+		return "", err
+	}
+
+	return receipt.TxHash.Hex(), nil
 }
 
 // DeactivatePolicy deactivates a policy on-chain in the PolicyRegistry.
@@ -418,6 +468,48 @@ func (c *Client) ReactivatePolicy(ctx context.Context, policyID [32]byte) (strin
 	return receipt.TxHash.Hex(), nil
 }
 
+// OnchainPolicy mirrors the PolicyRegistry.Policy struct returned by getPolicy.
+type OnchainPolicy struct {
+	ID          [32]byte
+	Owner       common.Address
+	ContentHash [32]byte
+	Version     *big.Int
+	CreatedAt   *big.Int
+	UpdatedAt   *big.Int
+	Active      bool
+}
+
+// GetPolicy reads a policy from the PolicyRegistry on-chain.
+// Returns nil in simulated mode since there is no chain state to read.
+func (c *Client) GetPolicy(ctx context.Context, policyID [32]byte) (*OnchainPolicy, error) {
+	if c.simulated || c.policyRegistry == nil {
+		return nil, nil
+	}
+
+	var result []interface{}
+	err := c.policyRegistry.Call(&bind.CallOpts{Context: ctx}, &result, "getPolicy", policyID)
+	if err != nil {
+		return nil, fmt.Errorf("policyRegistry.getPolicy failed: %w", err)
+	}
+	if len(result) < 7 {
+		return nil, fmt.Errorf("policyRegistry.getPolicy returned %d values, expected 7", len(result))
+	}
+
+	p := &OnchainPolicy{}
+	p.ID, _ = result[0].([32]byte)
+	p.Owner, _ = result[1].(common.Address)
+	p.ContentHash, _ = result[2].([32]byte)
+	p.Version, _ = result[3].(*big.Int)
+	p.CreatedAt, _ = result[4].(*big.Int)
+	p.UpdatedAt, _ = result[5].(*big.Int)
+	p.Active, _ = result[6].(bool)
+
+	if p.Owner == (common.Address{}) {
+		return nil, fmt.Errorf("policy 0x%s not found on-chain", hex.EncodeToString(policyID[:]))
+	}
+	return p, nil
+}
+
 // IsAgentActiveOnchain returns true if the agent is registered and active in the
 // IdentityRegistry on-chain. Returns false in simulated mode or on any error.
 func (c *Client) IsAgentActiveOnchain(ctx context.Context, agentID [32]byte) bool {
@@ -433,6 +525,25 @@ func (c *Client) IsAgentActiveOnchain(ctx context.Context, agentID [32]byte) boo
 	return ok && active
 }
 
+// IsPermissionValid reads PolicyRegistry.isPermissionValid for a minted
+// permission. Unlike IsAgentActiveOnchain it reports failures, since callers
+// show the on-chain answer to third parties; simulated mode has no answer.
+func (c *Client) IsPermissionValid(ctx context.Context, permissionID [32]byte) (bool, error) {
+	if c.simulated || c.policyRegistry == nil {
+		return false, errors.New("no on-chain state in simulated mode")
+	}
+	var result []interface{}
+	err := c.policyRegistry.Call(&bind.CallOpts{Context: ctx}, &result, "isPermissionValid", permissionID)
+	if err != nil {
+		return false, fmt.Errorf("policyRegistry.isPermissionValid failed: %w", err)
+	}
+	if len(result) == 0 {
+		return false, errors.New("policyRegistry.isPermissionValid returned no value")
+	}
+	valid, _ := result[0].(bool)
+	return valid, nil
+}
+
 // DiagnoseGrantPermission performs read-only pre-flight checks and returns a
 // human-readable reason if grantPermission would revert. Returns "" if all
 // checks pass. No-ops in simulated mode.
@@ -136,6 +136,25 @@ func TestRegisterAgent_Simulated(t *testing.T) {
 	}
 }
 
+func TestDeactivateAgent_Simulated(t *testing.T) {
+	cfg := &config.Config{
+		Blockchain: config.BlockchainConfig{},
+	}
+	logger := zerolog.Nop()
+	client := NewClient(cfg, logger)
+
+	agentID := UUIDToBytes32("550e8400-e29b-41d4-a716-446655440000")
+	result, err := client.DeactivateAgent(nil, agentID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	registered, _ := client.RegisterAgent(nil, agentID, "test-agent")
+	if result == "" || result == registered {
+		t.Fatalf("expected a distinct simulated tx hash, got %q", result)
+	}
+}
+
 func TestCreateSmartAccount_Simulated(t *testing.T) {
 	cfg := &config.Config{
 		Blockchain: config.BlockchainConfig{},
@@ -1,6 +1,7 @@
 package blockchain
 
 import (
+	"bytes"
 	"encoding/hex"
 	"fmt"
 	"math/big"
@@ -27,6 +28,16 @@ func UUIDToBytes32(id string) [32]byte {
 	return result
 }
 
+// Bytes32ToUUID reverses UUIDToBytes32 for IDs made from a UUID, which are
+// zero in their first 16 bytes.
+func Bytes32ToUUID(b [32]byte) (uuid.UUID, error) {
+	var zero [16]byte
+	if !bytes.Equal(b[:16], zero[:]) {
+		return uuid.Nil, fmt.Errorf("bytes32 is not a UUID-derived ID")
+	}
+	return uuid.FromBytes(b[16:])
+}
+
 // Keccak256String returns keccak256(abi.encodePacked(s)) as [32]byte,
 // matching what Solidity keccak256(abi.encodePacked(string)) produces.
 func Keccak256String(s string) [32]byte {
@@ -82,3 +93,49 @@ func HexToBytes32(hexStr string) ([32]byte, error) {
 func HexToAddress(hexStr string) common.Address {
 	return common.HexToAddress(hexStr)
 }
+
+var (
+	selectorERC20Transfer = []byte{0xa9, 0x05, 0x9c, 0xbb} // transfer(address,uint256)
+	selectorERC20Approve  = []byte{0x09, 0x5e, 0xa7, 0xb3} // approve(address,uint256)
+)
+
+// DecodeExecutedAction reconstructs the policy action performed by an
+// AgentSmartAccount Executed(address indexed target, uint256 value, uint256 fee, bytes data)
+// event. ERC-20 transfers and approvals and plain native transfers are
+// recognised; any other call is reported with the target as protocol and no
+// action type. Keys match the JSON form of policy.Action.
+func DecodeExecutedAction(target common.Address, logData []byte) map[string]interface{} {
+	if len(logData) < 128 {
+		return nil
+	}
+	value := new(big.Int).SetBytes(logData[0:32])
+	length := new(big.Int).SetBytes(logData[96:128])
+	var calldata []byte
+	if length.IsInt64() && int64(len(logData)-128) >= length.Int64() {
+		calldata = logData[128 : 128+length.Int64()]
+	}
+
+	action := map[string]interface{}{}
+	switch {
+	case len(calldata) >= 68 && bytes.Equal(calldata[:4], selectorERC20Transfer):
+		action["type"] = "transfer"
+		action["token"] = strings.ToLower(target.Hex())
+		action["to"] = strings.ToLower(common.BytesToAddress(calldata[4:36]).Hex())
+		action["amount"] = new(big.Int).SetBytes(calldata[36:68]).String()
+	case len(calldata) >= 68 && bytes.Equal(calldata[:4], selectorERC20Approve):
+		action["type"] = "approve"
+		action["token"] = strings.ToLower(target.Hex())
+		action["protocol"] = strings.ToLower(common.BytesToAddress(calldata[4:36]).Hex())
+		action["amount"] = new(big.Int).SetBytes(calldata[36:68]).String()
+	case len(calldata) == 0:
+		action["type"] = "transfer"
+		action["to"] = strings.ToLower(target.Hex())
+		action["amount"] = value.String()
+	default:
+		action["protocol"] = strings.ToLower(target.Hex())
+		if value.Sign() > 0 {
+			action["amount"] = value.String()
+		}
+	}
+	return action
+}
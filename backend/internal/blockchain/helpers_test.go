@@ -3,8 +3,10 @@ package blockchain
 import (
 	"encoding/hex"
 	"math/big"
+	"strings"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/google/uuid"
 )
 
@@ -55,6 +57,22 @@ func TestUUIDToBytes32_InvalidUUID(t *testing.T) {
 	}
 }
 
+func TestBytes32ToUUID_RoundTrip(t *testing.T) {
+	id := "550e8400-e29b-41d4-a716-446655440000"
+	got, err := Bytes32ToUUID(UUIDToBytes32(id))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.String() != id {
+		t.Fatalf("expected %s, got %s", id, got)
+	}
+
+	// Hash-derived IDs do not map back to a UUID
+	if _, err := Bytes32ToUUID(UUIDToBytes32("not-a-uuid")); err == nil {
+		t.Fatal("expected non-UUID bytes32 to be rejected")
+	}
+}
+
 func TestKeccak256String(t *testing.T) {
 	result := Keccak256String("swap")
 
@@ -156,3 +174,46 @@ func TestWeiFromString_LargeValue(t *testing.T) {
 		t.Fatalf("WeiFromString large value: got %s, want %s", val.String(), expected.String())
 	}
 }
+
+func executedLogData(value int64, calldata []byte) []byte {
+	data := make([]byte, 128)
+	big.NewInt(value).FillBytes(data[0:32])
+	big.NewInt(96).FillBytes(data[64:96])
+	big.NewInt(int64(len(calldata))).FillBytes(data[96:128])
+	padded := make([]byte, (len(calldata)+31)/32*32)
+	copy(padded, calldata)
+	return append(data, padded...)
+}
+
+func TestDecodeExecutedAction_ERC20Transfer(t *testing.T) {
+	token := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	calldata := append([]byte{0xa9, 0x05, 0x9c, 0xbb}, common.LeftPadBytes(to.Bytes(), 32)...)
+	calldata = append(calldata, common.LeftPadBytes(big.NewInt(2500).Bytes(), 32)...)
+
+	action := DecodeExecutedAction(token, executedLogData(0, calldata))
+	if action["type"] != "transfer" || action["amount"] != "2500" {
+		t.Fatalf("unexpected action: %v", action)
+	}
+	if action["token"] != strings.ToLower(token.Hex()) || action["to"] != strings.ToLower(to.Hex()) {
+		t.Fatalf("unexpected token or recipient: %v", action)
+	}
+}
+
+func TestDecodeExecutedAction_NativeAndUnknown(t *testing.T) {
+	target := common.HexToAddress("0x2222222222222222222222222222222222222222")
+
+	native := DecodeExecutedAction(target, executedLogData(1000, nil))
+	if native["type"] != "transfer" || native["amount"] != "1000" || native["token"] != nil {
+		t.Fatalf("unexpected native transfer: %v", native)
+	}
+
+	call := DecodeExecutedAction(target, executedLogData(0, []byte{0xde, 0xad, 0xbe, 0xef}))
+	if call["type"] != nil || call["protocol"] != strings.ToLower(target.Hex()) {
+		t.Fatalf("unexpected contract call: %v", call)
+	}
+
+	if DecodeExecutedAction(target, []byte{0x01}) != nil {
+		t.Fatal("expected nil for truncated data")
+	}
+}
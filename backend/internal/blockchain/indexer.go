@@ -35,6 +35,7 @@ type Indexer struct {
 	auditLogger  *audit.Logger
 	logger       zerolog.Logger
 	pollInterval time.Duration
+	onViolation  func(ctx context.Context, walletID, agentID uuid.UUID)
 }
 
 // NewIndexer creates a new Indexer.
@@ -48,6 +49,12 @@ func NewIndexer(client *Client, db *pgxpool.Pool, auditLogger *audit.Logger, log
 	}
 }
 
+// OnConstraintViolation registers fn to be called after a ConstraintViolation
+// event of a known agent is recorded.
+func (idx *Indexer) OnConstraintViolation(fn func(ctx context.Context, walletID, agentID uuid.UUID)) {
+	idx.onViolation = fn
+}
+
 // Start launches the indexer loop in the background.
 // No-ops in simulated mode.
 func (idx *Indexer) Start(ctx context.Context) {
@@ -283,6 +290,9 @@ func (idx *Indexer) handleConstraintViolation(ctx context.Context, log types.Log
 			"contract": log.Address.Hex(),
 		},
 	})
+	if idx.onViolation != nil && agentID != nil {
+		idx.onViolation(ctx, walletID, *agentID)
+	}
 }
 
 func (idx *Indexer) handleUsageRecorded(ctx context.Context, log types.Log, txHash string, blockNumber int64) {
@@ -324,6 +334,18 @@ func (idx *Indexer) handleExecuted(ctx context.Context, log types.Log, txHash st
 		return
 	}
 	aid := agentID
+	details := map[string]interface{}{
+		"tx_hash":       txHash,
+		"block":         blockNumber,
+		"smart_account": accountAddr,
+	}
+	if len(log.Topics) >= 2 {
+		target := common.BytesToAddress(log.Topics[1].Bytes())
+		details["target"] = target.Hex()
+		if action := DecodeExecutedAction(target, log.Data); action != nil {
+			details["action"] = action
+		}
+	}
 	idx.auditLogger.Log(ctx, audit.Event{
 		WalletID:    walletID,
 		AgentID:     &aid,
@@ -331,11 +353,7 @@ func (idx *Indexer) handleExecuted(ctx context.Context, log types.Log, txHash st
 		Source:      "onchain",
 		TxHash:      txHash,
 		BlockNumber: blockNumber,
-		Details: map[string]interface{}{
-			"tx_hash":       txHash,
-			"block":         blockNumber,
-			"smart_account": accountAddr,
-		},
+		Details:     details,
 	})
 }
 
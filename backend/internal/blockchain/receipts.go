@@ -0,0 +1,88 @@
+package blockchain
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/erc8004/policy-saas/pkg/policyeval"
+)
+
+// ValidationReceiptType is the EIP-712 type of a signed validation decision.
+const ValidationReceiptType = "ValidationReceipt(bytes32 requestId,bytes32 agentId,bytes32 actionHash,bool allowed,bytes32 policyHash,uint256 expiresAt)"
+
+var validationReceiptTypeHash = crypto.Keccak256([]byte(ValidationReceiptType))
+
+// ValidationReceipt is the signed content of a validation decision.
+// PolicyHash is the content hash of the policy that allowed the action, or
+// zero when no policy did.
+type ValidationReceipt struct {
+	RequestID  [32]byte
+	AgentID    [32]byte
+	ActionHash [32]byte
+	Allowed    bool
+	PolicyHash [32]byte
+	ExpiresAt  int64
+}
+
+// Digest returns the EIP-712 digest of the receipt on chainID.
+func (r ValidationReceipt) Digest(chainID int64) [32]byte {
+	var allowed [32]byte
+	if r.Allowed {
+		allowed[31] = 1
+	}
+	structHash := crypto.Keccak256(
+		validationReceiptTypeHash,
+		r.RequestID[:],
+		r.AgentID[:],
+		r.ActionHash[:],
+		allowed[:],
+		r.PolicyHash[:],
+		math.U256Bytes(big.NewInt(r.ExpiresAt)),
+	)
+	return policyeval.TypedDataDigest(policyeval.ServiceDomainSeparator(chainID), structHash)
+}
+
+// ServiceSigner signs the policy service's EIP-712 statements with a
+// dedicated key, kept apart from the deployer key that sends transactions.
+type ServiceSigner struct {
+	key     *ecdsa.PrivateKey
+	address common.Address
+	chainID int64
+}
+
+// NewServiceSigner loads a hex-encoded secp256k1 private key.
+func NewServiceSigner(hexKey string, chainID int64) (*ServiceSigner, error) {
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(hexKey, "0x"))
+	if err != nil {
+		return nil, errors.New("invalid signing key")
+	}
+	return &ServiceSigner{key: key, address: crypto.PubkeyToAddress(key.PublicKey), chainID: chainID}, nil
+}
+
+// Address returns the address signatures recover to.
+func (s *ServiceSigner) Address() common.Address { return s.address }
+
+// ChainID returns the chain ID of the signing domain.
+func (s *ServiceSigner) ChainID() int64 { return s.chainID }
+
+// SignDigest signs an EIP-712 digest. The signature is 65 bytes, r ‖ s ‖ v
+// with v of 27 or 28 as Solidity's ecrecover expects.
+func (s *ServiceSigner) SignDigest(digest [32]byte) ([]byte, error) {
+	sig, err := crypto.Sign(digest[:], s.key)
+	if err != nil {
+		return nil, err
+	}
+	sig[64] += 27
+	return sig, nil
+}
+
+// SignReceipt signs a validation receipt.
+func (s *ServiceSigner) SignReceipt(r ValidationReceipt) ([]byte, error) {
+	return s.SignDigest(r.Digest(s.chainID))
+}
@@ -0,0 +1,119 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+
+	"github.com/erc8004/policy-saas/pkg/policyeval"
+)
+
+const testSigningKey = "4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+
+func testReceipt() ValidationReceipt {
+	return ValidationReceipt{
+		RequestID:  UUIDToBytes32("550e8400-e29b-41d4-a716-446655440000"),
+		AgentID:    UUIDToBytes32("6ba7b810-9dad-11d1-80b4-00c04fd430c8"),
+		ActionHash: Keccak256String(`{"amount":"100","type":"swap"}`),
+		Allowed:    true,
+		PolicyHash: Keccak256String("policy"),
+		ExpiresAt:  1893456000,
+	}
+}
+
+func TestValidationReceiptDigest_MatchesTypedData(t *testing.T) {
+	r := testReceipt()
+	chainID := int64(8453)
+
+	td := apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+			},
+			"ValidationReceipt": {
+				{Name: "requestId", Type: "bytes32"},
+				{Name: "agentId", Type: "bytes32"},
+				{Name: "actionHash", Type: "bytes32"},
+				{Name: "allowed", Type: "bool"},
+				{Name: "policyHash", Type: "bytes32"},
+				{Name: "expiresAt", Type: "uint256"},
+			},
+		},
+		PrimaryType: "ValidationReceipt",
+		Domain: apitypes.TypedDataDomain{
+			Name:    policyeval.ServiceDomainName,
+			Version: policyeval.ServiceDomainVersion,
+			ChainId: (*math.HexOrDecimal256)(big.NewInt(chainID)),
+		},
+		Message: apitypes.TypedDataMessage{
+			"requestId":  hexutil.Encode(r.RequestID[:]),
+			"agentId":    hexutil.Encode(r.AgentID[:]),
+			"actionHash": hexutil.Encode(r.ActionHash[:]),
+			"allowed":    r.Allowed,
+			"policyHash": hexutil.Encode(r.PolicyHash[:]),
+			"expiresAt":  big.NewInt(r.ExpiresAt).String(),
+		},
+	}
+	want, _, err := apitypes.TypedDataAndHash(td)
+	if err != nil {
+		t.Fatalf("typed data hash: %v", err)
+	}
+
+	got := r.Digest(chainID)
+	if !bytes.Equal(got[:], want) {
+		t.Fatalf("digest mismatch:\n got  %x\n want %x", got, want)
+	}
+}
+
+func TestServiceSigner_SignAndRecover(t *testing.T) {
+	signer, err := NewServiceSigner("0x"+testSigningKey, 31337)
+	if err != nil {
+		t.Fatalf("load key: %v", err)
+	}
+	r := testReceipt()
+
+	sig, err := signer.SignReceipt(r)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if len(sig) != 65 || (sig[64] != 27 && sig[64] != 28) {
+		t.Fatalf("unexpected signature format: %s", hex.EncodeToString(sig))
+	}
+
+	addr, err := policyeval.RecoverSigner(r.Digest(31337), sig)
+	if err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	if addr != signer.Address() {
+		t.Fatalf("recovered %s, want %s", addr.Hex(), signer.Address().Hex())
+	}
+
+	// Any change to the receipt, or another chain, recovers someone else
+	tampered := r
+	tampered.Allowed = false
+	if addr, _ := policyeval.RecoverSigner(tampered.Digest(31337), sig); addr == signer.Address() {
+		t.Fatal("tampered receipt should not verify")
+	}
+	if addr, _ := policyeval.RecoverSigner(r.Digest(1), sig); addr == signer.Address() {
+		t.Fatal("receipt should not verify on another chain")
+	}
+}
+
+func TestNewServiceSigner_InvalidKey(t *testing.T) {
+	if _, err := NewServiceSigner("not-a-key", 1); err == nil {
+		t.Fatal("expected invalid key to be rejected")
+	}
+}
+
+func TestRecoverSigner_BadSignature(t *testing.T) {
+	if _, err := policyeval.RecoverSigner([32]byte{}, []byte{1, 2, 3}); err == nil {
+		t.Fatal("expected short signature to be rejected")
+	}
+}
@@ -0,0 +1,4 @@
+ALTER TABLE policy_versions DROP COLUMN IF EXISTS content_hash;
+ALTER TABLE policy_versions DROP COLUMN IF EXISTS canonical_definition;
+ALTER TABLE policies DROP COLUMN IF EXISTS content_hash;
+ALTER TABLE policies DROP COLUMN IF EXISTS canonical_definition;
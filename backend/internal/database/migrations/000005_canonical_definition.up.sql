@@ -0,0 +1,9 @@
+-- Canonical (sorted-key, whitespace-free) serialization of the policy definition
+-- and its keccak256 hash. The JSONB definition column reorders keys, so the
+-- canonical form is stored alongside it to make the on-chain content hash
+-- independently reproducible.
+ALTER TABLE policies ADD COLUMN IF NOT EXISTS canonical_definition TEXT;
+ALTER TABLE policies ADD COLUMN IF NOT EXISTS content_hash VARCHAR(66);
+
+ALTER TABLE policy_versions ADD COLUMN IF NOT EXISTS canonical_definition TEXT;
+ALTER TABLE policy_versions ADD COLUMN IF NOT EXISTS content_hash VARCHAR(66);
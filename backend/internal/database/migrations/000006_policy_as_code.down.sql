@@ -0,0 +1,5 @@
+ALTER TABLE permissions DROP COLUMN IF EXISTS managed_by;
+ALTER TABLE policies DROP COLUMN IF EXISTS managed_by;
+ALTER TABLE agents DROP COLUMN IF EXISTS managed_by;
+
+DROP TABLE IF EXISTS address_books;
@@ -0,0 +1,19 @@
+-- Named address lists that policy bundles reference as "@name"
+CREATE TABLE address_books (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    entries JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE(wallet_id, name)
+);
+
+CREATE INDEX idx_address_books_wallet_id ON address_books(wallet_id);
+
+-- Resources created or adopted by a bundle apply. Only managed resources are
+-- revoked when they are removed from a bundle.
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS managed_by VARCHAR(50);
+ALTER TABLE policies ADD COLUMN IF NOT EXISTS managed_by VARCHAR(50);
+ALTER TABLE permissions ADD COLUMN IF NOT EXISTS managed_by VARCHAR(50);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_policies_template_id;
+ALTER TABLE policies DROP COLUMN IF EXISTS template_params;
+ALTER TABLE policies DROP COLUMN IF EXISTS template_version;
+ALTER TABLE policies DROP COLUMN IF EXISTS template_id;
+
+DROP TABLE IF EXISTS policy_template_versions;
+DROP TABLE IF EXISTS policy_templates;
@@ -0,0 +1,36 @@
+-- Parameterized policy templates. Definitions contain "{{param}}" placeholders
+-- that are filled per permission when the template is granted.
+CREATE TABLE policy_templates (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    library_key VARCHAR(100),
+    parameters JSONB NOT NULL DEFAULT '[]',
+    definition JSONB NOT NULL,
+    version INTEGER NOT NULL DEFAULT 1,
+    status VARCHAR(50) NOT NULL DEFAULT 'active',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_policy_templates_wallet_id ON policy_templates(wallet_id);
+
+CREATE TABLE policy_template_versions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    template_id UUID NOT NULL REFERENCES policy_templates(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    parameters JSONB NOT NULL,
+    definition JSONB NOT NULL,
+    created_by UUID NOT NULL REFERENCES wallets(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE(template_id, version)
+);
+
+-- Policies instantiated from a template keep the parameters they were granted
+-- with so template changes can be re-rendered into them.
+ALTER TABLE policies ADD COLUMN IF NOT EXISTS template_id UUID REFERENCES policy_templates(id) ON DELETE SET NULL;
+ALTER TABLE policies ADD COLUMN IF NOT EXISTS template_version INTEGER;
+ALTER TABLE policies ADD COLUMN IF NOT EXISTS template_params JSONB;
+
+CREATE INDEX idx_policies_template_id ON policies(template_id);
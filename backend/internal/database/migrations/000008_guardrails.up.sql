@@ -0,0 +1,14 @@
+-- Wallet-wide guardrails intersected with every validation decision
+CREATE TABLE guardrails (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    definition JSONB NOT NULL,
+    status VARCHAR(50) NOT NULL DEFAULT 'active',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_guardrails_wallet_id ON guardrails(wallet_id);
+CREATE INDEX idx_guardrails_status ON guardrails(status);
@@ -0,0 +1,4 @@
+-- Wallet default for strict matching: actions that omit a restricted token,
+-- protocol or chain are denied instead of skipping the restriction.
+-- Policies can override it with "strict" in their definition.
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS strict_matching BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,19 @@
+-- Break-glass escalations: temporary constraint overrides for one permission
+CREATE TABLE escalations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    permission_id UUID NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+    constraints JSONB NOT NULL,
+    reason TEXT NOT NULL,
+    approver VARCHAR(42) NOT NULL,
+    status VARCHAR(50) NOT NULL DEFAULT 'pending', -- pending, active, rejected, expired, revoked
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    approved_at TIMESTAMPTZ,
+    ended_at TIMESTAMPTZ
+);
+
+-- At most one open escalation per permission
+CREATE UNIQUE INDEX idx_escalations_open ON escalations(permission_id) WHERE status IN ('pending', 'active');
+CREATE INDEX idx_escalations_wallet_id ON escalations(wallet_id);
+CREATE INDEX idx_escalations_active_expiry ON escalations(expires_at) WHERE status = 'active';
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_validation_requests_counterparty;
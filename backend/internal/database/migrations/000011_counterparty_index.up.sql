@@ -0,0 +1,5 @@
+-- Per-counterparty limits look up an agent's history with one recipient
+-- (or protocol, when the action names no recipient).
+CREATE INDEX IF NOT EXISTS idx_validation_requests_counterparty ON validation_requests (
+    wallet_id, agent_id, LOWER(COALESCE(NULLIF(action_data->>'to', ''), action_data->>'protocol'))
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS group_budget_spend;
+DROP TABLE IF EXISTS agent_group_members;
+DROP TABLE IF EXISTS agent_groups;
@@ -0,0 +1,35 @@
+-- Agent groups share a budget across their member agents
+CREATE TABLE agent_groups (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    budget JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (wallet_id, name)
+);
+
+CREATE INDEX idx_agent_groups_wallet_id ON agent_groups(wallet_id);
+
+-- allocation is the member's own share of the group budget
+CREATE TABLE agent_group_members (
+    group_id UUID NOT NULL REFERENCES agent_groups(id) ON DELETE CASCADE,
+    agent_id UUID NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    allocation JSONB NOT NULL DEFAULT '{}',
+    added_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (group_id, agent_id)
+);
+
+CREATE INDEX idx_agent_group_members_agent_id ON agent_group_members(agent_id);
+
+-- Volume reserved against group budgets by allowed validations
+CREATE TABLE group_budget_spend (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    group_id UUID NOT NULL REFERENCES agent_groups(id) ON DELETE CASCADE,
+    agent_id UUID NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    amount NUMERIC NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_group_budget_spend_group_created ON group_budget_spend(group_id, created_at);
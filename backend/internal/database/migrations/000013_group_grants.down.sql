@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_permissions_group_grant_agent;
+ALTER TABLE permissions DROP COLUMN IF EXISTS group_grant_id;
+DROP TABLE IF EXISTS group_grants;
+ALTER TABLE agent_group_members DROP COLUMN IF EXISTS source;
+ALTER TABLE agent_groups DROP COLUMN IF EXISTS match_tags;
+DROP INDEX IF EXISTS idx_agents_tags;
+ALTER TABLE agents DROP COLUMN IF EXISTS tags;
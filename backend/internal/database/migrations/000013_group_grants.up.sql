@@ -0,0 +1,29 @@
+-- Agent tags; groups with match_tags include every active agent carrying one
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+CREATE INDEX IF NOT EXISTS idx_agents_tags ON agents USING GIN (tags);
+
+ALTER TABLE agent_groups ADD COLUMN IF NOT EXISTS match_tags TEXT[] NOT NULL DEFAULT '{}';
+
+-- 'manual' members were added explicitly, 'tag' members through match_tags
+ALTER TABLE agent_group_members ADD COLUMN IF NOT EXISTS source VARCHAR(20) NOT NULL DEFAULT 'manual';
+
+-- A policy granted to a group. Each member gets its own permission linked
+-- through permissions.group_grant_id, including agents that join later.
+CREATE TABLE group_grants (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    group_id UUID NOT NULL REFERENCES agent_groups(id) ON DELETE CASCADE,
+    policy_id UUID NOT NULL REFERENCES policies(id) ON DELETE CASCADE,
+    status VARCHAR(50) NOT NULL DEFAULT 'active',
+    valid_from TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    valid_until TIMESTAMPTZ,
+    auto_mint BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_group_grants_group_id ON group_grants(group_id);
+
+ALTER TABLE permissions ADD COLUMN IF NOT EXISTS group_grant_id UUID REFERENCES group_grants(id) ON DELETE SET NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_permissions_group_grant_agent ON permissions(group_grant_id, agent_id)
+    WHERE status = 'active' AND group_grant_id IS NOT NULL;
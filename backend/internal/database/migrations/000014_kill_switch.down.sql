@@ -0,0 +1,5 @@
+ALTER TABLE permissions DROP COLUMN IF EXISTS frozen_by;
+DROP TABLE IF EXISTS kill_switch_steps;
+DROP TABLE IF EXISTS kill_switch_jobs;
+ALTER TABLE wallets DROP COLUMN IF EXISTS frozen_reason;
+ALTER TABLE wallets DROP COLUMN IF EXISTS frozen_at;
@@ -0,0 +1,38 @@
+-- Emergency kill switch: a frozen wallet denies every validation
+ALTER TABLE wallets ADD COLUMN frozen_at TIMESTAMPTZ;
+ALTER TABLE wallets ADD COLUMN frozen_reason TEXT;
+
+-- Freeze and unfreeze runs, tracked step by step
+CREATE TABLE kill_switch_jobs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL, -- freeze, unfreeze
+    status VARCHAR(20) NOT NULL DEFAULT 'running', -- running, completed, failed
+    reason TEXT,
+    requested_by VARCHAR(42),
+    remint BOOLEAN NOT NULL DEFAULT false, -- unfreeze: mint restored grants that were minted
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_kill_switch_jobs_wallet_id ON kill_switch_jobs(wallet_id, created_at DESC);
+
+CREATE TABLE kill_switch_steps (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    job_id UUID NOT NULL REFERENCES kill_switch_jobs(id) ON DELETE CASCADE,
+    kind VARCHAR(30) NOT NULL, -- revoke_permission, deactivate_agent, reactivate_agent, restore_permission
+    target_id UUID NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, done, failed
+    tx_hash VARCHAR(66),
+    result_id UUID,
+    error TEXT,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_kill_switch_steps_job_id ON kill_switch_steps(job_id);
+-- A frozen permission is restored at most once
+CREATE UNIQUE INDEX idx_kill_switch_steps_restore ON kill_switch_steps(target_id)
+    WHERE kind = 'restore_permission' AND status != 'failed';
+
+-- The freeze that revoked a permission, so it can be restored later
+ALTER TABLE permissions ADD COLUMN frozen_by UUID REFERENCES kill_switch_jobs(id) ON DELETE SET NULL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_audit_logs_agent_event;
+DROP TABLE IF EXISTS circuit_trips;
+DROP TABLE IF EXISTS circuit_breakers;
@@ -0,0 +1,34 @@
+-- Circuit breaker rules for one agent, or for each agent of the wallet when agent_id is NULL
+CREATE TABLE circuit_breakers (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    agent_id UUID REFERENCES agents(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    rule JSONB NOT NULL,
+    status VARCHAR(50) NOT NULL DEFAULT 'active', -- active, disabled
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_circuit_breakers_wallet_id ON circuit_breakers(wallet_id);
+
+-- An agent's circuit opening; the agent stays suspended while it is open
+CREATE TABLE circuit_trips (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    agent_id UUID NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    breaker_id UUID REFERENCES circuit_breakers(id) ON DELETE SET NULL,
+    reason TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'open', -- open, closed
+    opened_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    reset_at TIMESTAMPTZ, -- end of the cooldown; NULL resets manually only
+    closed_at TIMESTAMPTZ,
+    closed_by VARCHAR(42) -- 'cooldown' or the address that reset it
+);
+
+-- At most one open circuit per agent
+CREATE UNIQUE INDEX idx_circuit_trips_open ON circuit_trips(agent_id) WHERE status = 'open';
+CREATE INDEX idx_circuit_trips_wallet_id ON circuit_trips(wallet_id, opened_at DESC);
+CREATE INDEX idx_circuit_trips_reset ON circuit_trips(reset_at) WHERE status = 'open';
+
+CREATE INDEX idx_audit_logs_agent_event ON audit_logs(agent_id, event_type, created_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_permissions_active_expiry;
+DROP TABLE IF EXISTS permission_expiry_warnings;
+ALTER TABLE wallets DROP COLUMN IF EXISTS expiry_warning_days;
@@ -0,0 +1,12 @@
+-- Days before valid_until at which an "expiring" warning is sent; empty disables warnings
+ALTER TABLE wallets ADD COLUMN expiry_warning_days INT[] NOT NULL DEFAULT '{7,1}';
+
+-- Warnings already sent, so each threshold fires once per permission
+CREATE TABLE permission_expiry_warnings (
+    permission_id UUID NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+    days INT NOT NULL,
+    sent_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (permission_id, days)
+);
+
+CREATE INDEX idx_permissions_active_expiry ON permissions(valid_until) WHERE status = 'active';
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_permissions_retire_at;
+DROP INDEX IF EXISTS idx_permissions_rotated_from;
+ALTER TABLE permissions DROP COLUMN IF EXISTS retire_at;
+ALTER TABLE permissions DROP COLUMN IF EXISTS rotated_from;
@@ -0,0 +1,9 @@
+-- Rotation lineage: a successor points at the permission it replaced
+ALTER TABLE permissions ADD COLUMN rotated_from UUID REFERENCES permissions(id) ON DELETE SET NULL;
+-- When a rotated predecessor is revoked, after the overlap with its successor
+ALTER TABLE permissions ADD COLUMN retire_at TIMESTAMPTZ;
+
+-- At most one active successor per permission
+CREATE UNIQUE INDEX idx_permissions_rotated_from ON permissions(rotated_from)
+    WHERE rotated_from IS NOT NULL AND status = 'active';
+CREATE INDEX idx_permissions_retire_at ON permissions(retire_at) WHERE status = 'active';
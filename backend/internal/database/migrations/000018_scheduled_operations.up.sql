@@ -0,0 +1,21 @@
+-- Policy and permission operations to run at a future time
+CREATE TABLE scheduled_operations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    operation VARCHAR(50) NOT NULL, -- activate_policy, revoke_policy, mint_permission, delete_permission
+    target_id UUID NOT NULL, -- the policy or permission
+    run_at TIMESTAMPTZ NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, running, completed, failed, cancelled
+    attempts INT NOT NULL DEFAULT 0,
+    max_attempts INT NOT NULL DEFAULT 3,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    last_error TEXT,
+    created_by VARCHAR(42),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ,
+    cancelled_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_scheduled_operations_wallet_id ON scheduled_operations(wallet_id, run_at);
+CREATE INDEX idx_scheduled_operations_due ON scheduled_operations(next_attempt_at) WHERE status IN ('pending', 'running');
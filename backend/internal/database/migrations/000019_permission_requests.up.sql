@@ -0,0 +1,23 @@
+-- Permissions requested by agents, reviewed by the wallet owner
+CREATE TABLE permission_requests (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    agent_id UUID NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    -- Either a template with params or a proposed definition
+    template_id UUID REFERENCES policy_templates(id) ON DELETE SET NULL,
+    template_params JSONB,
+    definition JSONB,
+    reason TEXT NOT NULL,
+    -- The denied validation that prompted the request, if any
+    validation_request_id UUID REFERENCES validation_requests(id) ON DELETE SET NULL,
+    valid_until TIMESTAMPTZ,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, approved, rejected
+    decided_by VARCHAR(42),
+    decision_note TEXT,
+    permission_id UUID REFERENCES permissions(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    decided_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_permission_requests_wallet_id ON permission_requests(wallet_id, status, created_at DESC);
+CREATE INDEX idx_permission_requests_agent_id ON permission_requests(agent_id);
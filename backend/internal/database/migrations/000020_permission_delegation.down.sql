@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_validation_requests_permission_id;
+DROP INDEX IF EXISTS idx_permissions_delegated_from;
+ALTER TABLE permissions DROP COLUMN IF EXISTS delegated_from;
@@ -0,0 +1,6 @@
+-- Delegation: a sub-permission points at the permission it attenuates
+ALTER TABLE permissions ADD COLUMN delegated_from UUID REFERENCES permissions(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_permissions_delegated_from ON permissions(delegated_from) WHERE delegated_from IS NOT NULL;
+-- Charging delegates' spending to the delegating permission
+CREATE INDEX idx_validation_requests_permission_id ON validation_requests(permission_id, created_at) WHERE allowed = true;
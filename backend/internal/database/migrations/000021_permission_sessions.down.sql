@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_permissions_session_token;
+ALTER TABLE permissions DROP COLUMN IF EXISTS session_token_hash;
+ALTER TABLE permissions DROP COLUMN IF EXISTS session_scope;
+ALTER TABLE permissions DROP COLUMN IF EXISTS task_id;
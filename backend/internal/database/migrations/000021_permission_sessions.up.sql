@@ -0,0 +1,7 @@
+-- Task sessions: short-lived permissions cut from a parent for one task,
+-- used with their own credential
+ALTER TABLE permissions ADD COLUMN task_id TEXT;
+ALTER TABLE permissions ADD COLUMN session_scope JSONB;
+ALTER TABLE permissions ADD COLUMN session_token_hash TEXT;
+
+CREATE UNIQUE INDEX idx_permissions_session_token ON permissions(session_token_hash) WHERE session_token_hash IS NOT NULL;
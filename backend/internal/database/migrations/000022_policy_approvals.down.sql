@@ -0,0 +1,5 @@
+ALTER TABLE policy_versions DROP COLUMN IF EXISTS signed_at;
+ALTER TABLE policy_versions DROP COLUMN IF EXISTS owner_signature_type;
+ALTER TABLE policy_versions DROP COLUMN IF EXISTS owner_signature;
+
+ALTER TABLE wallets DROP COLUMN IF EXISTS require_policy_signatures;
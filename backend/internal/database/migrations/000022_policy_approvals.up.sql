@@ -0,0 +1,6 @@
+-- Owner-signed EIP-712 approvals of policy versions
+ALTER TABLE wallets ADD COLUMN require_policy_signatures BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE policy_versions ADD COLUMN owner_signature TEXT;
+ALTER TABLE policy_versions ADD COLUMN owner_signature_type VARCHAR(16);
+ALTER TABLE policy_versions ADD COLUMN signed_at TIMESTAMPTZ;
@@ -0,0 +1,2 @@
+-- Opt-in exposure of policy definitions on the public verification API
+ALTER TABLE wallets ADD COLUMN public_policy_details BOOLEAN NOT NULL DEFAULT false;
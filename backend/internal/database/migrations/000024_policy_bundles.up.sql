@@ -0,0 +1,3 @@
+-- Revocation epoch of signed policy bundles: advancing it makes every bundle
+-- issued before stop validating
+ALTER TABLE wallets ADD COLUMN revocation_epoch BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,2 @@
+-- API keys may be bound to one agent, which then acts only as itself
+ALTER TABLE api_keys ADD COLUMN agent_id UUID REFERENCES agents(id) ON DELETE CASCADE;
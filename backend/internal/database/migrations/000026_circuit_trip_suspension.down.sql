@@ -0,0 +1 @@
+ALTER TABLE circuit_trips DROP COLUMN IF EXISTS suspended_agent;
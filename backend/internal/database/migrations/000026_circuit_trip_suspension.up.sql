@@ -0,0 +1,2 @@
+-- Whether the trip suspended the agent, so closing it lifts only its own suspension
+ALTER TABLE circuit_trips ADD COLUMN suspended_agent BOOLEAN NOT NULL DEFAULT false;
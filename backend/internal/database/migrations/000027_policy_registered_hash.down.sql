@@ -0,0 +1,2 @@
+ALTER TABLE policies DROP COLUMN IF EXISTS registered_version;
+ALTER TABLE policies DROP COLUMN IF EXISTS registered_content_hash;
@@ -0,0 +1,4 @@
+-- The content hash and version last registered in the PolicyRegistry, so
+-- verification can tell an unregistered change from on-chain tampering
+ALTER TABLE policies ADD COLUMN registered_content_hash VARCHAR(66);
+ALTER TABLE policies ADD COLUMN registered_version INT;
@@ -0,0 +1 @@
+ALTER TABLE address_books DROP COLUMN IF EXISTS managed_by;
@@ -0,0 +1,3 @@
+-- Address books created or adopted by a bundle apply. Only managed address
+-- books are deleted when they are removed from a bundle.
+ALTER TABLE address_books ADD COLUMN managed_by VARCHAR(50);
@@ -28,8 +28,15 @@ type Event struct {
 	Source      string // "offchain" or "onchain"
 	TxHash      string
 	BlockNumber int64
+	// Priority "high" delivers the event to every active webhook of the
+	// wallet, subscribed or not, with extra retries.
+	Priority string
 }
 
+// PriorityHigh marks events that must reach the owner, such as the
+// emergency kill switch.
+const PriorityHigh = "high"
+
 type Logger struct {
 	db     *pgxpool.Pool
 	logger zerolog.Logger
@@ -94,7 +101,7 @@ func (l *Logger) triggerWebhooks(ctx context.Context, event Event) {
 		}
 
 		// Check if this event type is subscribed
-		subscribed := false
+		subscribed := event.Priority == PriorityHigh
 		for _, e := range events {
 			if e == event.EventType || e == "*" {
 				subscribed = true
@@ -118,6 +125,9 @@ func (l *Logger) sendWebhook(ctx context.Context, webhookID uuid.UUID, url, secr
 		"timestamp": time.Now().UTC().Format(time.RFC3339),
 		"details":   event.Details,
 	}
+	if event.Priority != "" {
+		payload["priority"] = event.Priority
+	}
 	if event.AgentID != nil {
 		payload["agent_id"] = event.AgentID.String()
 	}
@@ -136,8 +146,12 @@ func (l *Logger) sendWebhook(ctx context.Context, webhookID uuid.UUID, url, secr
 	signature := hex.EncodeToString(mac.Sum(nil))
 
 	// Send with retries
+	attempts := 3
+	if event.Priority == PriorityHigh {
+		attempts = 5
+	}
 	var lastErr error
-	for attempt := 0; attempt < 3; attempt++ {
+	for attempt := 0; attempt < attempts; attempt++ {
 		if attempt > 0 {
 			time.Sleep(time.Duration(attempt*attempt) * time.Second)
 		}
@@ -146,6 +160,9 @@ func (l *Logger) sendWebhook(ctx context.Context, webhookID uuid.UUID, url, secr
 		req.Header.Set("Content-Type", "application/json")
 		req.Header.Set("X-Webhook-Signature", signature)
 		req.Header.Set("X-Webhook-ID", webhookID.String())
+		if event.Priority != "" {
+			req.Header.Set("X-Webhook-Priority", event.Priority)
+		}
 
 		client := &http.Client{Timeout: 10 * time.Second}
 		resp, err := client.Do(req)
@@ -0,0 +1,306 @@
+package gitops
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"gopkg.in/yaml.v3"
+
+	"github.com/erc8004/policy-saas/internal/domain/policy"
+)
+
+// BundleVersion is the only bundle file format version currently understood.
+const BundleVersion = 1
+
+// ManagedBy is the value stored in the managed_by column of resources created
+// or adopted by a bundle apply. Only managed resources are removed when they
+// disappear from a bundle.
+const ManagedBy = "gitops"
+
+// Bundle is the declarative description of a wallet's agents, policies,
+// permissions and address books. It is the unit accepted by plan and apply.
+type Bundle struct {
+	Version      int              `json:"version"`
+	AddressBooks []AddressBook    `json:"addressBooks,omitempty"`
+	Agents       []AgentSpec      `json:"agents,omitempty"`
+	Policies     []PolicySpec     `json:"policies,omitempty"`
+	Permissions  []PermissionSpec `json:"permissions,omitempty"`
+}
+
+// AddressBook is a named list of addresses that policies can reference as
+// "@name" inside assets.tokens and assets.protocols.
+type AddressBook struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Entries     []AddressEntry `json:"entries"`
+}
+
+// AddressEntry is a single labelled address in an address book.
+type AddressEntry struct {
+	Label   string `json:"label,omitempty"`
+	Address string `json:"address"`
+}
+
+// AgentSpec declares an agent. Agents are identified by name.
+type AgentSpec struct {
+	Name         string `json:"name"`
+	Description  string `json:"description,omitempty"`
+	AgentAddress string `json:"agentAddress,omitempty"`
+}
+
+// PolicySpec declares a policy. Status is "active" (default) or "draft".
+type PolicySpec struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Status      string            `json:"status,omitempty"`
+	Definition  policy.Definition `json:"definition"`
+}
+
+// PermissionSpec grants the named policy to the named agent.
+type PermissionSpec struct {
+	Agent      string     `json:"agent"`
+	Policy     string     `json:"policy"`
+	ValidFrom  *time.Time `json:"validFrom,omitempty"`
+	ValidUntil *time.Time `json:"validUntil,omitempty"`
+}
+
+// Key returns the identity of a permission within a bundle.
+func (p PermissionSpec) Key() string {
+	return p.Agent + "/" + p.Policy
+}
+
+// DesiredStatus returns the status the policy should end up in after apply.
+func (p PolicySpec) DesiredStatus() string {
+	if p.Status == "" {
+		return "active"
+	}
+	return p.Status
+}
+
+// ParseBundle decodes a bundle from YAML or JSON. format is "yaml", "yml",
+// "json" or empty to detect it from the content. Unknown fields are rejected
+// so that typos in a bundle do not silently drop guardrails.
+func ParseBundle(data []byte, format string) (*Bundle, error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "" {
+		format = "yaml"
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+			format = "json"
+		}
+	}
+
+	var jsonData []byte
+	switch format {
+	case "json":
+		jsonData = data
+	case "yaml", "yml":
+		// YAML is converted to JSON so the bundle types need only one set of
+		// field tags and policy definitions decode exactly as they do via the API.
+		var generic interface{}
+		if err := yaml.Unmarshal(data, &generic); err != nil {
+			return nil, fmt.Errorf("invalid yaml: %w", err)
+		}
+		normalized, err := normalizeYAML(generic)
+		if err != nil {
+			return nil, err
+		}
+		jsonData, err = json.Marshal(normalized)
+		if err != nil {
+			return nil, fmt.Errorf("invalid yaml: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported bundle format: %s", format)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(jsonData))
+	dec.DisallowUnknownFields()
+	var b Bundle
+	if err := dec.Decode(&b); err != nil {
+		return nil, fmt.Errorf("invalid bundle: %w", err)
+	}
+	return &b, nil
+}
+
+// normalizeYAML converts YAML-decoded values into types encoding/json accepts.
+func normalizeYAML(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			n, err := normalizeYAML(item)
+			if err != nil {
+				return nil, err
+			}
+			val[k] = n
+		}
+		return val, nil
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid yaml: non-string key %v", k)
+			}
+			n, err := normalizeYAML(item)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = n
+		}
+		return out, nil
+	case []interface{}:
+		for i, item := range val {
+			n, err := normalizeYAML(item)
+			if err != nil {
+				return nil, err
+			}
+			val[i] = n
+		}
+		return val, nil
+	case time.Time:
+		return val.UTC().Format(time.RFC3339Nano), nil
+	default:
+		return v, nil
+	}
+}
+
+// Validate checks the bundle for structural errors that do not depend on the
+// current state: version, duplicate names, address formats and references.
+func (b *Bundle) Validate() error {
+	if b.Version != BundleVersion {
+		return fmt.Errorf("unsupported bundle version %d (expected %d)", b.Version, BundleVersion)
+	}
+
+	books := make(map[string]bool)
+	for _, book := range b.AddressBooks {
+		if book.Name == "" {
+			return errors.New("address book name is required")
+		}
+		if books[book.Name] {
+			return fmt.Errorf("duplicate address book: %s", book.Name)
+		}
+		books[book.Name] = true
+		for _, e := range book.Entries {
+			if !common.IsHexAddress(e.Address) {
+				return fmt.Errorf("address book %s: invalid address %q", book.Name, e.Address)
+			}
+		}
+	}
+
+	agents := make(map[string]bool)
+	for _, a := range b.Agents {
+		if a.Name == "" {
+			return errors.New("agent name is required")
+		}
+		if agents[a.Name] {
+			return fmt.Errorf("duplicate agent: %s", a.Name)
+		}
+		agents[a.Name] = true
+		if a.AgentAddress != "" && !common.IsHexAddress(a.AgentAddress) {
+			return fmt.Errorf("agent %s: invalid agentAddress %q", a.Name, a.AgentAddress)
+		}
+	}
+
+	policies := make(map[string]string)
+	for _, p := range b.Policies {
+		if p.Name == "" {
+			return errors.New("policy name is required")
+		}
+		if _, ok := policies[p.Name]; ok {
+			return fmt.Errorf("duplicate policy: %s", p.Name)
+		}
+		status := p.DesiredStatus()
+		if status != "active" && status != "draft" {
+			return fmt.Errorf("policy %s: status must be active or draft", p.Name)
+		}
+		policies[p.Name] = status
+		for _, ref := range addressBookRefs(&p.Definition) {
+			if !books[ref] {
+				return fmt.Errorf("policy %s: unknown address book @%s", p.Name, ref)
+			}
+		}
+	}
+
+	perms := make(map[string]bool)
+	for _, p := range b.Permissions {
+		if p.Agent == "" || p.Policy == "" {
+			return errors.New("permission agent and policy are required")
+		}
+		if perms[p.Key()] {
+			return fmt.Errorf("duplicate permission: %s", p.Key())
+		}
+		perms[p.Key()] = true
+		if status, ok := policies[p.Policy]; ok && status != "active" {
+			return fmt.Errorf("permission %s: policy %s is not active", p.Key(), p.Policy)
+		}
+		if p.ValidFrom != nil && p.ValidUntil != nil && p.ValidUntil.Before(*p.ValidFrom) {
+			return fmt.Errorf("permission %s: validUntil must be after validFrom", p.Key())
+		}
+	}
+
+	return nil
+}
+
+// ResolveDefinition returns a copy of def with "@book" references in
+// assets.tokens and assets.protocols replaced by the book's addresses.
+// Addresses are lowercased and de-duplicated in first-seen order.
+func (b *Bundle) ResolveDefinition(def policy.Definition) (policy.Definition, error) {
+	books := make(map[string]AddressBook, len(b.AddressBooks))
+	for _, book := range b.AddressBooks {
+		books[book.Name] = book
+	}
+
+	expand := func(items []string) ([]string, error) {
+		if items == nil {
+			return nil, nil
+		}
+		out := make([]string, 0, len(items))
+		seen := make(map[string]bool)
+		add := func(s string) {
+			if !seen[s] {
+				seen[s] = true
+				out = append(out, s)
+			}
+		}
+		for _, item := range items {
+			if !strings.HasPrefix(item, "@") {
+				add(item)
+				continue
+			}
+			book, ok := books[item[1:]]
+			if !ok {
+				return nil, fmt.Errorf("unknown address book %s", item)
+			}
+			for _, e := range book.Entries {
+				add(strings.ToLower(e.Address))
+			}
+		}
+		return out, nil
+	}
+
+	resolved := def
+	var err error
+	if resolved.Assets.Tokens, err = expand(def.Assets.Tokens); err != nil {
+		return resolved, err
+	}
+	if resolved.Assets.Protocols, err = expand(def.Assets.Protocols); err != nil {
+		return resolved, err
+	}
+	return resolved, nil
+}
+
+func addressBookRefs(def *policy.Definition) []string {
+	var refs []string
+	for _, list := range [][]string{def.Assets.Tokens, def.Assets.Protocols} {
+		for _, item := range list {
+			if strings.HasPrefix(item, "@") {
+				refs = append(refs, item[1:])
+			}
+		}
+	}
+	return refs
+}
@@ -0,0 +1,129 @@
+package gitops
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/erc8004/policy-saas/internal/domain/policy"
+)
+
+const sampleYAML = `
+version: 1
+addressBooks:
+  - name: stables
+    entries:
+      - label: USDC
+        address: "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"
+agents:
+  - name: trader
+    description: Trading bot
+policies:
+  - name: swap-stables
+    definition:
+      actions: [swap]
+      assets:
+        tokens: ["@stables"]
+      constraints:
+        maxValuePerTx: "1000"
+      duration:
+        validUntil: 2030-01-01T00:00:00Z
+permissions:
+  - agent: trader
+    policy: swap-stables
+`
+
+func TestParseBundle_YAML(t *testing.T) {
+	b, err := ParseBundle([]byte(sampleYAML), "yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := b.Validate(); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+
+	if len(b.Policies) != 1 || b.Policies[0].Definition.Constraints.MaxValuePerTx != "1000" {
+		t.Fatalf("policy not decoded: %+v", b.Policies)
+	}
+	if b.Policies[0].Definition.Duration.ValidUntil == nil {
+		t.Fatal("expected validUntil timestamp to be decoded")
+	}
+	if b.Policies[0].DesiredStatus() != "active" {
+		t.Fatalf("expected default status active, got %s", b.Policies[0].DesiredStatus())
+	}
+}
+
+func TestParseBundle_DetectsJSON(t *testing.T) {
+	data := []byte(`{"version":1,"agents":[{"name":"trader"}]}`)
+	b, err := ParseBundle(data, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(b.Agents) != 1 || b.Agents[0].Name != "trader" {
+		t.Fatalf("agents not decoded: %+v", b.Agents)
+	}
+}
+
+func TestParseBundle_RejectsUnknownFields(t *testing.T) {
+	data := []byte("version: 1\npolicies:\n  - name: p\n    definiton:\n      actions: [swap]\n")
+	if _, err := ParseBundle(data, "yaml"); err == nil {
+		t.Fatal("expected error for misspelled field")
+	}
+}
+
+func TestParseBundle_UnsupportedFormat(t *testing.T) {
+	if _, err := ParseBundle([]byte(`x = 1`), "hcl"); err == nil {
+		t.Fatal("expected error for unsupported format")
+	}
+}
+
+func TestValidate_UnknownAddressBook(t *testing.T) {
+	b := &Bundle{
+		Version: 1,
+		Policies: []PolicySpec{{
+			Name:       "p",
+			Definition: policy.Definition{Actions: []string{"swap"}, Assets: policy.Assets{Tokens: []string{"@missing"}}},
+		}},
+	}
+	err := b.Validate()
+	if err == nil || !strings.Contains(err.Error(), "@missing") {
+		t.Fatalf("expected unknown address book error, got %v", err)
+	}
+}
+
+func TestValidate_PermissionOnDraftPolicy(t *testing.T) {
+	b := &Bundle{
+		Version:     1,
+		Agents:      []AgentSpec{{Name: "a"}},
+		Policies:    []PolicySpec{{Name: "p", Status: "draft", Definition: policy.Definition{Actions: []string{"swap"}}}},
+		Permissions: []PermissionSpec{{Agent: "a", Policy: "p"}},
+	}
+	if err := b.Validate(); err == nil {
+		t.Fatal("expected error granting a draft policy")
+	}
+}
+
+func TestResolveDefinition_ExpandsAndDedupes(t *testing.T) {
+	b := &Bundle{
+		Version: 1,
+		AddressBooks: []AddressBook{{
+			Name:    "stables",
+			Entries: []AddressEntry{{Address: "0xAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"}},
+		}},
+	}
+	def := policy.Definition{
+		Actions: []string{"swap"},
+		Assets:  policy.Assets{Tokens: []string{"0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", "@stables", "ETH"}},
+	}
+
+	resolved, err := b.ResolveDefinition(def)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", "ETH"}
+	if strings.Join(resolved.Assets.Tokens, ",") != strings.Join(want, ",") {
+		t.Fatalf("got %v, want %v", resolved.Assets.Tokens, want)
+	}
+	if def.Assets.Tokens[1] != "@stables" {
+		t.Fatal("input definition must not be modified")
+	}
+}
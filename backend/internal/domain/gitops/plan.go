@@ -0,0 +1,598 @@
+package gitops
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/erc8004/policy-saas/internal/domain/policy"
+)
+
+// Action is what apply will do to a single resource.
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionRevoke Action = "revoke"
+	ActionDelete Action = "delete"
+	ActionNoop   Action = "noop"
+)
+
+// Resource kinds as they appear in a plan.
+const (
+	ResourceAddressBook = "address_book"
+	ResourceAgent       = "agent"
+	ResourcePolicy      = "policy"
+	ResourcePermission  = "permission"
+)
+
+// State is the current server-side state of a wallet, loaded by the caller.
+type State struct {
+	AddressBooks []AddressBookState
+	Agents       []AgentState
+	Policies     []PolicyState
+	Permissions  []PermissionState
+}
+
+type AddressBookState struct {
+	ID          uuid.UUID
+	Name        string
+	Description string
+	Entries     []AddressEntry
+	Managed     bool
+}
+
+type AgentState struct {
+	ID           uuid.UUID
+	Name         string
+	Description  string
+	AgentAddress string
+	Status       string
+	Managed      bool
+}
+
+type PolicyState struct {
+	ID          uuid.UUID
+	Name        string
+	Description string
+	Definition  policy.Definition
+	Status      string
+	Version     int
+	Managed     bool
+}
+
+// PermissionState is an active permission. Revoked permissions are not part
+// of the state a bundle reconciles against.
+type PermissionState struct {
+	ID         uuid.UUID
+	AgentID    uuid.UUID
+	PolicyID   uuid.UUID
+	ValidFrom  time.Time
+	ValidUntil *time.Time
+	Minted     bool
+	Managed    bool
+}
+
+// FieldChange is a single differing field between current and desired state.
+// Path uses dots for nested objects, e.g. "definition.constraints.maxValuePerTx".
+type FieldChange struct {
+	Path   string      `json:"path"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// Change is one planned operation. The desired spec is carried for apply but
+// not serialized; the diff is the reviewable representation.
+type Change struct {
+	Resource string        `json:"resource"`
+	Action   Action        `json:"action"`
+	Name     string        `json:"name"`
+	ID       *uuid.UUID    `json:"id,omitempty"`
+	Version  int           `json:"version,omitempty"`
+	Diff     []FieldChange `json:"diff,omitempty"`
+	Warnings []string      `json:"warnings,omitempty"`
+
+	AddressBook *AddressBook    `json:"-"`
+	Agent       *AgentSpec      `json:"-"`
+	Policy      *PolicySpec     `json:"-"`
+	Permission  *PermissionSpec `json:"-"`
+	// IDs of an existing agent and policy a permission refers to; nil when
+	// the referenced resource is created by the same apply.
+	AgentID  *uuid.UUID `json:"-"`
+	PolicyID *uuid.UUID `json:"-"`
+	// Status transition a policy update needs after its fields are written:
+	// "activate" (draft -> active) or "reactivate" (revoked -> active). A
+	// permission update is "remint" when its new window must reach a minted
+	// grant, which apply replaces with a freshly minted successor.
+	Transition string `json:"-"`
+}
+
+// Plan is the ordered list of changes that reconciles State with a Bundle.
+// Changes are listed in the order apply executes them: creates and updates
+// for address books, agents, policies and permissions, followed by removals
+// of permissions, policies and address books.
+type Plan struct {
+	Changes  []Change       `json:"changes"`
+	Summary  map[Action]int `json:"summary"`
+	Warnings []string       `json:"warnings,omitempty"`
+	Hash     string         `json:"plan_hash"`
+}
+
+// HasChanges reports whether applying the plan would modify anything.
+func (p *Plan) HasChanges() bool {
+	for _, c := range p.Changes {
+		if c.Action != ActionNoop {
+			return true
+		}
+	}
+	return false
+}
+
+// BuildPlan diffs the bundle against the current state. Resources are matched
+// by name (permissions by agent and policy name). Existing resources that are
+// not managed by a bundle are adopted when named in it and never removed.
+func BuildPlan(b *Bundle, s *State) (*Plan, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{Changes: []Change{}, Summary: map[Action]int{}}
+	var removals []Change
+
+	// Address books
+	bookByName := make(map[string]AddressBookState)
+	for _, ab := range s.AddressBooks {
+		bookByName[ab.Name] = ab
+	}
+	declaredBooks := make(map[string]bool)
+	for i := range b.AddressBooks {
+		book := &b.AddressBooks[i]
+		declaredBooks[book.Name] = true
+		c := Change{Resource: ResourceAddressBook, Name: book.Name, AddressBook: book}
+		cur, ok := bookByName[book.Name]
+		if !ok {
+			c.Action = ActionCreate
+			c.Diff = diffValues("", nil, addressBookDoc(book.Description, book.Entries, ""))
+		} else {
+			c.ID = uuidPtr(cur.ID)
+			c.Diff = diffValues("",
+				addressBookDoc(cur.Description, cur.Entries, managedValue(cur.Managed)),
+				addressBookDoc(book.Description, book.Entries, ManagedBy))
+			c.Action = actionFor(c.Diff)
+		}
+		plan.add(c)
+	}
+	for _, ab := range s.AddressBooks {
+		if ab.Managed && !declaredBooks[ab.Name] {
+			removals = append(removals, Change{Resource: ResourceAddressBook, Action: ActionDelete, Name: ab.Name, ID: uuidPtr(ab.ID)})
+		}
+	}
+
+	// Agents
+	agentByName := make(map[string]AgentState)
+	for _, a := range s.Agents {
+		if _, dup := agentByName[a.Name]; dup {
+			if containsAgent(b.Agents, a.Name) || referencesAgent(b.Permissions, a.Name) {
+				return nil, fmt.Errorf("multiple agents named %s; rename one before using it in a bundle", a.Name)
+			}
+			continue
+		}
+		agentByName[a.Name] = a
+	}
+	declaredAgents := make(map[string]bool)
+	for i := range b.Agents {
+		spec := &b.Agents[i]
+		declaredAgents[spec.Name] = true
+		c := Change{Resource: ResourceAgent, Name: spec.Name, Agent: spec}
+		cur, ok := agentByName[spec.Name]
+		if !ok {
+			c.Action = ActionCreate
+			c.Diff = diffValues("", nil, agentDoc(spec.Description, spec.AgentAddress, ""))
+		} else {
+			c.ID = uuidPtr(cur.ID)
+			before := agentDoc(cur.Description, cur.AgentAddress, managedValue(cur.Managed))
+			after := agentDoc(spec.Description, spec.AgentAddress, ManagedBy)
+			if spec.AgentAddress == "" {
+				// An omitted address leaves the registered one untouched.
+				after["agentAddress"] = before["agentAddress"]
+			}
+			c.Diff = diffValues("", before, after)
+			c.Action = actionFor(c.Diff)
+			if cur.Status != "active" {
+				c.Warnings = append(c.Warnings, fmt.Sprintf("agent is %s; apply does not change agent status", cur.Status))
+			}
+		}
+		plan.add(c)
+	}
+	for _, a := range s.Agents {
+		if a.Managed && !declaredAgents[a.Name] {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("agent %s is no longer declared; agents are never removed by apply", a.Name))
+		}
+	}
+
+	// Policies
+	policyByName := make(map[string]PolicyState)
+	for _, p := range s.Policies {
+		if existing, dup := policyByName[p.Name]; dup {
+			if statusRank(p.Status) == statusRank(existing.Status) {
+				if containsPolicy(b.Policies, p.Name) || referencesPolicy(b.Permissions, p.Name) {
+					return nil, fmt.Errorf("multiple %s policies named %s; rename one before using it in a bundle", p.Status, p.Name)
+				}
+			}
+			if statusRank(p.Status) <= statusRank(existing.Status) {
+				continue
+			}
+		}
+		policyByName[p.Name] = p
+	}
+	declaredPolicies := make(map[string]bool)
+	for i := range b.Policies {
+		spec := b.Policies[i]
+		declaredPolicies[spec.Name] = true
+		resolved, err := b.ResolveDefinition(spec.Definition)
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: %w", spec.Name, err)
+		}
+		spec.Definition = resolved
+		desired := policyDoc(spec.Description, &spec.Definition, spec.DesiredStatus(), ManagedBy)
+		if desired == nil {
+			return nil, fmt.Errorf("policy %s: failed to canonicalize definition", spec.Name)
+		}
+
+		c := Change{Resource: ResourcePolicy, Name: spec.Name, Policy: &spec}
+		cur, ok := policyByName[spec.Name]
+		if !ok {
+			c.Action = ActionCreate
+			c.Version = 1
+			delete(desired, "managedBy")
+			c.Diff = diffValues("", nil, desired)
+			plan.add(c)
+			continue
+		}
+
+		c.ID = uuidPtr(cur.ID)
+		c.Version = cur.Version
+		switch {
+		case cur.Status == "draft" && spec.DesiredStatus() == "active":
+			c.Transition = "activate"
+		case cur.Status == "revoked" && spec.DesiredStatus() == "active":
+			c.Transition = "reactivate"
+		case cur.Status != spec.DesiredStatus():
+			c.Warnings = append(c.Warnings, fmt.Sprintf("policy is %s and cannot return to %s; status left unchanged", cur.Status, spec.DesiredStatus()))
+			desired["status"] = cur.Status
+		}
+		c.Diff = diffValues("", policyDoc(cur.Description, &cur.Definition, cur.Status, managedValue(cur.Managed)), desired)
+		c.Action = actionFor(c.Diff)
+		if cur.Status == "active" && hasPrefix(c.Diff, "definition") {
+			c.Version = cur.Version + 1
+		}
+		plan.add(c)
+	}
+	for _, p := range s.Policies {
+		if !p.Managed || declaredPolicies[p.Name] {
+			continue
+		}
+		switch p.Status {
+		case "active":
+			removals = append(removals, Change{Resource: ResourcePolicy, Action: ActionRevoke, Name: p.Name, ID: uuidPtr(p.ID), Version: p.Version})
+		case "draft":
+			removals = append(removals, Change{Resource: ResourcePolicy, Action: ActionDelete, Name: p.Name, ID: uuidPtr(p.ID), Version: p.Version})
+		}
+	}
+
+	// Permissions
+	// Only permissions between the agents and policies matched by name can be
+	// reconciled; others get a key that no bundle entry can produce.
+	permKey := func(p PermissionState) string {
+		agentName, policyName := p.AgentID.String(), p.PolicyID.String()
+		for name, a := range agentByName {
+			if a.ID == p.AgentID {
+				agentName = name
+			}
+		}
+		for name, pol := range policyByName {
+			if pol.ID == p.PolicyID {
+				policyName = name
+			}
+		}
+		return agentName + "/" + policyName
+	}
+	permByKey := make(map[string]PermissionState)
+	for _, p := range s.Permissions {
+		key := permKey(p)
+		if _, dup := permByKey[key]; !dup {
+			permByKey[key] = p
+		}
+	}
+	declaredPerms := make(map[string]bool)
+	for i := range b.Permissions {
+		spec := &b.Permissions[i]
+		key := spec.Key()
+		declaredPerms[key] = true
+
+		if _, ok := agentByName[spec.Agent]; !ok && !declaredAgents[spec.Agent] {
+			return nil, fmt.Errorf("permission %s: unknown agent %s", key, spec.Agent)
+		}
+		if cur, ok := policyByName[spec.Policy]; !ok && !declaredPolicies[spec.Policy] {
+			return nil, fmt.Errorf("permission %s: unknown policy %s", key, spec.Policy)
+		} else if ok && !declaredPolicies[spec.Policy] && cur.Status != "active" {
+			return nil, fmt.Errorf("permission %s: policy %s is not active", key, spec.Policy)
+		}
+
+		c := Change{Resource: ResourcePermission, Name: key, Permission: spec}
+		if a, ok := agentByName[spec.Agent]; ok {
+			c.AgentID = uuidPtr(a.ID)
+		}
+		if p, ok := policyByName[spec.Policy]; ok {
+			c.PolicyID = uuidPtr(p.ID)
+		}
+		cur, ok := permByKey[key]
+		if !ok {
+			c.Action = ActionCreate
+			c.Diff = diffValues("", nil, permissionDoc(spec.ValidFrom, spec.ValidUntil, ""))
+		} else {
+			c.ID = uuidPtr(cur.ID)
+			validFrom := spec.ValidFrom
+			if validFrom == nil {
+				// An omitted validFrom keeps the existing start time.
+				validFrom = &cur.ValidFrom
+			}
+			c.Diff = diffValues("",
+				permissionDoc(&cur.ValidFrom, cur.ValidUntil, managedValue(cur.Managed)),
+				permissionDoc(validFrom, spec.ValidUntil, ManagedBy))
+			c.Action = actionFor(c.Diff)
+			if cur.Minted && (hasPrefix(c.Diff, "validFrom") || hasPrefix(c.Diff, "validUntil")) {
+				if spec.ValidUntil == nil {
+					return nil, fmt.Errorf("permission %s: a minted permission cannot drop its validUntil; revoke it and declare it again", key)
+				}
+				// The minted grant carries the old window; apply replaces it.
+				remint := *spec
+				remint.ValidFrom = validFrom
+				c.Permission = &remint
+				c.Transition = "remint"
+				c.Warnings = append(c.Warnings, "permission is minted on-chain; apply revokes it and mints a replacement with the new validity window")
+			}
+		}
+		plan.add(c)
+	}
+	var permRemovals []Change
+	for _, p := range s.Permissions {
+		key := permKey(p)
+		if p.Managed && !declaredPerms[key] {
+			permRemovals = append(permRemovals, Change{Resource: ResourcePermission, Action: ActionRevoke, Name: key, ID: uuidPtr(p.ID)})
+		}
+	}
+
+	// Removals run last, dependents first.
+	for _, c := range permRemovals {
+		plan.add(c)
+	}
+	for _, kind := range []string{ResourcePolicy, ResourceAddressBook} {
+		for _, c := range removals {
+			if c.Resource == kind {
+				plan.add(c)
+			}
+		}
+	}
+
+	hash, err := plan.computeHash()
+	if err != nil {
+		return nil, err
+	}
+	plan.Hash = hash
+	return plan, nil
+}
+
+func (p *Plan) add(c Change) {
+	p.Changes = append(p.Changes, c)
+	p.Summary[c.Action]++
+}
+
+// computeHash fingerprints the serialized plan so apply can refuse to run
+// when the state changed between a reviewed plan and its application.
+func (p *Plan) computeHash() (string, error) {
+	data, err := json.Marshal(struct {
+		Changes  []Change `json:"changes"`
+		Warnings []string `json:"warnings"`
+	}{p.Changes, p.Warnings})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func actionFor(diff []FieldChange) Action {
+	if len(diff) == 0 {
+		return ActionNoop
+	}
+	return ActionUpdate
+}
+
+func hasPrefix(diff []FieldChange, prefix string) bool {
+	for _, d := range diff {
+		if d.Path == prefix || strings.HasPrefix(d.Path, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func addressBookDoc(description string, entries []AddressEntry, managedBy string) map[string]interface{} {
+	list := make([]interface{}, 0, len(entries))
+	for _, e := range entries {
+		list = append(list, map[string]interface{}{"label": e.Label, "address": strings.ToLower(e.Address)})
+	}
+	doc := map[string]interface{}{"description": description, "entries": list}
+	if managedBy != "" {
+		doc["managedBy"] = managedBy
+	}
+	return doc
+}
+
+func agentDoc(description, address, managedBy string) map[string]interface{} {
+	doc := map[string]interface{}{"description": description, "agentAddress": strings.ToLower(address)}
+	if managedBy != "" {
+		doc["managedBy"] = managedBy
+	}
+	return doc
+}
+
+func policyDoc(description string, def *policy.Definition, status, managedBy string) map[string]interface{} {
+	canonical, err := policy.CanonicalJSON(def)
+	if err != nil {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(canonical))
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"description": description,
+		"definition":  generic,
+		"status":      status,
+		"managedBy":   managedBy,
+	}
+}
+
+func permissionDoc(validFrom, validUntil *time.Time, managedBy string) map[string]interface{} {
+	doc := map[string]interface{}{}
+	if validFrom != nil {
+		doc["validFrom"] = validFrom.UTC().Format(time.RFC3339)
+	}
+	if validUntil != nil {
+		doc["validUntil"] = validUntil.UTC().Format(time.RFC3339)
+	}
+	if managedBy != "" {
+		doc["managedBy"] = managedBy
+	}
+	return doc
+}
+
+// DiffDefinitions returns the differing fields between two policy
+// definitions, with paths relative to the definition.
+func DiffDefinitions(before, after *policy.Definition) []FieldChange {
+	b := policyDoc("", before, "", "")
+	a := policyDoc("", after, "", "")
+	return diffValues("", b["definition"], a["definition"])
+}
+
+// diffValues walks two JSON-like values and returns the differing leaves.
+// Objects are descended into; arrays and scalars are compared as a whole.
+func diffValues(path string, before, after interface{}) []FieldChange {
+	bm, bIsMap := before.(map[string]interface{})
+	am, aIsMap := after.(map[string]interface{})
+	if (bIsMap || before == nil) && (aIsMap || after == nil) && (bIsMap || aIsMap) {
+		keys := make(map[string]bool)
+		for k := range bm {
+			keys[k] = true
+		}
+		for k := range am {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+
+		var changes []FieldChange
+		for _, k := range sorted {
+			child := k
+			if path != "" {
+				child = path + "." + k
+			}
+			changes = append(changes, diffValues(child, bm[k], am[k])...)
+		}
+		return changes
+	}
+	if isEmpty(before) && isEmpty(after) {
+		return nil
+	}
+	if reflect.DeepEqual(before, after) {
+		return nil
+	}
+	return []FieldChange{{Path: path, Before: before, After: after}}
+}
+
+func isEmpty(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return true
+	case string:
+		return val == ""
+	case []interface{}:
+		return len(val) == 0
+	}
+	return false
+}
+
+func managedValue(managed bool) string {
+	if managed {
+		return ManagedBy
+	}
+	return ""
+}
+
+func statusRank(status string) int {
+	switch status {
+	case "active":
+		return 3
+	case "draft":
+		return 2
+	case "revoked":
+		return 1
+	}
+	return 0
+}
+
+func containsAgent(specs []AgentSpec, name string) bool {
+	for _, s := range specs {
+		if s.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func containsPolicy(specs []PolicySpec, name string) bool {
+	for _, s := range specs {
+		if s.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func referencesAgent(specs []PermissionSpec, name string) bool {
+	for _, s := range specs {
+		if s.Agent == name {
+			return true
+		}
+	}
+	return false
+}
+
+func referencesPolicy(specs []PermissionSpec, name string) bool {
+	for _, s := range specs {
+		if s.Policy == name {
+			return true
+		}
+	}
+	return false
+}
+
+func uuidPtr(id uuid.UUID) *uuid.UUID {
+	return &id
+}
@@ -0,0 +1,243 @@
+package gitops
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/erc8004/policy-saas/internal/domain/policy"
+)
+
+func findChange(plan *Plan, resource, name string) *Change {
+	for i := range plan.Changes {
+		if plan.Changes[i].Resource == resource && plan.Changes[i].Name == name {
+			return &plan.Changes[i]
+		}
+	}
+	return nil
+}
+
+func TestBuildPlan_CreatesFromEmptyState(t *testing.T) {
+	b, err := ParseBundle([]byte(sampleYAML), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := BuildPlan(b, &State{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if plan.Summary[ActionCreate] != 4 {
+		t.Fatalf("expected 4 creates, got %+v", plan.Summary)
+	}
+
+	c := findChange(plan, ResourcePolicy, "swap-stables")
+	if c == nil || c.Action != ActionCreate || c.Version != 1 {
+		t.Fatalf("unexpected policy change: %+v", c)
+	}
+	if got := c.Policy.Definition.Assets.Tokens; len(got) != 1 || got[0] != "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48" {
+		t.Fatalf("address book not resolved: %v", got)
+	}
+	if plan.Hash == "" {
+		t.Fatal("expected plan hash")
+	}
+}
+
+func TestBuildPlan_ActivePolicyUpdateBumpsVersion(t *testing.T) {
+	policyID := uuid.New()
+	state := &State{
+		Policies: []PolicyState{{
+			ID:         policyID,
+			Name:       "p",
+			Definition: policy.Definition{Actions: []string{"swap"}, Constraints: policy.Constraints{MaxValuePerTx: "100"}},
+			Status:     "active",
+			Version:    3,
+			Managed:    true,
+		}},
+	}
+	b := &Bundle{
+		Version: 1,
+		Policies: []PolicySpec{{
+			Name:       "p",
+			Definition: policy.Definition{Actions: []string{"swap"}, Constraints: policy.Constraints{MaxValuePerTx: "200"}},
+		}},
+	}
+
+	plan, err := BuildPlan(b, state)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := findChange(plan, ResourcePolicy, "p")
+	if c.Action != ActionUpdate || c.Version != 4 {
+		t.Fatalf("expected update to version 4, got %s v%d", c.Action, c.Version)
+	}
+	if len(c.Diff) != 1 || c.Diff[0].Path != "definition.constraints.maxValuePerTx" {
+		t.Fatalf("unexpected diff: %+v", c.Diff)
+	}
+}
+
+func TestBuildPlan_NoopIsStable(t *testing.T) {
+	def := policy.Definition{Actions: []string{"swap"}}
+	state := &State{
+		Policies: []PolicyState{{ID: uuid.New(), Name: "p", Definition: def, Status: "active", Version: 1, Managed: true}},
+	}
+	b := &Bundle{Version: 1, Policies: []PolicySpec{{Name: "p", Definition: def}}}
+
+	first, err := BuildPlan(b, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := BuildPlan(b, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.HasChanges() {
+		t.Fatalf("expected no changes, got %+v", first.Changes)
+	}
+	if first.Hash != second.Hash {
+		t.Fatal("plan hash must be deterministic")
+	}
+}
+
+func TestBuildPlan_AdoptsUnmanagedResource(t *testing.T) {
+	def := policy.Definition{Actions: []string{"swap"}}
+	state := &State{
+		Policies: []PolicyState{{ID: uuid.New(), Name: "p", Definition: def, Status: "active", Version: 1}},
+	}
+	b := &Bundle{Version: 1, Policies: []PolicySpec{{Name: "p", Definition: def}}}
+
+	plan, err := BuildPlan(b, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := findChange(plan, ResourcePolicy, "p")
+	if c.Action != ActionUpdate || len(c.Diff) != 1 || c.Diff[0].Path != "managedBy" {
+		t.Fatalf("expected adoption update, got %+v", c)
+	}
+	if c.Version != 1 {
+		t.Fatalf("adoption must not bump version, got %d", c.Version)
+	}
+}
+
+func TestBuildPlan_RevokesOnlyManagedResources(t *testing.T) {
+	agentID, managedPolicy, unmanagedPolicy := uuid.New(), uuid.New(), uuid.New()
+	state := &State{
+		Agents: []AgentState{{ID: agentID, Name: "a", Status: "active", Managed: true}},
+		Policies: []PolicyState{
+			{ID: managedPolicy, Name: "managed", Definition: policy.Definition{Actions: []string{"swap"}}, Status: "active", Version: 1, Managed: true},
+			{ID: unmanagedPolicy, Name: "manual", Definition: policy.Definition{Actions: []string{"swap"}}, Status: "active", Version: 1},
+		},
+		Permissions: []PermissionState{
+			{ID: uuid.New(), AgentID: agentID, PolicyID: managedPolicy, ValidFrom: time.Now(), Managed: true},
+		},
+	}
+	b := &Bundle{Version: 1, Agents: []AgentSpec{{Name: "a"}}}
+
+	plan, err := BuildPlan(b, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := findChange(plan, ResourcePolicy, "managed"); c == nil || c.Action != ActionRevoke {
+		t.Fatalf("expected managed policy revoke, got %+v", c)
+	}
+	if c := findChange(plan, ResourcePolicy, "manual"); c != nil {
+		t.Fatalf("unmanaged policy must not be touched, got %+v", c)
+	}
+
+	// Permission revokes must precede the policy revoke they depend on.
+	last := plan.Changes[len(plan.Changes)-1]
+	if last.Resource != ResourcePolicy || plan.Changes[len(plan.Changes)-2].Resource != ResourcePermission {
+		t.Fatalf("unexpected removal order: %+v", plan.Changes)
+	}
+}
+
+func TestBuildPlan_DeletesOnlyManagedAddressBooks(t *testing.T) {
+	managed, manual := uuid.New(), uuid.New()
+	state := &State{
+		AddressBooks: []AddressBookState{
+			{ID: managed, Name: "old", Managed: true},
+			{ID: manual, Name: "manual"},
+		},
+	}
+	b := &Bundle{Version: 1}
+
+	plan, err := BuildPlan(b, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := findChange(plan, ResourceAddressBook, "old"); c == nil || c.Action != ActionDelete {
+		t.Fatalf("expected managed address book delete, got %+v", c)
+	}
+	if c := findChange(plan, ResourceAddressBook, "manual"); c != nil {
+		t.Fatalf("unmanaged address book must not be touched, got %+v", c)
+	}
+}
+
+func TestBuildPlan_MintedPermissionWindowRemints(t *testing.T) {
+	agentID, policyID := uuid.New(), uuid.New()
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	state := &State{
+		Agents:   []AgentState{{ID: agentID, Name: "a", Status: "active", Managed: true}},
+		Policies: []PolicyState{{ID: policyID, Name: "p", Definition: policy.Definition{Actions: []string{"swap"}}, Status: "active", Version: 1, Managed: true}},
+		Permissions: []PermissionState{
+			{ID: uuid.New(), AgentID: agentID, PolicyID: policyID, ValidFrom: from, Minted: true, Managed: true},
+		},
+	}
+	b := &Bundle{
+		Version:     1,
+		Agents:      []AgentSpec{{Name: "a"}},
+		Policies:    []PolicySpec{{Name: "p", Definition: policy.Definition{Actions: []string{"swap"}}}},
+		Permissions: []PermissionSpec{{Agent: "a", Policy: "p", ValidUntil: &until}},
+	}
+
+	plan, err := BuildPlan(b, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := findChange(plan, ResourcePermission, "a/p")
+	if c.Action != ActionUpdate || len(c.Diff) != 1 || c.Diff[0].Path != "validUntil" {
+		t.Fatalf("unexpected permission change: %+v", c)
+	}
+	if c.Transition != "remint" || len(c.Warnings) != 1 {
+		t.Fatalf("expected remint with a warning, got %q %v", c.Transition, c.Warnings)
+	}
+	if c.Permission.ValidFrom == nil || !c.Permission.ValidFrom.Equal(from) {
+		t.Fatalf("remint must keep the existing start, got %v", c.Permission.ValidFrom)
+	}
+	if b.Permissions[0].ValidFrom != nil {
+		t.Fatal("planning must not modify the bundle")
+	}
+
+	// Dropping the end of a minted window cannot be reminted as declared.
+	state.Permissions[0].ValidUntil = &until
+	b.Permissions[0].ValidUntil = nil
+	if _, err := BuildPlan(b, state); err == nil {
+		t.Fatal("expected error for a minted permission losing its validUntil")
+	}
+}
+
+func TestBuildPlan_UnknownPermissionAgent(t *testing.T) {
+	b := &Bundle{
+		Version:     1,
+		Policies:    []PolicySpec{{Name: "p", Definition: policy.Definition{Actions: []string{"swap"}}}},
+		Permissions: []PermissionSpec{{Agent: "ghost", Policy: "p"}},
+	}
+	if _, err := BuildPlan(b, &State{}); err == nil {
+		t.Fatal("expected unknown agent error")
+	}
+}
+
+func TestDiffDefinitions(t *testing.T) {
+	before := &policy.Definition{Actions: []string{"*"}, Constraints: policy.Constraints{MaxValuePerTx: "1000"}}
+	after := &policy.Definition{Actions: []string{"swap"}, Constraints: policy.Constraints{MaxValuePerTx: "120"}}
+
+	diff := DiffDefinitions(before, after)
+	if len(diff) != 2 || diff[0].Path != "actions" || diff[1].Path != "constraints.maxValuePerTx" {
+		t.Fatalf("unexpected diff: %+v", diff)
+	}
+	if len(DiffDefinitions(before, before)) != 0 {
+		t.Fatal("expected no diff for identical definitions")
+	}
+}
@@ -0,0 +1,229 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Budget caps volume over the group's members combined or, as a member
+// allocation, over one member agent.
+type Budget struct {
+	MaxDailyVolume  string `json:"maxDailyVolume,omitempty"`
+	MaxWeeklyVolume string `json:"maxWeeklyVolume,omitempty"`
+}
+
+// ValidateBudget checks a group budget or member allocation.
+func ValidateBudget(b *Budget) error {
+	if b == nil {
+		return nil
+	}
+	for name, v := range map[string]string{
+		"maxDailyVolume":  b.MaxDailyVolume,
+		"maxWeeklyVolume": b.MaxWeeklyVolume,
+	} {
+		if v == "" {
+			continue
+		}
+		if n, ok := new(big.Int).SetString(v, 10); !ok || n.Sign() < 0 {
+			return errors.New(name + " must be a valid non-negative integer")
+		}
+	}
+	return nil
+}
+
+// BudgetUsage is the volume already reserved against a group budget, for the
+// whole group and for one member.
+type BudgetUsage struct {
+	GroupDaily  *big.Int
+	GroupWeekly *big.Int
+	AgentDaily  *big.Int
+	AgentWeekly *big.Int
+}
+
+// GroupHeadroom is what is left of the budgets of one group the agent is in.
+type GroupHeadroom struct {
+	GroupID uuid.UUID                  `json:"group_id"`
+	Name    string                     `json:"name"`
+	Limits  map[string]*EffectiveLimit `json:"limits"`
+}
+
+type memberBudget struct {
+	GroupID    uuid.UUID
+	Name       string
+	Budget     Budget
+	Allocation Budget
+}
+
+// Check returns the reason amount would exceed the group budget or the
+// member's allocation, or "" if it fits.
+func (m *memberBudget) Check(amount *big.Int, usage BudgetUsage) string {
+	switch {
+	case exceeds(amount, usage.GroupDaily, m.Budget.MaxDailyVolume):
+		return "daily budget of group " + m.Name + " exceeded"
+	case exceeds(amount, usage.GroupWeekly, m.Budget.MaxWeeklyVolume):
+		return "weekly budget of group " + m.Name + " exceeded"
+	case exceeds(amount, usage.AgentDaily, m.Allocation.MaxDailyVolume):
+		return "agent's daily allocation in group " + m.Name + " exceeded"
+	case exceeds(amount, usage.AgentWeekly, m.Allocation.MaxWeeklyVolume):
+		return "agent's weekly allocation in group " + m.Name + " exceeded"
+	}
+	return ""
+}
+
+// Headroom reports the group budget and the member's allocation with the
+// volume used and remaining.
+func (m *memberBudget) Headroom(usage BudgetUsage) GroupHeadroom {
+	id := m.GroupID
+	src := Source{Kind: "group", Name: m.Name, GroupID: &id}
+	h := GroupHeadroom{GroupID: m.GroupID, Name: m.Name, Limits: map[string]*EffectiveLimit{}}
+
+	for _, l := range []struct {
+		key, limit string
+		used       *big.Int
+	}{
+		{"groupDailyVolume", m.Budget.MaxDailyVolume, usage.GroupDaily},
+		{"groupWeeklyVolume", m.Budget.MaxWeeklyVolume, usage.GroupWeekly},
+		{"agentDailyAllocation", m.Allocation.MaxDailyVolume, usage.AgentDaily},
+		{"agentWeeklyAllocation", m.Allocation.MaxWeeklyVolume, usage.AgentWeekly},
+	} {
+		if l.limit == "" {
+			continue
+		}
+		h.Limits[l.key] = &EffectiveLimit{Limit: l.limit, Source: src}
+		remaining(h.Limits[l.key], l.used)
+	}
+	return h
+}
+
+// reserveGroupBudgets checks amount against the budget of every group the
+// agent belongs to and, if reserve is set and all of them allow it, records
+// the spend. The group rows stay locked until the spend is recorded, so
+// concurrent validations cannot overrun a shared budget.
+func (e *Engine) reserveGroupBudgets(ctx context.Context, walletID, agentID uuid.UUID, amount *big.Int, reserve bool) (string, error) {
+	tx, err := e.db.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	lock := ""
+	if reserve {
+		lock = " FOR UPDATE OF g"
+	}
+	members, err := loadMemberBudgets(ctx, tx, walletID, agentID, lock)
+	if err != nil || len(members) == 0 {
+		return "", err
+	}
+
+	for _, m := range members {
+		usage, err := loadBudgetUsage(ctx, tx, m.GroupID, agentID)
+		if err != nil {
+			return "", err
+		}
+		if reason := m.Check(amount, usage); reason != "" {
+			return reason, nil
+		}
+	}
+
+	if !reserve {
+		return "", nil
+	}
+	for _, m := range members {
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO group_budget_spend (group_id, agent_id, amount) VALUES ($1, $2, $3::numeric)`,
+			m.GroupID, agentID, amount.String(),
+		); err != nil {
+			return "", err
+		}
+	}
+	return "", tx.Commit(ctx)
+}
+
+// GroupHeadroom returns the remaining budget of each group the agent is in.
+func (e *Engine) GroupHeadroom(ctx context.Context, walletID, agentID uuid.UUID) ([]GroupHeadroom, error) {
+	tx, err := e.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	members, err := loadMemberBudgets(ctx, tx, walletID, agentID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	out := []GroupHeadroom{}
+	for _, m := range members {
+		usage, err := loadBudgetUsage(ctx, tx, m.GroupID, agentID)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, m.Headroom(usage))
+	}
+	return out, nil
+}
+
+func loadMemberBudgets(ctx context.Context, tx pgx.Tx, walletID, agentID uuid.UUID, lock string) ([]memberBudget, error) {
+	rows, err := tx.Query(ctx,
+		`SELECT g.id, g.name, g.budget, m.allocation
+		 FROM agent_groups g
+		 JOIN agent_group_members m ON m.group_id = g.id
+		 WHERE g.wallet_id = $1 AND m.agent_id = $2
+		 ORDER BY g.id`+lock,
+		walletID, agentID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []memberBudget
+	for rows.Next() {
+		var m memberBudget
+		var budget, allocation []byte
+		if err := rows.Scan(&m.GroupID, &m.Name, &budget, &allocation); err != nil {
+			return nil, err
+		}
+		json.Unmarshal(budget, &m.Budget)
+		json.Unmarshal(allocation, &m.Allocation)
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// loadBudgetUsage sums reserved spend for today and the last seven days,
+// matching the windows of the per-agent volume limits.
+func loadBudgetUsage(ctx context.Context, tx pgx.Tx, groupID, agentID uuid.UUID) (BudgetUsage, error) {
+	var groupDaily, groupWeekly, agentDaily, agentWeekly string
+	err := tx.QueryRow(ctx,
+		`SELECT COALESCE(SUM(amount) FILTER (WHERE created_at >= CURRENT_DATE), 0)::text,
+		        COALESCE(SUM(amount), 0)::text,
+		        COALESCE(SUM(amount) FILTER (WHERE agent_id = $2 AND created_at >= CURRENT_DATE), 0)::text,
+		        COALESCE(SUM(amount) FILTER (WHERE agent_id = $2), 0)::text
+		 FROM group_budget_spend
+		 WHERE group_id = $1 AND created_at >= CURRENT_DATE - INTERVAL '6 days'`,
+		groupID, agentID,
+	).Scan(&groupDaily, &groupWeekly, &agentDaily, &agentWeekly)
+	if err != nil {
+		return BudgetUsage{}, err
+	}
+	return BudgetUsage{
+		GroupDaily:  parseAmount(groupDaily),
+		GroupWeekly: parseAmount(groupWeekly),
+		AgentDaily:  parseAmount(agentDaily),
+		AgentWeekly: parseAmount(agentWeekly),
+	}, nil
+}
+
+func parseAmount(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return big.NewInt(0)
+	}
+	return n
+}
@@ -0,0 +1,61 @@
+package policy
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestMemberBudget_Check(t *testing.T) {
+	m := memberBudget{
+		GroupID:    uuid.New(),
+		Name:       "treasury",
+		Budget:     Budget{MaxDailyVolume: "1000", MaxWeeklyVolume: "5000"},
+		Allocation: Budget{MaxDailyVolume: "400"},
+	}
+	usage := BudgetUsage{
+		GroupDaily:  big.NewInt(900),
+		GroupWeekly: big.NewInt(900),
+		AgentDaily:  big.NewInt(0),
+		AgentWeekly: big.NewInt(0),
+	}
+
+	if reason := m.Check(big.NewInt(100), usage); reason != "" {
+		t.Fatalf("expected amount within the group budget to fit, got %q", reason)
+	}
+	if reason := m.Check(big.NewInt(101), usage); reason != "daily budget of group treasury exceeded" {
+		t.Fatalf("expected group daily budget denial, got %q", reason)
+	}
+
+	usage.GroupDaily = big.NewInt(0)
+	usage.AgentDaily = big.NewInt(350)
+	if reason := m.Check(big.NewInt(51), usage); reason != "agent's daily allocation in group treasury exceeded" {
+		t.Fatalf("expected allocation denial, got %q", reason)
+	}
+}
+
+func TestMemberBudget_Headroom(t *testing.T) {
+	m := memberBudget{GroupID: uuid.New(), Name: "treasury", Budget: Budget{MaxDailyVolume: "1000"}}
+	h := m.Headroom(BudgetUsage{GroupDaily: big.NewInt(1200)})
+
+	daily := h.Limits["groupDailyVolume"]
+	if daily == nil || daily.Remaining != "0" || daily.Used != "1200" || daily.Source.Kind != "group" {
+		t.Fatalf("expected exhausted group budget, got %+v", daily)
+	}
+	if len(h.Limits) != 1 {
+		t.Fatalf("expected only configured limits, got %+v", h.Limits)
+	}
+}
+
+func TestValidateBudget(t *testing.T) {
+	if err := ValidateBudget(&Budget{MaxDailyVolume: "-1"}); err == nil {
+		t.Fatal("expected negative budget to be rejected")
+	}
+	if err := ValidateBudget(&Budget{MaxWeeklyVolume: "abc"}); err == nil {
+		t.Fatal("expected non-integer budget to be rejected")
+	}
+	if err := ValidateBudget(&Budget{MaxDailyVolume: "100"}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,204 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/erc8004/policy-saas/internal/blockchain"
+	"github.com/erc8004/policy-saas/pkg/policyeval"
+)
+
+const (
+	// DefaultBundleTTL is how long a bundle validates unless asked otherwise.
+	DefaultBundleTTL = 15 * time.Minute
+	// MaxBundleTTL bounds how long a bundle may validate. Usage figures are
+	// only as fresh as the bundle, so it is kept short.
+	MaxBundleTTL = time.Hour
+)
+
+// Bundles and their evaluator live in pkg/policyeval, where agents can
+// import them.
+type (
+	Bundle          = policyeval.Bundle
+	BundleGrant     = policyeval.BundleGrant
+	BundleGuardrail = policyeval.BundleGuardrail
+	OnlineOnlyGrant = policyeval.OnlineOnlyGrant
+	SignedBundle    = policyeval.SignedBundle
+)
+
+// SignBundle signs a bundle with the service key.
+func SignBundle(b *Bundle, signer *blockchain.ServiceSigner) (*SignedBundle, error) {
+	content, err := b.Statement()
+	if err != nil {
+		return nil, err
+	}
+	sig, err := signer.SignDigest(content.Digest(signer.ChainID()))
+	if err != nil {
+		return nil, err
+	}
+	return &SignedBundle{
+		Bundle:     *b,
+		BundleHash: ContentHashHex(content.BundleHash),
+		ChainID:    signer.ChainID(),
+		Signer:     signer.Address().Hex(),
+		Signature:  hexutil.Encode(sig),
+	}, nil
+}
+
+// BuildBundle exports the agent's active permissions, and those starting
+// before the bundle expires, for offline evaluation. A non-empty reason
+// means no bundle may be issued now.
+func (e *Engine) BuildBundle(ctx context.Context, walletID, agentID uuid.UUID, ttl time.Duration) (*Bundle, string, error) {
+	frozen, err := e.walletFrozen(ctx, walletID)
+	if err != nil {
+		return nil, "", err
+	}
+	if frozen {
+		return nil, "wallet is frozen by the emergency kill switch", nil
+	}
+	circuit, err := e.openCircuit(ctx, agentID)
+	if err != nil {
+		return nil, "", err
+	}
+	if circuit != "" {
+		return nil, "agent suspended by circuit breaker (" + circuit + ")", nil
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	b := &Bundle{
+		Version:    policyeval.BundleFormatVersion,
+		WalletID:   walletID,
+		AgentID:    agentID,
+		IssuedAt:   now,
+		ExpiresAt:  now.Add(ttl),
+		Grants:     []BundleGrant{},
+		Guardrails: []BundleGuardrail{},
+		OnlineOnly: []OnlineOnlyGrant{},
+	}
+	err = e.db.QueryRow(ctx,
+		`SELECT revocation_epoch, strict_matching FROM wallets WHERE id = $1`, walletID,
+	).Scan(&b.RevocationEpoch, &b.StrictDefault)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Group budgets are shared with other agents and bound whichever grant
+	// matches, so an agent in a budgeted group validates online only
+	headroom, err := e.GroupHeadroom(ctx, walletID, agentID)
+	if err != nil {
+		return nil, "", err
+	}
+	budgeted := ""
+	for _, h := range headroom {
+		if len(h.Limits) > 0 {
+			budgeted = h.Name
+			break
+		}
+	}
+
+	// Task sessions authenticate with their own token and are never bundled
+	rows, err := e.db.Query(ctx,
+		`SELECT p.id, p.policy_id, pol.content_hash, pol.definition, p.valid_from, p.valid_until, esc.constraints, esc.expires_at, p.delegated_from, p.retire_at
+		 FROM permissions p
+		 JOIN policies pol ON p.policy_id = pol.id
+		 LEFT JOIN escalations esc ON esc.permission_id = p.id
+		      AND esc.status = 'active' AND esc.expires_at > NOW()
+		 WHERE p.wallet_id = $1 AND p.agent_id = $2 AND p.status = 'active'
+		 AND pol.status = 'active'
+		 AND p.valid_from < $3
+		 AND (p.valid_until IS NULL OR p.valid_until > NOW())
+		 AND p.task_id IS NULL
+		 AND `+groupGrantHeld+`
+		 ORDER BY p.created_at`,
+		walletID, agentID, b.ExpiresAt,
+	)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var g BundleGrant
+		var defBytes, escBytes []byte
+		var escExpiresAt *time.Time
+		var delegatedFrom *uuid.UUID
+		var retireAt *time.Time
+		if err := rows.Scan(&g.PermissionID, &g.PolicyID, &g.ContentHash, &defBytes, &g.ValidFrom, &g.ValidUntil, &escBytes, &escExpiresAt, &delegatedFrom, &retireAt); err != nil {
+			return nil, "", err
+		}
+		if err := json.Unmarshal(defBytes, &g.Definition); err != nil {
+			continue
+		}
+
+		switch {
+		case budgeted != "":
+			b.OnlineOnly = append(b.OnlineOnly, OnlineOnlyGrant{PermissionID: g.PermissionID, Reason: "the budget of group " + budgeted + " is shared with other agents"})
+			continue
+		case delegatedFrom != nil:
+			b.OnlineOnly = append(b.OnlineOnly, OnlineOnlyGrant{PermissionID: g.PermissionID, Reason: "delegated permissions draw on the limits of the permissions above them"})
+			continue
+		case g.Definition.Constraints.HasCounterpartyLimits():
+			b.OnlineOnly = append(b.OnlineOnly, OnlineOnlyGrant{PermissionID: g.PermissionID, Reason: "counterparty limits need the recorded history"})
+			continue
+		}
+
+		// An escalation ending before the bundle expires is not applied: the
+		// grant keeps its own limits rather than outlive the escalation offline
+		escalation := parseEscalation(escBytes)
+		if escalation != nil && escExpiresAt != nil && !escExpiresAt.Before(b.ExpiresAt) {
+			g.Definition.Constraints = escalation.Apply(g.Definition.Constraints)
+			g.Escalated = true
+		}
+
+		// A rotated permission ends when its overlap with the successor does
+		if retireAt != nil && (g.ValidUntil == nil || retireAt.Before(*g.ValidUntil)) {
+			g.ValidUntil = retireAt
+		}
+		g.ValidFrom = g.ValidFrom.UTC()
+		if g.ValidUntil != nil {
+			until := g.ValidUntil.UTC()
+			g.ValidUntil = &until
+		}
+		b.Grants = append(b.Grants, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	rows.Close()
+
+	guardrails, err := loadGuardrails(ctx, e.db, walletID)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, g := range guardrails {
+		b.Guardrails = append(b.Guardrails, BundleGuardrail{Name: g.Name, Definition: g.Guardrail})
+	}
+
+	b.DailyUsed = e.getDailyUsage(ctx, walletID, agentID).String()
+	b.WalletDailyUsed = e.getWalletDailyUsage(ctx, walletID).String()
+
+	return b, "", nil
+}
+
+// RevocationEpoch returns the wallet's current revocation epoch.
+func RevocationEpoch(ctx context.Context, db *pgxpool.Pool, walletID uuid.UUID) (int64, error) {
+	var epoch int64
+	err := db.QueryRow(ctx, `SELECT revocation_epoch FROM wallets WHERE id = $1`, walletID).Scan(&epoch)
+	return epoch, err
+}
+
+// BumpRevocationEpoch advances the wallet's revocation epoch, so every
+// bundle issued before stops validating once evaluators learn the new one.
+func BumpRevocationEpoch(ctx context.Context, db *pgxpool.Pool, walletID uuid.UUID) (int64, error) {
+	var epoch int64
+	err := db.QueryRow(ctx,
+		`UPDATE wallets SET revocation_epoch = revocation_epoch + 1 WHERE id = $1 RETURNING revocation_epoch`,
+		walletID,
+	).Scan(&epoch)
+	return epoch, err
+}
@@ -0,0 +1,38 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/google/uuid"
+
+	"github.com/erc8004/policy-saas/internal/blockchain"
+	"github.com/erc8004/policy-saas/pkg/policyeval"
+)
+
+func TestSignBundle_VerifiesOffline(t *testing.T) {
+	signer, err := blockchain.NewServiceSigner("4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318", 8453)
+	if err != nil {
+		t.Fatalf("load key: %v", err)
+	}
+	now := time.Now().UTC()
+	b := &Bundle{
+		Version:    policyeval.BundleFormatVersion,
+		WalletID:   uuid.New(),
+		AgentID:    uuid.New(),
+		IssuedAt:   now,
+		ExpiresAt:  now.Add(DefaultBundleTTL),
+		Grants:     []BundleGrant{},
+		Guardrails: []BundleGuardrail{},
+		OnlineOnly: []OnlineOnlyGrant{},
+	}
+
+	sb, err := SignBundle(b, signer)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if err := policyeval.VerifyBundle(sb, 8453, []common.Address{signer.Address()}); err != nil {
+		t.Fatalf("expected the signed bundle to verify, got %v", err)
+	}
+}
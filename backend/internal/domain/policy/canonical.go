@@ -0,0 +1,54 @@
+package policy
+
+import (
+	"encoding/hex"
+
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/erc8004/policy-saas/internal/blockchain"
+	"github.com/erc8004/policy-saas/pkg/policyeval"
+)
+
+// CanonicalJSON returns the canonical serialization of a policy definition.
+// Object keys are sorted lexicographically, insignificant whitespace is removed,
+// HTML characters are not escaped and timestamps are normalised to UTC. The same
+// Definition always produces the same bytes, so anyone holding the definition can
+// reproduce the on-chain content hash independently of how Postgres stores JSONB.
+func CanonicalJSON(def *Definition) ([]byte, error) {
+	normalized := *def
+	if def.Duration.ValidFrom != nil {
+		t := def.Duration.ValidFrom.UTC()
+		normalized.Duration.ValidFrom = &t
+	}
+	if def.Duration.ValidUntil != nil {
+		t := def.Duration.ValidUntil.UTC()
+		normalized.Duration.ValidUntil = &t
+	}
+
+	return policyeval.Canonicalize(normalized)
+}
+
+// ContentHash returns the keccak256 hash of the canonical definition together
+// with the canonical bytes it was computed from.
+func ContentHash(def *Definition) ([32]byte, []byte, error) {
+	canonical, err := CanonicalJSON(def)
+	if err != nil {
+		return [32]byte{}, nil, err
+	}
+	return blockchain.PolicyContentHash(canonical), canonical, nil
+}
+
+// ContentHashHex formats a content hash as a 0x-prefixed hex string.
+func ContentHashHex(hash [32]byte) string {
+	return "0x" + hex.EncodeToString(hash[:])
+}
+
+// ActionDigest returns the keccak256 hash of an action's canonical JSON, the
+// action a signed validation receipt refers to.
+func ActionDigest(action *Action) ([32]byte, error) {
+	canonical, err := policyeval.Canonicalize(action)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return crypto.Keccak256Hash(canonical), nil
+}
@@ -0,0 +1,118 @@
+package policy
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCanonicalJSON_SortedKeysNoWhitespace(t *testing.T) {
+	def := &Definition{
+		Actions: []string{"swap"},
+		Assets: Assets{
+			Tokens: []string{"0xUSDC"},
+		},
+		Constraints: Constraints{
+			MaxValuePerTx: "1000",
+		},
+	}
+
+	canonical, err := CanonicalJSON(def)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `{"actions":["swap"],"assets":{"tokens":["0xUSDC"]},"constraints":{"maxValuePerTx":"1000"},"duration":{}}`
+	if string(canonical) != expected {
+		t.Fatalf("canonical JSON mismatch:\n got: %s\nwant: %s", canonical, expected)
+	}
+}
+
+func TestCanonicalJSON_IndependentOfInputKeyOrder(t *testing.T) {
+	a := []byte(`{"constraints":{"maxDailyVolume":"5","maxValuePerTx":"1"},"actions":["swap"],
+		"conditions":[{"value":{"b":1,"a":2},"operator":"eq","field":"x"}]}`)
+	b := []byte(`{"actions":["swap"],"conditions":[{"field":"x","operator":"eq","value":{"a":2,"b":1}}],
+		"constraints":{"maxValuePerTx":"1","maxDailyVolume":"5"}}`)
+
+	var defA, defB Definition
+	if err := json.Unmarshal(a, &defA); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &defB); err != nil {
+		t.Fatal(err)
+	}
+
+	hashA, canonicalA, err := ContentHash(&defA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashB, canonicalB, err := ContentHash(&defB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hashA != hashB {
+		t.Fatalf("expected equal hashes, got\n%s\n%s", canonicalA, canonicalB)
+	}
+}
+
+func TestCanonicalJSON_NormalizesTimezone(t *testing.T) {
+	utc := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	est := utc.In(time.FixedZone("EST", -5*3600))
+
+	hashUTC, _, _ := ContentHash(&Definition{Actions: []string{"swap"}, Duration: Duration{ValidUntil: &utc}})
+	hashEST, _, _ := ContentHash(&Definition{Actions: []string{"swap"}, Duration: Duration{ValidUntil: &est}})
+
+	if hashUTC != hashEST {
+		t.Fatal("same instant in different zones should hash identically")
+	}
+}
+
+func TestCanonicalJSON_NoHTMLEscaping(t *testing.T) {
+	def := &Definition{
+		Actions:    []string{"swap"},
+		Conditions: []Condition{{Field: "to", Operator: "contains", Value: "<&>"}},
+	}
+	canonical, err := CanonicalJSON(def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !json.Valid(canonical) {
+		t.Fatal("canonical output should be valid JSON")
+	}
+	if !strings.Contains(string(canonical), "<&>") {
+		t.Fatalf("canonical output should not HTML-escape, got %s", canonical)
+	}
+}
+
+func TestContentHash_DiffersOnChange(t *testing.T) {
+	h1, _, _ := ContentHash(&Definition{Actions: []string{"swap"}})
+	h2, _, _ := ContentHash(&Definition{Actions: []string{"transfer"}})
+	if h1 == h2 {
+		t.Fatal("different definitions should produce different hashes")
+	}
+	if len(ContentHashHex(h1)) != 66 {
+		t.Fatalf("expected 0x-prefixed 32-byte hex, got %s", ContentHashHex(h1))
+	}
+}
+
+func TestActionDigest_IndependentOfDataKeyOrder(t *testing.T) {
+	var a, b Action
+	json.Unmarshal([]byte(`{"type":"swap","amount":"100","data":{"x":1,"y":"<z>"}}`), &a)
+	json.Unmarshal([]byte(`{"data":{"y":"<z>","x":1},"amount":"100","type":"swap"}`), &b)
+
+	ha, err := ActionDigest(&a)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	hb, _ := ActionDigest(&b)
+	if ha != hb {
+		t.Fatal("equal actions should hash the same regardless of key order")
+	}
+
+	b.Amount = "101"
+	if hc, _ := ActionDigest(&b); hc == ha {
+		t.Fatal("different actions should produce different hashes")
+	}
+}
@@ -0,0 +1,452 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+
+	"github.com/erc8004/policy-saas/internal/blockchain"
+	"github.com/erc8004/policy-saas/internal/domain/audit"
+)
+
+// defaultCircuitMinRequests is how many requests a window needs before
+// MaxDenialRate applies, so a single early denial cannot trip it.
+const defaultCircuitMinRequests = 10
+
+// CircuitRule trips an agent's circuit when it misbehaves within a window:
+// too many denied validations, too high a share of denials, or too many
+// on-chain ConstraintViolation events.
+type CircuitRule struct {
+	WindowSeconds int `json:"windowSeconds"`
+	MaxDenials    int `json:"maxDenials,omitempty"`
+	// MaxDenialRate is the highest share of denied requests, from 0 to 1.
+	MaxDenialRate float64 `json:"maxDenialRate,omitempty"`
+	MinRequests   int     `json:"minRequests,omitempty"`
+	MaxViolations int     `json:"maxViolations,omitempty"`
+	// CooldownSeconds closes the circuit again after that long; 0 means it
+	// stays open until reset by hand.
+	CooldownSeconds int `json:"cooldownSeconds,omitempty"`
+	// RevokeOnchain also revokes the agent's minted permissions when tripped.
+	RevokeOnchain bool `json:"revokeOnchain,omitempty"`
+}
+
+// CircuitStats is an agent's activity within a rule's window.
+type CircuitStats struct {
+	Requests   int
+	Denials    int
+	Violations int
+}
+
+// ValidateCircuitRule checks a circuit breaker rule.
+func ValidateCircuitRule(r *CircuitRule) error {
+	if r == nil {
+		return errors.New("rule is required")
+	}
+	if r.WindowSeconds <= 0 {
+		return errors.New("windowSeconds must be positive")
+	}
+	if r.MaxDenials < 0 || r.MaxViolations < 0 || r.MinRequests < 0 || r.CooldownSeconds < 0 {
+		return errors.New("counts and cooldownSeconds must not be negative")
+	}
+	if r.MaxDenialRate < 0 || r.MaxDenialRate > 1 {
+		return errors.New("maxDenialRate must be between 0 and 1")
+	}
+	if r.MaxDenials == 0 && r.MaxDenialRate == 0 && r.MaxViolations == 0 {
+		return errors.New("set at least one of maxDenials, maxDenialRate or maxViolations")
+	}
+	return nil
+}
+
+// Check returns why the rule trips for these stats, or "" if it does not.
+func (r *CircuitRule) Check(s CircuitStats) string {
+	window := time.Duration(r.WindowSeconds) * time.Second
+	if r.MaxDenials > 0 && s.Denials >= r.MaxDenials {
+		return fmt.Sprintf("%d denials within %s", s.Denials, window)
+	}
+	minRequests := r.MinRequests
+	if minRequests == 0 {
+		minRequests = defaultCircuitMinRequests
+	}
+	if r.MaxDenialRate > 0 && s.Requests >= minRequests {
+		if rate := float64(s.Denials) / float64(s.Requests); rate > r.MaxDenialRate {
+			return fmt.Sprintf("%.0f%% of %d requests denied within %s", rate*100, s.Requests, window)
+		}
+	}
+	if r.MaxViolations > 0 && s.Violations >= r.MaxViolations {
+		return fmt.Sprintf("%d on-chain constraint violations within %s", s.Violations, window)
+	}
+	return ""
+}
+
+// CircuitBreaker trips circuit breaker rules and closes circuits whose
+// cooldown has passed. While an agent's circuit is open the engine denies
+// all its actions and the agent is suspended.
+type CircuitBreaker struct {
+	db          *pgxpool.Pool
+	mc          *blockchain.MultiClient
+	auditLogger *audit.Logger
+	logger      zerolog.Logger
+	interval    time.Duration
+}
+
+func NewCircuitBreaker(db *pgxpool.Pool, mc *blockchain.MultiClient, auditLogger *audit.Logger, logger zerolog.Logger, interval time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		db:          db,
+		mc:          mc,
+		auditLogger: auditLogger,
+		logger:      logger,
+		interval:    interval,
+	}
+}
+
+// Start launches the cooldown loop in the background.
+func (b *CircuitBreaker) Start(ctx context.Context) {
+	if b.interval <= 0 {
+		return
+	}
+	go b.run(ctx)
+}
+
+func (b *CircuitBreaker) run(ctx context.Context) {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := b.closeCooledDown(ctx); err != nil {
+				b.logger.Error().Err(err).Msg("circuit breaker: cooldown error")
+			}
+		}
+	}
+}
+
+// Check evaluates the agent against the wallet's active rules and opens its
+// circuit if one trips. It is called after a denied validation and after an
+// on-chain constraint violation.
+func (b *CircuitBreaker) Check(ctx context.Context, walletID, agentID uuid.UUID) {
+	if err := b.check(ctx, walletID, agentID); err != nil {
+		b.logger.Error().Err(err).Str("agent_id", agentID.String()).Msg("circuit breaker: check failed")
+	}
+}
+
+func (b *CircuitBreaker) check(ctx context.Context, walletID, agentID uuid.UUID) error {
+	// Activity before the last reset does not count again
+	var open bool
+	var lastClosed *time.Time
+	if err := b.db.QueryRow(ctx,
+		`SELECT COALESCE(BOOL_OR(status = 'open'), false), MAX(closed_at)
+		 FROM circuit_trips WHERE agent_id = $1`,
+		agentID,
+	).Scan(&open, &lastClosed); err != nil {
+		return err
+	}
+	if open {
+		return nil
+	}
+
+	rows, err := b.db.Query(ctx,
+		`SELECT id, name, rule FROM circuit_breakers
+		 WHERE wallet_id = $1 AND status = 'active' AND (agent_id IS NULL OR agent_id = $2)
+		 ORDER BY agent_id NULLS LAST, created_at`,
+		walletID, agentID,
+	)
+	if err != nil {
+		return err
+	}
+	type breaker struct {
+		id   uuid.UUID
+		name string
+		rule CircuitRule
+	}
+	var breakers []breaker
+	for rows.Next() {
+		var br breaker
+		var ruleBytes []byte
+		if err := rows.Scan(&br.id, &br.name, &ruleBytes); err != nil {
+			continue
+		}
+		json.Unmarshal(ruleBytes, &br.rule)
+		breakers = append(breakers, br)
+	}
+	rows.Close()
+
+	now := time.Now()
+	for _, br := range breakers {
+		since := now.Add(-time.Duration(br.rule.WindowSeconds) * time.Second)
+		if lastClosed != nil && lastClosed.After(since) {
+			since = *lastClosed
+		}
+		stats, err := b.loadStats(ctx, walletID, agentID, since)
+		if err != nil {
+			return err
+		}
+		if reason := br.rule.Check(stats); reason != "" {
+			return b.trip(ctx, walletID, agentID, br.id, br.name+": "+reason, br.rule)
+		}
+	}
+	return nil
+}
+
+func (b *CircuitBreaker) loadStats(ctx context.Context, walletID, agentID uuid.UUID, since time.Time) (CircuitStats, error) {
+	var s CircuitStats
+	err := b.db.QueryRow(ctx,
+		`SELECT COUNT(*), COUNT(*) FILTER (WHERE NOT allowed)
+		 FROM validation_requests
+		 WHERE wallet_id = $1 AND agent_id = $2 AND created_at >= $3`,
+		walletID, agentID, since,
+	).Scan(&s.Requests, &s.Denials)
+	if err != nil {
+		return s, err
+	}
+	err = b.db.QueryRow(ctx,
+		`SELECT COUNT(*) FROM audit_logs
+		 WHERE wallet_id = $1 AND agent_id = $2 AND event_type = 'onchain.constraint_violation' AND created_at >= $3`,
+		walletID, agentID, since,
+	).Scan(&s.Violations)
+	return s, err
+}
+
+// trip opens the agent's circuit, suspends the agent and, if the rule says
+// so, revokes its minted permissions on-chain.
+func (b *CircuitBreaker) trip(ctx context.Context, walletID, agentID, breakerID uuid.UUID, reason string, rule CircuitRule) error {
+	var resetAt *time.Time
+	if rule.CooldownSeconds > 0 {
+		t := time.Now().Add(time.Duration(rule.CooldownSeconds) * time.Second)
+		resetAt = &t
+	}
+
+	var tripID uuid.UUID
+	err := b.db.QueryRow(ctx,
+		`INSERT INTO circuit_trips (wallet_id, agent_id, breaker_id, reason, reset_at)
+		 VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (agent_id) WHERE status = 'open' DO NOTHING
+		 RETURNING id`,
+		walletID, agentID, breakerID, reason, resetAt,
+	).Scan(&tripID)
+	if err != nil {
+		// Another check opened the circuit first
+		return nil
+	}
+
+	// An agent the owner already suspended stays theirs to reactivate
+	result, err := b.db.Exec(ctx,
+		`UPDATE agents SET status = 'suspended', updated_at = NOW() WHERE id = $1 AND status = 'active'`,
+		agentID,
+	)
+	if err == nil && result.RowsAffected() > 0 {
+		b.db.Exec(ctx, `UPDATE circuit_trips SET suspended_agent = true WHERE id = $1`, tripID)
+	}
+	if _, err := BumpRevocationEpoch(ctx, b.db, walletID); err != nil {
+		b.logger.Error().Err(err).Msg("failed to advance revocation epoch")
+	}
+
+	details := map[string]interface{}{
+		"trip_id":    tripID,
+		"breaker_id": breakerID,
+		"reason":     reason,
+	}
+	if resetAt != nil {
+		details["reset_at"] = *resetAt
+	}
+	if rule.RevokeOnchain {
+		details["permissions_revoked"] = b.revokeMinted(ctx, walletID, agentID, tripID)
+	}
+
+	b.auditLogger.Log(ctx, audit.Event{
+		WalletID:  walletID,
+		AgentID:   &agentID,
+		EventType: "agent.circuit_open",
+		Priority:  audit.PriorityHigh,
+		Details:   details,
+	})
+	return nil
+}
+
+// revokeMinted revokes the agent's minted, active permissions on-chain and
+// in the database, along with the permissions delegated from them, and
+// returns their IDs. On-chain failures are logged and the permission is
+// revoked off-chain regardless.
+func (b *CircuitBreaker) revokeMinted(ctx context.Context, walletID, agentID, tripID uuid.UUID) []uuid.UUID {
+	rows, err := b.db.Query(ctx,
+		`UPDATE permissions SET status = 'revoked', revoked_at = NOW()
+		 WHERE wallet_id = $1 AND agent_id = $2 AND status = 'active' AND onchain_token_id IS NOT NULL
+		 RETURNING id, agent_id, policy_id, onchain_token_id, delegated_from`,
+		walletID, agentID,
+	)
+	if err != nil {
+		b.logger.Error().Err(err).Str("agent_id", agentID.String()).Msg("circuit breaker: failed to revoke permissions")
+		return nil
+	}
+	perms := scanRevoked(rows)
+
+	revoked := []uuid.UUID{}
+	for _, m := range perms {
+		b.revoked(ctx, walletID, tripID, m)
+		revoked = append(revoked, m.id)
+	}
+	for _, m := range perms {
+		revoked = append(revoked, b.revokeDelegates(ctx, walletID, m.id, tripID)...)
+	}
+	return revoked
+}
+
+// revokeDelegates revokes the active permissions delegated from permID,
+// directly or further down, as revoking permID by hand does.
+func (b *CircuitBreaker) revokeDelegates(ctx context.Context, walletID, permID, tripID uuid.UUID) []uuid.UUID {
+	rows, err := b.db.Query(ctx,
+		`WITH RECURSIVE tree AS (
+		   SELECT id FROM permissions WHERE delegated_from = $1 AND wallet_id = $2
+		   UNION
+		   SELECT p.id FROM permissions p JOIN tree t ON p.delegated_from = t.id
+		 )
+		 UPDATE permissions SET status = 'revoked', revoked_at = NOW()
+		 WHERE id IN (SELECT id FROM tree) AND status = 'active'
+		 RETURNING id, agent_id, policy_id, onchain_token_id, delegated_from`,
+		permID, walletID,
+	)
+	if err != nil {
+		b.logger.Error().Err(err).Str("permission_id", permID.String()).Msg("circuit breaker: failed to revoke delegated permissions")
+		return nil
+	}
+	delegates := scanRevoked(rows)
+
+	revoked := []uuid.UUID{}
+	for _, d := range delegates {
+		b.revoked(ctx, walletID, tripID, d)
+		revoked = append(revoked, d.id)
+	}
+	return revoked
+}
+
+// revokedPermission is a permission the breaker revoked in the database.
+type revokedPermission struct {
+	id, agentID, policyID uuid.UUID
+	tokenID               *string
+	delegatedFrom         *uuid.UUID
+}
+
+func scanRevoked(rows pgx.Rows) []revokedPermission {
+	defer rows.Close()
+	var perms []revokedPermission
+	for rows.Next() {
+		var m revokedPermission
+		if err := rows.Scan(&m.id, &m.agentID, &m.policyID, &m.tokenID, &m.delegatedFrom); err == nil {
+			perms = append(perms, m)
+		}
+	}
+	return perms
+}
+
+// revoked revokes a permission on-chain, if minted, and records it.
+func (b *CircuitBreaker) revoked(ctx context.Context, walletID, tripID uuid.UUID, m revokedPermission) {
+	details := map[string]interface{}{"circuit_trip_id": tripID}
+	if m.delegatedFrom != nil {
+		details["delegated_from"] = *m.delegatedFrom
+	}
+	if m.tokenID != nil && *m.tokenID != "" {
+		permIDBytes, err := blockchain.HexToBytes32(*m.tokenID)
+		if err == nil {
+			var txHash string
+			txHash, err = b.mc.Primary().RevokePermission(ctx, permIDBytes)
+			details["tx_hash"] = txHash
+		}
+		if err != nil {
+			b.logger.Error().Err(err).Str("permission_id", m.id.String()).Msg("circuit breaker: on-chain revocation failed")
+			details["onchain_error"] = err.Error()
+		}
+	}
+	b.auditLogger.Log(ctx, audit.Event{
+		WalletID:     walletID,
+		AgentID:      &m.agentID,
+		PolicyID:     &m.policyID,
+		PermissionID: &m.id,
+		EventType:    "permission.revoked",
+		Details:      details,
+	})
+}
+
+// Reset closes the agent's open circuit and lifts the suspension it caused,
+// reporting whether a circuit was open. by is recorded as who closed it.
+func (b *CircuitBreaker) Reset(ctx context.Context, walletID, agentID uuid.UUID, by string) (bool, error) {
+	var tripID uuid.UUID
+	err := b.db.QueryRow(ctx,
+		`UPDATE circuit_trips SET status = 'closed', closed_at = NOW(), closed_by = $3
+		 WHERE wallet_id = $1 AND agent_id = $2 AND status = 'open'
+		 RETURNING id`,
+		walletID, agentID, by,
+	).Scan(&tripID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	b.closed(ctx, walletID, agentID, tripID, by)
+	return true, nil
+}
+
+func (b *CircuitBreaker) closeCooledDown(ctx context.Context) error {
+	rows, err := b.db.Query(ctx,
+		`UPDATE circuit_trips SET status = 'closed', closed_at = NOW(), closed_by = 'cooldown'
+		 WHERE status = 'open' AND reset_at <= NOW()
+		 RETURNING id, wallet_id, agent_id`,
+	)
+	if err != nil {
+		return err
+	}
+	type trip struct{ id, walletID, agentID uuid.UUID }
+	var trips []trip
+	for rows.Next() {
+		var t trip
+		if err := rows.Scan(&t.id, &t.walletID, &t.agentID); err == nil {
+			trips = append(trips, t)
+		}
+	}
+	rows.Close()
+
+	for _, t := range trips {
+		b.closed(ctx, t.walletID, t.agentID, t.id, "cooldown")
+	}
+	return nil
+}
+
+func (b *CircuitBreaker) closed(ctx context.Context, walletID, agentID, tripID uuid.UUID, by string) {
+	b.db.Exec(ctx,
+		`UPDATE agents SET status = 'active', updated_at = NOW()
+		 WHERE id = $1 AND status = 'suspended'
+		 AND EXISTS(SELECT 1 FROM circuit_trips WHERE id = $2 AND suspended_agent)`,
+		agentID, tripID,
+	)
+	b.auditLogger.Log(ctx, audit.Event{
+		WalletID:  walletID,
+		AgentID:   &agentID,
+		EventType: "agent.circuit_closed",
+		Details:   map[string]interface{}{"trip_id": tripID, "closed_by": by},
+	})
+}
+
+// openCircuit returns the reason of the agent's open circuit, or "" when it
+// is closed. A circuit past its cooldown counts as closed even before the
+// breaker job has closed it.
+func (e *Engine) openCircuit(ctx context.Context, agentID uuid.UUID) (string, error) {
+	var reason string
+	err := e.db.QueryRow(ctx,
+		`SELECT reason FROM circuit_trips
+		 WHERE agent_id = $1 AND status = 'open' AND (reset_at IS NULL OR reset_at > NOW())`,
+		agentID,
+	).Scan(&reason)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return reason, err
+}
@@ -0,0 +1,54 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateCircuitRule(t *testing.T) {
+	if err := ValidateCircuitRule(&CircuitRule{MaxDenials: 50}); err == nil {
+		t.Fatal("expected error for missing window")
+	}
+	if err := ValidateCircuitRule(&CircuitRule{WindowSeconds: 300}); err == nil {
+		t.Fatal("expected error for rule without a threshold")
+	}
+	if err := ValidateCircuitRule(&CircuitRule{WindowSeconds: 300, MaxDenialRate: 1.5}); err == nil {
+		t.Fatal("expected error for rate above 1")
+	}
+	if err := ValidateCircuitRule(&CircuitRule{WindowSeconds: 300, MaxDenials: 50, CooldownSeconds: -1}); err == nil {
+		t.Fatal("expected error for negative cooldown")
+	}
+	if err := ValidateCircuitRule(&CircuitRule{WindowSeconds: 300, MaxDenials: 50, CooldownSeconds: 3600}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCircuitRule_Check(t *testing.T) {
+	denials := CircuitRule{WindowSeconds: 300, MaxDenials: 50}
+	if reason := denials.Check(CircuitStats{Requests: 60, Denials: 49}); reason != "" {
+		t.Fatalf("expected closed below threshold, got %q", reason)
+	}
+	if reason := denials.Check(CircuitStats{Requests: 60, Denials: 50}); !strings.Contains(reason, "50 denials within 5m0s") {
+		t.Fatalf("expected denial count trip, got %q", reason)
+	}
+
+	rate := CircuitRule{WindowSeconds: 60, MaxDenialRate: 0.5}
+	if reason := rate.Check(CircuitStats{Requests: 4, Denials: 4}); reason != "" {
+		t.Fatalf("expected rate to wait for min requests, got %q", reason)
+	}
+	if reason := rate.Check(CircuitStats{Requests: 10, Denials: 5}); reason != "" {
+		t.Fatalf("expected exactly the max rate to stay closed, got %q", reason)
+	}
+	if reason := rate.Check(CircuitStats{Requests: 10, Denials: 8}); !strings.Contains(reason, "80%") {
+		t.Fatalf("expected rate trip, got %q", reason)
+	}
+	rate.MinRequests = 2
+	if reason := rate.Check(CircuitStats{Requests: 2, Denials: 2}); reason == "" {
+		t.Fatal("expected custom min requests to apply")
+	}
+
+	violations := CircuitRule{WindowSeconds: 3600, MaxViolations: 3}
+	if reason := violations.Check(CircuitStats{Violations: 3}); !strings.Contains(reason, "on-chain") {
+		t.Fatalf("expected violation trip, got %q", reason)
+	}
+}
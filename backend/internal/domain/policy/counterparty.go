@@ -0,0 +1,103 @@
+package policy
+
+import (
+	"context"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// counterpartyExpr is the SQL form of Counterparty over validation_requests.
+const counterpartyExpr = `LOWER(COALESCE(NULLIF(action_data->>'to', ''), action_data->>'protocol'))`
+
+// Counterparty returns who an action pays or interacts with: the recipient
+// address when set, otherwise the protocol. Empty when the action names neither.
+func Counterparty(action *Action) string {
+	if action.To != "" {
+		return strings.ToLower(action.To)
+	}
+	return strings.ToLower(action.Protocol)
+}
+
+// CounterpartyHistory is what recorded validations say about one
+// counterparty of an agent.
+type CounterpartyHistory struct {
+	// FirstSeen is the first request naming the counterparty, allowed or not.
+	FirstSeen *time.Time
+	// Known is set once any request to the counterparty was allowed.
+	Known bool
+	// DailyVolume is the allowed volume sent to it today.
+	DailyVolume *big.Int
+	// NewToday is the number of distinct counterparties first allowed today.
+	NewToday int
+}
+
+// checkCounterparty applies the per-counterparty limits and returns the
+// reason for a denial, or "" when the action is within them. Actions that
+// name no counterparty are not subject to these limits.
+func checkCounterparty(c *Constraints, counterparty string, amount *big.Int, h CounterpartyHistory, now time.Time) string {
+	if counterparty == "" {
+		return ""
+	}
+
+	if c.NewRecipientHoldHours > 0 {
+		hold := time.Duration(c.NewRecipientHoldHours) * time.Hour
+		if h.FirstSeen == nil {
+			return "counterparty " + counterparty + " is new and on hold for " + strconv.Itoa(c.NewRecipientHoldHours) + "h"
+		}
+		if until := h.FirstSeen.Add(hold); now.Before(until) {
+			return "counterparty " + counterparty + " is on hold until " + until.UTC().Format(time.RFC3339)
+		}
+	}
+
+	if c.MaxNewRecipientsPerDay > 0 && !h.Known && h.NewToday >= c.MaxNewRecipientsPerDay {
+		return "daily limit of " + strconv.Itoa(c.MaxNewRecipientsPerDay) + " new counterparties reached"
+	}
+
+	if c.MaxDailyPerRecipient != "" && amount != nil {
+		max, ok := new(big.Int).SetString(c.MaxDailyPerRecipient, 10)
+		if ok {
+			used := h.DailyVolume
+			if used == nil {
+				used = big.NewInt(0)
+			}
+			if new(big.Int).Add(used, amount).Cmp(max) > 0 {
+				return "daily volume to counterparty " + counterparty + " would exceed " + c.MaxDailyPerRecipient
+			}
+		}
+	}
+
+	return ""
+}
+
+// getCounterpartyHistory loads an agent's recorded history with one
+// counterparty from its validation requests.
+func (e *Engine) getCounterpartyHistory(ctx context.Context, walletID, agentID uuid.UUID, counterparty string) (CounterpartyHistory, error) {
+	var h CounterpartyHistory
+	var dailyStr string
+	err := e.db.QueryRow(ctx,
+		`SELECT MIN(created_at), COALESCE(BOOL_OR(allowed), false),
+		        COALESCE(SUM((action_data->>'amount')::numeric) FILTER (WHERE allowed AND created_at >= CURRENT_DATE), 0)::text
+		 FROM validation_requests
+		 WHERE wallet_id = $1 AND agent_id = $2 AND `+counterpartyExpr+` = $3`,
+		walletID, agentID, counterparty,
+	).Scan(&h.FirstSeen, &h.Known, &dailyStr)
+	if err != nil {
+		return h, err
+	}
+	h.DailyVolume, _ = new(big.Int).SetString(dailyStr, 10)
+
+	err = e.db.QueryRow(ctx,
+		`SELECT COUNT(*) FROM (
+		   SELECT `+counterpartyExpr+` AS counterparty FROM validation_requests
+		   WHERE wallet_id = $1 AND agent_id = $2 AND allowed = true
+		   GROUP BY 1
+		   HAVING MIN(created_at) >= CURRENT_DATE
+		 ) t WHERE counterparty IS NOT NULL AND counterparty != ''`,
+		walletID, agentID,
+	).Scan(&h.NewToday)
+	return h, err
+}
@@ -0,0 +1,60 @@
+package policy
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCounterparty_PrefersRecipient(t *testing.T) {
+	if got := Counterparty(&Action{To: "0xABC", Protocol: "uniswap"}); got != "0xabc" {
+		t.Fatalf("expected recipient, got %q", got)
+	}
+	if got := Counterparty(&Action{Protocol: "Uniswap"}); got != "uniswap" {
+		t.Fatalf("expected protocol fallback, got %q", got)
+	}
+}
+
+func TestCheckCounterparty_Hold(t *testing.T) {
+	now := time.Now()
+	c := Constraints{NewRecipientHoldHours: 24}
+
+	if reason := checkCounterparty(&c, "0xabc", nil, CounterpartyHistory{}, now); !strings.Contains(reason, "new and on hold") {
+		t.Fatalf("expected unseen recipient to be held, got %q", reason)
+	}
+	recent := now.Add(-time.Hour)
+	if reason := checkCounterparty(&c, "0xabc", nil, CounterpartyHistory{FirstSeen: &recent}, now); !strings.Contains(reason, "on hold until") {
+		t.Fatalf("expected recipient within hold to be denied, got %q", reason)
+	}
+	old := now.Add(-25 * time.Hour)
+	if reason := checkCounterparty(&c, "0xabc", nil, CounterpartyHistory{FirstSeen: &old}, now); reason != "" {
+		t.Fatalf("expected hold to have passed, got %q", reason)
+	}
+}
+
+func TestCheckCounterparty_NewRecipientsPerDay(t *testing.T) {
+	c := Constraints{MaxNewRecipientsPerDay: 2}
+
+	if reason := checkCounterparty(&c, "0xabc", nil, CounterpartyHistory{NewToday: 2}, time.Now()); reason == "" {
+		t.Fatal("expected a third new recipient to be denied")
+	}
+	if reason := checkCounterparty(&c, "0xabc", nil, CounterpartyHistory{NewToday: 2, Known: true}, time.Now()); reason != "" {
+		t.Fatalf("expected known recipient to be allowed, got %q", reason)
+	}
+}
+
+func TestCheckCounterparty_DailyPerRecipient(t *testing.T) {
+	c := Constraints{MaxDailyPerRecipient: "1000"}
+	h := CounterpartyHistory{Known: true, DailyVolume: big.NewInt(800)}
+
+	if reason := checkCounterparty(&c, "0xabc", big.NewInt(200), h, time.Now()); reason != "" {
+		t.Fatalf("expected volume up to the limit to be allowed, got %q", reason)
+	}
+	if reason := checkCounterparty(&c, "0xabc", big.NewInt(201), h, time.Now()); reason == "" {
+		t.Fatal("expected volume over the limit to be denied")
+	}
+	if reason := checkCounterparty(&c, "", big.NewInt(5000), h, time.Now()); reason != "" {
+		t.Fatalf("expected actions without a counterparty to be exempt, got %q", reason)
+	}
+}
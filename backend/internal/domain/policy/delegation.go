@@ -0,0 +1,253 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxDelegationDepth bounds how many times a permission may be re-delegated.
+const MaxDelegationDepth = 3
+
+// CheckAttenuation proves that child grants no more than parent: every
+// action, asset and chain it allows is allowed by parent, each limit parent
+// sets is set at least as tight, parent's conditions are kept and the
+// validity window lies within parent's.
+func CheckAttenuation(child, parent *Definition) error {
+	if !contains(parent.Actions, "*") {
+		if contains(child.Actions, "*") {
+			return errors.New("actions: * exceeds the delegating permission")
+		}
+		for _, a := range child.Actions {
+			if !containsFold(parent.Actions, a) {
+				return errors.New("actions: " + a + " is not allowed by the delegating permission")
+			}
+		}
+	}
+
+	if err := attenuateList("tokens", child.Assets.Tokens, parent.Assets.Tokens); err != nil {
+		return err
+	}
+	if err := attenuateList("protocols", child.Assets.Protocols, parent.Assets.Protocols); err != nil {
+		return err
+	}
+	if len(parent.Assets.Chains) > 0 {
+		if len(child.Assets.Chains) == 0 {
+			return errors.New("chains: must be restricted to the delegating permission's chains")
+		}
+		for _, c := range child.Assets.Chains {
+			if !containsChain(parent.Assets.Chains, c) {
+				return errors.New("chains: " + strconv.FormatInt(c, 10) + " is not allowed by the delegating permission")
+			}
+		}
+	}
+
+	pc, cc := parent.Constraints, child.Constraints
+	for _, f := range []struct {
+		name, child, parent string
+	}{
+		{"maxValuePerTx", cc.MaxValuePerTx, pc.MaxValuePerTx},
+		{"maxDailyVolume", cc.MaxDailyVolume, pc.MaxDailyVolume},
+		{"maxWeeklyVolume", cc.MaxWeeklyVolume, pc.MaxWeeklyVolume},
+		{"maxDailyPerRecipient", cc.MaxDailyPerRecipient, pc.MaxDailyPerRecipient},
+	} {
+		if f.parent == "" {
+			continue
+		}
+		if f.child == "" {
+			return errors.New(f.name + ": must be set, the delegating permission limits it to " + f.parent)
+		}
+		c, ok := new(big.Int).SetString(f.child, 10)
+		p, _ := new(big.Int).SetString(f.parent, 10)
+		if !ok || p == nil || c.Cmp(p) > 0 {
+			return errors.New(f.name + ": must not exceed " + f.parent)
+		}
+	}
+	for _, f := range []struct {
+		name          string
+		child, parent int
+	}{
+		{"maxTxCount", cc.MaxTxCount, pc.MaxTxCount},
+		{"maxNewRecipientsPerDay", cc.MaxNewRecipientsPerDay, pc.MaxNewRecipientsPerDay},
+	} {
+		if f.parent > 0 && (f.child <= 0 || f.child > f.parent) {
+			return errors.New(f.name + ": must be between 1 and " + strconv.Itoa(f.parent))
+		}
+	}
+	if cc.NewRecipientHoldHours < pc.NewRecipientHoldHours {
+		return errors.New("newRecipientHoldHours: must be at least " + strconv.Itoa(pc.NewRecipientHoldHours))
+	}
+	if pc.RequireApproval && !cc.RequireApproval {
+		return errors.New("requireApproval: must stay required")
+	}
+
+	if err := WithinWindow(child.Duration, parent.Duration.ValidFrom, parent.Duration.ValidUntil); err != nil {
+		return err
+	}
+
+	for _, pcond := range parent.Conditions {
+		kept := false
+		for _, ccond := range child.Conditions {
+			if reflect.DeepEqual(pcond, ccond) {
+				kept = true
+				break
+			}
+		}
+		if !kept {
+			return errors.New("conditions: the delegating permission's condition on " + pcond.Field + " must be kept")
+		}
+	}
+
+	// Lenient matching skips restrictions an action leaves out, so a strict
+	// parent needs a strict child, and a child may not opt out of strict mode
+	if parent.Strict != nil && *parent.Strict && (child.Strict == nil || !*child.Strict) {
+		return errors.New("strict: must stay strict")
+	}
+	if parent.Strict == nil && child.Strict != nil && !*child.Strict {
+		return errors.New("strict: cannot be turned off by delegation")
+	}
+	return nil
+}
+
+// WithinWindow checks that d lies within [from, until); unset bounds of the
+// outer window are open.
+func WithinWindow(d Duration, from, until *time.Time) error {
+	if from != nil && (d.ValidFrom == nil || d.ValidFrom.Before(*from)) {
+		return errors.New("validity: must not start before the delegating permission")
+	}
+	if until != nil && (d.ValidUntil == nil || d.ValidUntil.After(*until)) {
+		return errors.New("validity: must not end after the delegating permission")
+	}
+	return nil
+}
+
+// attenuateList checks that child restricts an asset list to a subset of
+// parent's. An empty list or * allows any value.
+func attenuateList(name string, child, parent []string) error {
+	if !restricts(parent) {
+		return nil
+	}
+	if !restricts(child) {
+		return errors.New(name + ": must be restricted to the delegating permission's " + name)
+	}
+	for _, v := range child {
+		if !containsFold(parent, v) {
+			return errors.New(name + ": " + v + " is not allowed by the delegating permission")
+		}
+	}
+	return nil
+}
+
+// checkDelegation charges an action matched by permID to the permissions it
+// was delegated from: each must still be active and valid, allow the action
+// and have room for it within its daily volume, which includes everything its
+// delegates spent. It also charges permID's own delegates' spending. Returns
+// a denial reason, or "".
+func (e *Engine) checkDelegation(ctx context.Context, walletID uuid.UUID, permID uuid.UUID, action *Action) (string, error) {
+	rows, err := e.db.Query(ctx,
+		`WITH RECURSIVE chain AS (
+		   SELECT id, agent_id, delegated_from, status, valid_from, valid_until, policy_id, 0 AS depth
+		   FROM permissions WHERE id = $1
+		   UNION ALL
+		   SELECT p.id, p.agent_id, p.delegated_from, p.status, p.valid_from, p.valid_until, p.policy_id, c.depth + 1
+		   FROM permissions p JOIN chain c ON p.id = c.delegated_from
+		   WHERE c.depth <= $2
+		 )
+		 SELECT c.id, c.agent_id, c.status, c.valid_from, c.valid_until, pol.status, pol.definition, c.depth,
+		        EXISTS(SELECT 1 FROM permissions d WHERE d.delegated_from = c.id)
+		 FROM chain c JOIN policies pol ON pol.id = c.policy_id
+		 ORDER BY c.depth`,
+		permID, MaxDelegationDepth,
+	)
+	if err != nil {
+		return "", err
+	}
+	type link struct {
+		id, agentID          uuid.UUID
+		status, policyStatus string
+		validFrom            time.Time
+		validUntil           *time.Time
+		def                  Definition
+		depth                int
+		delegated            bool
+	}
+	var chain []link
+	for rows.Next() {
+		var l link
+		var defBytes []byte
+		if err := rows.Scan(&l.id, &l.agentID, &l.status, &l.validFrom, &l.validUntil, &l.policyStatus, &defBytes, &l.depth, &l.delegated); err != nil {
+			rows.Close()
+			return "", err
+		}
+		json.Unmarshal(defBytes, &l.def)
+		chain = append(chain, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	amount, _ := new(big.Int).SetString(action.Amount, 10)
+	now := time.Now()
+	for _, l := range chain {
+		if l.depth > 0 {
+			if l.status != "active" || l.policyStatus != "active" || now.Before(l.validFrom) ||
+				(l.validUntil != nil && !now.Before(*l.validUntil)) || !l.def.Duration.Contains(now) {
+				return "delegating permission is no longer valid", nil
+			}
+			if !e.matchesPolicy(&l.def, action, walletID, l.agentID, ctx) {
+				return "action exceeds the delegating permission", nil
+			}
+		}
+		if !l.delegated || amount == nil || amount.Sign() <= 0 || l.def.Constraints.MaxDailyVolume == "" {
+			continue
+		}
+		maxDaily, ok := new(big.Int).SetString(l.def.Constraints.MaxDailyVolume, 10)
+		if !ok {
+			continue
+		}
+		delegated, err := e.delegatedUsage(ctx, l.id, l.agentID)
+		if err != nil {
+			return "", err
+		}
+		total := new(big.Int).Add(e.getDailyUsage(ctx, walletID, l.agentID), delegated)
+		if total.Add(total, amount).Cmp(maxDaily) > 0 {
+			return "daily volume of the delegating permission exceeded, including its delegates", nil
+		}
+	}
+	return "", nil
+}
+
+// delegatedUsage sums today's allowed volume of every permission delegated
+// from permID, directly or further down. Spending by agentID, the holder of
+// permID, is left out: it is already in the agent's own usage, and only task
+// sessions are delegated back to the same agent.
+func (e *Engine) delegatedUsage(ctx context.Context, permID, agentID uuid.UUID) (*big.Int, error) {
+	var totalStr string
+	err := e.db.QueryRow(ctx,
+		`WITH RECURSIVE tree AS (
+		   SELECT id FROM permissions WHERE delegated_from = $1
+		   UNION
+		   SELECT p.id FROM permissions p JOIN tree t ON p.delegated_from = t.id
+		 )
+		 SELECT COALESCE(SUM((action_data->>'amount')::numeric), 0)::text
+		 FROM validation_requests
+		 WHERE permission_id IN (SELECT id FROM tree) AND allowed = true
+		 AND agent_id <> $2 AND created_at >= CURRENT_DATE`,
+		permID, agentID,
+	).Scan(&totalStr)
+	if err != nil {
+		return nil, err
+	}
+	total, ok := new(big.Int).SetString(totalStr, 10)
+	if !ok {
+		return big.NewInt(0), nil
+	}
+	return total, nil
+}
@@ -0,0 +1,101 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func delegationParent() Definition {
+	until := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	return Definition{
+		Actions: []string{"swap", "transfer"},
+		Assets: Assets{
+			Tokens: []string{"USDC", "WETH"},
+			Chains: []int64{1, 8453},
+		},
+		Constraints: Constraints{
+			MaxValuePerTx:  "1000",
+			MaxDailyVolume: "5000",
+			MaxTxCount:     10,
+		},
+		Duration:   Duration{ValidUntil: &until},
+		Conditions: []Condition{{Field: "to", Operator: "in", Value: []interface{}{"0xabc"}}},
+	}
+}
+
+func TestCheckAttenuation_Subset(t *testing.T) {
+	parent := delegationParent()
+	until := parent.Duration.ValidUntil.Add(-time.Hour)
+	child := Definition{
+		Actions:     []string{"SWAP"},
+		Assets:      Assets{Tokens: []string{"usdc"}, Chains: []int64{8453}},
+		Constraints: Constraints{MaxValuePerTx: "100", MaxDailyVolume: "5000", MaxTxCount: 3},
+		Duration:    Duration{ValidUntil: &until},
+		Conditions:  append([]Condition{{Field: "amount", Operator: "lt", Value: "50"}}, parent.Conditions...),
+	}
+	if err := CheckAttenuation(&child, &parent); err != nil {
+		t.Fatalf("expected subset to pass, got %v", err)
+	}
+}
+
+func TestCheckAttenuation_Widening(t *testing.T) {
+	parent := delegationParent()
+	later := parent.Duration.ValidUntil.Add(time.Hour)
+
+	cases := map[string]func(d *Definition){
+		"actions":             func(d *Definition) { d.Actions = []string{"swap", "bridge"} },
+		"action wildcard":     func(d *Definition) { d.Actions = []string{"*"} },
+		"tokens":              func(d *Definition) { d.Assets.Tokens = []string{"DAI"} },
+		"unrestricted tokens": func(d *Definition) { d.Assets.Tokens = nil },
+		"chains":              func(d *Definition) { d.Assets.Chains = []int64{10} },
+		"maxValuePerTx":       func(d *Definition) { d.Constraints.MaxValuePerTx = "1001" },
+		"unset limit":         func(d *Definition) { d.Constraints.MaxDailyVolume = "" },
+		"maxTxCount":          func(d *Definition) { d.Constraints.MaxTxCount = 0 },
+		"validity":            func(d *Definition) { d.Duration.ValidUntil = &later },
+		"open validity":       func(d *Definition) { d.Duration.ValidUntil = nil },
+		"conditions":          func(d *Definition) { d.Conditions = nil },
+		"strict":              func(d *Definition) { off := false; d.Strict = &off },
+	}
+	for name, widen := range cases {
+		child := delegationParent()
+		widen(&child)
+		if err := CheckAttenuation(&child, &parent); err == nil {
+			t.Errorf("%s: expected widening to be rejected", name)
+		}
+	}
+}
+
+func TestCheckAttenuation_WildcardParent(t *testing.T) {
+	parent := Definition{Actions: []string{"*"}, Constraints: Constraints{RequireApproval: true}}
+
+	child := Definition{Actions: []string{"*"}, Assets: Assets{Tokens: []string{"USDC"}}, Constraints: Constraints{RequireApproval: true}}
+	if err := CheckAttenuation(&child, &parent); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	child.Constraints.RequireApproval = false
+	if err := CheckAttenuation(&child, &parent); err == nil || !strings.Contains(err.Error(), "requireApproval") {
+		t.Fatalf("expected approval requirement to be kept, got %v", err)
+	}
+}
+
+func TestWithinWindow(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := from.Add(24 * time.Hour)
+	inside := from.Add(time.Hour)
+
+	if err := WithinWindow(Duration{ValidFrom: &inside, ValidUntil: &until}, &from, &until); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	before := from.Add(-time.Hour)
+	if err := WithinWindow(Duration{ValidFrom: &before, ValidUntil: &until}, &from, &until); err == nil {
+		t.Fatal("expected early start to be rejected")
+	}
+	if err := WithinWindow(Duration{ValidFrom: &inside}, &from, &until); err == nil {
+		t.Fatal("expected open end to be rejected under a bounded parent")
+	}
+	if err := WithinWindow(Duration{}, nil, nil); err != nil {
+		t.Fatalf("open windows should nest, got %v", err)
+	}
+}
@@ -0,0 +1,388 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Grant is an active, in-window permission together with the policy it grants.
+type Grant struct {
+	PermissionID  uuid.UUID  `json:"permission_id"`
+	PolicyID      uuid.UUID  `json:"policy_id"`
+	PolicyName    string     `json:"policy_name"`
+	PolicyVersion int        `json:"policy_version"`
+	ValidFrom     time.Time  `json:"valid_from"`
+	ValidUntil    *time.Time `json:"valid_until,omitempty"`
+	Definition    Definition `json:"definition"`
+	// Escalated is set when a break-glass escalation raised the limits.
+	Escalated bool `json:"escalated,omitempty"`
+	// GroupGrantID is set when the agent holds the grant through a group.
+	GroupGrantID *uuid.UUID `json:"group_grant_id,omitempty"`
+}
+
+// Source records where an effective capability or limit comes from.
+type Source struct {
+	Kind         string     `json:"kind"` // "policy", "guardrail" or "group"
+	Name         string     `json:"name"`
+	PermissionID *uuid.UUID `json:"permission_id,omitempty"`
+	PolicyID     *uuid.UUID `json:"policy_id,omitempty"`
+	GuardrailID  *uuid.UUID `json:"guardrail_id,omitempty"`
+	GroupID      *uuid.UUID `json:"group_id,omitempty"`
+}
+
+// Capability is one allowed value ("*" meaning any) and the grants allowing it.
+type Capability struct {
+	Value   string   `json:"value"`
+	Sources []Source `json:"sources"`
+	// Limits, set on actions, are the loosest limits among the grants
+	// allowing the action, bounded by the guardrails.
+	Limits map[string]string `json:"limits,omitempty"`
+}
+
+// EffectiveLimit is the most a limit allows under any grant, bounded by the
+// guardrails, and for volume limits what is left of it in the current window.
+type EffectiveLimit struct {
+	Limit     string `json:"limit"`
+	Used      string `json:"used,omitempty"`
+	Remaining string `json:"remaining,omitempty"`
+	Source    Source `json:"source"`
+}
+
+// Expiry is the next point in time at which a grant stops applying.
+type Expiry struct {
+	At     time.Time `json:"at"`
+	Source Source    `json:"source"`
+}
+
+// EffectivePermissions is the merged view of everything an agent can do now.
+type EffectivePermissions struct {
+	AgentID         uuid.UUID                  `json:"agent_id"`
+	Actions         []Capability               `json:"actions"`
+	Tokens          []Capability               `json:"tokens"`
+	Protocols       []Capability               `json:"protocols"`
+	Chains          []Capability               `json:"chains"`
+	Limits          map[string]*EffectiveLimit `json:"limits"`
+	RequireApproval []Source                   `json:"require_approval,omitempty"`
+	NextExpiry      *Expiry                    `json:"next_expiry,omitempty"`
+	Groups          []GroupHeadroom            `json:"groups"`
+	Grants          []Grant                    `json:"grants"`
+	Notes           []string                   `json:"notes,omitempty"`
+	ComputedAt      time.Time                  `json:"computed_at"`
+}
+
+// EffectiveUsage is the volume already consumed, used for remaining quotas.
+type EffectiveUsage struct {
+	Daily       *big.Int
+	WalletDaily *big.Int
+}
+
+// EffectivePermissions merges all active, in-window permissions of an agent
+// into a capability summary, bounded by the wallet's guardrails.
+func (e *Engine) EffectivePermissions(ctx context.Context, walletID, agentID uuid.UUID) (*EffectivePermissions, error) {
+	// Task sessions only narrow a permission already listed, and only for
+	// the holder of their token
+	rows, err := e.db.Query(ctx,
+		`SELECT p.id, p.policy_id, pol.name, pol.version, pol.definition, p.valid_from, p.valid_until, esc.constraints, p.group_grant_id
+		 FROM permissions p
+		 JOIN policies pol ON p.policy_id = pol.id
+		 LEFT JOIN escalations esc ON esc.permission_id = p.id
+		      AND esc.status = 'active' AND esc.expires_at > NOW()
+		 WHERE p.wallet_id = $1 AND p.agent_id = $2 AND p.status = 'active'
+		 AND pol.status = 'active'
+		 AND p.valid_from <= NOW()
+		 AND (p.valid_until IS NULL OR p.valid_until > NOW())
+		 AND p.task_id IS NULL
+		 AND `+groupGrantHeld+`
+		 ORDER BY p.created_at`,
+		walletID, agentID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	var grants []Grant
+	for rows.Next() {
+		var g Grant
+		var defBytes, escBytes []byte
+		if err := rows.Scan(&g.PermissionID, &g.PolicyID, &g.PolicyName, &g.PolicyVersion, &defBytes, &g.ValidFrom, &g.ValidUntil, &escBytes, &g.GroupGrantID); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(defBytes, &g.Definition); err != nil {
+			continue
+		}
+		if esc := parseEscalation(escBytes); esc != nil {
+			g.Definition.Constraints = esc.Apply(g.Definition.Constraints)
+			g.Escalated = true
+		}
+		if !g.Definition.Duration.Contains(now) {
+			continue
+		}
+		g.ValidFrom, g.ValidUntil = EffectiveWindow(g.Definition.Duration, g.ValidFrom, g.ValidUntil)
+		grants = append(grants, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	guardrails, err := loadGuardrails(ctx, e.db, walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := EffectiveUsage{
+		Daily:       e.getDailyUsage(ctx, walletID, agentID),
+		WalletDaily: e.getWalletDailyUsage(ctx, walletID),
+	}
+
+	out := mergeGrants(agentID, grants, guardrails, usage, now)
+	if out.Groups, err = e.GroupHeadroom(ctx, walletID, agentID); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// mergeGrants computes the effective view. Permissions are alternatives (any
+// matching one allows an action), so allowed values are the union across
+// grants after each grant is narrowed by the guardrails, and limits are the
+// loosest among the grants. Guardrails apply to every action, so they
+// tighten the limits with the lowest value any of them sets.
+func mergeGrants(agentID uuid.UUID, grants []Grant, guardrails []activeGuardrail, usage EffectiveUsage, now time.Time) *EffectivePermissions {
+	out := &EffectivePermissions{
+		AgentID:    agentID,
+		Actions:    []Capability{},
+		Tokens:     []Capability{},
+		Protocols:  []Capability{},
+		Chains:     []Capability{},
+		Limits:     map[string]*EffectiveLimit{},
+		Grants:     []Grant{},
+		Groups:     []GroupHeadroom{},
+		ComputedAt: now,
+	}
+
+	bounds := make([]Guardrail, len(guardrails))
+	for i, g := range guardrails {
+		bounds[i] = g.Guardrail
+	}
+
+	actions := map[string][]Source{}
+	tokens := map[string][]Source{}
+	protocols := map[string][]Source{}
+	chains := map[string][]Source{}
+	var allowing []limitedGrant
+
+	for _, g := range grants {
+		src := grantSource(g)
+		def, _ := ApplyGuardrails(g.Definition, bounds)
+		out.Grants = append(out.Grants, g)
+		if blockedByGuardrails(&def) {
+			out.Notes = append(out.Notes, "policy "+g.PolicyName+" is fully blocked by guardrails")
+			continue
+		}
+
+		collect(actions, def.Actions, noneAction, src)
+		collect(tokens, def.Assets.Tokens, noneAddress, src)
+		collect(protocols, def.Assets.Protocols, noneAddress, src)
+		chainValues := make([]string, len(def.Assets.Chains))
+		for i, c := range def.Assets.Chains {
+			chainValues[i] = strconv.FormatInt(c, 10)
+		}
+		collect(chains, chainValues, "", src)
+
+		allowing = append(allowing, limitedGrant{limits: grantLimits(g.Definition.Constraints), src: src})
+
+		if g.Definition.Constraints.RequireApproval {
+			out.RequireApproval = append(out.RequireApproval, src)
+		}
+		if g.ValidUntil != nil && (out.NextExpiry == nil || g.ValidUntil.Before(out.NextExpiry.At)) {
+			out.NextExpiry = &Expiry{At: *g.ValidUntil, Source: src}
+		}
+	}
+
+	out.Limits = loosest(allowing)
+	caps := map[string]string{}
+	for _, g := range guardrails {
+		id := g.ID
+		src := Source{Kind: "guardrail", Name: g.Name, GuardrailID: &id}
+		caps["maxValuePerTx"] = minAmount(caps["maxValuePerTx"], g.MaxValuePerTx)
+		caps["maxDailyVolume"] = minAmount(caps["maxDailyVolume"], g.MaxDailyVolumePerAgent)
+		if len(grants) > 0 {
+			tighten(out.Limits, "maxValuePerTx", g.MaxValuePerTx, src)
+			tighten(out.Limits, "maxDailyVolume", g.MaxDailyVolumePerAgent, src)
+			tighten(out.Limits, "walletDailyVolume", g.MaxDailyVolume, src)
+		}
+		if len(g.DeniedTokens) > 0 {
+			out.Notes = append(out.Notes, "guardrail "+g.Name+" denies tokens: "+strings.Join(g.DeniedTokens, ", "))
+		}
+		if len(g.DeniedProtocols) > 0 {
+			out.Notes = append(out.Notes, "guardrail "+g.Name+" denies protocols: "+strings.Join(g.DeniedProtocols, ", "))
+		}
+		if len(g.DeniedRecipients) > 0 {
+			out.Notes = append(out.Notes, "guardrail "+g.Name+" denies recipients: "+strings.Join(g.DeniedRecipients, ", "))
+		}
+	}
+
+	remaining(out.Limits["maxDailyVolume"], usage.Daily)
+	remaining(out.Limits["walletDailyVolume"], usage.WalletDaily)
+
+	out.Actions = capabilities(actions)
+	for i := range out.Actions {
+		out.Actions[i].Limits = capabilityLimits(out.Actions[i].Sources, allowing, caps)
+	}
+	out.Tokens = capabilities(tokens)
+	out.Protocols = capabilities(protocols)
+	out.Chains = capabilities(chains)
+
+	return out
+}
+
+// blockedByGuardrails reports whether narrowing left a dimension empty, in
+// which case the grant no longer allows anything.
+func blockedByGuardrails(def *Definition) bool {
+	return contains(def.Actions, noneAction) ||
+		contains(def.Assets.Tokens, noneAddress) ||
+		contains(def.Assets.Protocols, noneAddress) ||
+		(len(def.Assets.Chains) == 1 && def.Assets.Chains[0] == 0)
+}
+
+func grantSource(g Grant) Source {
+	permID, policyID := g.PermissionID, g.PolicyID
+	return Source{Kind: "policy", Name: g.PolicyName, PermissionID: &permID, PolicyID: &policyID}
+}
+
+// collect adds values to the set, treating an empty list as "any" and
+// skipping the sentinel guardrails use for an empty intersection.
+func collect(set map[string][]Source, values []string, none string, src Source) {
+	if len(values) == 0 {
+		values = []string{"*"}
+	}
+	for _, v := range values {
+		if v == none {
+			continue
+		}
+		v = strings.ToLower(v)
+		set[v] = append(set[v], src)
+	}
+}
+
+func capabilities(set map[string][]Source) []Capability {
+	out := make([]Capability, 0, len(set))
+	for v, sources := range set {
+		out = append(out, Capability{Value: v, Sources: sources})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Value == "*" || out[j].Value == "*" {
+			return out[i].Value == "*"
+		}
+		return out[i].Value < out[j].Value
+	})
+	return out
+}
+
+// grantLimitKeys are the limits of a grant that validation enforces, in the
+// order reported. maxWeeklyVolume and maxTxCount are only pushed on-chain.
+var grantLimitKeys = []string{"maxValuePerTx", "maxDailyVolume", "maxDailyPerRecipient"}
+
+// limitedGrant is a grant's limits keyed as in grantLimitKeys; an empty
+// value means the grant does not set the limit.
+type limitedGrant struct {
+	limits map[string]string
+	src    Source
+}
+
+func grantLimits(c Constraints) map[string]string {
+	return map[string]string{
+		"maxValuePerTx":        c.MaxValuePerTx,
+		"maxDailyVolume":       c.MaxDailyVolume,
+		"maxDailyPerRecipient": c.MaxDailyPerRecipient,
+	}
+}
+
+// loosest returns each limit at its highest value among the grants, with
+// the grant setting it. A grant without the limit leaves it unbounded.
+func loosest(grants []limitedGrant) map[string]*EffectiveLimit {
+	out := map[string]*EffectiveLimit{}
+	for _, key := range grantLimitKeys {
+		var best *EffectiveLimit
+		for _, g := range grants {
+			v := g.limits[key]
+			if v == "" {
+				best = nil
+				break
+			}
+			if best == nil || minAmount(best.Limit, v) != v {
+				best = &EffectiveLimit{Limit: v, Source: g.src}
+			}
+		}
+		if best != nil {
+			out[key] = best
+		}
+	}
+	return out
+}
+
+// capabilityLimits returns the loosest limits among the grants in sources,
+// bounded by the guardrails' caps.
+func capabilityLimits(sources []Source, grants []limitedGrant, caps map[string]string) map[string]string {
+	var from []limitedGrant
+	for _, g := range grants {
+		for _, src := range sources {
+			if src.PermissionID != nil && g.src.PermissionID != nil && *src.PermissionID == *g.src.PermissionID {
+				from = append(from, g)
+				break
+			}
+		}
+	}
+	out := map[string]string{}
+	for key, l := range loosest(from) {
+		out[key] = l.Limit
+	}
+	for key, c := range caps {
+		if v := minAmount(out[key], c); v != "" {
+			out[key] = v
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// tighten records value for key if it is lower than the current limit.
+func tighten(limits map[string]*EffectiveLimit, key, value string, src Source) {
+	if value == "" {
+		return
+	}
+	if current, ok := limits[key]; ok && minAmount(current.Limit, value) == current.Limit {
+		return
+	}
+	limits[key] = &EffectiveLimit{Limit: value, Source: src}
+}
+
+// remaining fills in used and remaining quota, floored at zero.
+func remaining(limit *EffectiveLimit, used *big.Int) {
+	if limit == nil {
+		return
+	}
+	max, ok := new(big.Int).SetString(limit.Limit, 10)
+	if !ok {
+		return
+	}
+	if used == nil {
+		used = big.NewInt(0)
+	}
+	left := new(big.Int).Sub(max, used)
+	if left.Sign() < 0 {
+		left.SetInt64(0)
+	}
+	limit.Used = used.String()
+	limit.Remaining = left.String()
+}
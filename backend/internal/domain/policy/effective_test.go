@@ -0,0 +1,161 @@
+package policy
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func testGrant(name string, def Definition, validUntil *time.Time) Grant {
+	return Grant{
+		PermissionID:  uuid.New(),
+		PolicyID:      uuid.New(),
+		PolicyName:    name,
+		PolicyVersion: 1,
+		ValidFrom:     time.Now().Add(-time.Hour),
+		ValidUntil:    validUntil,
+		Definition:    def,
+	}
+}
+
+func findCapability(caps []Capability, value string) *Capability {
+	for i := range caps {
+		if caps[i].Value == value {
+			return &caps[i]
+		}
+	}
+	return nil
+}
+
+func TestMergeGrants_UnionWithProvenance(t *testing.T) {
+	soon := time.Now().Add(24 * time.Hour)
+	later := time.Now().Add(48 * time.Hour)
+	a := testGrant("trading", Definition{
+		Actions:     []string{"swap", "transfer"},
+		Assets:      Assets{Tokens: []string{"0xAAA"}, Chains: []int64{1}},
+		Constraints: Constraints{MaxValuePerTx: "1000", MaxDailyVolume: "5000"},
+	}, &later)
+	b := testGrant("payments", Definition{
+		Actions:     []string{"transfer", "approve"},
+		Constraints: Constraints{MaxValuePerTx: "200", MaxDailyVolume: "2000", RequireApproval: true},
+	}, &soon)
+
+	out := mergeGrants(uuid.New(), []Grant{a, b}, nil, EffectiveUsage{Daily: big.NewInt(1200)}, time.Now())
+
+	transfer := findCapability(out.Actions, "transfer")
+	if transfer == nil || len(transfer.Sources) != 2 {
+		t.Fatalf("expected transfer from both policies, got %+v", transfer)
+	}
+	swap := findCapability(out.Actions, "swap")
+	if swap == nil || len(swap.Sources) != 1 || *swap.Sources[0].PolicyID != a.PolicyID {
+		t.Fatalf("expected swap from trading policy, got %+v", swap)
+	}
+	if findCapability(out.Tokens, "*") == nil || findCapability(out.Tokens, "0xaaa") == nil {
+		t.Fatalf("expected any token plus 0xaaa, got %+v", out.Tokens)
+	}
+	if out.Tokens[0].Value != "*" {
+		t.Fatalf("expected wildcard listed first, got %+v", out.Tokens)
+	}
+
+	perTx := out.Limits["maxValuePerTx"]
+	if perTx.Limit != "1000" || perTx.Source.Name != "trading" {
+		t.Fatalf("expected loosest per-tx limit from trading, got %+v", perTx)
+	}
+	if limit := transfer.Limits["maxValuePerTx"]; limit != "1000" {
+		t.Fatalf("expected transfer to allow trading's per-tx limit, got %q", limit)
+	}
+	if approve := findCapability(out.Actions, "approve"); approve == nil || approve.Limits["maxValuePerTx"] != "200" {
+		t.Fatalf("expected approve limited to payments' per-tx limit, got %+v", approve)
+	}
+	daily := out.Limits["maxDailyVolume"]
+	if daily.Remaining != "3800" || daily.Used != "1200" {
+		t.Fatalf("expected 3800 remaining of daily volume, got %+v", daily)
+	}
+	if len(out.RequireApproval) != 1 || out.RequireApproval[0].Name != "payments" {
+		t.Fatalf("expected approval requirement from payments, got %+v", out.RequireApproval)
+	}
+	if out.NextExpiry == nil || !out.NextExpiry.At.Equal(soon) || out.NextExpiry.Source.Name != "payments" {
+		t.Fatalf("expected next expiry from payments, got %+v", out.NextExpiry)
+	}
+}
+
+func TestMergeGrants_GuardrailsNarrowAndTighten(t *testing.T) {
+	a := testGrant("all", Definition{
+		Actions:     []string{"*"},
+		Constraints: Constraints{MaxValuePerTx: "1000"},
+	}, nil)
+	g := activeGuardrail{
+		ID:   uuid.New(),
+		Name: "org",
+		Guardrail: Guardrail{
+			DeniedActions:  []string{"bridge"},
+			MaxValuePerTx:  "100",
+			MaxDailyVolume: "500",
+			DeniedTokens:   []string{"0xbad"},
+		},
+	}
+
+	out := mergeGrants(uuid.New(), []Grant{a}, []activeGuardrail{g}, EffectiveUsage{WalletDaily: big.NewInt(700)}, time.Now())
+
+	if findCapability(out.Actions, "bridge") != nil || findCapability(out.Actions, "*") != nil {
+		t.Fatalf("expected bridge removed from expanded actions, got %+v", out.Actions)
+	}
+	if findCapability(out.Actions, "swap") == nil {
+		t.Fatal("expected swap to remain allowed")
+	}
+	perTx := out.Limits["maxValuePerTx"]
+	if perTx.Limit != "100" || perTx.Source.Kind != "guardrail" {
+		t.Fatalf("expected guardrail per-tx limit, got %+v", perTx)
+	}
+	if limit := findCapability(out.Actions, "swap").Limits["maxValuePerTx"]; limit != "100" {
+		t.Fatalf("expected the guardrail to cap swap's per-tx limit, got %q", limit)
+	}
+	if wallet := out.Limits["walletDailyVolume"]; wallet.Remaining != "0" {
+		t.Fatalf("expected exhausted wallet quota floored at 0, got %+v", wallet)
+	}
+	if len(out.Notes) != 1 {
+		t.Fatalf("expected denied tokens note, got %v", out.Notes)
+	}
+}
+
+func TestMergeGrants_UnsetLimitIsUnbounded(t *testing.T) {
+	a := testGrant("capped", Definition{Actions: []string{"swap"}, Constraints: Constraints{MaxDailyVolume: "100"}}, nil)
+	b := testGrant("open", Definition{Actions: []string{"swap"}}, nil)
+
+	out := mergeGrants(uuid.New(), []Grant{a, b}, nil, EffectiveUsage{}, time.Now())
+
+	if daily, ok := out.Limits["maxDailyVolume"]; ok {
+		t.Fatalf("expected no daily limit when a grant sets none, got %+v", daily)
+	}
+	if swap := findCapability(out.Actions, "swap"); swap == nil || swap.Limits != nil {
+		t.Fatalf("expected swap without limits, got %+v", swap)
+	}
+}
+
+func TestMergeGrants_FullyBlockedGrant(t *testing.T) {
+	a := testGrant("bridging", Definition{Actions: []string{"bridge"}}, nil)
+	g := activeGuardrail{ID: uuid.New(), Name: "org", Guardrail: Guardrail{DeniedActions: []string{"bridge"}}}
+
+	out := mergeGrants(uuid.New(), []Grant{a}, []activeGuardrail{g}, EffectiveUsage{}, time.Now())
+
+	if len(out.Actions) != 0 || len(out.Tokens) != 0 {
+		t.Fatalf("expected no capabilities, got %+v", out)
+	}
+	if len(out.Grants) != 1 || len(out.Notes) != 1 {
+		t.Fatalf("expected grant listed with a blocked note, got %+v", out)
+	}
+}
+
+func TestMergeGrants_ReportsOnlyEnforcedLimits(t *testing.T) {
+	a := testGrant("weekly", Definition{Actions: []string{"swap"}, Constraints: Constraints{MaxWeeklyVolume: "100", MaxTxCount: 5}}, nil)
+
+	out := mergeGrants(uuid.New(), []Grant{a}, nil, EffectiveUsage{}, time.Now())
+
+	for _, key := range []string{"maxWeeklyVolume", "maxTxCount"} {
+		if limit, ok := out.Limits[key]; ok {
+			t.Fatalf("expected %s not to be reported, got %+v", key, limit)
+		}
+	}
+}
@@ -9,8 +9,11 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog"
+
+	"github.com/erc8004/policy-saas/pkg/policyeval"
 )
 
 type Engine struct {
@@ -57,6 +60,14 @@ func (e *Engine) ValidateDefinition(def *Definition) error {
 			return errors.New("maxWeeklyVolume must be a valid integer")
 		}
 	}
+	if def.Constraints.MaxDailyPerRecipient != "" {
+		if _, ok := new(big.Int).SetString(def.Constraints.MaxDailyPerRecipient, 10); !ok {
+			return errors.New("maxDailyPerRecipient must be a valid integer")
+		}
+	}
+	if def.Constraints.MaxNewRecipientsPerDay < 0 || def.Constraints.NewRecipientHoldHours < 0 {
+		return errors.New("counterparty limits must not be negative")
+	}
 
 	// Validate conditions
 	for _, cond := range def.Conditions {
@@ -75,18 +86,87 @@ func (e *Engine) ValidateDefinition(def *Definition) error {
 	return nil
 }
 
-// Validate checks if an action is allowed for an agent
+// groupGrantHeld limits permissions p from a group grant to agents still in
+// the group while the grant is active. Membership changes revoke such
+// permissions as well; this keeps validation right if that lags behind.
+const groupGrantHeld = `(p.group_grant_id IS NULL OR EXISTS (
+		     SELECT 1 FROM group_grants gg
+		     JOIN agent_group_members m ON m.group_id = gg.group_id AND m.agent_id = p.agent_id
+		     WHERE gg.id = p.group_grant_id AND gg.status = 'active'))`
+
+// Validate checks if an action is allowed for an agent. An allowed amount is
+// reserved against the budgets of the agent's groups.
 func (e *Engine) Validate(ctx context.Context, walletID, agentID uuid.UUID, action Action) ValidationResult {
+	return e.validate(ctx, walletID, agentID, action, true, nil)
+}
+
+// validate checks action against the agent's active permissions, or only
+// against session when set. Task sessions are never matched otherwise.
+func (e *Engine) validate(ctx context.Context, walletID, agentID uuid.UUID, action Action, reserve bool, session *uuid.UUID) ValidationResult {
+	// A wallet frozen by the emergency kill switch denies every agent
+	frozen, err := e.walletFrozen(ctx, walletID)
+	if err != nil {
+		e.logger.Error().Err(err).Msg("failed to check wallet freeze")
+		return ValidationResult{
+			Allowed: false,
+			Reason:  "internal error",
+		}
+	}
+	if frozen {
+		return ValidationResult{
+			Allowed: false,
+			Reason:  "wallet is frozen by the emergency kill switch",
+		}
+	}
+
+	// An agent whose circuit breaker tripped is suspended
+	circuit, err := e.openCircuit(ctx, agentID)
+	if err != nil {
+		e.logger.Error().Err(err).Msg("failed to check circuit breaker")
+		return ValidationResult{
+			Allowed: false,
+			Reason:  "internal error",
+		}
+	}
+	if circuit != "" {
+		return ValidationResult{
+			Allowed: false,
+			Reason:  "agent suspended by circuit breaker (" + circuit + ")",
+		}
+	}
+
+	// Wallet guardrails bound every decision, whichever permission would match
+	reason, err := e.checkGuardrails(ctx, walletID, agentID, &action)
+	if err != nil {
+		e.logger.Error().Err(err).Msg("failed to load guardrails")
+		return ValidationResult{
+			Allowed: false,
+			Reason:  "internal error",
+		}
+	}
+	if reason != "" {
+		return ValidationResult{
+			Allowed: false,
+			Reason:  reason,
+		}
+	}
+
+	strictDefault := e.walletStrictDefault(ctx, walletID)
+
 	// Find active permissions for this agent
 	rows, err := e.db.Query(ctx,
-		`SELECT p.id, p.policy_id, pol.definition, p.valid_from, p.valid_until
+		`SELECT p.id, p.policy_id, pol.definition, p.valid_from, p.valid_until, esc.constraints, p.session_scope
 		 FROM permissions p
 		 JOIN policies pol ON p.policy_id = pol.id
+		 LEFT JOIN escalations esc ON esc.permission_id = p.id
+		      AND esc.status = 'active' AND esc.expires_at > NOW()
 		 WHERE p.wallet_id = $1 AND p.agent_id = $2 AND p.status = 'active'
 		 AND pol.status = 'active'
 		 AND p.valid_from <= NOW()
-		 AND (p.valid_until IS NULL OR p.valid_until > NOW())`,
-		walletID, agentID,
+		 AND (p.valid_until IS NULL OR p.valid_until > NOW())
+		 AND (($3::uuid IS NULL AND p.task_id IS NULL) OR p.id = $3)
+		 AND `+groupGrantHeld,
+		walletID, agentID, session,
 	)
 	if err != nil {
 		e.logger.Error().Err(err).Msg("failed to query permissions")
@@ -97,13 +177,20 @@ func (e *Engine) Validate(ctx context.Context, walletID, agentID uuid.UUID, acti
 	}
 	defer rows.Close()
 
+	now := time.Now()
+	var strictMissing []string
+	var counterpartyReason string
+	var delegationReason string
+	var history *CounterpartyHistory
 	for rows.Next() {
 		var permID, policyID uuid.UUID
 		var defBytes []byte
 		var validFrom time.Time
 		var validUntil *time.Time
+		var escBytes []byte
+		var scope *SessionScope
 
-		if err := rows.Scan(&permID, &policyID, &defBytes, &validFrom, &validUntil); err != nil {
+		if err := rows.Scan(&permID, &policyID, &defBytes, &validFrom, &validUntil, &escBytes, &scope); err != nil {
 			continue
 		}
 
@@ -112,8 +199,99 @@ func (e *Engine) Validate(ctx context.Context, walletID, agentID uuid.UUID, acti
 			continue
 		}
 
+		// A break-glass escalation temporarily raises this permission's limits
+		escalation := parseEscalation(escBytes)
+		def.Constraints = escalation.Apply(def.Constraints)
+
+		// A task session narrows its parent's policy
+		def = scope.Apply(def)
+
+		// The policy's own validity window applies on top of the permission's
+		if !def.Duration.Contains(now) {
+			continue
+		}
+
+		// In strict mode an action must name every restricted dimension
+		if def.StrictMatching(strictDefault) {
+			if missing := policyeval.MissingRestrictedFields(&def, &action); len(missing) > 0 {
+				strictMissing = missing
+				continue
+			}
+		}
+
 		// Check if action matches policy
 		if e.matchesPolicy(&def, &action, walletID, agentID, ctx) {
+			if def.Constraints.HasCounterpartyLimits() {
+				if history == nil {
+					h, err := e.getCounterpartyHistory(ctx, walletID, agentID, Counterparty(&action))
+					if err != nil {
+						e.logger.Error().Err(err).Msg("failed to load counterparty history")
+						return ValidationResult{
+							Allowed: false,
+							Reason:  "internal error",
+						}
+					}
+					history = &h
+				}
+				amount, _ := new(big.Int).SetString(action.Amount, 10)
+				if reason := checkCounterparty(&def.Constraints, Counterparty(&action), amount, *history, now); reason != "" {
+					counterpartyReason = reason
+					continue
+				}
+			}
+
+			// A task session stops at its budget or transaction count, however
+			// long it has left
+			if scope != nil {
+				amount, _ := new(big.Int).SetString(action.Amount, 10)
+				reason, err := e.checkSessionLimits(ctx, permID, scope, amount)
+				if err != nil {
+					e.logger.Error().Err(err).Msg("failed to check session limits")
+					return ValidationResult{
+						Allowed: false,
+						Reason:  "internal error",
+					}
+				}
+				if reason != "" {
+					return ValidationResult{
+						Allowed: false,
+						Reason:  reason,
+					}
+				}
+			}
+
+			// A delegated permission also draws on each permission above it
+			reason, err := e.checkDelegation(ctx, walletID, permID, &action)
+			if err != nil {
+				e.logger.Error().Err(err).Msg("failed to check delegation chain")
+				return ValidationResult{
+					Allowed: false,
+					Reason:  "internal error",
+				}
+			}
+			if reason != "" {
+				delegationReason = reason
+				continue
+			}
+
+			// Group budgets are shared, so they bound the agent whichever policy matched
+			if amount, ok := new(big.Int).SetString(action.Amount, 10); ok && amount.Sign() > 0 {
+				reason, err := e.reserveGroupBudgets(ctx, walletID, agentID, amount, reserve)
+				if err != nil {
+					e.logger.Error().Err(err).Msg("failed to check group budgets")
+					return ValidationResult{
+						Allowed: false,
+						Reason:  "internal error",
+					}
+				}
+				if reason != "" {
+					return ValidationResult{
+						Allowed: false,
+						Reason:  reason,
+					}
+				}
+			}
+
 			return ValidationResult{
 				Allowed:      true,
 				PermissionID: &permID,
@@ -122,105 +300,94 @@ func (e *Engine) Validate(ctx context.Context, walletID, agentID uuid.UUID, acti
 					"maxValuePerTx":   def.Constraints.MaxValuePerTx,
 					"maxDailyVolume":  def.Constraints.MaxDailyVolume,
 					"requireApproval": def.Constraints.RequireApproval,
+					"escalated":       escalation != nil,
 				},
 			}
 		}
 	}
 
-	return ValidationResult{
-		Allowed: false,
-		Reason:  "no matching policy found for this action",
+	if counterpartyReason != "" {
+		return ValidationResult{
+			Allowed: false,
+			Reason:  counterpartyReason,
+		}
 	}
-}
 
-// matchesPolicy checks if an action matches a policy definition
-func (e *Engine) matchesPolicy(def *Definition, action *Action, walletID, agentID uuid.UUID, ctx context.Context) bool {
-	// Check action type
-	actionAllowed := false
-	for _, a := range def.Actions {
-		if a == "*" || strings.EqualFold(a, action.Type) {
-			actionAllowed = true
-			break
+	if delegationReason != "" {
+		return ValidationResult{
+			Allowed: false,
+			Reason:  delegationReason,
 		}
 	}
-	if !actionAllowed {
-		return false
-	}
 
-	// Check assets
-	if len(def.Assets.Tokens) > 0 && action.Token != "" {
-		tokenAllowed := false
-		for _, t := range def.Assets.Tokens {
-			if strings.EqualFold(t, action.Token) || t == "*" {
-				tokenAllowed = true
-				break
-			}
-		}
-		if !tokenAllowed {
-			return false
+	if len(strictMissing) > 0 {
+		return ValidationResult{
+			Allowed: false,
+			Reason:  "no matching policy found for this action (strict mode requires " + strings.Join(strictMissing, ", ") + ")",
 		}
 	}
 
-	if len(def.Assets.Protocols) > 0 && action.Protocol != "" {
-		protocolAllowed := false
-		for _, p := range def.Assets.Protocols {
-			if strings.EqualFold(p, action.Protocol) || p == "*" {
-				protocolAllowed = true
-				break
-			}
-		}
-		if !protocolAllowed {
-			return false
-		}
+	return ValidationResult{
+		Allowed: false,
+		Reason:  "no matching policy found for this action",
 	}
+}
 
-	if len(def.Assets.Chains) > 0 && action.Chain != 0 {
-		chainAllowed := false
-		for _, c := range def.Assets.Chains {
-			if c == action.Chain {
-				chainAllowed = true
-				break
-			}
+// RestrictedFields lists the action fields the definition restricts.
+func RestrictedFields(def *Definition) []string {
+	return policyeval.MissingRestrictedFields(def, &Action{})
+}
+
+// StrictImpact counts how many of the given actions strict matching would
+// deny, in total and per missing field.
+func StrictImpact(def *Definition, actions []Action) (int, map[string]int) {
+	denied := 0
+	byField := map[string]int{}
+	for i := range actions {
+		missing := policyeval.MissingRestrictedFields(def, &actions[i])
+		if len(missing) == 0 {
+			continue
 		}
-		if !chainAllowed {
-			return false
+		denied++
+		for _, f := range missing {
+			byField[f]++
 		}
 	}
+	return denied, byField
+}
 
-	// Check constraints
-	if action.Amount != "" {
-		amount, ok := new(big.Int).SetString(action.Amount, 10)
-		if !ok {
-			return false
-		}
-
-		// Check max value per tx
-		if def.Constraints.MaxValuePerTx != "" {
-			maxValue, _ := new(big.Int).SetString(def.Constraints.MaxValuePerTx, 10)
-			if amount.Cmp(maxValue) > 0 {
-				return false
-			}
-		}
+func restricts(list []string) bool {
+	return len(list) > 0 && !contains(list, "*")
+}
 
-		// Check daily volume
-		if def.Constraints.MaxDailyVolume != "" {
-			maxDaily, _ := new(big.Int).SetString(def.Constraints.MaxDailyVolume, 10)
-			dailyUsage := e.getDailyUsage(ctx, walletID, agentID)
-			totalAfter := new(big.Int).Add(dailyUsage, amount)
-			if totalAfter.Cmp(maxDaily) > 0 {
-				return false
-			}
-		}
+// walletStrictDefault returns the wallet's strict matching default.
+func (e *Engine) walletStrictDefault(ctx context.Context, walletID uuid.UUID) bool {
+	var strict bool
+	if err := e.db.QueryRow(ctx,
+		`SELECT strict_matching FROM wallets WHERE id = $1`, walletID,
+	).Scan(&strict); err != nil {
+		return false
 	}
+	return strict
+}
 
-	// Check conditions
-	for _, cond := range def.Conditions {
-		if !e.evaluateCondition(&cond, action) {
-			return false
-		}
+// walletFrozen reports whether the wallet's emergency kill switch is engaged.
+func (e *Engine) walletFrozen(ctx context.Context, walletID uuid.UUID) (bool, error) {
+	var frozen bool
+	err := e.db.QueryRow(ctx,
+		`SELECT frozen_at IS NOT NULL FROM wallets WHERE id = $1`, walletID,
+	).Scan(&frozen)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
 	}
+	return frozen, err
+}
 
-	return true
+// matchesPolicy checks if an action matches a policy definition
+func (e *Engine) matchesPolicy(def *Definition, action *Action, walletID, agentID uuid.UUID, ctx context.Context) bool {
+	return policyeval.MatchDefinition(def, action, func() *big.Int {
+		return e.getDailyUsage(ctx, walletID, agentID)
+	})
 }
 
 // getDailyUsage calculates the total volume used today
@@ -244,100 +411,9 @@ func (e *Engine) getDailyUsage(ctx context.Context, walletID, agentID uuid.UUID)
 	return total
 }
 
-// evaluateCondition evaluates a single condition against an action
-func (e *Engine) evaluateCondition(cond *Condition, action *Action) bool {
-	var fieldValue interface{}
-
-	switch cond.Field {
-	case "type":
-		fieldValue = action.Type
-	case "token":
-		fieldValue = action.Token
-	case "protocol":
-		fieldValue = action.Protocol
-	case "amount":
-		fieldValue = action.Amount
-	case "chain":
-		fieldValue = action.Chain
-	case "to":
-		fieldValue = action.To
-	default:
-		if action.Data != nil {
-			fieldValue = action.Data[cond.Field]
-		}
-	}
-
-	switch cond.Operator {
-	case "eq":
-		return fieldValue == cond.Value
-	case "ne":
-		return fieldValue != cond.Value
-	case "gt":
-		return compareNumeric(fieldValue, cond.Value) > 0
-	case "gte":
-		return compareNumeric(fieldValue, cond.Value) >= 0
-	case "lt":
-		return compareNumeric(fieldValue, cond.Value) < 0
-	case "lte":
-		return compareNumeric(fieldValue, cond.Value) <= 0
-	case "in":
-		if values, ok := cond.Value.([]interface{}); ok {
-			for _, v := range values {
-				if fieldValue == v {
-					return true
-				}
-			}
-		}
-		return false
-	case "not_in":
-		if values, ok := cond.Value.([]interface{}); ok {
-			for _, v := range values {
-				if fieldValue == v {
-					return false
-				}
-			}
-		}
-		return true
-	case "contains":
-		if str, ok := fieldValue.(string); ok {
-			if substr, ok := cond.Value.(string); ok {
-				return strings.Contains(str, substr)
-			}
-		}
-		return false
-	}
-
-	return false
-}
-
-func compareNumeric(a, b interface{}) int {
-	aVal := toBigInt(a)
-	bVal := toBigInt(b)
-	if aVal == nil || bVal == nil {
-		return 0
-	}
-	return aVal.Cmp(bVal)
-}
-
-func toBigInt(v interface{}) *big.Int {
-	switch val := v.(type) {
-	case string:
-		i, _ := new(big.Int).SetString(val, 10)
-		return i
-	case int64:
-		return big.NewInt(val)
-	case float64:
-		return big.NewInt(int64(val))
-	case int:
-		return big.NewInt(int64(val))
-	default:
-		return nil
-	}
-}
-
 // Simulate simulates an action without recording it
 func (e *Engine) Simulate(ctx context.Context, walletID, agentID uuid.UUID, action Action) SimulationResult {
-	result := e.Validate(ctx, walletID, agentID, action)
+	result := e.validate(ctx, walletID, agentID, action, false, nil)
 
 	var currentUsage map[string]interface{}
 	var remainingQuota map[string]interface{}
@@ -365,6 +441,11 @@ func (e *Engine) Simulate(ctx context.Context, walletID, agentID uuid.UUID, acti
 		recommendations = append(recommendations, "Grant permission to the agent with an active policy")
 	}
 
+	groups, err := e.GroupHeadroom(ctx, walletID, agentID)
+	if err != nil {
+		e.logger.Error().Err(err).Msg("failed to load group headroom")
+	}
+
 	return SimulationResult{
 		WouldAllow:      result.Allowed,
 		Reason:          result.Reason,
@@ -372,5 +453,6 @@ func (e *Engine) Simulate(ctx context.Context, walletID, agentID uuid.UUID, acti
 		CurrentUsage:    currentUsage,
 		RemainingQuota:  remainingQuota,
 		Recommendations: recommendations,
+		GroupHeadroom:   groups,
 	}
 }
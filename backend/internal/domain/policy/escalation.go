@@ -0,0 +1,220 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+
+	"github.com/erc8004/policy-saas/internal/domain/audit"
+)
+
+// MaxEscalationDuration bounds how long a break-glass escalation may last.
+const MaxEscalationDuration = 72 * time.Hour
+
+// Escalation temporarily raises selected constraints of one permission. Only
+// the fields that are set override the policy; guardrails still apply.
+type Escalation struct {
+	MaxValuePerTx   string `json:"maxValuePerTx,omitempty"`
+	MaxDailyVolume  string `json:"maxDailyVolume,omitempty"`
+	MaxWeeklyVolume string `json:"maxWeeklyVolume,omitempty"`
+	MaxTxCount      int    `json:"maxTxCount,omitempty"`
+}
+
+// ValidateEscalation checks that the escalation sets at least one limit and
+// that every limit it sets is higher than the current one. Lowering a limit
+// is a policy change, not an escalation.
+func ValidateEscalation(e *Escalation, current Constraints) error {
+	if e == nil || (*e == Escalation{}) {
+		return errors.New("at least one constraint must be escalated")
+	}
+	for _, f := range []struct {
+		name, value, current string
+	}{
+		{"maxValuePerTx", e.MaxValuePerTx, current.MaxValuePerTx},
+		{"maxDailyVolume", e.MaxDailyVolume, current.MaxDailyVolume},
+		{"maxWeeklyVolume", e.MaxWeeklyVolume, current.MaxWeeklyVolume},
+	} {
+		if f.value == "" {
+			continue
+		}
+		v, ok := new(big.Int).SetString(f.value, 10)
+		if !ok || v.Sign() < 0 {
+			return errors.New(f.name + " must be a valid non-negative integer")
+		}
+		if f.current == "" {
+			return errors.New(f.name + " is not limited by the policy")
+		}
+		if c, ok := new(big.Int).SetString(f.current, 10); ok && v.Cmp(c) <= 0 {
+			return errors.New(f.name + " must be higher than the current limit " + f.current)
+		}
+	}
+	if e.MaxTxCount < 0 {
+		return errors.New("maxTxCount must not be negative")
+	}
+	if e.MaxTxCount > 0 {
+		if current.MaxTxCount == 0 {
+			return errors.New("maxTxCount is not limited by the policy")
+		}
+		if e.MaxTxCount <= current.MaxTxCount {
+			return errors.New("maxTxCount must be higher than the current limit")
+		}
+	}
+	return nil
+}
+
+// MayDecideEscalation reports whether caller, a signed-in address, is the
+// escalation's approver. The approver need not own the wallet. API keys have
+// no address and never may.
+func MayDecideEscalation(approver, caller string) bool {
+	return caller != "" && strings.EqualFold(approver, caller)
+}
+
+// Apply returns c with the escalated limits.
+func (e *Escalation) Apply(c Constraints) Constraints {
+	if e == nil {
+		return c
+	}
+	if e.MaxValuePerTx != "" {
+		c.MaxValuePerTx = e.MaxValuePerTx
+	}
+	if e.MaxDailyVolume != "" {
+		c.MaxDailyVolume = e.MaxDailyVolume
+	}
+	if e.MaxWeeklyVolume != "" {
+		c.MaxWeeklyVolume = e.MaxWeeklyVolume
+	}
+	if e.MaxTxCount > 0 {
+		c.MaxTxCount = e.MaxTxCount
+	}
+	return c
+}
+
+// parseEscalation decodes the constraints column of an active escalation.
+// A NULL column (no escalation) yields nil.
+func parseEscalation(data []byte) *Escalation {
+	if len(data) == 0 {
+		return nil
+	}
+	var e Escalation
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil
+	}
+	return &e
+}
+
+// loadActiveEscalation returns the unexpired, approved escalation of a
+// permission, or nil.
+func loadActiveEscalation(ctx context.Context, db *pgxpool.Pool, permissionID uuid.UUID) (*Escalation, error) {
+	var data []byte
+	err := db.QueryRow(ctx,
+		`SELECT constraints FROM escalations
+		 WHERE permission_id = $1 AND status = 'active' AND expires_at > NOW()`,
+		permissionID,
+	).Scan(&data)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseEscalation(data), nil
+}
+
+// EscalationExpirer ends escalations whose expiry has passed and re-syncs the
+// original constraints on-chain. Off-chain validation ignores expired
+// escalations on its own; this job reverts the enforcer and records it.
+type EscalationExpirer struct {
+	db          *pgxpool.Pool
+	syncer      *OnchainSyncer
+	auditLogger *audit.Logger
+	logger      zerolog.Logger
+	interval    time.Duration
+}
+
+func NewEscalationExpirer(db *pgxpool.Pool, syncer *OnchainSyncer, auditLogger *audit.Logger, logger zerolog.Logger, interval time.Duration) *EscalationExpirer {
+	return &EscalationExpirer{
+		db:          db,
+		syncer:      syncer,
+		auditLogger: auditLogger,
+		logger:      logger,
+		interval:    interval,
+	}
+}
+
+// Start launches the expiry loop in the background.
+func (x *EscalationExpirer) Start(ctx context.Context) {
+	if x.interval <= 0 {
+		return
+	}
+	go x.run(ctx)
+}
+
+func (x *EscalationExpirer) run(ctx context.Context) {
+	ticker := time.NewTicker(x.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := x.expire(ctx); err != nil {
+				x.logger.Error().Err(err).Msg("escalations: expiry error")
+			}
+		}
+	}
+}
+
+func (x *EscalationExpirer) expire(ctx context.Context) error {
+	// Requests nobody approved in time never took effect; just close them.
+	if _, err := x.db.Exec(ctx,
+		`UPDATE escalations SET status = 'expired', ended_at = NOW()
+		 WHERE status = 'pending' AND expires_at <= NOW()`,
+	); err != nil {
+		return err
+	}
+
+	rows, err := x.db.Query(ctx,
+		`UPDATE escalations e SET status = 'expired', ended_at = NOW()
+		 FROM permissions p
+		 WHERE p.id = e.permission_id AND e.status = 'active' AND e.expires_at <= NOW()
+		 RETURNING e.id, e.wallet_id, e.permission_id, p.agent_id, p.policy_id`,
+	)
+	if err != nil {
+		return err
+	}
+	type expired struct{ id, walletID, permID, agentID, policyID uuid.UUID }
+	var ended []expired
+	for rows.Next() {
+		var e expired
+		if err := rows.Scan(&e.id, &e.walletID, &e.permID, &e.agentID, &e.policyID); err == nil {
+			ended = append(ended, e)
+		}
+	}
+	rows.Close()
+
+	for _, e := range ended {
+		details := map[string]interface{}{"escalation_id": e.id}
+		if err := x.syncer.SyncConstraints(ctx, e.permID, e.agentID); err != nil {
+			details["onchain_sync_error"] = err.Error()
+			x.logger.Error().Err(err).Str("escalation_id", e.id.String()).Msg("escalations: failed to revert on-chain constraints")
+		}
+		x.auditLogger.Log(ctx, audit.Event{
+			WalletID:     e.walletID,
+			AgentID:      &e.agentID,
+			PolicyID:     &e.policyID,
+			PermissionID: &e.permID,
+			EventType:    "escalation.expired",
+			Details:      details,
+		})
+	}
+	return nil
+}
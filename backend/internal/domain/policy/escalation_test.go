@@ -0,0 +1,58 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateEscalation(t *testing.T) {
+	current := Constraints{MaxValuePerTx: "1000", MaxDailyVolume: "5000", MaxTxCount: 10}
+
+	if err := ValidateEscalation(&Escalation{}, current); err == nil {
+		t.Fatal("expected error for empty escalation")
+	}
+	if err := ValidateEscalation(&Escalation{MaxValuePerTx: "500"}, current); err == nil || !strings.Contains(err.Error(), "higher") {
+		t.Fatalf("expected lowering to be rejected, got %v", err)
+	}
+	if err := ValidateEscalation(&Escalation{MaxWeeklyVolume: "100000"}, current); err == nil {
+		t.Fatal("expected error for escalating an unlimited constraint")
+	}
+	if err := ValidateEscalation(&Escalation{MaxTxCount: 5}, current); err == nil {
+		t.Fatal("expected lower tx count to be rejected")
+	}
+	if err := ValidateEscalation(&Escalation{MaxValuePerTx: "abc"}, current); err == nil {
+		t.Fatal("expected invalid amount error")
+	}
+	if err := ValidateEscalation(&Escalation{MaxValuePerTx: "20000", MaxTxCount: 20}, current); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestEscalation_Apply(t *testing.T) {
+	current := Constraints{MaxValuePerTx: "1000", MaxDailyVolume: "5000", RequireApproval: true}
+
+	out := (&Escalation{MaxDailyVolume: "50000"}).Apply(current)
+	if out.MaxDailyVolume != "50000" || out.MaxValuePerTx != "1000" || !out.RequireApproval {
+		t.Fatalf("expected only daily volume raised, got %+v", out)
+	}
+
+	var none *Escalation
+	if none.Apply(current) != current {
+		t.Fatal("nil escalation must leave constraints unchanged")
+	}
+}
+
+func TestMayDecideEscalation(t *testing.T) {
+	approver := "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed"
+	owner := "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359"
+
+	if !MayDecideEscalation(approver, "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed") {
+		t.Fatal("expected a non-owner approver to decide, whatever the address case")
+	}
+	if MayDecideEscalation(approver, owner) {
+		t.Fatal("expected the owner to be refused when someone else approves")
+	}
+	if MayDecideEscalation(approver, "") {
+		t.Fatal("expected a caller without an address to be refused")
+	}
+}
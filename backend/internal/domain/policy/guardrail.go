@@ -0,0 +1,305 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/erc8004/policy-saas/pkg/policyeval"
+)
+
+// Guardrail is a wallet-wide boundary, similar to an AWS service control
+// policy. It never grants anything: every validation decision is intersected
+// with all active guardrails regardless of which permission matched.
+type Guardrail = policyeval.Guardrail
+
+// GuardrailUsage is the volume already consumed today, needed for daily caps.
+type GuardrailUsage = policyeval.GuardrailUsage
+
+// ValidateGuardrail checks a guardrail definition.
+func ValidateGuardrail(g *Guardrail) error {
+	if g == nil {
+		return errors.New("definition is required")
+	}
+	for _, list := range [][]string{g.Actions, g.DeniedActions} {
+		for _, action := range list {
+			if !ValidActions[strings.ToLower(action)] {
+				return errors.New("invalid action: " + action)
+			}
+		}
+	}
+	for name, v := range map[string]string{
+		"maxValuePerTx":          g.MaxValuePerTx,
+		"maxDailyVolumePerAgent": g.MaxDailyVolumePerAgent,
+		"maxDailyVolume":         g.MaxDailyVolume,
+	} {
+		if v == "" {
+			continue
+		}
+		if n, ok := new(big.Int).SetString(v, 10); !ok || n.Sign() < 0 {
+			return errors.New(name + " must be a valid non-negative integer")
+		}
+	}
+	return nil
+}
+
+// noneAction and noneAddress replace a list that the intersection emptied. An
+// empty list means "no restriction" on-chain, so an unsatisfiable ceiling is
+// encoded as a value no real action, token or protocol can match.
+const (
+	noneAction  = "none"
+	noneAddress = "0x0000000000000000000000000000000000000000"
+)
+
+// ApplyGuardrails returns def narrowed by the guardrails for on-chain sync,
+// together with notes about rules the on-chain enforcer cannot represent
+// (deny lists without an allowlist to subtract from, recipients and the
+// aggregate daily cap). Those rules are still enforced off-chain.
+func ApplyGuardrails(def Definition, guardrails []Guardrail) (Definition, []string) {
+	var notes []string
+	out := def
+	out.Actions = append([]string(nil), def.Actions...)
+	out.Assets.Tokens = append([]string(nil), def.Assets.Tokens...)
+	out.Assets.Protocols = append([]string(nil), def.Assets.Protocols...)
+	out.Assets.Chains = append([]int64(nil), def.Assets.Chains...)
+
+	for _, g := range guardrails {
+		// Actions: expand a wildcard so denials can be subtracted.
+		if len(g.Actions) > 0 && !contains(g.Actions, "*") {
+			out.Actions = intersectStrings(out.Actions, g.Actions, noneAction)
+		}
+		if len(g.DeniedActions) > 0 {
+			if len(out.Actions) == 0 || contains(out.Actions, "*") {
+				out.Actions = allActions()
+			}
+			out.Actions = subtractStrings(out.Actions, g.DeniedActions, noneAction)
+		}
+
+		if len(g.Assets.Tokens) > 0 && !contains(g.Assets.Tokens, "*") {
+			out.Assets.Tokens = intersectStrings(out.Assets.Tokens, g.Assets.Tokens, noneAddress)
+		}
+		if len(g.DeniedTokens) > 0 {
+			if len(out.Assets.Tokens) == 0 || contains(out.Assets.Tokens, "*") {
+				notes = append(notes, "deniedTokens cannot be enforced on-chain without a token allowlist")
+			} else {
+				out.Assets.Tokens = subtractStrings(out.Assets.Tokens, g.DeniedTokens, noneAddress)
+			}
+		}
+
+		if len(g.Assets.Protocols) > 0 && !contains(g.Assets.Protocols, "*") {
+			out.Assets.Protocols = intersectStrings(out.Assets.Protocols, g.Assets.Protocols, noneAddress)
+		}
+		if len(g.DeniedProtocols) > 0 {
+			if len(out.Assets.Protocols) == 0 || contains(out.Assets.Protocols, "*") {
+				notes = append(notes, "deniedProtocols cannot be enforced on-chain without a protocol allowlist")
+			} else {
+				out.Assets.Protocols = subtractStrings(out.Assets.Protocols, g.DeniedProtocols, noneAddress)
+			}
+		}
+
+		if len(g.Assets.Chains) > 0 {
+			if len(out.Assets.Chains) == 0 {
+				out.Assets.Chains = append([]int64(nil), g.Assets.Chains...)
+			} else {
+				var chains []int64
+				for _, c := range out.Assets.Chains {
+					for _, allowed := range g.Assets.Chains {
+						if c == allowed {
+							chains = append(chains, c)
+							break
+						}
+					}
+				}
+				if len(chains) == 0 {
+					chains = []int64{0}
+				}
+				out.Assets.Chains = chains
+			}
+		}
+
+		out.Constraints.MaxValuePerTx = minAmount(out.Constraints.MaxValuePerTx, g.MaxValuePerTx)
+		out.Constraints.MaxDailyVolume = minAmount(out.Constraints.MaxDailyVolume, g.MaxDailyVolumePerAgent)
+
+		if len(g.DeniedRecipients) > 0 {
+			notes = append(notes, "deniedRecipients are enforced off-chain only")
+		}
+		if g.MaxDailyVolume != "" {
+			notes = append(notes, "aggregate maxDailyVolume is enforced off-chain only")
+		}
+	}
+
+	return out, notes
+}
+
+func exceeds(amount, used *big.Int, limit string) bool {
+	if limit == "" {
+		return false
+	}
+	max, ok := new(big.Int).SetString(limit, 10)
+	if !ok {
+		return false
+	}
+	total := new(big.Int).Set(amount)
+	if used != nil {
+		total.Add(total, used)
+	}
+	return total.Cmp(max) > 0
+}
+
+func minAmount(a, b string) string {
+	if a == "" {
+		return b
+	}
+	if b == "" {
+		return a
+	}
+	ai, okA := new(big.Int).SetString(a, 10)
+	bi, okB := new(big.Int).SetString(b, 10)
+	if !okA || !okB {
+		return a
+	}
+	if bi.Cmp(ai) < 0 {
+		return b
+	}
+	return a
+}
+
+// intersectStrings narrows current to ceiling. An empty or wildcard current
+// list means "anything", so the ceiling itself becomes the list.
+func intersectStrings(current, ceiling []string, none string) []string {
+	if len(current) == 0 || contains(current, "*") {
+		return append([]string(nil), ceiling...)
+	}
+	var out []string
+	for _, item := range current {
+		if containsFold(ceiling, item) {
+			out = append(out, item)
+		}
+	}
+	if len(out) == 0 {
+		return []string{none}
+	}
+	return out
+}
+
+func subtractStrings(current, denied []string, none string) []string {
+	var out []string
+	for _, item := range current {
+		if !containsFold(denied, item) {
+			out = append(out, item)
+		}
+	}
+	if len(out) == 0 {
+		return []string{none}
+	}
+	return out
+}
+
+func allActions() []string {
+	var actions []string
+	for a := range ValidActions {
+		if a != "*" {
+			actions = append(actions, a)
+		}
+	}
+	sort.Strings(actions)
+	return actions
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// activeGuardrail is a guardrail loaded from the database.
+type activeGuardrail struct {
+	ID   uuid.UUID
+	Name string
+	Guardrail
+}
+
+func loadGuardrails(ctx context.Context, db *pgxpool.Pool, walletID uuid.UUID) ([]activeGuardrail, error) {
+	rows, err := db.Query(ctx,
+		`SELECT id, name, definition FROM guardrails WHERE wallet_id = $1 AND status = 'active' ORDER BY created_at`,
+		walletID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var guardrails []activeGuardrail
+	for rows.Next() {
+		var g activeGuardrail
+		var defBytes []byte
+		if err := rows.Scan(&g.ID, &g.Name, &defBytes); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(defBytes, &g.Guardrail); err != nil {
+			return nil, err
+		}
+		guardrails = append(guardrails, g)
+	}
+	return guardrails, rows.Err()
+}
+
+// checkGuardrails returns the reason the action is blocked by one of the
+// wallet's guardrails, or "" if it is within all of them.
+func (e *Engine) checkGuardrails(ctx context.Context, walletID, agentID uuid.UUID, action *Action) (string, error) {
+	guardrails, err := loadGuardrails(ctx, e.db, walletID)
+	if err != nil {
+		return "", err
+	}
+
+	var usage GuardrailUsage
+	for _, g := range guardrails {
+		if g.NeedsUsage() && usage.AgentDaily == nil {
+			usage.AgentDaily = e.getDailyUsage(ctx, walletID, agentID)
+			usage.WalletDaily = e.getWalletDailyUsage(ctx, walletID)
+		}
+		if reason := g.Check(action, usage); reason != "" {
+			return "blocked by guardrail " + g.Name + ": " + reason, nil
+		}
+	}
+	return "", nil
+}
+
+// getWalletDailyUsage calculates today's volume across all agents of a wallet
+func (e *Engine) getWalletDailyUsage(ctx context.Context, walletID uuid.UUID) *big.Int {
+	var totalStr string
+	err := e.db.QueryRow(ctx,
+		`SELECT COALESCE(SUM((action_data->>'amount')::numeric), 0)::text
+		 FROM validation_requests
+		 WHERE wallet_id = $1 AND allowed = true
+		 AND created_at >= CURRENT_DATE`,
+		walletID,
+	).Scan(&totalStr)
+	if err != nil {
+		return big.NewInt(0)
+	}
+
+	total, _ := new(big.Int).SetString(totalStr, 10)
+	if total == nil {
+		return big.NewInt(0)
+	}
+	return total
+}
@@ -0,0 +1,138 @@
+package policy
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestGuardrail_DeniedAction(t *testing.T) {
+	g := &Guardrail{DeniedActions: []string{"bridge"}}
+
+	if reason := g.Check(&Action{Type: "bridge"}, GuardrailUsage{}); reason == "" {
+		t.Fatal("expected bridge to be denied")
+	}
+	if reason := g.Check(&Action{Type: "swap"}, GuardrailUsage{}); reason != "" {
+		t.Fatalf("expected swap to pass, got %q", reason)
+	}
+}
+
+func TestGuardrail_AssetCeilings(t *testing.T) {
+	g := &Guardrail{
+		Assets:       Assets{Tokens: []string{"0xUSDC", "0xWETH"}, Chains: []int64{1}},
+		DeniedTokens: []string{"0xWETH"},
+	}
+
+	if reason := g.Check(&Action{Type: "swap", Token: "0xusdc", Chain: 1}, GuardrailUsage{}); reason != "" {
+		t.Fatalf("expected USDC on chain 1 to pass, got %q", reason)
+	}
+	if reason := g.Check(&Action{Type: "swap", Token: "0xDAI", Chain: 1}, GuardrailUsage{}); reason == "" {
+		t.Fatal("expected token outside ceiling to be blocked")
+	}
+	if reason := g.Check(&Action{Type: "swap", Token: "0xWETH", Chain: 1}, GuardrailUsage{}); reason == "" {
+		t.Fatal("expected denied token to be blocked")
+	}
+	if reason := g.Check(&Action{Type: "swap", Token: "0xusdc", Chain: 10}, GuardrailUsage{}); reason == "" {
+		t.Fatal("expected chain outside ceiling to be blocked")
+	}
+}
+
+func TestGuardrail_MissingRestrictedFields(t *testing.T) {
+	g := &Guardrail{DeniedTokens: []string{"0xWETH"}, Assets: Assets{Chains: []int64{1}}}
+
+	if reason := g.Check(&Action{Type: "swap"}, GuardrailUsage{}); reason != "action must specify token, chain" {
+		t.Fatalf("expected an action omitting restricted fields to be denied, got %q", reason)
+	}
+	if reason := g.Check(&Action{Type: "swap", Token: "0xusdc", Chain: 1}, GuardrailUsage{}); reason != "" {
+		t.Fatalf("expected an action naming allowed values to pass, got %q", reason)
+	}
+	if reason := (&Guardrail{DeniedActions: []string{"bridge"}}).Check(&Action{Type: "swap"}, GuardrailUsage{}); reason != "" {
+		t.Fatalf("expected a guardrail without asset rules to need no fields, got %q", reason)
+	}
+
+	denied := &Guardrail{DeniedRecipients: []string{"0xBAD"}}
+	if reason := denied.Check(&Action{Type: "transfer"}, GuardrailUsage{}); reason != "action must specify to" {
+		t.Fatalf("expected an action omitting the recipient to be denied, got %q", reason)
+	}
+	if reason := denied.Check(&Action{Type: "transfer", To: "0xgood"}, GuardrailUsage{}); reason != "" {
+		t.Fatalf("expected another recipient to pass, got %q", reason)
+	}
+}
+
+func TestGuardrail_AggregateDailyVolume(t *testing.T) {
+	g := &Guardrail{MaxDailyVolume: "1000", MaxDailyVolumePerAgent: "600"}
+	usage := GuardrailUsage{AgentDaily: big.NewInt(100), WalletDaily: big.NewInt(900)}
+
+	reason := g.Check(&Action{Type: "transfer", Amount: "200"}, usage)
+	if !strings.Contains(reason, "aggregate") {
+		t.Fatalf("expected aggregate limit violation, got %q", reason)
+	}
+
+	reason = g.Check(&Action{Type: "transfer", Amount: "550"}, GuardrailUsage{AgentDaily: big.NewInt(100), WalletDaily: big.NewInt(100)})
+	if !strings.Contains(reason, "agent daily") {
+		t.Fatalf("expected per-agent limit violation, got %q", reason)
+	}
+
+	if reason := g.Check(&Action{Type: "transfer", Amount: "50"}, usage); reason != "" {
+		t.Fatalf("expected small transfer to pass, got %q", reason)
+	}
+}
+
+func TestApplyGuardrails_NarrowsDefinition(t *testing.T) {
+	def := Definition{
+		Actions:     []string{"*"},
+		Assets:      Assets{Tokens: []string{"0xaaa", "0xbbb"}},
+		Constraints: Constraints{MaxValuePerTx: "5000"},
+	}
+	g := Guardrail{
+		DeniedActions:          []string{"bridge"},
+		Assets:                 Assets{Tokens: []string{"0xBBB", "0xccc"}},
+		MaxValuePerTx:          "1000",
+		MaxDailyVolumePerAgent: "3000",
+		MaxDailyVolume:         "10000",
+	}
+
+	out, notes := ApplyGuardrails(def, []Guardrail{g})
+
+	if contains(out.Actions, "bridge") || contains(out.Actions, "*") || !contains(out.Actions, "swap") {
+		t.Fatalf("expected wildcard expanded without bridge, got %v", out.Actions)
+	}
+	if len(out.Assets.Tokens) != 1 || out.Assets.Tokens[0] != "0xbbb" {
+		t.Fatalf("expected token intersection [0xbbb], got %v", out.Assets.Tokens)
+	}
+	if out.Constraints.MaxValuePerTx != "1000" || out.Constraints.MaxDailyVolume != "3000" {
+		t.Fatalf("expected tightest limits, got %+v", out.Constraints)
+	}
+	if len(notes) != 1 {
+		t.Fatalf("expected aggregate cap note, got %v", notes)
+	}
+	if def.Actions[0] != "*" || len(def.Assets.Tokens) != 2 {
+		t.Fatal("input definition must not be modified")
+	}
+}
+
+func TestApplyGuardrails_EmptyIntersectionBlocks(t *testing.T) {
+	def := Definition{Actions: []string{"swap"}, Assets: Assets{Tokens: []string{"0xaaa"}}}
+	g := Guardrail{Actions: []string{"transfer"}, Assets: Assets{Tokens: []string{"0xbbb"}}}
+
+	out, _ := ApplyGuardrails(def, []Guardrail{g})
+
+	if len(out.Actions) != 1 || out.Actions[0] != noneAction {
+		t.Fatalf("expected unsatisfiable actions sentinel, got %v", out.Actions)
+	}
+	if len(out.Assets.Tokens) != 1 || out.Assets.Tokens[0] != noneAddress {
+		t.Fatalf("expected unsatisfiable tokens sentinel, got %v", out.Assets.Tokens)
+	}
+}
+
+func TestValidateGuardrail(t *testing.T) {
+	if err := ValidateGuardrail(&Guardrail{DeniedActions: []string{"teleport"}}); err == nil {
+		t.Fatal("expected invalid action error")
+	}
+	if err := ValidateGuardrail(&Guardrail{MaxDailyVolume: "-1"}); err == nil {
+		t.Fatal("expected invalid amount error")
+	}
+	if err := ValidateGuardrail(&Guardrail{DeniedActions: []string{"bridge"}, MaxDailyVolume: "100"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package policy
+
+import "encoding/json"
+
+// LibraryTemplate is a built-in starter template. Key is the stable identifier
+// used to copy it into a wallet.
+type LibraryTemplate struct {
+	Key  string `json:"key"`
+	Role string `json:"role"`
+	Template
+}
+
+// StarterTemplates returns the built-in template library covering common agent
+// roles. Each call returns fresh copies that callers may modify.
+func StarterTemplates() []LibraryTemplate {
+	return []LibraryTemplate{
+		{
+			Key:  "dex-trader",
+			Role: "Trading",
+			Template: Template{
+				Name:        "DEX trader",
+				Description: "Swap up to a per-transaction amount of the given tokens on the given protocols",
+				Parameters: []TemplateParam{
+					{Name: "tokens", Type: ParamAddressList, Description: "Tokens the agent may swap", Required: true},
+					{Name: "protocols", Type: ParamStringList, Description: "DEX protocols, e.g. uniswap-v3", Required: true},
+					{Name: "max_per_tx", Type: ParamAmount, Description: "Maximum amount per swap", Required: true},
+					{Name: "max_daily", Type: ParamAmount, Description: "Maximum daily volume"},
+					{Name: "chains", Type: ParamChainList, Description: "Allowed chain IDs"},
+				},
+				Definition: json.RawMessage(`{
+					"actions": ["swap"],
+					"assets": {"tokens": "{{tokens}}", "protocols": "{{protocols}}", "chains": "{{chains}}"},
+					"constraints": {"maxValuePerTx": "{{max_per_tx}}", "maxDailyVolume": "{{max_daily}}"}
+				}`),
+			},
+		},
+		{
+			Key:  "payments",
+			Role: "Payments",
+			Template: Template{
+				Name:        "Payments agent",
+				Description: "Transfer a token to an allowlist of recipients within per-transaction and daily limits",
+				Parameters: []TemplateParam{
+					{Name: "token", Type: ParamAddress, Description: "Token used for payments", Required: true},
+					{Name: "recipients", Type: ParamAddressList, Description: "Allowed recipient addresses", Required: true},
+					{Name: "max_per_tx", Type: ParamAmount, Description: "Maximum amount per payment", Required: true},
+					{Name: "max_daily", Type: ParamAmount, Description: "Maximum daily volume", Required: true},
+				},
+				Definition: json.RawMessage(`{
+					"actions": ["transfer"],
+					"assets": {"tokens": ["{{token}}"]},
+					"constraints": {"maxValuePerTx": "{{max_per_tx}}", "maxDailyVolume": "{{max_daily}}"},
+					"conditions": [{"field": "to", "operator": "in", "value": "{{recipients}}"}]
+				}`),
+			},
+		},
+		{
+			Key:  "yield-farmer",
+			Role: "Yield",
+			Template: Template{
+				Name:        "Yield farmer",
+				Description: "Deposit, withdraw, stake and claim rewards on approved protocols",
+				Parameters: []TemplateParam{
+					{Name: "protocols", Type: ParamStringList, Description: "Approved yield protocols", Required: true},
+					{Name: "tokens", Type: ParamAddressList, Description: "Tokens the agent may deploy"},
+					{Name: "max_per_tx", Type: ParamAmount, Description: "Maximum amount per transaction", Required: true},
+				},
+				Definition: json.RawMessage(`{
+					"actions": ["deposit", "withdraw", "stake", "unstake", "claim"],
+					"assets": {"tokens": "{{tokens}}", "protocols": "{{protocols}}"},
+					"constraints": {"maxValuePerTx": "{{max_per_tx}}"}
+				}`),
+			},
+		},
+		{
+			Key:  "treasury-rebalancer",
+			Role: "Treasury",
+			Template: Template{
+				Name:        "Treasury rebalancer",
+				Description: "Swap and transfer treasury assets; every action requires approval by default",
+				Parameters: []TemplateParam{
+					{Name: "tokens", Type: ParamAddressList, Description: "Treasury tokens", Required: true},
+					{Name: "max_per_tx", Type: ParamAmount, Description: "Maximum amount per transaction", Required: true},
+					{Name: "max_weekly", Type: ParamAmount, Description: "Maximum weekly volume"},
+					{Name: "require_approval", Type: ParamBoolean, Description: "Require owner approval", Default: true},
+				},
+				Definition: json.RawMessage(`{
+					"actions": ["swap", "transfer"],
+					"assets": {"tokens": "{{tokens}}"},
+					"constraints": {"maxValuePerTx": "{{max_per_tx}}", "maxWeeklyVolume": "{{max_weekly}}", "requireApproval": "{{require_approval}}"}
+				}`),
+			},
+		},
+		{
+			Key:  "governance-voter",
+			Role: "Governance",
+			Template: Template{
+				Name:        "Governance voter",
+				Description: "Vote and delegate on the given governance protocols; no value transfer",
+				Parameters: []TemplateParam{
+					{Name: "protocols", Type: ParamStringList, Description: "Governance protocols", Required: true},
+				},
+				Definition: json.RawMessage(`{
+					"actions": ["vote", "delegate"],
+					"assets": {"protocols": "{{protocols}}"},
+					"constraints": {"maxValuePerTx": "0"}
+				}`),
+			},
+		},
+		{
+			Key:  "rewards-claimer",
+			Role: "Operations",
+			Template: Template{
+				Name:        "Rewards claimer",
+				Description: "Claim rewards only, capped at a number of claims",
+				Parameters: []TemplateParam{
+					{Name: "protocols", Type: ParamStringList, Description: "Protocols to claim from", Required: true},
+					{Name: "max_tx_count", Type: ParamInteger, Description: "Maximum number of claims", Default: float64(100)},
+				},
+				Definition: json.RawMessage(`{
+					"actions": ["claim"],
+					"assets": {"protocols": "{{protocols}}"},
+					"constraints": {"maxTxCount": "{{max_tx_count}}"}
+				}`),
+			},
+		},
+	}
+}
+
+// StarterTemplate returns the built-in template with the given key.
+func StarterTemplate(key string) (LibraryTemplate, bool) {
+	for _, t := range StarterTemplates() {
+		if t.Key == key {
+			return t, true
+		}
+	}
+	return LibraryTemplate{}, false
+}
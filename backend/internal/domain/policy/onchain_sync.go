@@ -71,11 +71,13 @@ func (s *OnchainSyncer) SyncConstraints(ctx context.Context, permissionID uuid.U
 
 	// Get the policy definition for this permission
 	var definitionJSON []byte
+	var walletID uuid.UUID
+	var scope *SessionScope
 	err = s.db.QueryRow(ctx,
-		`SELECT p.definition FROM policies p
+		`SELECT p.definition, perm.wallet_id, perm.session_scope FROM policies p
 		 JOIN permissions perm ON perm.policy_id = p.id
 		 WHERE perm.id = $1`, permissionID,
-	).Scan(&definitionJSON)
+	).Scan(&definitionJSON, &walletID, &scope)
 	if err != nil {
 		s.logger.Error().Err(err).Str("permission_id", permissionID.String()).Msg("failed to get policy definition for sync")
 		return err
@@ -88,6 +90,38 @@ func (s *OnchainSyncer) SyncConstraints(ctx context.Context, permissionID uuid.U
 		return err
 	}
 
+	// An active break-glass escalation raises the limits until it ends; syncing
+	// again afterwards reverts them.
+	escalation, err := loadActiveEscalation(ctx, s.db, permissionID)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("failed to load escalation for sync")
+		return err
+	}
+	def.Constraints = escalation.Apply(def.Constraints)
+
+	// A task session enforces its narrower lists and its budget as the
+	// per-transaction and daily cap
+	def = scope.Apply(def)
+
+	// Fold the wallet's guardrails into the constraints where the enforcer can
+	// represent them; the rest stays enforced off-chain by Engine.Validate.
+	guardrails, err := loadGuardrails(ctx, s.db, walletID)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("failed to load guardrails for sync")
+		return err
+	}
+	if len(guardrails) > 0 {
+		bounds := make([]Guardrail, 0, len(guardrails))
+		for _, g := range guardrails {
+			bounds = append(bounds, g.Guardrail)
+		}
+		var notes []string
+		def, notes = ApplyGuardrails(def, bounds)
+		for _, note := range notes {
+			s.logger.Debug().Str("permission_id", permissionID.String()).Msg(note)
+		}
+	}
+
 	// Build constraint parameters
 	syncData := buildSyncData(&def)
 	permIDBytes := blockchain.UUIDToBytes32(permissionID.String())
@@ -0,0 +1,253 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Observation sources.
+const (
+	ObservedValidation = "validation"
+	ObservedOnchain    = "onchain"
+)
+
+// Observation is one action an agent actually performed.
+type Observation struct {
+	Action Action
+	Source string
+	At     time.Time
+}
+
+// RecommendOptions tune how limits are derived from observed amounts.
+type RecommendOptions struct {
+	// Percentile of observed amounts used as the base of a limit (1-100).
+	Percentile int
+	// HeadroomPercent is added on top of the percentile value.
+	HeadroomPercent int
+}
+
+// DefaultRecommendOptions takes the p95 with 20% headroom.
+var DefaultRecommendOptions = RecommendOptions{Percentile: 95, HeadroomPercent: 20}
+
+// ObservedSummary describes the usage a recommendation is based on.
+type ObservedSummary struct {
+	Validations     int      `json:"validations"`
+	Executions      int      `json:"onchainExecutions"`
+	Actions         []string `json:"actions"`
+	PerTxPercentile string   `json:"perTxPercentile,omitempty"`
+	DailyPercentile string   `json:"dailyPercentile,omitempty"`
+}
+
+// Recommendation is a least-privilege definition derived from usage.
+type Recommendation struct {
+	Definition Definition      `json:"definition"`
+	Observed   ObservedSummary `json:"observed"`
+	Notes      []string        `json:"notes,omitempty"`
+}
+
+// Recommend tightens current to what the observations show is actually
+// used. It never loosens: every list is intersected with the current one and
+// every limit is capped by the current value. Dimensions without any
+// observations are left as they are.
+func Recommend(current Definition, observations []Observation, opts RecommendOptions) Recommendation {
+	if opts.Percentile <= 0 || opts.Percentile > 100 {
+		opts.Percentile = DefaultRecommendOptions.Percentile
+	}
+	if opts.HeadroomPercent < 0 {
+		opts.HeadroomPercent = DefaultRecommendOptions.HeadroomPercent
+	}
+
+	rec := Recommendation{Definition: current, Observed: ObservedSummary{Actions: []string{}}}
+	rec.Definition.Actions = append([]string(nil), current.Actions...)
+	rec.Definition.Assets.Tokens = append([]string(nil), current.Assets.Tokens...)
+	rec.Definition.Assets.Protocols = append([]string(nil), current.Assets.Protocols...)
+	rec.Definition.Assets.Chains = append([]int64(nil), current.Assets.Chains...)
+
+	var actions, tokens, protocols []string
+	chains := map[int64]bool{}
+	var perTx []*big.Int
+	daily := map[string]*big.Int{}
+	weekly := map[string]*big.Int{}
+
+	for _, o := range observations {
+		switch o.Source {
+		case ObservedOnchain:
+			rec.Observed.Executions++
+		default:
+			rec.Observed.Validations++
+		}
+
+		a := o.Action
+		if t := strings.ToLower(a.Type); ValidActions[t] && t != "*" {
+			actions = appendUnique(actions, t)
+		}
+		if a.Token != "" {
+			tokens = appendUnique(tokens, strings.ToLower(a.Token))
+		}
+		if a.Protocol != "" {
+			protocols = appendUnique(protocols, strings.ToLower(a.Protocol))
+		}
+		if a.Chain != 0 {
+			chains[a.Chain] = true
+		}
+		if amount, ok := new(big.Int).SetString(a.Amount, 10); ok && amount.Sign() > 0 {
+			perTx = append(perTx, amount)
+			day := o.At.UTC().Format("2006-01-02")
+			year, week := o.At.UTC().ISOWeek()
+			addTo(daily, day, amount)
+			addTo(weekly, fmt.Sprintf("%d-%02d", year, week), amount)
+		}
+	}
+
+	if len(observations) == 0 {
+		rec.Notes = append(rec.Notes, "no usage observed in the period; nothing to tighten")
+		return rec
+	}
+
+	sort.Strings(actions)
+	rec.Observed.Actions = actions
+	rec.Definition.Actions = narrow(rec.Definition.Actions, actions, "actions", &rec.Notes)
+	rec.Definition.Assets.Tokens = narrow(rec.Definition.Assets.Tokens, tokens, "tokens", &rec.Notes)
+	rec.Definition.Assets.Protocols = narrow(rec.Definition.Assets.Protocols, protocols, "protocols", &rec.Notes)
+
+	if len(chains) > 0 {
+		var used []int64
+		for c := range chains {
+			if len(current.Assets.Chains) == 0 || containsChain(current.Assets.Chains, c) {
+				used = append(used, c)
+			}
+		}
+		sort.Slice(used, func(i, j int) bool { return used[i] < used[j] })
+		if len(used) > 0 {
+			rec.Definition.Assets.Chains = used
+		}
+	}
+
+	if len(perTx) > 0 {
+		p := percentile(perTx, opts.Percentile)
+		rec.Observed.PerTxPercentile = p.String()
+		rec.Definition.Constraints.MaxValuePerTx = minAmount(current.Constraints.MaxValuePerTx, withHeadroom(p, opts.HeadroomPercent))
+
+		d := percentile(values(daily), opts.Percentile)
+		rec.Observed.DailyPercentile = d.String()
+		rec.Definition.Constraints.MaxDailyVolume = minAmount(current.Constraints.MaxDailyVolume, withHeadroom(d, opts.HeadroomPercent))
+
+		if current.Constraints.MaxWeeklyVolume != "" {
+			w := percentile(values(weekly), opts.Percentile)
+			rec.Definition.Constraints.MaxWeeklyVolume = minAmount(current.Constraints.MaxWeeklyVolume, withHeadroom(w, opts.HeadroomPercent))
+		}
+	} else {
+		rec.Notes = append(rec.Notes, "no amounts observed; limits left unchanged")
+	}
+
+	return rec
+}
+
+// narrow replaces current with the used values it allows. An empty or
+// wildcard current list allows everything used.
+func narrow(current, used []string, name string, notes *[]string) []string {
+	if len(used) == 0 {
+		return current
+	}
+	var out []string
+	for _, u := range used {
+		if len(current) == 0 || contains(current, "*") || containsFold(current, u) {
+			out = append(out, u)
+		}
+	}
+	if len(out) == 0 {
+		*notes = append(*notes, "observed "+name+" are outside the current policy; left unchanged")
+		return current
+	}
+	sort.Strings(out)
+	return out
+}
+
+func appendUnique(list []string, s string) []string {
+	if contains(list, s) {
+		return list
+	}
+	return append(list, s)
+}
+
+func containsChain(chains []int64, c int64) bool {
+	for _, chain := range chains {
+		if chain == c {
+			return true
+		}
+	}
+	return false
+}
+
+func addTo(totals map[string]*big.Int, key string, amount *big.Int) {
+	if totals[key] == nil {
+		totals[key] = new(big.Int)
+	}
+	totals[key].Add(totals[key], amount)
+}
+
+func values(totals map[string]*big.Int) []*big.Int {
+	out := make([]*big.Int, 0, len(totals))
+	for _, v := range totals {
+		out = append(out, v)
+	}
+	return out
+}
+
+// percentile returns the nearest-rank percentile of amounts.
+func percentile(amounts []*big.Int, p int) *big.Int {
+	sorted := append([]*big.Int(nil), amounts...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Cmp(sorted[j]) < 0 })
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func withHeadroom(amount *big.Int, headroomPercent int) string {
+	out := new(big.Int).Mul(amount, big.NewInt(int64(100+headroomPercent)))
+	out.Add(out, big.NewInt(99))
+	return out.Div(out, big.NewInt(100)).String()
+}
+
+// ObservedActions loads the actions an agent performed since the given time:
+// allowed validation requests (optionally only those matched by policyID) and
+// on-chain executions recorded by the indexer.
+func (e *Engine) ObservedActions(ctx context.Context, walletID, agentID uuid.UUID, policyID *uuid.UUID, since time.Time) ([]Observation, error) {
+	rows, err := e.db.Query(ctx,
+		`SELECT 'validation', action_data, created_at FROM validation_requests
+		 WHERE wallet_id = $1 AND agent_id = $2 AND allowed = true AND created_at >= $3
+		 AND ($4::uuid IS NULL OR policy_id = $4)
+		 UNION ALL
+		 SELECT 'onchain', details->'action', created_at FROM audit_logs
+		 WHERE wallet_id = $1 AND agent_id = $2 AND event_type = 'onchain.executed'
+		 AND details ? 'action' AND created_at >= $3`,
+		walletID, agentID, since, policyID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var observations []Observation
+	for rows.Next() {
+		var o Observation
+		var data []byte
+		if err := rows.Scan(&o.Source, &data, &o.At); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &o.Action); err != nil {
+			continue
+		}
+		observations = append(observations, o)
+	}
+	return observations, rows.Err()
+}
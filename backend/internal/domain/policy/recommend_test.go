@@ -0,0 +1,87 @@
+package policy
+
+import (
+	"testing"
+	"time"
+)
+
+func observe(at time.Time, source string, action Action) Observation {
+	return Observation{Action: action, Source: source, At: at}
+}
+
+func TestRecommend_NarrowsToObservedUsage(t *testing.T) {
+	current := Definition{
+		Actions:     []string{"*"},
+		Assets:      Assets{Chains: []int64{1, 8453}},
+		Constraints: Constraints{MaxValuePerTx: "1000000", MaxDailyVolume: "5000000", RequireApproval: true},
+	}
+	day1 := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	obs := []Observation{
+		observe(day1, ObservedValidation, Action{Type: "swap", Token: "0xAAA", Protocol: "uniswap", Amount: "100", Chain: 8453}),
+		observe(day1, ObservedValidation, Action{Type: "swap", Token: "0xaaa", Protocol: "uniswap", Amount: "300", Chain: 8453}),
+		observe(day2, ObservedOnchain, Action{Type: "transfer", Token: "0xbbb", Amount: "200"}),
+	}
+
+	rec := Recommend(current, obs, DefaultRecommendOptions)
+	def := rec.Definition
+
+	if len(def.Actions) != 2 || def.Actions[0] != "swap" || def.Actions[1] != "transfer" {
+		t.Fatalf("expected [swap transfer], got %v", def.Actions)
+	}
+	if len(def.Assets.Tokens) != 2 || def.Assets.Tokens[0] != "0xaaa" {
+		t.Fatalf("expected observed tokens, got %v", def.Assets.Tokens)
+	}
+	if len(def.Assets.Chains) != 1 || def.Assets.Chains[0] != 8453 {
+		t.Fatalf("expected chain 8453 only, got %v", def.Assets.Chains)
+	}
+	// p95 of [100 200 300] is 300; +20% = 360
+	if def.Constraints.MaxValuePerTx != "360" {
+		t.Fatalf("expected per-tx limit 360, got %s", def.Constraints.MaxValuePerTx)
+	}
+	// daily totals [400 200]; p95 is 400; +20% = 480
+	if def.Constraints.MaxDailyVolume != "480" {
+		t.Fatalf("expected daily limit 480, got %s", def.Constraints.MaxDailyVolume)
+	}
+	if !def.Constraints.RequireApproval {
+		t.Fatal("approval requirement must be kept")
+	}
+	if rec.Observed.Validations != 2 || rec.Observed.Executions != 1 {
+		t.Fatalf("unexpected observation counts: %+v", rec.Observed)
+	}
+}
+
+func TestRecommend_NeverLoosens(t *testing.T) {
+	current := Definition{
+		Actions:     []string{"swap"},
+		Assets:      Assets{Tokens: []string{"0xaaa"}},
+		Constraints: Constraints{MaxValuePerTx: "50"},
+	}
+	obs := []Observation{
+		observe(time.Now(), ObservedOnchain, Action{Type: "transfer", Token: "0xccc", Amount: "1000"}),
+	}
+
+	rec := Recommend(current, obs, DefaultRecommendOptions)
+
+	if len(rec.Definition.Actions) != 1 || rec.Definition.Actions[0] != "swap" {
+		t.Fatalf("actions outside the policy must not be added, got %v", rec.Definition.Actions)
+	}
+	if len(rec.Definition.Assets.Tokens) != 1 || rec.Definition.Assets.Tokens[0] != "0xaaa" {
+		t.Fatalf("tokens outside the policy must not be added, got %v", rec.Definition.Assets.Tokens)
+	}
+	if rec.Definition.Constraints.MaxValuePerTx != "50" {
+		t.Fatalf("limit must not be raised, got %s", rec.Definition.Constraints.MaxValuePerTx)
+	}
+	if len(rec.Notes) != 2 {
+		t.Fatalf("expected notes for unchanged actions and tokens, got %v", rec.Notes)
+	}
+}
+
+func TestRecommend_NoObservations(t *testing.T) {
+	current := Definition{Actions: []string{"*"}}
+	rec := Recommend(current, nil, DefaultRecommendOptions)
+
+	if len(rec.Definition.Actions) != 1 || rec.Definition.Actions[0] != "*" || len(rec.Notes) != 1 {
+		t.Fatalf("expected unchanged definition with a note, got %+v", rec)
+	}
+}
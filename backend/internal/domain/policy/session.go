@@ -0,0 +1,158 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultSessionTTL is how long a task session lasts unless asked otherwise.
+	DefaultSessionTTL = 15 * time.Minute
+	// MaxSessionTTL bounds how long a task session may last.
+	MaxSessionTTL = time.Hour
+)
+
+// SessionScope narrows a permission for a single task session. Budget caps
+// the session's total spending; the optional lists replace the parent's and
+// must be subsets of them.
+type SessionScope struct {
+	Budget     string   `json:"budget"`
+	Actions    []string `json:"actions,omitempty"`
+	Tokens     []string `json:"tokens,omitempty"`
+	Protocols  []string `json:"protocols,omitempty"`
+	MaxTxCount int      `json:"maxTxCount,omitempty"`
+}
+
+// ValidateSessionScope checks that s sets a positive budget and narrows
+// parent rather than widening it.
+func ValidateSessionScope(s *SessionScope, parent *Definition) error {
+	budget, ok := new(big.Int).SetString(s.Budget, 10)
+	if !ok || budget.Sign() <= 0 {
+		return errors.New("budget must be a positive integer")
+	}
+	if contains(s.Actions, "*") {
+		return errors.New("actions: * cannot narrow a session")
+	}
+	if !contains(parent.Actions, "*") {
+		for _, a := range s.Actions {
+			if !containsFold(parent.Actions, a) {
+				return errors.New("actions: " + a + " is not allowed by the permission")
+			}
+		}
+	}
+	for _, l := range []struct {
+		name          string
+		scope, parent []string
+	}{
+		{"tokens", s.Tokens, parent.Assets.Tokens},
+		{"protocols", s.Protocols, parent.Assets.Protocols},
+	} {
+		if len(l.scope) == 0 {
+			continue
+		}
+		if err := attenuateList(l.name, l.scope, l.parent); err != nil {
+			return err
+		}
+	}
+	if s.MaxTxCount < 0 {
+		return errors.New("maxTxCount must not be negative")
+	}
+	if pc := parent.Constraints.MaxTxCount; pc > 0 && s.MaxTxCount > pc {
+		return errors.New("maxTxCount: must not exceed " + strconv.Itoa(pc))
+	}
+	return nil
+}
+
+// Apply returns def narrowed to the session: its lists replace def's where
+// set, and no single transaction or day may exceed the budget.
+func (s *SessionScope) Apply(def Definition) Definition {
+	if s == nil {
+		return def
+	}
+	if len(s.Actions) > 0 {
+		def.Actions = s.Actions
+	}
+	if len(s.Tokens) > 0 {
+		def.Assets.Tokens = s.Tokens
+	}
+	if len(s.Protocols) > 0 {
+		def.Assets.Protocols = s.Protocols
+	}
+	def.Constraints.MaxValuePerTx = minLimit(def.Constraints.MaxValuePerTx, s.Budget)
+	def.Constraints.MaxDailyVolume = minLimit(def.Constraints.MaxDailyVolume, s.Budget)
+	if s.MaxTxCount > 0 && (def.Constraints.MaxTxCount == 0 || s.MaxTxCount < def.Constraints.MaxTxCount) {
+		def.Constraints.MaxTxCount = s.MaxTxCount
+	}
+	return def
+}
+
+// minLimit returns the tighter of two decimal limits; an empty limit is unset.
+func minLimit(a, b string) string {
+	x, okA := new(big.Int).SetString(a, 10)
+	y, okB := new(big.Int).SetString(b, 10)
+	switch {
+	case !okB:
+		return a
+	case !okA || y.Cmp(x) < 0:
+		return b
+	default:
+		return a
+	}
+}
+
+// ValidateSession checks an action against a single task session only; the
+// session's agent is the one acting.
+func (e *Engine) ValidateSession(ctx context.Context, walletID, sessionID uuid.UUID, action Action) ValidationResult {
+	var agentID uuid.UUID
+	err := e.db.QueryRow(ctx,
+		`SELECT agent_id FROM permissions WHERE id = $1 AND wallet_id = $2 AND task_id IS NOT NULL`,
+		sessionID, walletID,
+	).Scan(&agentID)
+	if err != nil {
+		return ValidationResult{
+			Allowed: false,
+			Reason:  "session not found",
+		}
+	}
+	return e.validate(ctx, walletID, agentID, action, true, &sessionID)
+}
+
+// checkSessionLimits returns a denial reason if the action would take a
+// session past its budget or transaction count, counting everything it was
+// allowed so far.
+func (e *Engine) checkSessionLimits(ctx context.Context, sessionID uuid.UUID, scope *SessionScope, amount *big.Int) (string, error) {
+	budget, ok := new(big.Int).SetString(scope.Budget, 10)
+	if !ok {
+		return "session budget is invalid", nil
+	}
+	var spentStr string
+	var txCount int
+	err := e.db.QueryRow(ctx,
+		`SELECT COALESCE(SUM((action_data->>'amount')::numeric), 0)::text, COUNT(*)
+		 FROM validation_requests
+		 WHERE permission_id = $1 AND allowed = true`,
+		sessionID,
+	).Scan(&spentStr, &txCount)
+	if err != nil {
+		return "", err
+	}
+	if scope.MaxTxCount > 0 && txCount >= scope.MaxTxCount {
+		return "session transaction count exhausted (" + strconv.Itoa(txCount) + " of " + strconv.Itoa(scope.MaxTxCount) + ")", nil
+	}
+	if amount == nil || amount.Sign() <= 0 {
+		return "", nil
+	}
+	spent, ok := new(big.Int).SetString(spentStr, 10)
+	if !ok {
+		spent = big.NewInt(0)
+	}
+	if spent.Add(spent, amount).Cmp(budget) > 0 {
+		return "session budget exhausted (" + spent.Sub(spent, amount).String() + " of " + budget.String() + " spent)", nil
+	}
+	return "", nil
+}
@@ -0,0 +1,69 @@
+package policy
+
+import "testing"
+
+func TestValidateSessionScope(t *testing.T) {
+	parent := delegationParent()
+
+	ok := SessionScope{Budget: "500", Actions: []string{"SWAP"}, Tokens: []string{"usdc"}, MaxTxCount: 2}
+	if err := ValidateSessionScope(&ok, &parent); err != nil {
+		t.Fatalf("expected narrowing scope to pass, got %v", err)
+	}
+	if err := ValidateSessionScope(&SessionScope{Budget: "500"}, &parent); err != nil {
+		t.Fatalf("a budget alone should be enough, got %v", err)
+	}
+
+	cases := map[string]SessionScope{
+		"no budget":       {},
+		"zero budget":     {Budget: "0"},
+		"invalid budget":  {Budget: "1e18"},
+		"wider actions":   {Budget: "1", Actions: []string{"bridge"}},
+		"action wildcard": {Budget: "1", Actions: []string{"*"}},
+		"wider tokens":    {Budget: "1", Tokens: []string{"DAI"}},
+		"maxTxCount":      {Budget: "1", MaxTxCount: 11},
+	}
+	for name, s := range cases {
+		if err := ValidateSessionScope(&s, &parent); err == nil {
+			t.Errorf("%s: expected scope to be rejected", name)
+		}
+	}
+}
+
+func TestSessionScopeApply(t *testing.T) {
+	parent := delegationParent()
+
+	s := &SessionScope{Budget: "300", Tokens: []string{"USDC"}, MaxTxCount: 2}
+	got := s.Apply(parent)
+	if got.Constraints.MaxValuePerTx != "300" || got.Constraints.MaxDailyVolume != "300" {
+		t.Errorf("expected limits capped at the budget, got %+v", got.Constraints)
+	}
+	if got.Constraints.MaxTxCount != 2 {
+		t.Errorf("expected maxTxCount 2, got %d", got.Constraints.MaxTxCount)
+	}
+	if len(got.Assets.Tokens) != 1 || got.Assets.Tokens[0] != "USDC" {
+		t.Errorf("expected tokens narrowed to USDC, got %v", got.Assets.Tokens)
+	}
+	if len(got.Actions) != 2 {
+		t.Errorf("expected actions kept, got %v", got.Actions)
+	}
+	if len(parent.Assets.Tokens) != 2 {
+		t.Errorf("parent definition was modified: %v", parent.Assets.Tokens)
+	}
+
+	// A budget above the parent's limits leaves them as they are
+	got = (&SessionScope{Budget: "99999"}).Apply(parent)
+	if got.Constraints.MaxValuePerTx != "1000" || got.Constraints.MaxDailyVolume != "5000" {
+		t.Errorf("expected parent limits kept, got %+v", got.Constraints)
+	}
+
+	// An unset limit is bounded by the budget
+	parent.Constraints.MaxValuePerTx = ""
+	if got := s.Apply(parent); got.Constraints.MaxValuePerTx != "300" {
+		t.Errorf("expected unset limit to become the budget, got %q", got.Constraints.MaxValuePerTx)
+	}
+
+	var none *SessionScope
+	if got := none.Apply(parent); got.Constraints.MaxDailyVolume != "5000" {
+		t.Errorf("nil scope should not change the definition")
+	}
+}
@@ -0,0 +1,343 @@
+package policy
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+
+	"github.com/erc8004/policy-saas/internal/blockchain"
+	"github.com/erc8004/policy-saas/internal/domain/audit"
+)
+
+// MaxExpiryWarningDays bounds how far ahead an expiry warning may be sent.
+const MaxExpiryWarningDays = 365
+
+// PermissionSweeper moves permissions past their valid_until to 'expired',
+// warns ahead of expiry at each wallet's configured thresholds, revokes
+// rotated permissions once their overlap with the successor ends and revokes
+// delegated permissions whose delegating permission ended. Task sessions
+// expire the same way, but are too short-lived to be warned about.
+// Validation stops matching a permission as soon as it expires; this job
+// records the transition. Minted grants carry the same validUntil on-chain
+// and lapse there on their own.
+type PermissionSweeper struct {
+	db          *pgxpool.Pool
+	mc          *blockchain.MultiClient
+	auditLogger *audit.Logger
+	logger      zerolog.Logger
+	interval    time.Duration
+}
+
+func NewPermissionSweeper(db *pgxpool.Pool, mc *blockchain.MultiClient, auditLogger *audit.Logger, logger zerolog.Logger, interval time.Duration) *PermissionSweeper {
+	return &PermissionSweeper{
+		db:          db,
+		mc:          mc,
+		auditLogger: auditLogger,
+		logger:      logger,
+		interval:    interval,
+	}
+}
+
+// Start launches the sweep loop in the background.
+func (s *PermissionSweeper) Start(ctx context.Context) {
+	if s.interval <= 0 {
+		return
+	}
+	go s.run(ctx)
+}
+
+func (s *PermissionSweeper) run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.expire(ctx); err != nil {
+				s.logger.Error().Err(err).Msg("permissions: expiry sweep error")
+			}
+			if err := s.warn(ctx); err != nil {
+				s.logger.Error().Err(err).Msg("permissions: expiry warning error")
+			}
+			if err := s.retire(ctx); err != nil {
+				s.logger.Error().Err(err).Msg("permissions: rotation retire error")
+			}
+			if err := s.cascade(ctx); err != nil {
+				s.logger.Error().Err(err).Msg("permissions: delegation cascade error")
+			}
+		}
+	}
+}
+
+func (s *PermissionSweeper) expire(ctx context.Context) error {
+	rows, err := s.db.Query(ctx,
+		`UPDATE permissions SET status = 'expired'
+		 WHERE status = 'active' AND valid_until <= NOW()
+		 RETURNING id, wallet_id, agent_id, policy_id, valid_until, onchain_token_id IS NOT NULL, group_grant_id`,
+	)
+	if err != nil {
+		return err
+	}
+	type expired struct {
+		id, walletID, agentID, policyID uuid.UUID
+		validUntil                      time.Time
+		minted                          bool
+		groupGrantID                    *uuid.UUID
+	}
+	var ended []expired
+	for rows.Next() {
+		var e expired
+		if err := rows.Scan(&e.id, &e.walletID, &e.agentID, &e.policyID, &e.validUntil, &e.minted, &e.groupGrantID); err == nil {
+			ended = append(ended, e)
+		}
+	}
+	rows.Close()
+
+	for _, e := range ended {
+		details := map[string]interface{}{"valid_until": e.validUntil, "minted": e.minted}
+		if e.groupGrantID != nil {
+			details["group_grant_id"] = *e.groupGrantID
+		}
+		s.auditLogger.Log(ctx, audit.Event{
+			WalletID:     e.walletID,
+			AgentID:      &e.agentID,
+			PolicyID:     &e.policyID,
+			PermissionID: &e.id,
+			EventType:    "permission.expired",
+			Details:      details,
+		})
+	}
+
+	// Expired group grants would otherwise hand out already-expired
+	// permissions to new members
+	grants, err := s.db.Query(ctx,
+		`UPDATE group_grants SET status = 'expired'
+		 WHERE status = 'active' AND valid_until <= NOW()
+		 RETURNING id, wallet_id, group_id, policy_id`,
+	)
+	if err != nil {
+		return err
+	}
+	type expiredGrant struct{ id, walletID, groupID, policyID uuid.UUID }
+	var endedGrants []expiredGrant
+	for grants.Next() {
+		var g expiredGrant
+		if err := grants.Scan(&g.id, &g.walletID, &g.groupID, &g.policyID); err == nil {
+			endedGrants = append(endedGrants, g)
+		}
+	}
+	grants.Close()
+
+	for _, g := range endedGrants {
+		s.auditLogger.Log(ctx, audit.Event{
+			WalletID:  g.walletID,
+			PolicyID:  &g.policyID,
+			EventType: "group_grant.expired",
+			Details:   map[string]interface{}{"grant_id": g.id, "group_id": g.groupID},
+		})
+	}
+	return nil
+}
+
+// retire revokes rotated permissions whose overlap with their successor has
+// ended, on-chain where minted. On-chain failures are recorded and the
+// permission is revoked off-chain regardless.
+func (s *PermissionSweeper) retire(ctx context.Context) error {
+	rows, err := s.db.Query(ctx,
+		`UPDATE permissions p SET status = 'revoked', revoked_at = NOW()
+		 WHERE p.status = 'active' AND p.retire_at <= NOW()
+		 RETURNING p.id, p.wallet_id, p.agent_id, p.policy_id, p.onchain_token_id,
+		           (SELECT s.id FROM permissions s WHERE s.rotated_from = p.id AND s.status = 'active')`,
+	)
+	if err != nil {
+		return err
+	}
+	type retired struct {
+		id, walletID, agentID, policyID uuid.UUID
+		tokenID                         *string
+		successorID                     *uuid.UUID
+	}
+	var ended []retired
+	for rows.Next() {
+		var r retired
+		if err := rows.Scan(&r.id, &r.walletID, &r.agentID, &r.policyID, &r.tokenID, &r.successorID); err == nil {
+			ended = append(ended, r)
+		}
+	}
+	rows.Close()
+
+	for _, r := range ended {
+		details := map[string]interface{}{"reason": "rotated"}
+		if r.successorID != nil {
+			details["successor_id"] = *r.successorID
+		}
+		s.revokeOnchain(ctx, r.id, r.tokenID, details)
+		s.auditLogger.Log(ctx, audit.Event{
+			WalletID:     r.walletID,
+			AgentID:      &r.agentID,
+			PolicyID:     &r.policyID,
+			PermissionID: &r.id,
+			EventType:    "permission.revoked",
+			Details:      details,
+		})
+	}
+	return nil
+}
+
+// cascade revokes delegated permissions whose delegating permission is no
+// longer active, however it ended. Validation already denies them; this
+// records the revocation and revokes them on-chain. Each pass reaches one
+// level further down.
+func (s *PermissionSweeper) cascade(ctx context.Context) error {
+	for level := 0; level < MaxDelegationDepth; level++ {
+		rows, err := s.db.Query(ctx,
+			`UPDATE permissions c SET status = 'revoked', revoked_at = NOW()
+			 FROM permissions p
+			 WHERE c.delegated_from = p.id AND c.status = 'active' AND p.status != 'active'
+			 RETURNING c.id, c.wallet_id, c.agent_id, c.policy_id, c.onchain_token_id, p.id, p.status`,
+		)
+		if err != nil {
+			return err
+		}
+		type revoked struct {
+			id, walletID, agentID, policyID, parentID uuid.UUID
+			tokenID                                   *string
+			parentStatus                              string
+		}
+		var ended []revoked
+		for rows.Next() {
+			var r revoked
+			if err := rows.Scan(&r.id, &r.walletID, &r.agentID, &r.policyID, &r.tokenID, &r.parentID, &r.parentStatus); err == nil {
+				ended = append(ended, r)
+			}
+		}
+		rows.Close()
+		if len(ended) == 0 {
+			return nil
+		}
+
+		for _, r := range ended {
+			details := map[string]interface{}{
+				"reason":         "delegating permission " + r.parentStatus,
+				"delegated_from": r.parentID,
+			}
+			s.revokeOnchain(ctx, r.id, r.tokenID, details)
+			s.auditLogger.Log(ctx, audit.Event{
+				WalletID:     r.walletID,
+				AgentID:      &r.agentID,
+				PolicyID:     &r.policyID,
+				PermissionID: &r.id,
+				EventType:    "permission.revoked",
+				Details:      details,
+			})
+		}
+	}
+	return nil
+}
+
+// revokeOnchain revokes a minted permission on-chain, recording the
+// transaction or the failure in details. Unminted permissions are skipped.
+func (s *PermissionSweeper) revokeOnchain(ctx context.Context, permID uuid.UUID, tokenID *string, details map[string]interface{}) {
+	if tokenID == nil || *tokenID == "" {
+		return
+	}
+	permIDBytes, err := blockchain.HexToBytes32(*tokenID)
+	if err == nil {
+		var txHash string
+		txHash, err = s.mc.Primary().RevokePermission(ctx, permIDBytes)
+		details["tx_hash"] = txHash
+	}
+	if err != nil {
+		s.logger.Error().Err(err).Str("permission_id", permID.String()).Msg("permissions: on-chain revocation failed")
+		details["onchain_error"] = err.Error()
+	}
+}
+
+// warn sends a permission.expiring event for each active permission that
+// crossed one of its wallet's warning thresholds since the last warning.
+func (s *PermissionSweeper) warn(ctx context.Context) error {
+	rows, err := s.db.Query(ctx,
+		`SELECT p.id, p.wallet_id, p.agent_id, p.policy_id, p.valid_until, w.expiry_warning_days,
+		        (SELECT MIN(pw.days) FROM permission_expiry_warnings pw WHERE pw.permission_id = p.id)
+		 FROM permissions p
+		 JOIN wallets w ON w.id = p.wallet_id
+		 WHERE p.status = 'active' AND p.valid_until > NOW() AND p.task_id IS NULL
+		 AND cardinality(w.expiry_warning_days) > 0
+		 AND p.valid_until <= NOW() + make_interval(days => (SELECT MAX(d) FROM unnest(w.expiry_warning_days) d))`,
+	)
+	if err != nil {
+		return err
+	}
+	type expiring struct {
+		id, walletID, agentID, policyID uuid.UUID
+		validUntil                      time.Time
+		days                            int
+	}
+	var due []expiring
+	now := time.Now()
+	for rows.Next() {
+		var e expiring
+		var thresholds []int32
+		var lastSent *int32
+		if err := rows.Scan(&e.id, &e.walletID, &e.agentID, &e.policyID, &e.validUntil, &thresholds, &lastSent); err != nil {
+			continue
+		}
+		days := make([]int, len(thresholds))
+		for i, t := range thresholds {
+			days[i] = int(t)
+		}
+		last := 0
+		if lastSent != nil {
+			last = int(*lastSent)
+		}
+		if e.days = expiryWarning(days, e.validUntil.Sub(now), last); e.days > 0 {
+			due = append(due, e)
+		}
+	}
+	rows.Close()
+
+	for _, e := range due {
+		tag, err := s.db.Exec(ctx,
+			`INSERT INTO permission_expiry_warnings (permission_id, days) VALUES ($1, $2)
+			 ON CONFLICT DO NOTHING`,
+			e.id, e.days,
+		)
+		if err != nil || tag.RowsAffected() == 0 {
+			continue
+		}
+		s.auditLogger.Log(ctx, audit.Event{
+			WalletID:     e.walletID,
+			AgentID:      &e.agentID,
+			PolicyID:     &e.policyID,
+			PermissionID: &e.id,
+			EventType:    "permission.expiring",
+			Details:      map[string]interface{}{"days": e.days, "valid_until": e.validUntil},
+		})
+	}
+	return nil
+}
+
+// expiryWarning returns the threshold, in days, to warn for when a
+// permission has remaining time left, or 0 if no warning is due. Only the
+// tightest threshold crossed is reported, and only if it is tighter than
+// lastSent, the tightest warning already sent (0 if none).
+func expiryWarning(thresholds []int, remaining time.Duration, lastSent int) int {
+	sorted := append([]int(nil), thresholds...)
+	sort.Ints(sorted)
+	for _, days := range sorted {
+		if days <= 0 || remaining > time.Duration(days)*24*time.Hour {
+			continue
+		}
+		if lastSent > 0 && days >= lastSent {
+			return 0
+		}
+		return days
+	}
+	return 0
+}
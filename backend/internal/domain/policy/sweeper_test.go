@@ -0,0 +1,35 @@
+package policy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpiryWarning(t *testing.T) {
+	day := 24 * time.Hour
+	thresholds := []int{7, 1}
+
+	cases := []struct {
+		name      string
+		remaining time.Duration
+		lastSent  int
+		want      int
+	}{
+		{"outside all thresholds", 10 * day, 0, 0},
+		{"crosses 7 days", 6 * day, 0, 7},
+		{"7 days already sent", 6 * day, 7, 0},
+		{"crosses 1 day after 7", 12 * time.Hour, 7, 1},
+		{"tightest only when several crossed", 12 * time.Hour, 0, 1},
+		{"1 day already sent", 2 * time.Hour, 1, 0},
+		{"exactly on threshold", 7 * day, 0, 7},
+	}
+	for _, c := range cases {
+		if got := expiryWarning(thresholds, c.remaining, c.lastSent); got != c.want {
+			t.Errorf("%s: expected %d, got %d", c.name, c.want, got)
+		}
+	}
+
+	if got := expiryWarning(nil, time.Hour, 0); got != 0 {
+		t.Fatalf("expected no warning without thresholds, got %d", got)
+	}
+}
@@ -0,0 +1,380 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Template parameter types
+const (
+	ParamString      = "string"
+	ParamAddress     = "address"
+	ParamAmount      = "amount"
+	ParamInteger     = "integer"
+	ParamBoolean     = "boolean"
+	ParamStringList  = "string_list"
+	ParamAddressList = "address_list"
+	ParamChainList   = "chain_list"
+)
+
+var validParamTypes = map[string]bool{
+	ParamString:      true,
+	ParamAddress:     true,
+	ParamAmount:      true,
+	ParamInteger:     true,
+	ParamBoolean:     true,
+	ParamStringList:  true,
+	ParamAddressList: true,
+	ParamChainList:   true,
+}
+
+// placeholderPattern matches "{{name}}" placeholders in template definitions.
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+var paramNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// TemplateParam declares a typed parameter of a policy template.
+type TemplateParam struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type"`
+	Description string      `json:"description,omitempty"`
+	Required    bool        `json:"required,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+}
+
+// Template is a policy definition with "{{param}}" placeholders. A string that
+// consists of a single placeholder is replaced by the typed parameter value, so
+// list and numeric parameters can fill arrays and numbers; placeholders inside
+// longer strings are interpolated as text.
+type Template struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  []TemplateParam `json:"parameters"`
+	Definition  json.RawMessage `json:"definition"`
+}
+
+// Validate checks parameter declarations and placeholders, then renders the
+// template with sample values to make sure instances decode as a Definition.
+// Rule-level validation (valid actions, operators, ...) depends on the actual
+// parameter values and happens when an instance is created.
+func (t *Template) Validate() error {
+	if t.Name == "" {
+		return errors.New("template name is required")
+	}
+	if len(t.Definition) == 0 {
+		return errors.New("template definition is required")
+	}
+
+	declared := make(map[string]TemplateParam)
+	for _, p := range t.Parameters {
+		if !paramNamePattern.MatchString(p.Name) {
+			return fmt.Errorf("invalid parameter name: %q", p.Name)
+		}
+		if _, dup := declared[p.Name]; dup {
+			return fmt.Errorf("duplicate parameter: %s", p.Name)
+		}
+		if !validParamTypes[p.Type] {
+			return fmt.Errorf("parameter %s: invalid type %q", p.Name, p.Type)
+		}
+		if p.Default != nil {
+			if _, err := coerceParam(p, p.Default); err != nil {
+				return fmt.Errorf("parameter %s: invalid default: %w", p.Name, err)
+			}
+		}
+		declared[p.Name] = p
+	}
+
+	for _, name := range t.Placeholders() {
+		if _, ok := declared[name]; !ok {
+			return fmt.Errorf("placeholder {{%s}} is not a declared parameter", name)
+		}
+	}
+
+	sample := make(map[string]interface{})
+	for _, p := range t.Parameters {
+		if p.Default == nil {
+			sample[p.Name] = sampleValue(p.Type)
+		}
+	}
+	_, err := t.Render(sample)
+	return err
+}
+
+// Placeholders returns the sorted, de-duplicated parameter names referenced
+// by the template definition.
+func (t *Template) Placeholders() []string {
+	seen := make(map[string]bool)
+	for _, m := range placeholderPattern.FindAllSubmatch(t.Definition, -1) {
+		seen[string(m[1])] = true
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render substitutes params into the template and returns the resulting
+// definition. Unknown parameters and missing required ones are errors;
+// optional parameters without a value fall back to their default, or are
+// removed from the definition when they have none.
+func (t *Template) Render(params map[string]interface{}) (*Definition, error) {
+	values, err := t.ResolveParams(params)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(t.Definition))
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return nil, fmt.Errorf("invalid template definition: %w", err)
+	}
+
+	rendered, keep, err := substitute(generic, values)
+	if err != nil {
+		return nil, err
+	}
+	if !keep {
+		return nil, errors.New("template definition is empty after rendering")
+	}
+
+	raw, err := json.Marshal(rendered)
+	if err != nil {
+		return nil, err
+	}
+	var def Definition
+	if err := json.Unmarshal(raw, &def); err != nil {
+		return nil, fmt.Errorf("rendered definition is invalid: %w", err)
+	}
+	return &def, nil
+}
+
+// ResolveParams validates params against the declarations and returns the
+// typed values (including defaults) that Render substitutes.
+func (t *Template) ResolveParams(params map[string]interface{}) (map[string]interface{}, error) {
+	declared := make(map[string]bool)
+	values := make(map[string]interface{})
+	for _, p := range t.Parameters {
+		declared[p.Name] = true
+		raw, ok := params[p.Name]
+		if !ok || raw == nil {
+			if p.Default != nil {
+				raw = p.Default
+			} else if p.Required {
+				return nil, fmt.Errorf("parameter %s is required", p.Name)
+			} else {
+				continue
+			}
+		}
+		v, err := coerceParam(p, raw)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s: %w", p.Name, err)
+		}
+		values[p.Name] = v
+	}
+	for name := range params {
+		if !declared[name] {
+			return nil, fmt.Errorf("unknown parameter: %s", name)
+		}
+	}
+	return values, nil
+}
+
+// substitute walks a decoded JSON value replacing placeholders. The boolean
+// result is false when the value consisted only of an unset optional
+// parameter and should be dropped from its parent.
+func substitute(v interface{}, values map[string]interface{}) (interface{}, bool, error) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			s, keep, err := substitute(item, values)
+			if err != nil {
+				return nil, false, err
+			}
+			if keep {
+				out[k] = s
+			}
+		}
+		return out, true, nil
+	case []interface{}:
+		out := make([]interface{}, 0, len(val))
+		for _, item := range val {
+			s, keep, err := substitute(item, values)
+			if err != nil {
+				return nil, false, err
+			}
+			if !keep {
+				continue
+			}
+			// A list parameter used as an array element is spliced in.
+			if list, ok := s.([]interface{}); ok && isPlaceholder(item) {
+				out = append(out, list...)
+				continue
+			}
+			out = append(out, s)
+		}
+		return out, true, nil
+	case string:
+		if m := placeholderPattern.FindStringSubmatch(val); m != nil && m[0] == val {
+			value, ok := values[m[1]]
+			return value, ok, nil
+		}
+		var missing string
+		out := placeholderPattern.ReplaceAllStringFunc(val, func(s string) string {
+			name := placeholderPattern.FindStringSubmatch(s)[1]
+			value, ok := values[name]
+			if !ok {
+				missing = name
+				return ""
+			}
+			return fmt.Sprint(value)
+		})
+		if missing != "" {
+			return nil, false, fmt.Errorf("parameter %s has no value", missing)
+		}
+		return out, true, nil
+	default:
+		return v, true, nil
+	}
+}
+
+func isPlaceholder(v interface{}) bool {
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+	m := placeholderPattern.FindStringSubmatch(s)
+	return m != nil && m[0] == s
+}
+
+// coerceParam converts a JSON-decoded value to the parameter's type.
+func coerceParam(p TemplateParam, raw interface{}) (interface{}, error) {
+	switch p.Type {
+	case ParamString:
+		s, ok := raw.(string)
+		if !ok {
+			return nil, errors.New("must be a string")
+		}
+		return s, nil
+	case ParamAddress:
+		s, ok := raw.(string)
+		if !ok || !common.IsHexAddress(s) {
+			return nil, errors.New("must be a hex address")
+		}
+		return s, nil
+	case ParamAmount:
+		s, ok := amountString(raw)
+		if !ok {
+			return nil, errors.New("must be a non-negative integer amount")
+		}
+		return s, nil
+	case ParamInteger:
+		n, ok := integerValue(raw)
+		if !ok {
+			return nil, errors.New("must be an integer")
+		}
+		return n, nil
+	case ParamBoolean:
+		b, ok := raw.(bool)
+		if !ok {
+			return nil, errors.New("must be a boolean")
+		}
+		return b, nil
+	case ParamStringList, ParamAddressList, ParamChainList:
+		items, ok := raw.([]interface{})
+		if !ok {
+			return nil, errors.New("must be a list")
+		}
+		itemType := map[string]string{
+			ParamStringList:  ParamString,
+			ParamAddressList: ParamAddress,
+			ParamChainList:   ParamInteger,
+		}[p.Type]
+		out := make([]interface{}, 0, len(items))
+		for i, item := range items {
+			v, err := coerceParam(TemplateParam{Type: itemType}, item)
+			if err != nil {
+				return nil, fmt.Errorf("item %d: %w", i, err)
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("unsupported type %q", p.Type)
+}
+
+func amountString(raw interface{}) (string, bool) {
+	var s string
+	switch v := raw.(type) {
+	case string:
+		s = v
+	case json.Number:
+		s = v.String()
+	case float64:
+		if v != math.Trunc(v) || v > math.MaxInt64 {
+			return "", false
+		}
+		s = fmt.Sprintf("%.0f", v)
+	case int:
+		s = fmt.Sprint(v)
+	case int64:
+		s = fmt.Sprint(v)
+	default:
+		return "", false
+	}
+	n, ok := new(big.Int).SetString(strings.TrimSpace(s), 10)
+	if !ok || n.Sign() < 0 {
+		return "", false
+	}
+	return n.String(), true
+}
+
+func integerValue(raw interface{}) (int64, bool) {
+	switch v := raw.(type) {
+	case float64:
+		if v != math.Trunc(v) || math.Abs(v) > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(v), true
+	case json.Number:
+		n, err := v.Int64()
+		return n, err == nil
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	}
+	return 0, false
+}
+
+func sampleValue(paramType string) interface{} {
+	switch paramType {
+	case ParamAddress:
+		return "0x0000000000000000000000000000000000000001"
+	case ParamAmount:
+		return "1"
+	case ParamInteger:
+		return int64(1)
+	case ParamBoolean:
+		return false
+	case ParamStringList:
+		return []interface{}{"sample"}
+	case ParamAddressList:
+		return []interface{}{"0x0000000000000000000000000000000000000001"}
+	case ParamChainList:
+		return []interface{}{int64(1)}
+	}
+	return "sample"
+}
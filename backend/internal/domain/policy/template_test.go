@@ -0,0 +1,116 @@
+package policy
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func swapTemplate() *Template {
+	return &Template{
+		Name: "swap",
+		Parameters: []TemplateParam{
+			{Name: "token", Type: ParamAddress, Required: true},
+			{Name: "protocols", Type: ParamStringList, Required: true},
+			{Name: "max", Type: ParamAmount, Required: true},
+			{Name: "daily", Type: ParamAmount},
+			{Name: "count", Type: ParamInteger, Default: float64(5)},
+		},
+		Definition: json.RawMessage(`{
+			"actions": ["swap"],
+			"assets": {"tokens": ["{{token}}"], "protocols": "{{protocols}}"},
+			"constraints": {"maxValuePerTx": "{{max}}", "maxDailyVolume": "{{daily}}", "maxTxCount": "{{count}}"},
+			"conditions": [{"field": "memo", "operator": "eq", "value": "agent-{{max}}"}]
+		}`),
+	}
+}
+
+func TestTemplate_RenderTypedValues(t *testing.T) {
+	tmpl := swapTemplate()
+	def, err := tmpl.Render(map[string]interface{}{
+		"token":     "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48",
+		"protocols": []interface{}{"uniswap-v3", "curve"},
+		"max":       float64(1000),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(def.Assets.Tokens) != 1 || def.Assets.Tokens[0] != "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48" {
+		t.Fatalf("token not substituted: %v", def.Assets.Tokens)
+	}
+	if strings.Join(def.Assets.Protocols, ",") != "uniswap-v3,curve" {
+		t.Fatalf("protocols not substituted: %v", def.Assets.Protocols)
+	}
+	if def.Constraints.MaxValuePerTx != "1000" {
+		t.Fatalf("expected amount 1000, got %q", def.Constraints.MaxValuePerTx)
+	}
+	if def.Constraints.MaxDailyVolume != "" {
+		t.Fatalf("unset optional parameter should be dropped, got %q", def.Constraints.MaxDailyVolume)
+	}
+	if def.Constraints.MaxTxCount != 5 {
+		t.Fatalf("expected default count 5, got %d", def.Constraints.MaxTxCount)
+	}
+	if def.Conditions[0].Value != "agent-1000" {
+		t.Fatalf("expected interpolated string, got %v", def.Conditions[0].Value)
+	}
+}
+
+func TestTemplate_RenderMissingRequired(t *testing.T) {
+	_, err := swapTemplate().Render(map[string]interface{}{
+		"token": "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48",
+	})
+	if err == nil || !strings.Contains(err.Error(), "required") {
+		t.Fatalf("expected required parameter error, got %v", err)
+	}
+}
+
+func TestTemplate_RenderRejectsBadTypes(t *testing.T) {
+	cases := map[string]map[string]interface{}{
+		"bad address": {"token": "not-an-address", "protocols": []interface{}{"x"}, "max": "1"},
+		"bad amount":  {"token": "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48", "protocols": []interface{}{"x"}, "max": "-5"},
+		"fractional":  {"token": "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48", "protocols": []interface{}{"x"}, "max": 1.5},
+		"unknown":     {"token": "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48", "protocols": []interface{}{"x"}, "max": "1", "extra": 1},
+	}
+	for name, params := range cases {
+		if _, err := swapTemplate().Render(params); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestTemplate_ValidateUndeclaredPlaceholder(t *testing.T) {
+	tmpl := &Template{
+		Name:       "bad",
+		Definition: json.RawMessage(`{"actions": ["swap"], "constraints": {"maxValuePerTx": "{{limit}}"}}`),
+	}
+	err := tmpl.Validate()
+	if err == nil || !strings.Contains(err.Error(), "limit") {
+		t.Fatalf("expected undeclared placeholder error, got %v", err)
+	}
+}
+
+func TestStarterTemplates_Valid(t *testing.T) {
+	engine := &Engine{}
+	for _, lt := range StarterTemplates() {
+		if err := lt.Validate(); err != nil {
+			t.Errorf("%s: %v", lt.Key, err)
+			continue
+		}
+
+		params := map[string]interface{}{}
+		for _, p := range lt.Parameters {
+			if p.Required {
+				params[p.Name] = sampleValue(p.Type)
+			}
+		}
+		def, err := lt.Render(params)
+		if err != nil {
+			t.Errorf("%s: render: %v", lt.Key, err)
+			continue
+		}
+		if err := engine.ValidateDefinition(def); err != nil {
+			t.Errorf("%s: rendered definition invalid: %v", lt.Key, err)
+		}
+	}
+}
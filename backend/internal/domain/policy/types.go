@@ -4,64 +4,33 @@ import (
 	"time"
 
 	"github.com/google/uuid"
-)
-
-// Definition represents a policy's rule set
-type Definition struct {
-	Actions     []string           `json:"actions"`
-	Assets      Assets             `json:"assets,omitempty"`
-	Constraints Constraints        `json:"constraints,omitempty"`
-	Duration    Duration           `json:"duration,omitempty"`
-	Conditions  []Condition        `json:"conditions,omitempty"`
-}
-
-// Assets defines which tokens/protocols are allowed
-type Assets struct {
-	Tokens    []string `json:"tokens,omitempty"`
-	Protocols []string `json:"protocols,omitempty"`
-	Chains    []int64  `json:"chains,omitempty"`
-}
-
-// Constraints define limits on actions
-type Constraints struct {
-	MaxValuePerTx   string `json:"maxValuePerTx,omitempty"`
-	MaxDailyVolume  string `json:"maxDailyVolume,omitempty"`
-	MaxWeeklyVolume string `json:"maxWeeklyVolume,omitempty"`
-	MaxTxCount      int    `json:"maxTxCount,omitempty"`
-	RequireApproval bool   `json:"requireApproval,omitempty"`
-}
 
-// Duration defines validity period
-type Duration struct {
-	ValidFrom  *time.Time `json:"validFrom,omitempty"`
-	ValidUntil *time.Time `json:"validUntil,omitempty"`
-}
-
-// Condition represents additional rule conditions
-type Condition struct {
-	Field    string      `json:"field"`
-	Operator string      `json:"operator"`
-	Value    interface{} `json:"value"`
-}
+	"github.com/erc8004/policy-saas/pkg/policyeval"
+)
 
-// Action represents an action being validated
-type Action struct {
-	Type     string                 `json:"type"`
-	Token    string                 `json:"token,omitempty"`
-	Protocol string                 `json:"protocol,omitempty"`
-	Amount   string                 `json:"amount,omitempty"`
-	Chain    int64                  `json:"chain,omitempty"`
-	To       string                 `json:"to,omitempty"`
-	Data     map[string]interface{} `json:"data,omitempty"`
-}
+// The policy model lives in pkg/policyeval, where agents evaluating bundles
+// offline can import it.
+type (
+	Definition       = policyeval.Definition
+	Assets           = policyeval.Assets
+	Constraints      = policyeval.Constraints
+	Duration         = policyeval.Duration
+	Condition        = policyeval.Condition
+	Action           = policyeval.Action
+	ValidationResult = policyeval.ValidationResult
+)
 
-// ValidationResult is the result of validating an action
-type ValidationResult struct {
-	Allowed      bool
-	Reason       string
-	PermissionID *uuid.UUID
-	PolicyID     *uuid.UUID
-	Constraints  map[string]interface{}
+// EffectiveWindow intersects a permission's validity with the policy's own
+// Duration: the later start and the earlier end win.
+func EffectiveWindow(d Duration, validFrom time.Time, validUntil *time.Time) (time.Time, *time.Time) {
+	if d.ValidFrom != nil && d.ValidFrom.After(validFrom) {
+		validFrom = *d.ValidFrom
+	}
+	if d.ValidUntil != nil && (validUntil == nil || d.ValidUntil.Before(*validUntil)) {
+		until := *d.ValidUntil
+		validUntil = &until
+	}
+	return validFrom, validUntil
 }
 
 // SimulationResult is the result of simulating an action
@@ -72,6 +41,7 @@ type SimulationResult struct {
 	CurrentUsage    map[string]interface{}
 	RemainingQuota  map[string]interface{}
 	Recommendations []string
+	GroupHeadroom   []GroupHeadroom
 }
 
 // ValidActions lists all valid action types
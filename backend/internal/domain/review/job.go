@@ -0,0 +1,112 @@
+package review
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+
+	"github.com/erc8004/policy-saas/internal/domain/audit"
+)
+
+// Job periodically reviews every wallet and records the findings as audit
+// events, which also reach the wallet's webhooks.
+type Job struct {
+	db          *pgxpool.Pool
+	auditLogger *audit.Logger
+	logger      zerolog.Logger
+	interval    time.Duration
+	staleDays   int
+}
+
+func NewJob(db *pgxpool.Pool, auditLogger *audit.Logger, logger zerolog.Logger, interval time.Duration, staleDays int) *Job {
+	return &Job{
+		db:          db,
+		auditLogger: auditLogger,
+		logger:      logger,
+		interval:    interval,
+		staleDays:   staleDays,
+	}
+}
+
+// Start launches the review loop in the background.
+// No-ops when the interval is not positive.
+func (j *Job) Start(ctx context.Context) {
+	if j.interval <= 0 {
+		j.logger.Info().Msg("access review: disabled")
+		return
+	}
+	j.logger.Info().Dur("interval", j.interval).Msg("access review: starting scheduled reviews")
+	go j.run(ctx)
+}
+
+func (j *Job) run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := j.reviewAll(ctx); err != nil {
+				j.logger.Error().Err(err).Msg("access review: run error")
+			}
+		}
+	}
+}
+
+func (j *Job) reviewAll(ctx context.Context) error {
+	rows, err := j.db.Query(ctx, `SELECT DISTINCT wallet_id FROM permissions WHERE status = 'active'`)
+	if err != nil {
+		return err
+	}
+	var wallets []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err == nil {
+			wallets = append(wallets, id)
+		}
+	}
+	rows.Close()
+
+	for _, walletID := range wallets {
+		report, err := Build(ctx, j.db, walletID, j.staleDays)
+		if err != nil {
+			j.logger.Error().Err(err).Str("wallet_id", walletID.String()).Msg("access review: failed to build report")
+			continue
+		}
+		j.record(ctx, report)
+	}
+	return nil
+}
+
+func (j *Job) record(ctx context.Context, report *Report) {
+	for _, f := range report.Findings {
+		agentID, policyID, permID := f.AgentID, f.PolicyID, f.PermissionID
+		j.auditLogger.Log(ctx, audit.Event{
+			WalletID:     report.WalletID,
+			AgentID:      &agentID,
+			PolicyID:     &policyID,
+			PermissionID: &permID,
+			EventType:    "access_review." + f.Kind,
+			Details: map[string]interface{}{
+				"severity":     f.Severity,
+				"detail":       f.Detail,
+				"last_used_at": f.LastUsedAt,
+			},
+		})
+	}
+
+	j.auditLogger.Log(ctx, audit.Event{
+		WalletID:  report.WalletID,
+		EventType: "access_review.completed",
+		Details: map[string]interface{}{
+			"stale_days":           report.StaleDays,
+			"permissions_reviewed": report.Reviewed,
+			"summary":              report.Summary,
+		},
+	})
+}
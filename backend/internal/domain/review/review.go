@@ -0,0 +1,216 @@
+// Package review builds access review reports: permissions that are unused,
+// overly broad, open-ended or out of sync with their policy.
+package review
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/erc8004/policy-saas/internal/domain/policy"
+)
+
+// Finding kinds.
+const (
+	KindUnused              = "unused"
+	KindWildcardPolicy      = "wildcard_policy"
+	KindNoExpiry            = "no_expiry"
+	KindRevokedPolicyMinted = "revoked_policy_minted"
+)
+
+// DefaultStaleDays is the inactivity window after which a permission is unused.
+const DefaultStaleDays = 30
+
+// Finding is one issue with an active permission.
+type Finding struct {
+	Kind         string     `json:"kind"`
+	Severity     string     `json:"severity"` // "high", "medium" or "low"
+	PermissionID uuid.UUID  `json:"permission_id"`
+	AgentID      uuid.UUID  `json:"agent_id"`
+	AgentName    string     `json:"agent_name"`
+	PolicyID     uuid.UUID  `json:"policy_id"`
+	PolicyName   string     `json:"policy_name"`
+	Detail       string     `json:"detail"`
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+}
+
+// Report is the access review of one wallet.
+type Report struct {
+	WalletID    uuid.UUID      `json:"wallet_id"`
+	GeneratedAt time.Time      `json:"generated_at"`
+	StaleDays   int            `json:"stale_days"`
+	Reviewed    int            `json:"permissions_reviewed"`
+	Summary     map[string]int `json:"summary"`
+	Findings    []Finding      `json:"findings"`
+}
+
+// PermissionUsage is an active permission with the facts needed to review it.
+type PermissionUsage struct {
+	PermissionID   uuid.UUID
+	AgentID        uuid.UUID
+	AgentName      string
+	PolicyID       uuid.UUID
+	PolicyName     string
+	PolicyStatus   string
+	Definition     policy.Definition
+	CreatedAt      time.Time
+	ValidUntil     *time.Time
+	Minted         bool
+	LastValidation *time.Time
+	LastExecution  *time.Time
+}
+
+// LastUsed is the latest allowed validation or, for minted permissions, the
+// agent's latest on-chain execution.
+func (p *PermissionUsage) LastUsed() *time.Time {
+	last := p.LastValidation
+	if p.Minted && p.LastExecution != nil && (last == nil || p.LastExecution.After(*last)) {
+		last = p.LastExecution
+	}
+	return last
+}
+
+// Evaluate returns the findings for the given permissions. Permissions younger
+// than the stale window are not reported as unused.
+func Evaluate(perms []PermissionUsage, staleDays int, now time.Time) []Finding {
+	cutoff := now.AddDate(0, 0, -staleDays)
+	findings := []Finding{}
+
+	for i := range perms {
+		p := &perms[i]
+		base := Finding{
+			PermissionID: p.PermissionID,
+			AgentID:      p.AgentID,
+			AgentName:    p.AgentName,
+			PolicyID:     p.PolicyID,
+			PolicyName:   p.PolicyName,
+			LastUsedAt:   p.LastUsed(),
+		}
+
+		if p.PolicyStatus == "revoked" && p.Minted {
+			f := base
+			f.Kind, f.Severity = KindRevokedPolicyMinted, "high"
+			f.Detail = "policy is revoked but the permission is still minted on-chain"
+			findings = append(findings, f)
+		}
+
+		if last := p.LastUsed(); p.CreatedAt.Before(cutoff) && (last == nil || last.Before(cutoff)) {
+			f := base
+			f.Kind, f.Severity = KindUnused, "medium"
+			if last == nil {
+				f.Detail = "never used"
+			} else {
+				f.Detail = "not used in " + strconv.Itoa(staleDays) + " days"
+			}
+			findings = append(findings, f)
+		}
+
+		if fields := wildcardFields(&p.Definition); len(fields) > 0 {
+			f := base
+			f.Kind, f.Severity = KindWildcardPolicy, "medium"
+			f.Detail = "policy grants wildcard " + strings.Join(fields, ", ")
+			findings = append(findings, f)
+		}
+
+		if p.ValidUntil == nil && p.Definition.Duration.ValidUntil == nil {
+			f := base
+			f.Kind, f.Severity = KindNoExpiry, "low"
+			f.Detail = "neither the permission nor its policy has an expiry"
+			findings = append(findings, f)
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return severityRank(findings[i].Severity) < severityRank(findings[j].Severity)
+	})
+	return findings
+}
+
+func wildcardFields(def *policy.Definition) []string {
+	var fields []string
+	for name, list := range map[string][]string{
+		"actions":   def.Actions,
+		"tokens":    def.Assets.Tokens,
+		"protocols": def.Assets.Protocols,
+	} {
+		for _, v := range list {
+			if v == "*" {
+				fields = append(fields, name)
+				break
+			}
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func severityRank(s string) int {
+	switch s {
+	case "high":
+		return 0
+	case "medium":
+		return 1
+	}
+	return 2
+}
+
+// Build loads the wallet's active permissions and evaluates them.
+func Build(ctx context.Context, db *pgxpool.Pool, walletID uuid.UUID, staleDays int) (*Report, error) {
+	if staleDays <= 0 {
+		staleDays = DefaultStaleDays
+	}
+
+	rows, err := db.Query(ctx,
+		`SELECT p.id, p.agent_id, a.name, p.policy_id, pol.name, pol.status, pol.definition,
+		        p.created_at, p.valid_until, p.onchain_token_id IS NOT NULL,
+		        (SELECT MAX(v.created_at) FROM validation_requests v
+		          WHERE v.permission_id = p.id AND v.allowed = true),
+		        (SELECT MAX(l.created_at) FROM audit_logs l
+		          WHERE l.agent_id = p.agent_id AND l.event_type = 'onchain.executed')
+		 FROM permissions p
+		 JOIN agents a ON a.id = p.agent_id
+		 JOIN policies pol ON pol.id = p.policy_id
+		 WHERE p.wallet_id = $1 AND p.status = 'active' AND a.status != 'deleted'
+		 ORDER BY p.created_at`,
+		walletID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var perms []PermissionUsage
+	for rows.Next() {
+		var p PermissionUsage
+		var defBytes []byte
+		if err := rows.Scan(&p.PermissionID, &p.AgentID, &p.AgentName, &p.PolicyID, &p.PolicyName, &p.PolicyStatus, &defBytes,
+			&p.CreatedAt, &p.ValidUntil, &p.Minted, &p.LastValidation, &p.LastExecution); err != nil {
+			return nil, err
+		}
+		json.Unmarshal(defBytes, &p.Definition)
+		perms = append(perms, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	report := &Report{
+		WalletID:    walletID,
+		GeneratedAt: now,
+		StaleDays:   staleDays,
+		Reviewed:    len(perms),
+		Summary:     map[string]int{},
+		Findings:    Evaluate(perms, staleDays, now),
+	}
+	for _, f := range report.Findings {
+		report.Summary[f.Kind]++
+	}
+	return report, nil
+}
@@ -0,0 +1,96 @@
+package review
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/erc8004/policy-saas/internal/domain/policy"
+)
+
+func usage(created time.Time) PermissionUsage {
+	until := created.AddDate(1, 0, 0)
+	return PermissionUsage{
+		PermissionID: uuid.New(),
+		AgentID:      uuid.New(),
+		PolicyID:     uuid.New(),
+		PolicyStatus: "active",
+		Definition:   policy.Definition{Actions: []string{"swap"}},
+		CreatedAt:    created,
+		ValidUntil:   &until,
+	}
+}
+
+func kinds(findings []Finding) map[string]int {
+	out := map[string]int{}
+	for _, f := range findings {
+		out[f.Kind]++
+	}
+	return out
+}
+
+func TestEvaluate_Unused(t *testing.T) {
+	now := time.Now()
+	old := now.AddDate(0, 0, -60)
+	recent := now.AddDate(0, 0, -2)
+
+	never := usage(old)
+	stale := usage(old)
+	staleAt := now.AddDate(0, 0, -45)
+	stale.LastValidation = &staleAt
+	active := usage(old)
+	active.LastValidation = &recent
+	young := usage(recent)
+
+	findings := Evaluate([]PermissionUsage{never, stale, active, young}, 30, now)
+	if kinds(findings)[KindUnused] != 2 {
+		t.Fatalf("expected 2 unused findings, got %+v", findings)
+	}
+	for _, f := range findings {
+		if f.PermissionID == active.PermissionID || f.PermissionID == young.PermissionID {
+			t.Fatalf("unexpected finding for used or new permission: %+v", f)
+		}
+	}
+}
+
+func TestEvaluate_OnchainExecutionCountsOnlyWhenMinted(t *testing.T) {
+	now := time.Now()
+	recent := now.AddDate(0, 0, -1)
+
+	offchain := usage(now.AddDate(0, 0, -60))
+	offchain.LastExecution = &recent
+	minted := usage(now.AddDate(0, 0, -60))
+	minted.Minted = true
+	minted.LastExecution = &recent
+
+	findings := Evaluate([]PermissionUsage{offchain, minted}, 30, now)
+	if len(findings) != 1 || findings[0].PermissionID != offchain.PermissionID {
+		t.Fatalf("expected only the unminted permission to be unused, got %+v", findings)
+	}
+}
+
+func TestEvaluate_RiskFindings(t *testing.T) {
+	now := time.Now()
+	recent := now.AddDate(0, 0, -1)
+
+	p := usage(recent)
+	p.Definition = policy.Definition{Actions: []string{"*"}, Assets: policy.Assets{Tokens: []string{"*"}}}
+	p.ValidUntil = nil
+	p.PolicyStatus = "revoked"
+	p.Minted = true
+
+	findings := Evaluate([]PermissionUsage{p}, 30, now)
+	k := kinds(findings)
+	if k[KindWildcardPolicy] != 1 || k[KindNoExpiry] != 1 || k[KindRevokedPolicyMinted] != 1 {
+		t.Fatalf("unexpected findings: %+v", findings)
+	}
+	if findings[0].Kind != KindRevokedPolicyMinted {
+		t.Fatalf("expected high severity finding first, got %s", findings[0].Kind)
+	}
+	for _, f := range findings {
+		if f.Kind == KindWildcardPolicy && f.Detail != "policy grants wildcard actions, tokens" {
+			t.Fatalf("unexpected wildcard detail: %s", f.Detail)
+		}
+	}
+}
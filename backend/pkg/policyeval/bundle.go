@@ -0,0 +1,153 @@
+package policyeval
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+)
+
+// BundleFormatVersion is the version of the Bundle layout.
+const BundleFormatVersion = 1
+
+// Bundle is an agent's effective policies, exported so the agent can
+// evaluate actions in-process instead of calling the service for each one.
+// Usage figures are those at IssuedAt; the Evaluator adds what it allows.
+type Bundle struct {
+	Version  int       `json:"version"`
+	WalletID uuid.UUID `json:"wallet_id"`
+	AgentID  uuid.UUID `json:"agent_id"`
+	// RevocationEpoch is the wallet's epoch at issue. The bundle stops
+	// validating once the wallet's epoch moves past it.
+	RevocationEpoch int64             `json:"revocation_epoch"`
+	IssuedAt        time.Time         `json:"issued_at"`
+	ExpiresAt       time.Time         `json:"expires_at"`
+	StrictDefault   bool              `json:"strict_default"`
+	Grants          []BundleGrant     `json:"grants"`
+	Guardrails      []BundleGuardrail `json:"guardrails"`
+	DailyUsed       string            `json:"daily_used"`
+	WalletDailyUsed string            `json:"wallet_daily_used"`
+	// OnlineOnly lists permissions left out because they need state only
+	// the service has. Actions they would allow must be validated online.
+	OnlineOnly []OnlineOnlyGrant `json:"online_only"`
+}
+
+// BundleGrant is one permission in a bundle, with any active escalation
+// already applied to its definition.
+type BundleGrant struct {
+	PermissionID uuid.UUID  `json:"permission_id"`
+	PolicyID     uuid.UUID  `json:"policy_id"`
+	ContentHash  *string    `json:"content_hash,omitempty"`
+	ValidFrom    time.Time  `json:"valid_from"`
+	ValidUntil   *time.Time `json:"valid_until,omitempty"`
+	Definition   Definition `json:"definition"`
+	Escalated    bool       `json:"escalated,omitempty"`
+}
+
+// BundleGuardrail is an active wallet guardrail.
+type BundleGuardrail struct {
+	Name       string    `json:"name"`
+	Definition Guardrail `json:"definition"`
+}
+
+type OnlineOnlyGrant struct {
+	PermissionID uuid.UUID `json:"permission_id"`
+	Reason       string    `json:"reason"`
+}
+
+// SignedBundle is a bundle with the service's EIP-712 signature over its
+// hash, epoch and expiry.
+type SignedBundle struct {
+	Bundle     Bundle `json:"bundle"`
+	BundleHash string `json:"bundle_hash"`
+	ChainID    int64  `json:"chain_id"`
+	Signer     string `json:"signer"`
+	Signature  string `json:"signature"`
+}
+
+// Hash returns the keccak256 of the bundle's canonical JSON.
+func (b *Bundle) Hash() ([32]byte, error) {
+	canonical, err := Canonicalize(b)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return crypto.Keccak256Hash(canonical), nil
+}
+
+// Statement returns the content the service signs for the bundle.
+func (b *Bundle) Statement() (BundleStatement, error) {
+	hash, err := b.Hash()
+	if err != nil {
+		return BundleStatement{}, err
+	}
+	// The on-chain agent ID is the UUID right-aligned in bytes32
+	var agentID [32]byte
+	copy(agentID[16:], b.AgentID[:])
+	return BundleStatement{
+		AgentID:         agentID,
+		BundleHash:      hash,
+		RevocationEpoch: b.RevocationEpoch,
+		ExpiresAt:       b.ExpiresAt.Unix(),
+	}, nil
+}
+
+// VerifyBundle checks that a signed bundle was issued for chainID and
+// signed by one of keys, the service's published signing keys.
+func VerifyBundle(sb *SignedBundle, chainID int64, keys []common.Address) error {
+	if sb.Bundle.Version != BundleFormatVersion {
+		return fmt.Errorf("unsupported bundle version %d", sb.Bundle.Version)
+	}
+	if sb.ChainID != chainID {
+		return errors.New("bundle was not issued for this chain")
+	}
+	content, err := sb.Bundle.Statement()
+	if err != nil {
+		return err
+	}
+	sig, err := hexutil.Decode(sb.Signature)
+	if err != nil {
+		return errors.New("signature: invalid hex")
+	}
+	signer, err := RecoverSigner(content.Digest(chainID), sig)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if k == signer {
+			return nil
+		}
+	}
+	return errors.New("bundle is not signed by a trusted key")
+}
+
+// Canonicalize serializes v with sorted object keys, no insignificant
+// whitespace and no HTML escaping, so the same value always hashes the same.
+func Canonicalize(v interface{}) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	// Round-trip through a generic value so struct field order is replaced by
+	// sorted map keys. UseNumber keeps numeric literals exactly as written.
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(generic); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
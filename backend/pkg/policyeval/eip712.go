@@ -0,0 +1,86 @@
+package policyeval
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// EIP-712 domain of the policy service's signed statements. It names no
+// verifying contract: signatures are checked off-chain against the
+// published key set.
+const (
+	ServiceDomainName    = "ERC8004 Policy Service"
+	ServiceDomainVersion = "1"
+)
+
+// PolicyBundleType is the EIP-712 type of a signed policy bundle.
+const PolicyBundleType = "PolicyBundle(bytes32 agentId,bytes32 bundleHash,uint256 revocationEpoch,uint256 expiresAt)"
+
+var (
+	eip712DomainTypeHash = crypto.Keccak256([]byte("EIP712Domain(string name,string version,uint256 chainId)"))
+	policyBundleTypeHash = crypto.Keccak256([]byte(PolicyBundleType))
+)
+
+// ServiceDomainSeparator returns the EIP-712 domain separator of the policy
+// service on chainID.
+func ServiceDomainSeparator(chainID int64) [32]byte {
+	var sep [32]byte
+	copy(sep[:], crypto.Keccak256(
+		eip712DomainTypeHash,
+		crypto.Keccak256([]byte(ServiceDomainName)),
+		crypto.Keccak256([]byte(ServiceDomainVersion)),
+		math.U256Bytes(big.NewInt(chainID)),
+	))
+	return sep
+}
+
+// TypedDataDigest returns the EIP-712 digest of a struct hash under a
+// domain separator: keccak256("\x19\x01" ‖ domainSeparator ‖ structHash).
+func TypedDataDigest(domainSeparator [32]byte, structHash []byte) [32]byte {
+	var digest [32]byte
+	copy(digest[:], crypto.Keccak256([]byte("\x19\x01"), domainSeparator[:], structHash))
+	return digest
+}
+
+// RecoverSigner returns the address that signed an EIP-712 digest. v may be
+// 0/1 or 27/28.
+func RecoverSigner(digest [32]byte, sig []byte) (common.Address, error) {
+	if len(sig) != 65 {
+		return common.Address{}, errors.New("signature must be 65 bytes")
+	}
+	normalized := make([]byte, 65)
+	copy(normalized, sig)
+	if normalized[64] >= 27 {
+		normalized[64] -= 27
+	}
+	pub, err := crypto.SigToPub(digest[:], normalized)
+	if err != nil {
+		return common.Address{}, errors.New("invalid signature")
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+// BundleStatement is the signed content of a policy bundle. BundleHash is
+// the keccak256 of the bundle's canonical JSON.
+type BundleStatement struct {
+	AgentID         [32]byte
+	BundleHash      [32]byte
+	RevocationEpoch int64
+	ExpiresAt       int64
+}
+
+// Digest returns the EIP-712 digest of the statement on chainID.
+func (s BundleStatement) Digest(chainID int64) [32]byte {
+	structHash := crypto.Keccak256(
+		policyBundleTypeHash,
+		s.AgentID[:],
+		s.BundleHash[:],
+		math.U256Bytes(big.NewInt(s.RevocationEpoch)),
+		math.U256Bytes(big.NewInt(s.ExpiresAt)),
+	)
+	return TypedDataDigest(ServiceDomainSeparator(chainID), structHash)
+}
@@ -0,0 +1,60 @@
+package policyeval
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/google/uuid"
+)
+
+func TestBundleStatementDigest_MatchesTypedData(t *testing.T) {
+	agent := uuid.MustParse("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	s := BundleStatement{
+		BundleHash:      crypto.Keccak256Hash([]byte("bundle")),
+		RevocationEpoch: 7,
+		ExpiresAt:       1893456000,
+	}
+	copy(s.AgentID[16:], agent[:])
+
+	td := apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+			},
+			"PolicyBundle": {
+				{Name: "agentId", Type: "bytes32"},
+				{Name: "bundleHash", Type: "bytes32"},
+				{Name: "revocationEpoch", Type: "uint256"},
+				{Name: "expiresAt", Type: "uint256"},
+			},
+		},
+		PrimaryType: "PolicyBundle",
+		Domain: apitypes.TypedDataDomain{
+			Name:    ServiceDomainName,
+			Version: ServiceDomainVersion,
+			ChainId: (*math.HexOrDecimal256)(big.NewInt(8453)),
+		},
+		Message: apitypes.TypedDataMessage{
+			"agentId":         hexutil.Encode(s.AgentID[:]),
+			"bundleHash":      hexutil.Encode(s.BundleHash[:]),
+			"revocationEpoch": "7",
+			"expiresAt":       big.NewInt(s.ExpiresAt).String(),
+		},
+	}
+	want, _, err := apitypes.TypedDataAndHash(td)
+	if err != nil {
+		t.Fatalf("typed data hash: %v", err)
+	}
+
+	got := s.Digest(8453)
+	if !bytes.Equal(got[:], want) {
+		t.Fatalf("digest mismatch:\n got  %x\n want %x", got, want)
+	}
+}
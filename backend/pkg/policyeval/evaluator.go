@@ -0,0 +1,136 @@
+package policyeval
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Evaluator decides actions against a verified bundle in-process, without
+// the service or its database. It adds the volume it allows to the bundle's
+// usage figures, so daily limits hold until the next bundle. It is safe for
+// concurrent use.
+type Evaluator struct {
+	mu     sync.Mutex
+	bundle Bundle
+	epoch  int64
+	spent  *big.Int
+	now    func() time.Time
+}
+
+// NewEvaluator verifies sb and returns an evaluator for it.
+func NewEvaluator(sb *SignedBundle, chainID int64, keys []common.Address) (*Evaluator, error) {
+	if err := VerifyBundle(sb, chainID, keys); err != nil {
+		return nil, err
+	}
+	return &Evaluator{
+		bundle: sb.Bundle,
+		epoch:  sb.Bundle.RevocationEpoch,
+		spent:  big.NewInt(0),
+		now:    time.Now,
+	}, nil
+}
+
+// SetRevocationEpoch records the wallet's current revocation epoch, as read
+// from the service. Once it is past the bundle's, every action is denied
+// until a new bundle is fetched.
+func (ev *Evaluator) SetRevocationEpoch(epoch int64) {
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	if epoch > ev.epoch {
+		ev.epoch = epoch
+	}
+}
+
+// ExpiresAt returns when the bundle stops validating.
+func (ev *Evaluator) ExpiresAt() time.Time {
+	return ev.bundle.ExpiresAt
+}
+
+// Evaluate decides action the way the service would have when the bundle
+// was issued. An allowed amount counts against later evaluations.
+func (ev *Evaluator) Evaluate(action Action) ValidationResult {
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+
+	b := &ev.bundle
+	now := ev.now()
+	if !now.Before(b.ExpiresAt) {
+		return ValidationResult{Allowed: false, Reason: "policy bundle expired"}
+	}
+	if ev.epoch > b.RevocationEpoch {
+		return ValidationResult{Allowed: false, Reason: "policy bundle was revoked"}
+	}
+
+	amount := big.NewInt(0)
+	if action.Amount != "" {
+		var ok bool
+		if amount, ok = new(big.Int).SetString(action.Amount, 10); !ok {
+			return ValidationResult{Allowed: false, Reason: "invalid amount"}
+		}
+	}
+	dailyUsed := new(big.Int).Add(parseAmount(b.DailyUsed), ev.spent)
+
+	usage := GuardrailUsage{
+		AgentDaily:  dailyUsed,
+		WalletDaily: new(big.Int).Add(parseAmount(b.WalletDailyUsed), ev.spent),
+	}
+	for _, g := range b.Guardrails {
+		if reason := g.Definition.Check(&action, usage); reason != "" {
+			return ValidationResult{Allowed: false, Reason: "blocked by guardrail " + g.Name + ": " + reason}
+		}
+	}
+
+	var strictMissing []string
+	for i := range b.Grants {
+		g := &b.Grants[i]
+		if now.Before(g.ValidFrom) || (g.ValidUntil != nil && !now.Before(*g.ValidUntil)) {
+			continue
+		}
+		def := g.Definition
+		if !def.Duration.Contains(now) {
+			continue
+		}
+		if def.StrictMatching(b.StrictDefault) {
+			if missing := MissingRestrictedFields(&def, &action); len(missing) > 0 {
+				strictMissing = missing
+				continue
+			}
+		}
+		if !MatchDefinition(&def, &action, func() *big.Int { return dailyUsed }) {
+			continue
+		}
+
+		ev.spent.Add(ev.spent, amount)
+		permID, policyID := g.PermissionID, g.PolicyID
+		return ValidationResult{
+			Allowed:      true,
+			PermissionID: &permID,
+			PolicyID:     &policyID,
+			Constraints: map[string]interface{}{
+				"maxValuePerTx":   def.Constraints.MaxValuePerTx,
+				"maxDailyVolume":  def.Constraints.MaxDailyVolume,
+				"requireApproval": def.Constraints.RequireApproval,
+				"escalated":       g.Escalated,
+			},
+		}
+	}
+
+	if len(strictMissing) > 0 {
+		return ValidationResult{
+			Allowed: false,
+			Reason:  "no matching policy found for this action (strict mode requires " + strings.Join(strictMissing, ", ") + ")",
+		}
+	}
+	if len(b.OnlineOnly) > 0 {
+		return ValidationResult{
+			Allowed: false,
+			Reason:  fmt.Sprintf("no matching policy in this bundle; %d permission(s) can only be checked online", len(b.OnlineOnly)),
+		}
+	}
+	return ValidationResult{Allowed: false, Reason: "no matching policy found for this action"}
+}
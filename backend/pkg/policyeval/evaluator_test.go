@@ -0,0 +1,166 @@
+package policyeval
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+)
+
+const testBundleKey = "4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+
+func testBundle(now time.Time) *Bundle {
+	return &Bundle{
+		Version:         BundleFormatVersion,
+		WalletID:        uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
+		AgentID:         uuid.MustParse("6ba7b810-9dad-11d1-80b4-00c04fd430c8"),
+		RevocationEpoch: 3,
+		IssuedAt:        now,
+		ExpiresAt:       now.Add(time.Hour),
+		Grants: []BundleGrant{{
+			PermissionID: uuid.MustParse("7ca7b810-9dad-11d1-80b4-00c04fd430c8"),
+			PolicyID:     uuid.MustParse("8da7b810-9dad-11d1-80b4-00c04fd430c8"),
+			ValidFrom:    now.Add(-time.Hour),
+			Definition: Definition{
+				Actions:     []string{"swap"},
+				Assets:      Assets{Tokens: []string{"USDC"}},
+				Constraints: Constraints{MaxValuePerTx: "500", MaxDailyVolume: "1000"},
+				Conditions:  []Condition{{Field: "amount", Operator: "gte", Value: float64(1)}},
+			},
+		}},
+		Guardrails: []BundleGuardrail{{Name: "no-bridges", Definition: Guardrail{DeniedActions: []string{"bridge"}}}},
+		DailyUsed:  "200",
+		OnlineOnly: []OnlineOnlyGrant{},
+	}
+}
+
+// signedTestBundle signs b as the service would and sends it through JSON,
+// as an agent gets it.
+func signedTestBundle(t *testing.T, b *Bundle) (*SignedBundle, common.Address) {
+	t.Helper()
+	key, err := crypto.HexToECDSA(testBundleKey)
+	if err != nil {
+		t.Fatalf("load key: %v", err)
+	}
+	content, err := b.Statement()
+	if err != nil {
+		t.Fatalf("statement: %v", err)
+	}
+	digest := content.Digest(8453)
+	sig, err := crypto.Sign(digest[:], key)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	sig[64] += 27
+	signer := crypto.PubkeyToAddress(key.PublicKey)
+
+	raw, _ := json.Marshal(&SignedBundle{
+		Bundle:     *b,
+		BundleHash: hexutil.Encode(content.BundleHash[:]),
+		ChainID:    8453,
+		Signer:     signer.Hex(),
+		Signature:  hexutil.Encode(sig),
+	})
+	var received SignedBundle
+	if err := json.Unmarshal(raw, &received); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return &received, signer
+}
+
+func TestVerifyBundle(t *testing.T) {
+	sb, key := signedTestBundle(t, testBundle(time.Now().UTC()))
+
+	if err := VerifyBundle(sb, 8453, []common.Address{key}); err != nil {
+		t.Fatalf("expected bundle to verify, got %v", err)
+	}
+	if err := VerifyBundle(sb, 1, []common.Address{key}); err == nil {
+		t.Error("expected a bundle for another chain to be rejected")
+	}
+	if err := VerifyBundle(sb, 8453, []common.Address{common.HexToAddress("0x01")}); err == nil {
+		t.Error("expected an untrusted signer to be rejected")
+	}
+
+	tampered := *sb
+	tampered.Bundle.Grants = append([]BundleGrant{}, sb.Bundle.Grants...)
+	tampered.Bundle.Grants[0].Definition.Constraints.MaxValuePerTx = "5000"
+	if err := VerifyBundle(&tampered, 8453, []common.Address{key}); err == nil {
+		t.Error("expected a tampered bundle to be rejected")
+	}
+}
+
+func TestEvaluator_Evaluate(t *testing.T) {
+	now := time.Now().UTC()
+	sb, key := signedTestBundle(t, testBundle(now))
+	ev, err := NewEvaluator(sb, 8453, []common.Address{key})
+	if err != nil {
+		t.Fatalf("new evaluator: %v", err)
+	}
+	ev.now = func() time.Time { return now }
+
+	r := ev.Evaluate(Action{Type: "swap", Token: "usdc", Amount: "400"})
+	if !r.Allowed || r.PermissionID == nil || *r.PermissionID != sb.Bundle.Grants[0].PermissionID {
+		t.Fatalf("expected swap to be allowed by the grant, got %+v", r)
+	}
+	if r := ev.Evaluate(Action{Type: "swap", Token: "usdc", Amount: "600"}); r.Allowed {
+		t.Error("expected the per-transaction limit to hold")
+	}
+	if r := ev.Evaluate(Action{Type: "bridge", Token: "usdc", Amount: "1"}); r.Allowed || r.Reason != "blocked by guardrail no-bridges: action bridge is denied" {
+		t.Errorf("expected the guardrail to block, got %+v", r)
+	}
+
+	// 200 used at issue and 400 allowed since: another 400 is over 1000
+	if r := ev.Evaluate(Action{Type: "swap", Token: "usdc", Amount: "400"}); !r.Allowed {
+		t.Fatalf("expected 1000 in total to be allowed, got %+v", r)
+	}
+	if r := ev.Evaluate(Action{Type: "swap", Token: "usdc", Amount: "1"}); r.Allowed {
+		t.Error("expected the daily volume to include what the evaluator allowed")
+	}
+}
+
+func TestEvaluator_ExpiryAndRevocation(t *testing.T) {
+	now := time.Now().UTC()
+	sb, key := signedTestBundle(t, testBundle(now))
+	ev, err := NewEvaluator(sb, 8453, []common.Address{key})
+	if err != nil {
+		t.Fatalf("new evaluator: %v", err)
+	}
+	action := Action{Type: "swap", Token: "USDC", Amount: "10"}
+
+	ev.now = func() time.Time { return now }
+	ev.SetRevocationEpoch(2)
+	if r := ev.Evaluate(action); !r.Allowed {
+		t.Fatalf("an older epoch should not revoke the bundle, got %+v", r)
+	}
+	ev.SetRevocationEpoch(4)
+	if r := ev.Evaluate(action); r.Allowed || r.Reason != "policy bundle was revoked" {
+		t.Errorf("expected a newer epoch to revoke the bundle, got %+v", r)
+	}
+
+	ev, _ = NewEvaluator(sb, 8453, []common.Address{key})
+	ev.now = func() time.Time { return now.Add(time.Hour) }
+	if r := ev.Evaluate(action); r.Allowed || r.Reason != "policy bundle expired" {
+		t.Errorf("expected an expired bundle to deny, got %+v", r)
+	}
+}
+
+func TestEvaluator_OnlineOnly(t *testing.T) {
+	now := time.Now().UTC()
+	b := testBundle(now)
+	b.OnlineOnly = []OnlineOnlyGrant{{PermissionID: uuid.New(), Reason: "the budget of group treasury is shared with other agents"}}
+	sb, key := signedTestBundle(t, b)
+	ev, err := NewEvaluator(sb, 8453, []common.Address{key})
+	if err != nil {
+		t.Fatalf("new evaluator: %v", err)
+	}
+	ev.now = func() time.Time { return now }
+
+	r := ev.Evaluate(Action{Type: "transfer", Token: "USDC", Amount: "1"})
+	if r.Allowed || r.Reason != "no matching policy in this bundle; 1 permission(s) can only be checked online" {
+		t.Errorf("expected a pointer to online validation, got %+v", r)
+	}
+}
@@ -0,0 +1,117 @@
+package policyeval
+
+import (
+	"math/big"
+	"strings"
+)
+
+// Guardrail is a wallet-wide boundary, similar to an AWS service control
+// policy. It never grants anything: every validation decision is intersected
+// with all active guardrails regardless of which permission matched.
+type Guardrail struct {
+	// Actions, when set, is the ceiling of action types any agent may perform.
+	Actions       []string `json:"actions,omitempty"`
+	DeniedActions []string `json:"deniedActions,omitempty"`
+	// Assets, when set, are ceilings for tokens, protocols and chains.
+	Assets           Assets   `json:"assets,omitempty"`
+	DeniedTokens     []string `json:"deniedTokens,omitempty"`
+	DeniedProtocols  []string `json:"deniedProtocols,omitempty"`
+	DeniedRecipients []string `json:"deniedRecipients,omitempty"`
+	MaxValuePerTx    string   `json:"maxValuePerTx,omitempty"`
+	// MaxDailyVolumePerAgent caps each agent's daily volume.
+	MaxDailyVolumePerAgent string `json:"maxDailyVolumePerAgent,omitempty"`
+	// MaxDailyVolume caps the daily volume of all agents of the wallet combined.
+	MaxDailyVolume string `json:"maxDailyVolume,omitempty"`
+}
+
+// GuardrailUsage is the volume already consumed today, needed for daily caps.
+type GuardrailUsage struct {
+	AgentDaily  *big.Int
+	WalletDaily *big.Int
+}
+
+// NeedsUsage reports whether Check needs daily usage figures.
+func (g *Guardrail) NeedsUsage() bool {
+	return g.MaxDailyVolumePerAgent != "" || g.MaxDailyVolume != ""
+}
+
+// Check returns the reason the action violates the guardrail, or "" if it
+// stays within the boundary. An action that leaves a token, protocol or
+// chain the guardrail restricts unset is denied, as strict policies deny it,
+// so a deny list cannot be skipped by omitting the field.
+func (g *Guardrail) Check(action *Action, usage GuardrailUsage) string {
+	if len(g.Actions) > 0 && !containsFold(g.Actions, action.Type) && !contains(g.Actions, "*") {
+		return "action " + action.Type + " is outside the allowed actions"
+	}
+	if containsFold(g.DeniedActions, action.Type) || contains(g.DeniedActions, "*") {
+		return "action " + action.Type + " is denied"
+	}
+
+	if missing := g.missingRestrictedFields(action); len(missing) > 0 {
+		return "action must specify " + strings.Join(missing, ", ")
+	}
+	if len(g.Assets.Tokens) > 0 && !containsFold(g.Assets.Tokens, action.Token) && !contains(g.Assets.Tokens, "*") {
+		return "token " + action.Token + " is outside the allowed tokens"
+	}
+	if containsFold(g.DeniedTokens, action.Token) {
+		return "token " + action.Token + " is denied"
+	}
+	if len(g.Assets.Protocols) > 0 && !containsFold(g.Assets.Protocols, action.Protocol) && !contains(g.Assets.Protocols, "*") {
+		return "protocol " + action.Protocol + " is outside the allowed protocols"
+	}
+	if containsFold(g.DeniedProtocols, action.Protocol) {
+		return "protocol " + action.Protocol + " is denied"
+	}
+	if len(g.Assets.Chains) > 0 {
+		allowed := false
+		for _, c := range g.Assets.Chains {
+			if c == action.Chain {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return "chain is outside the allowed chains"
+		}
+	}
+	if containsFold(g.DeniedRecipients, action.To) {
+		return "recipient " + action.To + " is denied"
+	}
+
+	if action.Amount == "" {
+		return ""
+	}
+	amount, ok := new(big.Int).SetString(action.Amount, 10)
+	if !ok {
+		return "invalid amount"
+	}
+	if exceeds(amount, nil, g.MaxValuePerTx) {
+		return "amount exceeds the per-transaction limit"
+	}
+	if exceeds(amount, usage.AgentDaily, g.MaxDailyVolumePerAgent) {
+		return "agent daily volume limit exceeded"
+	}
+	if exceeds(amount, usage.WalletDaily, g.MaxDailyVolume) {
+		return "aggregate daily volume limit exceeded"
+	}
+	return ""
+}
+
+// missingRestrictedFields lists the action fields the guardrail allows or
+// denies values of but the action leaves empty.
+func (g *Guardrail) missingRestrictedFields(action *Action) []string {
+	var missing []string
+	if (restricts(g.Assets.Tokens) || len(g.DeniedTokens) > 0) && action.Token == "" {
+		missing = append(missing, "token")
+	}
+	if (restricts(g.Assets.Protocols) || len(g.DeniedProtocols) > 0) && action.Protocol == "" {
+		missing = append(missing, "protocol")
+	}
+	if len(g.Assets.Chains) > 0 && action.Chain == 0 {
+		missing = append(missing, "chain")
+	}
+	if len(g.DeniedRecipients) > 0 && action.To == "" {
+		missing = append(missing, "to")
+	}
+	return missing
+}
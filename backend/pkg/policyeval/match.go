@@ -0,0 +1,251 @@
+package policyeval
+
+import (
+	"math/big"
+	"strings"
+)
+
+// MatchDefinition checks an action against a definition. dailyUsage is
+// called only when a daily volume limit applies.
+func MatchDefinition(def *Definition, action *Action, dailyUsage func() *big.Int) bool {
+	// Check action type
+	actionAllowed := false
+	for _, a := range def.Actions {
+		if a == "*" || strings.EqualFold(a, action.Type) {
+			actionAllowed = true
+			break
+		}
+	}
+	if !actionAllowed {
+		return false
+	}
+
+	// Check assets
+	if len(def.Assets.Tokens) > 0 && action.Token != "" {
+		tokenAllowed := false
+		for _, t := range def.Assets.Tokens {
+			if strings.EqualFold(t, action.Token) || t == "*" {
+				tokenAllowed = true
+				break
+			}
+		}
+		if !tokenAllowed {
+			return false
+		}
+	}
+
+	if len(def.Assets.Protocols) > 0 && action.Protocol != "" {
+		protocolAllowed := false
+		for _, p := range def.Assets.Protocols {
+			if strings.EqualFold(p, action.Protocol) || p == "*" {
+				protocolAllowed = true
+				break
+			}
+		}
+		if !protocolAllowed {
+			return false
+		}
+	}
+
+	if len(def.Assets.Chains) > 0 && action.Chain != 0 {
+		chainAllowed := false
+		for _, c := range def.Assets.Chains {
+			if c == action.Chain {
+				chainAllowed = true
+				break
+			}
+		}
+		if !chainAllowed {
+			return false
+		}
+	}
+
+	// Check constraints
+	if action.Amount != "" {
+		amount, ok := new(big.Int).SetString(action.Amount, 10)
+		if !ok {
+			return false
+		}
+
+		// Check max value per tx
+		if def.Constraints.MaxValuePerTx != "" {
+			maxValue, _ := new(big.Int).SetString(def.Constraints.MaxValuePerTx, 10)
+			if amount.Cmp(maxValue) > 0 {
+				return false
+			}
+		}
+
+		// Check daily volume
+		if def.Constraints.MaxDailyVolume != "" {
+			maxDaily, _ := new(big.Int).SetString(def.Constraints.MaxDailyVolume, 10)
+			totalAfter := new(big.Int).Add(dailyUsage(), amount)
+			if totalAfter.Cmp(maxDaily) > 0 {
+				return false
+			}
+		}
+	}
+
+	// Check conditions
+	for _, cond := range def.Conditions {
+		if !evaluateCondition(&cond, action) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MissingRestrictedFields lists the action fields that are empty although the
+// definition restricts them. Lenient matching skips such restrictions; strict
+// matching denies the action instead.
+func MissingRestrictedFields(def *Definition, action *Action) []string {
+	var missing []string
+	if restricts(def.Assets.Tokens) && action.Token == "" {
+		missing = append(missing, "token")
+	}
+	if restricts(def.Assets.Protocols) && action.Protocol == "" {
+		missing = append(missing, "protocol")
+	}
+	if len(def.Assets.Chains) > 0 && action.Chain == 0 {
+		missing = append(missing, "chain")
+	}
+	return missing
+}
+
+func restricts(list []string) bool {
+	return len(list) > 0 && !contains(list, "*")
+}
+
+// evaluateCondition evaluates a single condition against an action
+func evaluateCondition(cond *Condition, action *Action) bool {
+	var fieldValue interface{}
+
+	switch cond.Field {
+	case "type":
+		fieldValue = action.Type
+	case "token":
+		fieldValue = action.Token
+	case "protocol":
+		fieldValue = action.Protocol
+	case "amount":
+		fieldValue = action.Amount
+	case "chain":
+		fieldValue = action.Chain
+	case "to":
+		fieldValue = action.To
+	default:
+		if action.Data != nil {
+			fieldValue = action.Data[cond.Field]
+		}
+	}
+
+	switch cond.Operator {
+	case "eq":
+		return fieldValue == cond.Value
+	case "ne":
+		return fieldValue != cond.Value
+	case "gt":
+		return compareNumeric(fieldValue, cond.Value) > 0
+	case "gte":
+		return compareNumeric(fieldValue, cond.Value) >= 0
+	case "lt":
+		return compareNumeric(fieldValue, cond.Value) < 0
+	case "lte":
+		return compareNumeric(fieldValue, cond.Value) <= 0
+	case "in":
+		if values, ok := cond.Value.([]interface{}); ok {
+			for _, v := range values {
+				if fieldValue == v {
+					return true
+				}
+			}
+		}
+		return false
+	case "not_in":
+		if values, ok := cond.Value.([]interface{}); ok {
+			for _, v := range values {
+				if fieldValue == v {
+					return false
+				}
+			}
+		}
+		return true
+	case "contains":
+		if str, ok := fieldValue.(string); ok {
+			if substr, ok := cond.Value.(string); ok {
+				return strings.Contains(str, substr)
+			}
+		}
+		return false
+	}
+
+	return false
+}
+
+func compareNumeric(a, b interface{}) int {
+	aVal := toBigInt(a)
+	bVal := toBigInt(b)
+	if aVal == nil || bVal == nil {
+		return 0
+	}
+	return aVal.Cmp(bVal)
+}
+
+func toBigInt(v interface{}) *big.Int {
+	switch val := v.(type) {
+	case string:
+		i, _ := new(big.Int).SetString(val, 10)
+		return i
+	case int64:
+		return big.NewInt(val)
+	case float64:
+		return big.NewInt(int64(val))
+	case int:
+		return big.NewInt(int64(val))
+	default:
+		return nil
+	}
+}
+
+// exceeds reports whether amount on top of used is over limit. An empty or
+// malformed limit is unset.
+func exceeds(amount, used *big.Int, limit string) bool {
+	if limit == "" {
+		return false
+	}
+	max, ok := new(big.Int).SetString(limit, 10)
+	if !ok {
+		return false
+	}
+	total := new(big.Int).Set(amount)
+	if used != nil {
+		total.Add(total, used)
+	}
+	return total.Cmp(max) > 0
+}
+
+func parseAmount(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return big.NewInt(0)
+	}
+	return n
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,312 @@
+package policyeval
+
+import (
+	"math/big"
+	"testing"
+	"time"
+)
+
+// matches checks an action with no daily volume used.
+func matches(def *Definition, action *Action) bool {
+	return MatchDefinition(def, action, func() *big.Int { return big.NewInt(0) })
+}
+
+func TestMatchesPolicy_ActionMatch(t *testing.T) {
+	def := &Definition{Actions: []string{"swap"}}
+	action := &Action{Type: "swap"}
+
+	if !matches(def, action) {
+		t.Fatal("expected action to match")
+	}
+}
+
+func TestMatchesPolicy_ActionNoMatch(t *testing.T) {
+	def := &Definition{Actions: []string{"swap"}}
+	action := &Action{Type: "transfer"}
+
+	if matches(def, action) {
+		t.Fatal("expected action not to match")
+	}
+}
+
+func TestMatchesPolicy_WildcardAction(t *testing.T) {
+	def := &Definition{Actions: []string{"*"}}
+	action := &Action{Type: "anything"}
+
+	if !matches(def, action) {
+		t.Fatal("wildcard should match any action")
+	}
+}
+
+func TestMatchesPolicy_TokenFilter(t *testing.T) {
+	def := &Definition{
+		Actions: []string{"swap"},
+		Assets: Assets{
+			Tokens: []string{"0xUSDC"},
+		},
+	}
+
+	// Matching token
+	action := &Action{Type: "swap", Token: "0xUSDC"}
+	if !matches(def, action) {
+		t.Fatal("expected matching token to pass")
+	}
+
+	// Non-matching token
+	action2 := &Action{Type: "swap", Token: "0xDAI"}
+	if matches(def, action2) {
+		t.Fatal("expected non-matching token to fail")
+	}
+}
+
+func TestMatchesPolicy_ChainFilter(t *testing.T) {
+	def := &Definition{
+		Actions: []string{"swap"},
+		Assets: Assets{
+			Chains: []int64{1, 137},
+		},
+	}
+
+	// Matching chain
+	action := &Action{Type: "swap", Chain: 1}
+	if !matches(def, action) {
+		t.Fatal("expected chain 1 to match")
+	}
+
+	// Non-matching chain
+	action2 := &Action{Type: "swap", Chain: 42161}
+	if matches(def, action2) {
+		t.Fatal("expected chain 42161 to not match")
+	}
+}
+
+func TestMatchesPolicy_ProtocolFilter(t *testing.T) {
+	def := &Definition{
+		Actions: []string{"swap"},
+		Assets: Assets{
+			Protocols: []string{"0xUniswap"},
+		},
+	}
+
+	action := &Action{Type: "swap", Protocol: "0xUniswap"}
+	if !matches(def, action) {
+		t.Fatal("expected matching protocol")
+	}
+
+	action2 := &Action{Type: "swap", Protocol: "0xSushiSwap"}
+	if matches(def, action2) {
+		t.Fatal("expected non-matching protocol to fail")
+	}
+}
+
+func TestMatchesPolicy_MaxValuePerTx(t *testing.T) {
+	def := &Definition{
+		Actions: []string{"transfer"},
+		Constraints: Constraints{
+			MaxValuePerTx: "1000",
+		},
+	}
+
+	// Within limit
+	action := &Action{Type: "transfer", Amount: "500"}
+	if !matches(def, action) {
+		t.Fatal("expected amount within limit to pass")
+	}
+
+	// At limit
+	action2 := &Action{Type: "transfer", Amount: "1000"}
+	if !matches(def, action2) {
+		t.Fatal("expected amount at limit to pass")
+	}
+
+	// Over limit
+	action3 := &Action{Type: "transfer", Amount: "1001"}
+	if matches(def, action3) {
+		t.Fatal("expected amount over limit to fail")
+	}
+}
+
+// Test evaluateCondition
+func TestEvaluateCondition_Eq(t *testing.T) {
+	cond := &Condition{Field: "type", Operator: "eq", Value: "swap"}
+	action := &Action{Type: "swap"}
+	if !evaluateCondition(cond, action) {
+		t.Fatal("expected eq to match")
+	}
+}
+
+func TestEvaluateCondition_Ne(t *testing.T) {
+	cond := &Condition{Field: "type", Operator: "ne", Value: "transfer"}
+	action := &Action{Type: "swap"}
+	if !evaluateCondition(cond, action) {
+		t.Fatal("expected ne to match")
+	}
+}
+
+func TestEvaluateCondition_Gt(t *testing.T) {
+	cond := &Condition{Field: "amount", Operator: "gt", Value: "100"}
+	action := &Action{Amount: "200"}
+	if !evaluateCondition(cond, action) {
+		t.Fatal("expected gt to match (200 > 100)")
+	}
+}
+
+func TestEvaluateCondition_Gte(t *testing.T) {
+	cond := &Condition{Field: "amount", Operator: "gte", Value: "100"}
+	action := &Action{Amount: "100"}
+	if !evaluateCondition(cond, action) {
+		t.Fatal("expected gte to match (100 >= 100)")
+	}
+}
+
+func TestEvaluateCondition_Lt(t *testing.T) {
+	cond := &Condition{Field: "amount", Operator: "lt", Value: "100"}
+	action := &Action{Amount: "50"}
+	if !evaluateCondition(cond, action) {
+		t.Fatal("expected lt to match (50 < 100)")
+	}
+}
+
+func TestEvaluateCondition_Lte(t *testing.T) {
+	cond := &Condition{Field: "amount", Operator: "lte", Value: "100"}
+	action := &Action{Amount: "100"}
+	if !evaluateCondition(cond, action) {
+		t.Fatal("expected lte to match (100 <= 100)")
+	}
+}
+
+func TestEvaluateCondition_In(t *testing.T) {
+	cond := &Condition{Field: "type", Operator: "in", Value: []interface{}{"swap", "transfer"}}
+	action := &Action{Type: "swap"}
+	if !evaluateCondition(cond, action) {
+		t.Fatal("expected in to match")
+	}
+
+	action2 := &Action{Type: "stake"}
+	if evaluateCondition(cond, action2) {
+		t.Fatal("expected in to not match for stake")
+	}
+}
+
+func TestEvaluateCondition_NotIn(t *testing.T) {
+	cond := &Condition{Field: "type", Operator: "not_in", Value: []interface{}{"swap", "transfer"}}
+	action := &Action{Type: "stake"}
+	if !evaluateCondition(cond, action) {
+		t.Fatal("expected not_in to match for stake")
+	}
+
+	action2 := &Action{Type: "swap"}
+	if evaluateCondition(cond, action2) {
+		t.Fatal("expected not_in to fail for swap")
+	}
+}
+
+func TestEvaluateCondition_Contains(t *testing.T) {
+	cond := &Condition{Field: "to", Operator: "contains", Value: "uniswap"}
+	action := &Action{To: "uniswap-v3-router"}
+	if !evaluateCondition(cond, action) {
+		t.Fatal("expected contains to match")
+	}
+
+	action2 := &Action{To: "sushiswap-router"}
+	if evaluateCondition(cond, action2) {
+		t.Fatal("expected contains to not match")
+	}
+}
+
+func TestEvaluateCondition_CustomDataField(t *testing.T) {
+	cond := &Condition{Field: "slippage", Operator: "eq", Value: "0.5"}
+	action := &Action{Data: map[string]interface{}{"slippage": "0.5"}}
+	if !evaluateCondition(cond, action) {
+		t.Fatal("expected custom data field to match")
+	}
+}
+
+func TestCompareNumeric(t *testing.T) {
+	tests := []struct {
+		a, b     interface{}
+		expected int
+	}{
+		{"100", "50", 1},
+		{"50", "100", -1},
+		{"100", "100", 0},
+		{int64(200), "100", 1},
+		{float64(100), "200", -1},
+	}
+
+	for _, tt := range tests {
+		result := compareNumeric(tt.a, tt.b)
+		if result != tt.expected {
+			t.Errorf("compareNumeric(%v, %v) = %d, want %d", tt.a, tt.b, result, tt.expected)
+		}
+	}
+}
+
+func TestToBigInt(t *testing.T) {
+	tests := []struct {
+		input    interface{}
+		expected *big.Int
+	}{
+		{"100", big.NewInt(100)},
+		{int64(200), big.NewInt(200)},
+		{float64(300), big.NewInt(300)},
+		{int(400), big.NewInt(400)},
+		{nil, nil},
+		{true, nil},
+	}
+
+	for _, tt := range tests {
+		result := toBigInt(tt.input)
+		if tt.expected == nil {
+			if result != nil {
+				t.Errorf("toBigInt(%v) = %v, want nil", tt.input, result)
+			}
+		} else if result == nil || result.Cmp(tt.expected) != 0 {
+			t.Errorf("toBigInt(%v) = %v, want %v", tt.input, result, tt.expected)
+		}
+	}
+}
+
+func TestMissingRestrictedFields(t *testing.T) {
+	def := &Definition{
+		Actions: []string{"swap"},
+		Assets:  Assets{Tokens: []string{"0xUSDC"}, Protocols: []string{"*"}, Chains: []int64{1}},
+	}
+
+	missing := MissingRestrictedFields(def, &Action{Type: "swap"})
+	if len(missing) != 2 || missing[0] != "token" || missing[1] != "chain" {
+		t.Fatalf("expected [token chain], got %v", missing)
+	}
+	if missing := MissingRestrictedFields(def, &Action{Type: "swap", Token: "0xUSDC", Chain: 1}); len(missing) != 0 {
+		t.Fatalf("expected nothing missing, got %v", missing)
+	}
+}
+
+func TestStrictMatching_PolicyOverridesWallet(t *testing.T) {
+	on, off := true, false
+	if (&Definition{}).StrictMatching(false) || !(&Definition{}).StrictMatching(true) {
+		t.Fatal("expected wallet default without a policy setting")
+	}
+	if !(&Definition{Strict: &on}).StrictMatching(false) || (&Definition{Strict: &off}).StrictMatching(true) {
+		t.Fatal("expected policy setting to override the wallet default")
+	}
+}
+
+func TestDuration_Contains(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	if !(&Duration{}).Contains(now) {
+		t.Fatal("open duration should contain now")
+	}
+	if (&Duration{ValidUntil: &past}).Contains(now) {
+		t.Fatal("expired duration should not contain now")
+	}
+	if (&Duration{ValidFrom: &future}).Contains(now) {
+		t.Fatal("future duration should not contain now")
+	}
+	if !(&Duration{ValidFrom: &past, ValidUntil: &future}).Contains(now) {
+		t.Fatal("expected now within window")
+	}
+}
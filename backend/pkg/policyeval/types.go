@@ -0,0 +1,103 @@
+// Package policyeval evaluates actions against policy definitions without
+// the policy service or its database. Agents use it to verify a signed
+// policy bundle and decide actions in-process; the service uses the same
+// definitions and matching rules.
+package policyeval
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Definition represents a policy's rule set
+type Definition struct {
+	Actions     []string    `json:"actions"`
+	Assets      Assets      `json:"assets,omitempty"`
+	Constraints Constraints `json:"constraints,omitempty"`
+	Duration    Duration    `json:"duration,omitempty"`
+	Conditions  []Condition `json:"conditions,omitempty"`
+	// Strict, when set, overrides the wallet's strict matching default.
+	Strict *bool `json:"strict,omitempty"`
+}
+
+// StrictMatching reports whether the definition uses strict matching, falling
+// back to the wallet default when the policy does not say.
+func (d *Definition) StrictMatching(walletDefault bool) bool {
+	if d.Strict != nil {
+		return *d.Strict
+	}
+	return walletDefault
+}
+
+// Assets defines which tokens/protocols are allowed
+type Assets struct {
+	Tokens    []string `json:"tokens,omitempty"`
+	Protocols []string `json:"protocols,omitempty"`
+	Chains    []int64  `json:"chains,omitempty"`
+}
+
+// Constraints define limits on actions
+type Constraints struct {
+	MaxValuePerTx   string `json:"maxValuePerTx,omitempty"`
+	MaxDailyVolume  string `json:"maxDailyVolume,omitempty"`
+	MaxWeeklyVolume string `json:"maxWeeklyVolume,omitempty"`
+	MaxTxCount      int    `json:"maxTxCount,omitempty"`
+	RequireApproval bool   `json:"requireApproval,omitempty"`
+
+	// Per-counterparty limits, keyed by the action's recipient or, failing
+	// that, its protocol. Enforced off-chain from recorded validations.
+	MaxDailyPerRecipient   string `json:"maxDailyPerRecipient,omitempty"`
+	MaxNewRecipientsPerDay int    `json:"maxNewRecipientsPerDay,omitempty"`
+	NewRecipientHoldHours  int    `json:"newRecipientHoldHours,omitempty"`
+}
+
+// HasCounterpartyLimits reports whether any per-counterparty limit is set.
+func (c *Constraints) HasCounterpartyLimits() bool {
+	return c.MaxDailyPerRecipient != "" || c.MaxNewRecipientsPerDay > 0 || c.NewRecipientHoldHours > 0
+}
+
+// Duration defines validity period
+type Duration struct {
+	ValidFrom  *time.Time `json:"validFrom,omitempty"`
+	ValidUntil *time.Time `json:"validUntil,omitempty"`
+}
+
+// Contains reports whether t falls within the validity period. Unset bounds
+// are open.
+func (d *Duration) Contains(t time.Time) bool {
+	if d.ValidFrom != nil && t.Before(*d.ValidFrom) {
+		return false
+	}
+	if d.ValidUntil != nil && !t.Before(*d.ValidUntil) {
+		return false
+	}
+	return true
+}
+
+// Condition represents additional rule conditions
+type Condition struct {
+	Field    string      `json:"field"`
+	Operator string      `json:"operator"`
+	Value    interface{} `json:"value"`
+}
+
+// Action represents an action being validated
+type Action struct {
+	Type     string                 `json:"type"`
+	Token    string                 `json:"token,omitempty"`
+	Protocol string                 `json:"protocol,omitempty"`
+	Amount   string                 `json:"amount,omitempty"`
+	Chain    int64                  `json:"chain,omitempty"`
+	To       string                 `json:"to,omitempty"`
+	Data     map[string]interface{} `json:"data,omitempty"`
+}
+
+// ValidationResult is the result of validating an action
+type ValidationResult struct {
+	Allowed      bool
+	Reason       string
+	PermissionID *uuid.UUID
+	PolicyID     *uuid.UUID
+	Constraints  map[string]interface{}
+}